@@ -1,25 +1,62 @@
 package main
 
 import (
+	"crypto/tls"
 	"log"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/audit"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/budget"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/certs"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/config"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/cors"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/discovery"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/handlers"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/keys"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/models"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/permissions"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/pricing"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/prometheus"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/ratelimit"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/redact"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/usage"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
 )
 
 func main() {
+	// Keep key material out of logs, including gin's request log whose paths
+	// carry invite tokens
+	log.SetOutput(redact.NewWriter(os.Stderr))
+	gin.DefaultWriter = redact.NewWriter(os.Stdout)
+	gin.DefaultErrorWriter = redact.NewWriter(os.Stderr)
+
 	// Load configuration
 	cfg := config.Load()
 
+	// FIPS mode needs a validated crypto module and approved algorithms only
+	if cfg.FIPSMode {
+		if err := fips.Check(); err != nil {
+			log.Fatalf("Invalid FIPS_MODE: %v", err)
+		}
+		if !keys.IsFIPSApprovedHashAlgo(cfg.KeyHashAlgo) {
+			log.Fatalf("Invalid KEY_HASH_ALGO: %s is not FIPS approved, use %s", cfg.KeyHashAlgo, keys.HashAlgoSHA256)
+		}
+		log.Printf("FIPS mode enabled, crypto module %s", fips.Module())
+	}
+
 	// Create in-cluster config
 	restConfig, err := rest.InClusterConfig()
 	if err != nil {
@@ -38,6 +75,10 @@ func main() {
 		log.Fatalf("Failed to create dynamic client: %v", err)
 	}
 
+	// Use the Kuadrant policy versions the cluster serves
+	policyGVRs := teams.ResolvePolicyGVRs(clientset.Discovery())
+	policyGVRs.StartRefresh(clientset.Discovery(), cfg.PolicyDiscoveryInterval)
+
 	// Initialize managers
 	policyMgr := teams.NewPolicyManager(
 		kuadrantClient,
@@ -45,37 +86,340 @@ func main() {
 		cfg.KeyNamespace,
 		cfg.TokenRateLimitPolicyName,
 		cfg.AuthPolicyName,
+		cfg.DefaultLimitScope,
+		cfg.UnlimitedTierMode,
+		cfg.PolicyRetryAttempts,
+		policyGVRs,
+		cfg.PolicyApplyMode,
 	)
+	if !teams.IsValidLimitScope(cfg.DefaultLimitScope) {
+		log.Fatalf("Invalid DEFAULT_LIMIT_SCOPE: %s", cfg.DefaultLimitScope)
+	}
+	if !teams.IsValidUnlimitedMode(cfg.UnlimitedTierMode) {
+		log.Fatalf("Invalid UNLIMITED_TIER_MODE: %s", cfg.UnlimitedTierMode)
+	}
+	if !teams.IsValidApplyMode(cfg.PolicyApplyMode) {
+		log.Fatalf("Invalid POLICY_APPLY_MODE: %s", cfg.PolicyApplyMode)
+	}
+
+	if err := webhook.ValidateSecret(cfg.WebhookURL, cfg.WebhookSecret, cfg.AllowUnsignedWebhooks); err != nil {
+		log.Fatalf("Invalid WEBHOOK_URL: %v", err)
+	}
+	webhooks := webhook.NewDispatcher(cfg.WebhookURL, cfg.WebhookSecret)
+
+	keyHasher, err := keys.NewHasher(cfg.KeyHashAlgo)
+	if err != nil {
+		log.Fatalf("Invalid KEY_HASH_ALGO: %v", err)
+	}
+	if !keys.IsValidKeyFormat(cfg.KeyFormat) {
+		log.Fatalf("Invalid KEY_FORMAT: %s", cfg.KeyFormat)
+	}
+
+	// The admin key is read once; only its hash is kept in memory
+	adminKey, err := auth.NewAdminKey(cfg.AdminAPIKey, cfg.AdminAPIKeySHA256)
+	if err != nil {
+		log.Fatalf("Invalid admin key configuration: %v", err)
+	}
+	if cfg.AdminAPIKeySecretRef != "" {
+		if cfg.AdminAPIKey != "" || cfg.AdminAPIKeySHA256 != "" {
+			log.Fatalf("Invalid admin key configuration: ADMIN_API_KEY_SECRET_REF cannot be combined with ADMIN_API_KEY or ADMIN_API_KEY_SHA256")
+		}
+		if err := adminKey.WatchSecret(clientset, cfg.AdminAPIKeySecretRef); err != nil {
+			log.Fatalf("Invalid ADMIN_API_KEY_SECRET_REF: %v", err)
+		}
+	}
+	if !auth.IsValidAdminAuthMode(cfg.AdminAuthMode) {
+		log.Fatalf("Invalid ADMIN_AUTH_MODE: %s", cfg.AdminAuthMode)
+	}
+	// Admins are authorized by Kubernetes RBAC instead of the admin key
+	var adminReviewer *auth.KubernetesReviewer
+	if cfg.AdminAuthMode == auth.AdminAuthKubernetes {
+		adminReviewer = auth.NewKubernetesReviewer(clientset, cfg.AdminAuthCacheTTL)
+	}
+	// Admins and team admins may also present JWTs from an OIDC provider
+	var oidcVerifier *auth.OIDCVerifier
+	if cfg.OIDCIssuerURL != "" {
+		oidcVerifier, err = auth.NewOIDCVerifier(auth.OIDCConfig{
+			IssuerURL:           cfg.OIDCIssuerURL,
+			Audience:            cfg.OIDCAudience,
+			AdminGroup:          cfg.OIDCAdminGroup,
+			ViewerGroup:         cfg.OIDCViewerGroup,
+			GroupsClaim:         cfg.OIDCGroupsClaim,
+			TeamAdminClaim:      cfg.OIDCTeamAdminClaim,
+			JWKSRefreshInterval: cfg.OIDCJWKSRefreshInterval,
+		})
+		if err != nil {
+			log.Fatalf("Invalid OIDC configuration: %v", err)
+		}
+		log.Printf("OIDC authentication enabled for issuer %s", cfg.OIDCIssuerURL)
+	}
+	// Credentials may also come in a custom header or as a bare token
+	credentialHeaders := auth.NewCredentialHeaders(cfg.AuthHeaderName, cfg.AllowRawAuthHeader)
+	viewerKeys := auth.NewViewerKeys(cfg.ViewerAPIKeys)
+	certIdentities := auth.NewClientCertIdentities(cfg.ClientCertAllowedSANs)
+	// Short-lived session tokens for the GUI, signed with a mounted key
+	var sessions *auth.Sessions
+	if cfg.SessionSigningKeyFile != "" {
+		if cfg.SessionLifetime <= 0 || cfg.SessionKeyGrace < 0 {
+			log.Fatalf("Invalid session configuration: SESSION_LIFETIME must be positive and SESSION_KEY_GRACE not negative")
+		}
+		if cfg.SessionMaxAge < cfg.SessionLifetime {
+			log.Fatalf("Invalid session configuration: SESSION_MAX_AGE must be at least SESSION_LIFETIME")
+		}
+		sessions, err = auth.NewSessions(cfg.SessionSigningKeyFile, cfg.SessionLifetime, cfg.SessionMaxAge, cfg.SessionKeyGrace)
+		if err != nil {
+			log.Fatalf("Invalid SESSION_SIGNING_KEY_FILE: %v", err)
+		}
+	}
+	adminCredentials := auth.NewAdminCredentials(clientset, cfg.KeyNamespace, cfg.AdminCredentialsSecret)
+	adminCredentials.Start()
+	if !adminCredentials.WaitForSync() {
+		log.Printf("Warning: Admin credentials in secret %s were not loaded in time", cfg.AdminCredentialsSecret)
+	}
+
+	// Never leave the admin routes open by accident: without any admin
+	// credential an admin key is generated, unless explicitly allowed
+	adminAuthEnforced := adminReviewer != nil || oidcVerifier != nil || adminKey.Configured() ||
+		adminCredentials.Configured() || viewerKeys.Configured() || certIdentities.Configured()
+	if !adminAuthEnforced {
+		if cfg.AllowUnauthenticatedAdmin {
+			log.Printf("Warning: No admin credential is configured and ALLOW_UNAUTHENTICATED_ADMIN=true; admin routes are open")
+		} else {
+			if err := adminKey.Bootstrap(clientset, cfg.KeyNamespace); err != nil {
+				log.Fatalf("Failed to bootstrap the admin key: %v", err)
+			}
+			adminAuthEnforced = true
+		}
+	}
+
+	// Audit mutating admin operations
+	auditLog, err := audit.NewLog(cfg.AuditLogFile, int64(cfg.AuditLogMaxBytes), cfg.AuditLogRetained, cfg.FIPSMode)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	// Admin routes may be restricted to networks such as a bastion's
+	var adminAllowlist *auth.CIDRAllowlist
+	if len(cfg.AdminAllowedCIDRs) > 0 {
+		adminAllowlist, err = auth.NewCIDRAllowlist(cfg.AdminAllowedCIDRs)
+		if err != nil {
+			log.Fatalf("Invalid ADMIN_ALLOWED_CIDRS: %v", err)
+		}
+	}
+
+	// CORS for the management GUI, off unless origins are allowed
+	var corsPolicy *cors.Policy
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		corsPolicy, err = cors.NewPolicy(cors.Config{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   cfg.CORSAllowedMethods,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			ExposedHeaders:   cfg.CORSExposedHeaders,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		})
+		if err != nil {
+			log.Fatalf("Invalid CORS configuration: %v", err)
+		}
+	}
+
+	// Throttle the key manager's own API and lock out credential stuffing
+	limiter := ratelimit.NewLimiter(ratelimit.Config{
+		IPPerMinute:         cfg.APIRateLimitPerIP,
+		CredentialPerMinute: cfg.APIRateLimitPerCredential,
+		Burst:               cfg.APIRateLimitBurst,
+		LockoutThreshold:    cfg.AuthLockoutThreshold,
+		LockoutDuration:     cfg.AuthLockoutDuration,
+		LockoutMax:          cfg.AuthLockoutMaxDuration,
+	})
+
+	// Record lifecycle activity as Kubernetes Events unless disabled
+	var recorder *events.Recorder
+	if cfg.EventsEnabled {
+		recorder = events.NewRecorder(clientset, cfg.ServiceName, cfg.KeyNamespace, cfg.GatewayNamespace, cfg.GatewayName)
+	}
+
+	// Slow down and alert on sources that keep failing admin authentication
+	var authFailureBackoff []time.Duration
+	for _, value := range cfg.AuthFailureBackoff {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			log.Fatalf("Invalid AUTH_FAILURE_BACKOFF: %s", value)
+		}
+		authFailureBackoff = append(authFailureBackoff, delay)
+	}
+	authFailures := auth.NewFailureTracker(cfg.AuthFailureAlertThreshold, authFailureBackoff, cfg.AuthFailureWindow,
+		func(source string, failures int) {
+			recorder.AdminCredentials(cfg.AdminCredentialsSecret, corev1.EventTypeWarning, events.ReasonAdminAuthFailures,
+				"%d consecutive failed admin authentications from %s", failures, source)
+		})
+
+	// Mirror teams into MaaSTeam resources when enabled
+	var teamCRDStore *teams.CRDStore
+	if cfg.TeamCRDEnabled {
+		teamCRDStore = teams.NewCRDStore(kuadrantClient, cfg.KeyNamespace)
+	}
+
+	// Serve team key and member lookups from memory once synced
+	secretCache := teams.NewSecretCache(clientset, cfg.KeyNamespace)
+	secretCache.Start()
 
-	teamMgr := teams.NewManager(clientset, cfg.KeyNamespace, policyMgr)
-	keyMgr := keys.NewManager(clientset, cfg.KeyNamespace, teamMgr)
+	// Grant the key manager access to team key namespaces as they are added
+	var namespaceAccess *teams.KeyNamespaceAccess
+	if cfg.ManageKeyNamespaceAccess {
+		namespaceAccess = &teams.KeyNamespaceAccess{
+			ClusterRole:             cfg.KeyNamespaceClusterRole,
+			ServiceAccountNamespace: cfg.ServiceAccountNamespace,
+			ServiceAccountName:      cfg.ServiceAccountName,
+		}
+	}
+
+	teamMgr := teams.NewManager(clientset, cfg.KeyNamespace, policyMgr, teamCRDStore, recorder, webhooks, cfg.AutoCreateTeamNamespaces, cfg.AllowUnsignedWebhooks, namespaceAccess, secretCache)
+	if err := teamMgr.EnsureKeyNamespaceAccess(); err != nil {
+		log.Printf("Warning: Failed to check access to team key namespaces: %v", err)
+	}
+	if cfg.MigrateTeamsToCRD {
+		if _, err := teamMgr.MigrateTeamsToCRD(); err != nil {
+			log.Printf("Warning: Failed to migrate teams to MaaSTeam resources: %v", err)
+		}
+	}
+	if !teams.IsValidRemovalMode(cfg.MemberRemovalMode) {
+		log.Fatalf("Invalid MEMBER_REMOVAL_MODE: %s", cfg.MemberRemovalMode)
+	}
+	teamMgr.StartInactiveKeyCleanup(cfg.InactiveKeyCleanupInterval, cfg.InactiveKeyRetention)
+	teamMgr.StartProvisioningWorker()
+	teamMgr.StartPolicyReconciler(cfg.PolicyReconcileInterval)
+	keyMgr := keys.NewManager(clientset, cfg.KeyNamespace, teamMgr, cfg.MaxKeysPerUser, cfg.MaxKeysPerTeam, webhooks, keyHasher, recorder, cfg.KeyFormat, cfg.FIPSMode)
+	if err := keyMgr.BackfillKeyIDs(); err != nil {
+		log.Printf("Warning: Failed to label salted API keys with their key ID: %v", err)
+	}
 	modelMgr := models.NewManager(kuadrantClient)
+	limitadorClient := limitador.NewClient(cfg.LimitadorURL, cfg.LimitadorNamespace)
+
+	// Attribute live Limitador counters to teams, users and keys
+	liveUsage := teams.NewLiveUsage(teamMgr, limitadorClient)
+	liveUsage.Start(cfg.LiveUsageInterval)
+
+	// Resolve the public inference endpoint and keep it fresh
+	discoverer := discovery.NewDiscoverer(kuadrantClient, cfg.DiscoveryRoute, cfg.GatewayNamespace, cfg.GatewayName)
+	discoverer.Start(cfg.DiscoveryRefreshInterval)
+
+	// Accrue team spend and enforce monthly budgets
+	budgetEnforcer, err := budget.NewEnforcer(teamMgr, usage.NewCollector(clientset, restConfig, cfg.KeyNamespace),
+		cfg.BudgetEnforcementMode, cfg.BudgetOverPolicy)
+	if err != nil {
+		log.Fatalf("Invalid budget configuration: %v", err)
+	}
+	budgetEnforcer.Start(cfg.BudgetCheckInterval)
 
 	// Initialize handlers
-	usageHandler := handlers.NewUsageHandler(clientset, restConfig, cfg.KeyNamespace)
-	teamsHandler := handlers.NewTeamsHandler(teamMgr)
-	keysHandler := handlers.NewKeysHandler(keyMgr, teamMgr)
+	usageHistory := usage.NewHistory(prometheus.NewClient(cfg.PrometheusURL, cfg.PrometheusCacheTTL))
+	// Convert usage records into cost at the model prices
+	pricingRegistry, err := pricing.NewRegistry(clientset, cfg.KeyNamespace, pricing.Price{
+		InputPer1K:  cfg.DefaultInputPer1K,
+		OutputPer1K: cfg.DefaultOutputPer1K,
+		Currency:    cfg.DefaultCurrency,
+	}, cfg.PricingCurrencies)
+	if err != nil {
+		log.Fatalf("Invalid pricing configuration: %v", err)
+	}
+	pricingRegistry.Start(cfg.PricingRefreshInterval)
+	costEngine := pricing.NewEngine(pricingRegistry, teamMgr)
+
+	usageHandler := handlers.NewUsageHandler(clientset, restConfig, cfg.KeyNamespace, teamMgr, usageHistory, liveUsage, costEngine)
+	teamsHandler := handlers.NewTeamsHandler(teamMgr, limitadorClient, cfg.DefaultTeamTier, cfg.MemberRemovalMode)
+	keysHandler := handlers.NewKeysHandler(keyMgr, teamMgr, limitadorClient, discoverer, liveUsage, costEngine)
 	modelsHandler := handlers.NewModelsHandler(modelMgr)
 	legacyHandler := handlers.NewLegacyHandler(keyMgr)
-	healthHandler := handlers.NewHealthHandler()
+	healthHandler := handlers.NewHealthHandler(secretCache, policyGVRs, teamMgr, discoverer, limitadorClient, adminKey, adminAuthEnforced, cfg.FIPSMode)
+	discoveryHandler := handlers.NewDiscoveryHandler(discoverer)
+	selfServiceHandler := handlers.NewSelfServiceHandler(keyMgr, teamMgr, cfg.SelfServiceMaxKeysPerUser, discoverer)
+	invitesHandler := handlers.NewInvitesHandler(teamMgr, keyMgr, cfg.MaxInvitesPerTeam, cfg.InviteTTL)
+	tiersHandler := handlers.NewTiersHandler(teamMgr)
+	pricingHandler := handlers.NewPricingHandler(pricingRegistry)
+	credentialsHandler := handlers.NewCredentialsHandler(adminCredentials)
+	auditHandler := handlers.NewAuditHandler(auditLog)
+	metricsHandler := handlers.NewMetricsHandler(limiter, authFailures)
+	sessionHandler := handlers.NewSessionHandler(sessions)
+
+	// Report missing Kubernetes permissions at startup rather than on the
+	// first request that needs them
+	permissionOptions := permissions.Options{
+		KeyNamespace:         cfg.KeyNamespace,
+		GatewayNamespace:     cfg.GatewayNamespace,
+		DiscoveryRoute:       cfg.DiscoveryRoute,
+		AdminKeySecretRef:    cfg.AdminAPIKeySecretRef,
+		AutoCreateNamespaces: cfg.AutoCreateTeamNamespaces,
+		KubernetesAdminAuth:  cfg.AdminAuthMode == auth.AdminAuthKubernetes,
+		TeamCRD:              cfg.TeamCRDEnabled,
+		Events:               cfg.EventsEnabled,
+	}
+	if namespaceAccess != nil {
+		permissionOptions.KeyNamespaceClusterRole = namespaceAccess.ClusterRole
+	}
+	permissionChecker := permissions.NewChecker(clientset, permissions.Required(permissionOptions))
+	permissionChecker.CheckAndLog()
+	permissionsHandler := handlers.NewPermissionsHandler(permissionChecker)
 
 	// Create default team if enabled
 	if cfg.CreateDefaultTeam {
-		if err := teamMgr.CreateDefaultTeam(); err != nil {
+		if err := teamMgr.EnsureDefaultTeam(cfg.DefaultTeamTier); err != nil {
 			log.Printf("Warning: Failed to create default team: %v", err)
-		} else {
-			log.Printf("Default team created successfully")
 		}
 	}
 
 	// Initialize Gin router
+	handlers.ConfigureBinding()
 	r := gin.Default()
+	// Client addresses, which rate limits, lockouts and the admin allowlist
+	// go by, are read from X-Forwarded-For right to left, skipping the hops
+	// added by trusted proxies
+	r.RemoteIPHeaders = []string{"X-Forwarded-For"}
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(redact.Middleware(), cors.Middleware(corsPolicy), ratelimit.Middleware(limiter, credentialHeaders.Name()), handlers.BodyLimitMiddleware(int64(cfg.MaxRequestBodyBytes)))
 
 	// Health check endpoint (no auth required)
 	r.GET("/health", healthHandler.HealthCheck)
+	r.GET("/readyz", healthHandler.ReadinessCheck)
+	r.GET("/metrics", metricsHandler.Metrics)
+
+	// Self-service endpoints authenticated by the caller's own API key
+	selfRoutes := r.Group("/me", auth.APIKeyAuthMiddleware(keyMgr, credentialHeaders))
+	selfRoutes.GET("", selfServiceHandler.GetMe)
+	selfRoutes.GET("/keys", selfServiceHandler.ListMyKeys)
+	selfRoutes.POST("/keys", selfServiceHandler.CreateMyKey)
+
+	// Invite acceptance, authenticated by the invite token itself
+	r.POST("/invites/:token/accept", invitesHandler.AcceptInvite)
+
+	// Key syntax check for support and clients, revealing nothing stored
+	r.POST("/keys/check-format", keysHandler.CheckKeyFormat)
 
 	// Setup API routes with admin authentication
-	adminRoutes := r.Group("/", auth.AdminAuthMiddleware())
+	adminMiddleware := []gin.HandlerFunc{audit.Middleware(auditLog)}
+	if adminAllowlist != nil {
+		adminMiddleware = append(adminMiddleware, auth.AllowlistMiddleware(adminAllowlist))
+	}
+	// With allowed SANs a client certificate is one way to authenticate;
+	// otherwise it is required on top of the other credentials
+	if cfg.TLSClientCAFile != "" && !certIdentities.Configured() {
+		adminMiddleware = append(adminMiddleware, auth.ClientCertMiddleware())
+	}
+	adminMiddleware = append(adminMiddleware, auth.AdminAuthMiddleware(auth.AdminAuthConfig{
+		AdminKey:       adminKey,
+		Credentials:    adminCredentials,
+		ViewerKeys:     viewerKeys,
+		Reviewer:       adminReviewer,
+		OIDC:           oidcVerifier,
+		TeamTokens:     teamMgr,
+		Failures:       authFailures,
+		CertIdentities: certIdentities,
+		Sessions:       sessions,
+		Headers:        credentialHeaders,
+	}))
+	adminRoutes := r.Group("/", adminMiddleware...)
 
 	// Legacy endpoints (backward compatibility)
 	adminRoutes.POST("/generate_key", legacyHandler.GenerateKey)
@@ -87,11 +431,80 @@ func main() {
 	adminRoutes.GET("/teams/:team_id", teamsHandler.GetTeam)
 	adminRoutes.PATCH("/teams/:team_id", teamsHandler.UpdateTeam)
 	adminRoutes.DELETE("/teams/:team_id", teamsHandler.DeleteTeam)
+	adminRoutes.POST("/teams/:team_id/tier", teamsHandler.ChangeTier)
+	adminRoutes.POST("/teams/:team_id/budget/reset", teamsHandler.ResetBudget)
+	adminRoutes.POST("/teams/:team_id/archive", teamsHandler.ArchiveTeam)
+	adminRoutes.POST("/teams/:team_id/unarchive", teamsHandler.UnarchiveTeam)
+	adminRoutes.POST("/teams/:team_id/propagate-metadata", teamsHandler.PropagateMetadata)
+	adminRoutes.POST("/teams/:team_id/webhook/test", teamsHandler.TestWebhook)
+	adminRoutes.GET("/teams/:team_id/models", teamsHandler.GetTeamModels)
+	adminRoutes.PUT("/teams/:team_id/models", teamsHandler.SetTeamModels)
+	adminRoutes.GET("/teams/:team_id/policies", teamsHandler.GetTeamPolicies)
+	adminRoutes.GET("/teams/:team_id/provisioning", teamsHandler.GetProvisioningStatus)
+	adminRoutes.POST("/teams/:team_id/policies/sync", teamsHandler.SyncTeamPolicies)
+	adminRoutes.POST("/teams/:team_id/policies/validate", teamsHandler.ValidateTeamPolicies)
+	adminRoutes.GET("/teams/:team_id/limits/status", teamsHandler.GetTeamLimitStatus)
+	adminRoutes.GET("/teams/:team_id/policies/preview", teamsHandler.PreviewTeamPolicies)
+	adminRoutes.POST("/teams/:team_id/policies/preview", teamsHandler.PreviewTeamPolicies)
+
+	// Team-admin tokens (platform admin only)
+	adminRoutes.POST("/teams/:team_id/admin-tokens", teamsHandler.CreateAdminToken)
+	adminRoutes.GET("/teams/:team_id/admin-tokens", teamsHandler.ListAdminTokens)
+	adminRoutes.DELETE("/teams/:team_id/admin-tokens/:token_id", teamsHandler.RevokeAdminToken)
+
+	// Team membership
+	adminRoutes.POST("/teams/:team_id/members", teamsHandler.AddTeamMember)
+	adminRoutes.GET("/teams/:team_id/members", teamsHandler.ListTeamMembers)
+	adminRoutes.PATCH("/teams/:team_id/members/:user_id", teamsHandler.UpdateTeamMember)
+	adminRoutes.DELETE("/teams/:team_id/members/:user_id", teamsHandler.RemoveTeamMember)
+	adminRoutes.POST("/teams/:team_id/transfer-ownership", teamsHandler.TransferOwnership)
+
+	// Team invites
+	adminRoutes.POST("/teams/:team_id/invites", invitesHandler.CreateInvite)
+	adminRoutes.GET("/teams/:team_id/invites", invitesHandler.ListInvites)
+	adminRoutes.DELETE("/teams/:team_id/invites/:invite_id", invitesHandler.RevokeInvite)
 
 	// Team-scoped API key management
 	adminRoutes.POST("/teams/:team_id/keys", keysHandler.CreateTeamKey)
 	adminRoutes.GET("/teams/:team_id/keys", keysHandler.ListTeamKeys)
+	adminRoutes.GET("/keys/:key_name", keysHandler.GetTeamKey)
+	adminRoutes.PATCH("/keys/:key_name", keysHandler.UpdateTeamKey)
 	adminRoutes.DELETE("/keys/:key_name", keysHandler.DeleteTeamKey)
+	adminRoutes.POST("/keys/:key_name/rotate", keysHandler.RotateTeamKey)
+	adminRoutes.POST("/admin/keys/import", keysHandler.ImportKeys)
+	adminRoutes.GET("/admin/teams/export", keysHandler.ExportTeams)
+	adminRoutes.PUT("/admin/default-team", teamsHandler.UpdateDefaultTeam)
+	adminRoutes.POST("/admin/default-team/recreate", teamsHandler.RecreateDefaultTeam)
+	adminRoutes.POST("/admin/teams/import", keysHandler.ImportTeams)
+	adminRoutes.POST("/admin/teams/apply", teamsHandler.ApplyTeams)
+	adminRoutes.GET("/admin/provisioning/orphans", teamsHandler.GetProvisioningOrphans)
+	adminRoutes.GET("/admin/policies/compliance", teamsHandler.GetPolicyCompliance)
+	adminRoutes.POST("/admin/policies/sync-all", teamsHandler.SyncAllPolicies)
+	adminRoutes.GET("/admin/policies/export", teamsHandler.ExportPolicies)
+
+	// Named admin credentials (bootstrap admin key only)
+	adminRoutes.POST("/admin/credentials", credentialsHandler.CreateCredential)
+	adminRoutes.GET("/admin/credentials", credentialsHandler.ListCredentials)
+	adminRoutes.DELETE("/admin/credentials/:name", credentialsHandler.DeleteCredential)
+	adminRoutes.GET("/whoami", credentialsHandler.WhoAmI)
+
+	// Session tokens for the GUI, exchanged for an admin or team-admin credential
+	if sessions != nil {
+		adminRoutes.POST("/auth/session", sessionHandler.CreateSession)
+		adminRoutes.POST("/auth/refresh", sessionHandler.RefreshSession)
+		adminRoutes.POST("/auth/logout", sessionHandler.Logout)
+	}
+
+	adminRoutes.GET("/admin/policies/health", healthHandler.PolicyHealth)
+	adminRoutes.GET("/admin/permissions", permissionsHandler.CheckPermissions)
+
+	// Audit log of admin operations
+	adminRoutes.GET("/admin/audit", auditHandler.QueryAudit)
+
+	// Tier policies
+	adminRoutes.POST("/admin/policies/tiers", tiersHandler.CreateTierPolicy)
+	adminRoutes.GET("/admin/policies/tiers/:tier", tiersHandler.GetTierPolicy)
+	adminRoutes.PUT("/admin/policies/tiers/:tier", tiersHandler.UpdateTierPolicy)
 
 	// User key management
 	adminRoutes.GET("/users/:user_id/keys", keysHandler.ListUserKeys)
@@ -99,11 +512,66 @@ func main() {
 	// Usage endpoints
 	adminRoutes.GET("/users/:user_id/usage", usageHandler.GetUserUsage)
 	adminRoutes.GET("/teams/:team_id/usage", usageHandler.GetTeamUsage)
+	adminRoutes.GET("/teams/:team_id/usage/live", usageHandler.GetTeamLiveUsage)
+	adminRoutes.POST("/admin/usage/records", usageHandler.RecordUsage)
+	adminRoutes.GET("/admin/pricing", pricingHandler.ListModelPrices)
+	adminRoutes.PUT("/admin/pricing/:model_id", pricingHandler.SetModelPrice)
+	adminRoutes.DELETE("/admin/pricing/:model_id", pricingHandler.DeleteModelPrice)
 
 	// Model listing endpoint
 	adminRoutes.GET("/models", modelsHandler.ListModels)
 
+	// Endpoint discovery
+	adminRoutes.GET("/discover_endpoint", discoveryHandler.DiscoverEndpoint)
+
 	// Start server
-	log.Printf("Starting %s on port %s", cfg.ServiceName, cfg.Port)
-	log.Fatal(r.Run(":" + cfg.Port))
+	if len(cfg.ClientCertAllowedSANs) > 0 && cfg.TLSClientCAFile == "" {
+		log.Fatalf("Invalid TLS configuration: CLIENT_CERT_ALLOWED_SANS requires TLS_CLIENT_CA_FILE")
+	}
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			log.Fatalf("Invalid TLS configuration: TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		log.Printf("Starting %s on port %s", cfg.ServiceName, cfg.Port)
+		log.Fatal(r.Run(":" + cfg.Port))
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		log.Fatalf("Invalid TLS configuration: TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	reloader, err := certs.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.FIPSMode {
+		fips.RestrictTLS(tlsConfig)
+	}
+	if cfg.TLSClientCAFile != "" {
+		// Certificates are verified when presented and only required by the
+		// admin routes, so health checks and self-service work without one.
+		// Each handshake uses the current CAs, so they can be rotated.
+		clientCAs, err := certs.NewPoolReloader(cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to load TLS_CLIENT_CA_FILE: %v", err)
+		}
+		tlsConfig.ClientCAs = clientCAs.Pool()
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		baseConfig := tlsConfig.Clone()
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config := baseConfig.Clone()
+			config.ClientCAs = clientCAs.Pool()
+			return config, nil
+		}
+	}
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           r,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Starting %s on port %s with TLS", cfg.ServiceName, cfg.Port)
+	log.Fatal(server.ListenAndServeTLS("", ""))
 }
@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one audited admin operation. It only holds identifiers, never
+// request bodies, so keys and tokens sent or returned are not recorded.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Route    string    `json:"route,omitempty"`
+	Identity string    `json:"identity"`
+	Role     string    `json:"role,omitempty"`
+	TeamID   string    `json:"team_id,omitempty"`
+	KeyName  string    `json:"key_name,omitempty"`
+	UserID   string    `json:"user_id,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	Status   int       `json:"status"`
+	ClientIP string    `json:"client_ip,omitempty"`
+	FIPSMode bool      `json:"fips_mode,omitempty"`
+}
+
+// Log records audit entries as JSON lines to stdout and, optionally, a file
+// rotated when it grows past a size, which a PersistentVolume keeps across
+// restarts. Recent entries are also kept in memory for querying. Recording
+// is best effort: a write that fails is logged and the entry kept in memory.
+// A nil Log records nothing.
+type Log struct {
+	stdout   io.Writer
+	path     string
+	maxBytes int64
+	fipsMode bool
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewLog creates an audit log keeping the last retained entries in memory.
+// With a file path, entries are appended to it, the file is moved to
+// path.1 once it exceeds maxBytes, and the entries it already holds are
+// loaded so queries survive a restart. With fipsMode every entry records
+// that the operation ran in FIPS mode.
+func NewLog(path string, maxBytes int64, retained int, fipsMode bool) (*Log, error) {
+	if retained <= 0 {
+		retained = 1000
+	}
+	l := &Log{
+		stdout:   os.Stdout,
+		path:     path,
+		maxBytes: maxBytes,
+		fipsMode: fipsMode,
+		entries:  make([]Entry, retained),
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	l.load(path + ".1")
+	l.load(path)
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// load reads the entries of a previous audit file into memory
+func (l *Log) load(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			l.keep(entry)
+		}
+	}
+}
+
+// open opens the audit file for appending
+func (l *Log) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", l.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log %s: %w", l.path, err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Record writes an entry and keeps it for queries
+func (l *Log) Record(entry Entry) {
+	if l == nil {
+		return
+	}
+	entry.FIPSMode = l.fipsMode
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Warning: Failed to encode audit entry: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keep(entry)
+	if _, err := l.stdout.Write(line); err != nil {
+		log.Printf("Warning: Failed to write audit entry to stdout: %v", err)
+	}
+	if l.path != "" {
+		l.writeFile(line)
+	}
+}
+
+// writeFile appends a line to the audit file, rotating it first if full
+func (l *Log) writeFile(line []byte) {
+	if l.file != nil && l.maxBytes > 0 && l.size+int64(len(line)) > l.maxBytes {
+		l.file.Close()
+		l.file = nil
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			log.Printf("Warning: Failed to rotate audit log %s: %v", l.path, err)
+		}
+	}
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		log.Printf("Warning: Failed to write audit log %s: %v", l.path, err)
+	}
+}
+
+// keep adds an entry to the in-memory ring, replacing the oldest when full
+func (l *Log) keep(entry Entry) {
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Query returns the retained entries at or after since, of a team when
+// teamID is set, oldest first
+func (l *Log) Query(since time.Time, teamID string) []Entry {
+	entries := make([]Entry, 0)
+	if l == nil {
+		return entries
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start, count := 0, l.next
+	if l.full {
+		start, count = l.next, len(l.entries)
+	}
+	for i := 0; i < count; i++ {
+		entry := l.entries[(start+i)%len(l.entries)]
+		if entry.Time.Before(since) || (teamID != "" && entry.TeamID != teamID) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+)
+
+// maxCapturedBody bounds how much of a response is kept to find the
+// identifiers of what it created
+const maxCapturedBody = 64 * 1024
+
+// bodyCapture keeps the start of a response while it is written
+type bodyCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyCapture) Write(data []byte) (int, error) {
+	if room := maxCapturedBody - w.body.Len(); room > 0 {
+		if len(data) < room {
+			room = len(data)
+		}
+		w.body.Write(data[:room])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Middleware records every mutating request of the route group it is
+// installed on once it has been handled. It goes before the admin auth
+// middleware so rejected attempts are recorded too; the identity is read
+// from what the auth middleware set. Team, key and user identifiers come
+// from the path, or from the response for what a request created.
+func Middleware(l *Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if l == nil || method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		capture := &bodyCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
+		c.Next()
+
+		entry := Entry{
+			Time:     time.Now().UTC(),
+			Method:   method,
+			Path:     c.Request.URL.Path,
+			Route:    c.FullPath(),
+			Identity: identity(c),
+			Role:     c.GetString(auth.ContextRole),
+			TeamID:   c.Param("team_id"),
+			KeyName:  c.Param("key_name"),
+			UserID:   c.Param("user_id"),
+			Status:   c.Writer.Status(),
+			ClientIP: c.ClientIP(),
+		}
+		var targets []string
+		for _, param := range c.Params {
+			switch param.Key {
+			case "team_id", "key_name", "user_id":
+			default:
+				targets = append(targets, param.Key+"="+param.Value)
+			}
+		}
+		entry.Target = strings.Join(targets, ",")
+		fromResponse(&entry, capture.body.Bytes())
+
+		l.Record(entry)
+	}
+}
+
+// identity names who made a request from what the auth middleware set
+func identity(c *gin.Context) string {
+	if authenticated := auth.Identity(c); authenticated != "" {
+		return authenticated
+	}
+	if c.Writer.Status() == http.StatusUnauthorized || c.Writer.Status() == http.StatusForbidden {
+		return "unauthenticated"
+	}
+	// Admin routes are open while no admin key is configured
+	return "anonymous"
+}
+
+// fromResponse fills identifiers the path did not carry from the top-level
+// fields of a JSON response. Only these fields are read, so key material in
+// the response is never copied.
+func fromResponse(entry *Entry, body []byte) {
+	if entry.TeamID != "" && entry.KeyName != "" && entry.UserID != "" {
+		return
+	}
+	var fields struct {
+		TeamID     string `json:"team_id"`
+		SecretName string `json:"secret_name"`
+		UserID     string `json:"user_id"`
+	}
+	if json.Unmarshal(body, &fields) != nil {
+		return
+	}
+	if entry.TeamID == "" {
+		entry.TeamID = fields.TeamID
+	}
+	if entry.KeyName == "" {
+		entry.KeyName = fields.SecretName
+	}
+	if entry.UserID == "" {
+		entry.UserID = fields.UserID
+	}
+}
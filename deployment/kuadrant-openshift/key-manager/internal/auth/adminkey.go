@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// AdminKey holds the platform admin key. Only its SHA-256 hash is kept, so
+// the key can be configured as a hash alone, and presented keys are hashed
+// and compared in constant time.
+type AdminKey struct {
+	mu   sync.RWMutex
+	hash []byte
+	// Set when the key comes from a Secret, which keeps admin routes locked
+	// while the Secret is missing
+	required  bool
+	secretErr error
+}
+
+// NewAdminKey creates the admin key from either the key itself or the hex
+// SHA-256 hash of it. With neither set admin routes are left open.
+func NewAdminKey(key, keySHA256 string) (*AdminKey, error) {
+	adminKey := &AdminKey{}
+	if err := adminKey.Set(key, keySHA256); err != nil {
+		return nil, err
+	}
+	return adminKey, nil
+}
+
+// Set replaces the admin key, such as when it is rotated. Requests already
+// being authenticated finish against the key they started with.
+func (k *AdminKey) Set(key, keySHA256 string) error {
+	var hash []byte
+	switch {
+	case key != "" && keySHA256 != "":
+		return fmt.Errorf("set either the admin key or its SHA-256 hash, not both")
+	case key != "":
+		hash = fips.SHA256([]byte(key))
+	case keySHA256 != "":
+		decoded, err := hex.DecodeString(strings.TrimSpace(keySHA256))
+		if err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("admin key hash must be %d hex characters", 2*sha256.Size)
+		}
+		hash = decoded
+	}
+
+	k.mu.Lock()
+	k.hash = hash
+	k.mu.Unlock()
+	return nil
+}
+
+// Configured reports whether an admin key is set or expected from a Secret
+func (k *AdminKey) Configured() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.hash != nil || k.required
+}
+
+// Matches reports whether a presented key is the admin key
+func (k *AdminKey) Matches(providedKey string) bool {
+	k.mu.RLock()
+	hash := k.hash
+	k.mu.RUnlock()
+	if hash == nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(fips.SHA256([]byte(providedKey)), hash) == 1
+}
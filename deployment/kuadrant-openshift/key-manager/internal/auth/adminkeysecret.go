@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ParseSecretRef splits a secret reference of the form namespace/name#key
+func ParseSecretRef(ref string) (string, string, string, error) {
+	path, key, found := strings.Cut(ref, "#")
+	namespace, name, ok := strings.Cut(path, "/")
+	if !found || !ok || namespace == "" || name == "" || key == "" {
+		return "", "", "", fmt.Errorf("secret reference %q must have the form namespace/name#key", ref)
+	}
+	return namespace, name, key, nil
+}
+
+// WatchSecret loads the admin key from a Secret and follows changes to it,
+// so the key is rotated by updating the Secret alone. Until the Secret holds
+// the key, and after it is deleted, admin routes stay locked rather than
+// open, and Err reports why.
+func (k *AdminKey) WatchSecret(clientset kubernetes.Interface, ref string) error {
+	namespace, name, key, err := ParseSecretRef(ref)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.required = true
+	k.mu.Unlock()
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		k.setSecretErr(fmt.Errorf("admin key secret %s/%s: %w", namespace, name, err))
+	} else {
+		k.loadSecret(secret, key)
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().Secrets().Informer()
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { k.loadSecret(obj, key) },
+		UpdateFunc: func(_, obj interface{}) { k.loadSecret(obj, key) },
+		DeleteFunc: func(interface{}) {
+			// The last key stays in use so deleting the Secret cannot open
+			// the admin routes; readiness reports it missing
+			k.setSecretErr(fmt.Errorf("admin key secret %s/%s was deleted", namespace, name))
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch admin key secret: %w", err)
+	}
+	go informer.Run(make(chan struct{}))
+
+	log.Printf("Admin key loaded from secret %s/%s, key %s", namespace, name, key)
+	return nil
+}
+
+// loadSecret swaps in the admin key held by a Secret
+func (k *AdminKey) loadSecret(obj interface{}, key string) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return
+	}
+	value := strings.TrimSpace(string(secret.Data[key]))
+	if value == "" {
+		k.setSecretErr(fmt.Errorf("admin key secret %s/%s has no %s key", secret.Namespace, secret.Name, key))
+		return
+	}
+	if err := k.Set(value, ""); err != nil {
+		k.setSecretErr(err)
+		return
+	}
+	k.setSecretErr(nil)
+}
+
+// setSecretErr records why the admin key could not be read from its Secret
+func (k *AdminKey) setSecretErr(err error) {
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	k.mu.Lock()
+	k.secretErr = err
+	k.mu.Unlock()
+}
+
+// Err reports why the admin key could not be loaded from its Secret, nil
+// when it was or no Secret is referenced
+func (k *AdminKey) Err() error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.secretErr
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CIDRAllowlist holds the networks admin requests may come from
+type CIDRAllowlist struct {
+	networks []*net.IPNet
+}
+
+// NewCIDRAllowlist parses CIDRs such as 10.0.5.0/24. A bare address allows
+// that address alone.
+func NewCIDRAllowlist(cidrs []string) (*CIDRAllowlist, error) {
+	allowlist := &CIDRAllowlist{}
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		allowlist.networks = append(allowlist.networks, network)
+	}
+	return allowlist, nil
+}
+
+// Allows reports whether an address is in one of the networks
+func (a *CIDRAllowlist) Allows(ip net.IP) bool {
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowlistMiddleware rejects requests from outside the allowlist with 403.
+// The source is the client address the router resolves, the same one rate
+// limits and lockouts go by, so X-Forwarded-For is only believed as far as
+// trusted proxies added it.
+func AllowlistMiddleware(allowlist *CIDRAllowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		source := net.ParseIP(c.ClientIP())
+		if source == nil || !allowlist.Allows(source) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin API is not reachable from this address"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
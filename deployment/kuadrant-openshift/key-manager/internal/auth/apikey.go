@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/keys"
+)
+
+// Context keys set by APIKeyAuthMiddleware
+const (
+	ContextKeySecret = "key_secret"
+	ContextUserID    = "user_id"
+	ContextTeamID    = "team_id"
+)
+
+// KeyResolver resolves an end-user API key to the secret that backs it
+type KeyResolver interface {
+	ResolveKey(apiKey string) (*corev1.Secret, error)
+}
+
+// APIKeyAuthMiddleware authenticates requests using the caller's own API key
+// rather than the admin key, for self-service endpoints. The key may be
+// presented wherever headers allows.
+func APIKeyAuthMiddleware(resolver KeyResolver, headers *CredentialHeaders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Accept the same prefix the gateway uses as well as Bearer
+		providedKey, err := headers.Extract(c, "Bearer ", "APIKEY ")
+		if errors.Is(err, ErrCredentialMissing) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": headers.missingCredentialMessage()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format. Use: Authorization: APIKEY <key>"})
+			c.Abort()
+			return
+		}
+
+		secret, err := resolver.ResolveKey(providedKey)
+		if err != nil {
+			if errors.Is(err, keys.ErrKeyInactive) || errors.Is(err, keys.ErrKeyExpired) {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextKeySecret, secret)
+		c.Set(ContextUserID, secret.Labels["maas/user-id"])
+		c.Set(ContextTeamID, secret.Labels["maas/team-id"])
+		c.Next()
+	}
+}
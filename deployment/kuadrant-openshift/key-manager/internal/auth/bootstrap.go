@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// BootstrapSecretName is the Secret holding the admin key generated on
+// first startup
+const BootstrapSecretName = "key-manager-admin-credential"
+
+// Fields of the bootstrap Secret. Only the hash is read back; the plaintext
+// is there for the operator to retrieve once and may then be removed.
+const (
+	bootstrapHashField = "admin_key_sha256"
+	bootstrapKeyField  = "admin_key"
+)
+
+// Bootstrap makes sure admin routes are never open on a fresh install with
+// no admin credential configured. The key generated on first startup is
+// kept in the bootstrap Secret of the key namespace, and later startups, of
+// this replica or others, load its hash from there.
+func (k *AdminKey) Bootstrap(clientset kubernetes.Interface, namespace string) error {
+	secrets := clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(context.Background(), BootstrapSecretName, metav1.GetOptions{})
+	if err == nil {
+		return k.loadBootstrapSecret(secret)
+	}
+	if !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to read admin key secret %s/%s: %w", namespace, BootstrapSecretName, err)
+	}
+
+	key, err := fips.RandomHex(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate admin key: %w", err)
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BootstrapSecretName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":                "key-manager",
+				"maas/resource-type": "admin-bootstrap",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			bootstrapHashField: fips.SHA256Hex([]byte(key)),
+			bootstrapKeyField:  key,
+		},
+	}
+	if _, err := secrets.Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to store admin key secret %s/%s: %w", namespace, BootstrapSecretName, err)
+		}
+		// Another replica bootstrapped first
+		existing, err := secrets.Get(context.Background(), BootstrapSecretName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to read admin key secret %s/%s: %w", namespace, BootstrapSecretName, err)
+		}
+		return k.loadBootstrapSecret(existing)
+	}
+
+	if err := k.Set(key, ""); err != nil {
+		return err
+	}
+	log.Printf("No admin credential was configured, so an admin key was generated and stored in secret %s/%s. "+
+		"Retrieve it with: kubectl get secret -n %s %s -o jsonpath='{.data.%s}' | base64 -d . "+
+		"Then remove the plaintext, which the key manager does not need: "+
+		"kubectl patch secret -n %s %s --type=json -p='[{\"op\":\"remove\",\"path\":\"/data/%s\"}]'",
+		namespace, BootstrapSecretName, namespace, BootstrapSecretName, bootstrapKeyField,
+		namespace, BootstrapSecretName, bootstrapKeyField)
+	return nil
+}
+
+// loadBootstrapSecret sets the admin key from the hash in the bootstrap Secret
+func (k *AdminKey) loadBootstrapSecret(secret *corev1.Secret) error {
+	hash := string(secret.Data[bootstrapHashField])
+	if hash == "" {
+		return fmt.Errorf("admin key secret %s/%s has no %s key", secret.Namespace, secret.Name, bootstrapHashField)
+	}
+	if err := k.Set("", hash); err != nil {
+		return fmt.Errorf("admin key secret %s/%s: %w", secret.Namespace, secret.Name, err)
+	}
+	log.Printf("Admin key loaded from secret %s/%s", secret.Namespace, secret.Name)
+	return nil
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// CredentialBootstrap names the admin key configured in the environment,
+// the only credential allowed to manage the others
+const CredentialBootstrap = "bootstrap"
+
+// ContextCredential is the request context key holding the name of the admin
+// credential a request was authenticated with
+const ContextCredential = "admin_credential"
+
+// credentialNamePattern restricts credential names to what a Secret data key
+// and a log line carry safely
+var credentialNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// credentialsSyncTimeout bounds the wait for the first read of the Secret
+const credentialsSyncTimeout = 30 * time.Second
+
+// AdminCredentials are named admin keys, such as one per automation, kept as
+// SHA-256 hashes in a Secret. The Secret is watched, so credentials added or
+// removed by any replica take effect without a restart, and rotating one
+// does not affect the others.
+type AdminCredentials struct {
+	clientset  kubernetes.Interface
+	namespace  string
+	secretName string
+	informer   cache.SharedIndexInformer
+	// Reports when the first read of the Secret has been loaded
+	loaded cache.InformerSynced
+
+	mu     sync.RWMutex
+	hashes map[string][]byte
+}
+
+// NewAdminCredentials creates the credential store backed by a Secret. Call
+// Start to begin watching it.
+func NewAdminCredentials(clientset kubernetes.Interface, namespace, secretName string) *AdminCredentials {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", secretName).String()
+		}))
+
+	credentials := &AdminCredentials{
+		clientset:  clientset,
+		namespace:  namespace,
+		secretName: secretName,
+		informer:   factory.Core().V1().Secrets().Informer(),
+		hashes:     make(map[string][]byte),
+	}
+	registration, err := credentials.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { credentials.load(obj) },
+		UpdateFunc: func(_, obj interface{}) { credentials.load(obj) },
+		DeleteFunc: func(interface{}) { credentials.load(nil) },
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to watch admin credentials: %v", err)
+		credentials.loaded = credentials.informer.HasSynced
+	} else {
+		credentials.loaded = registration.HasSynced
+	}
+	return credentials
+}
+
+// Start begins watching the credentials Secret in the background
+func (a *AdminCredentials) Start() {
+	if a == nil {
+		return
+	}
+	go a.informer.Run(make(chan struct{}))
+	log.Printf("Admin credentials watched in secret %s/%s", a.namespace, a.secretName)
+}
+
+// WaitForSync blocks until the credentials in the Secret are loaded, so
+// Configured reflects them, and reports whether they were before the timeout
+func (a *AdminCredentials) WaitForSync() bool {
+	if a == nil {
+		return true
+	}
+	stop := make(chan struct{})
+	timer := time.AfterFunc(credentialsSyncTimeout, func() { close(stop) })
+	defer timer.Stop()
+	return cache.WaitForCacheSync(stop, a.loaded)
+}
+
+// load replaces the credentials with those of the Secret, or clears them
+// when it was deleted
+func (a *AdminCredentials) load(obj interface{}) {
+	hashes := make(map[string][]byte)
+	if secret, ok := obj.(*corev1.Secret); ok {
+		for name, value := range secret.Data {
+			hash, err := hex.DecodeString(string(value))
+			if err != nil || len(hash) != sha256.Size {
+				log.Printf("Warning: Ignoring admin credential %s with a malformed hash", name)
+				continue
+			}
+			hashes[name] = hash
+		}
+	}
+
+	a.mu.Lock()
+	a.hashes = hashes
+	a.mu.Unlock()
+}
+
+// Configured reports whether any named credential exists
+func (a *AdminCredentials) Configured() bool {
+	if a == nil {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.hashes) > 0
+}
+
+// Match returns the name of the credential a presented key belongs to.
+// Every credential is compared, in constant time, whether or not one has
+// already matched.
+func (a *AdminCredentials) Match(providedKey string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	sum := fips.SHA256([]byte(providedKey))
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	matched := ""
+	for name, hash := range a.hashes {
+		if subtle.ConstantTimeCompare(sum, hash) == 1 {
+			matched = name
+		}
+	}
+	return matched, matched != ""
+}
+
+// Names lists the named credentials
+func (a *AdminCredentials) Names() []string {
+	names := make([]string, 0)
+	if a == nil {
+		return names
+	}
+	a.mu.RLock()
+	for name := range a.hashes {
+		names = append(names, name)
+	}
+	a.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Add generates a key for a new named credential and stores its hash. The
+// key is returned once and cannot be recovered.
+func (a *AdminCredentials) Add(name string) (string, error) {
+	if a == nil {
+		return "", fmt.Errorf("admin credentials are not configured")
+	}
+	if name == CredentialBootstrap || len(name) > 63 || !credentialNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid credential name: must be a lowercase DNS label other than %q", CredentialBootstrap)
+	}
+
+	key, err := fips.RandomHex(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	sum := fips.SHA256([]byte(key))
+
+	err = a.update(func(data map[string][]byte) error {
+		if _, exists := data[name]; exists {
+			return fmt.Errorf("credential %s already exists", name)
+		}
+		data[name] = []byte(hex.EncodeToString(sum))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	a.hashes[name] = sum
+	a.mu.Unlock()
+	return key, nil
+}
+
+// Remove deletes a named credential
+func (a *AdminCredentials) Remove(name string) error {
+	if a == nil {
+		return fmt.Errorf("admin credentials are not configured")
+	}
+	err := a.update(func(data map[string][]byte) error {
+		if _, exists := data[name]; !exists {
+			return fmt.Errorf("credential %s not found", name)
+		}
+		delete(data, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	delete(a.hashes, name)
+	a.mu.Unlock()
+	return nil
+}
+
+// update applies a change to the credentials Secret, creating it if needed
+// and retrying when another replica changed it first
+func (a *AdminCredentials) update(mutate func(data map[string][]byte) error) error {
+	secrets := a.clientset.CoreV1().Secrets(a.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := secrets.Get(context.Background(), a.secretName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      a.secretName,
+					Namespace: a.namespace,
+					Labels:    map[string]string{"maas/resource-type": "admin-credentials"},
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: make(map[string][]byte),
+			}
+			if err := mutate(secret.Data); err != nil {
+				return err
+			}
+			_, err = secrets.Create(context.Background(), secret, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created by another replica meanwhile, retry as a conflict
+				return apierrors.NewConflict(corev1.Resource("secrets"), a.secretName, err)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create admin credentials: %w", err)
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get admin credentials: %w", err)
+		}
+
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		if err := mutate(secret.Data); err != nil {
+			return err
+		}
+		_, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+		if err != nil && !apierrors.IsConflict(err) {
+			return fmt.Errorf("failed to update admin credentials: %w", err)
+		}
+		return err
+	})
+}
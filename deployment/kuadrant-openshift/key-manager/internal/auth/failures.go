@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FailureTracker counts consecutive failed admin authentications per source
+// address. Once a source reaches the threshold it is reported, and each
+// further failure is answered only after a growing delay, which slows brute
+// forcing without locking legitimate callers out. A success from the source
+// resets it, and sources idle for the window are forgotten. A nil tracker
+// tracks nothing.
+type FailureTracker struct {
+	threshold int
+	backoff   []time.Duration
+	window    time.Duration
+	alert     func(source string, failures int)
+
+	mu      sync.Mutex
+	sources map[string]*sourceFailures
+}
+
+// sourceFailures is the failure history of one source
+type sourceFailures struct {
+	consecutive int
+	total       int64
+	last        time.Time
+}
+
+// NewFailureTracker creates a tracker. alert is called, once per run of
+// failures, when a source reaches threshold consecutive failures; backoff
+// lists the delays of that failure and the ones after it, the last delay
+// repeating.
+func NewFailureTracker(threshold int, backoff []time.Duration, window time.Duration, alert func(source string, failures int)) *FailureTracker {
+	return &FailureTracker{
+		threshold: threshold,
+		backoff:   backoff,
+		window:    window,
+		alert:     alert,
+		sources:   make(map[string]*sourceFailures),
+	}
+}
+
+// Fail records a failure from a source and returns how long to wait before
+// answering it
+func (t *FailureTracker) Fail(source string) time.Duration {
+	if t == nil || t.threshold <= 0 {
+		return 0
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	t.expire(now)
+	failures := t.sources[source]
+	if failures == nil {
+		failures = &sourceFailures{}
+		t.sources[source] = failures
+	}
+	failures.consecutive++
+	failures.total++
+	failures.last = now
+	consecutive := failures.consecutive
+	t.mu.Unlock()
+
+	if consecutive < t.threshold {
+		return 0
+	}
+	if consecutive == t.threshold {
+		log.Printf("Warning: %d consecutive failed admin authentications from %s", consecutive, source)
+		if t.alert != nil {
+			t.alert(source, consecutive)
+		}
+	}
+	if len(t.backoff) == 0 {
+		return 0
+	}
+	step := consecutive - t.threshold
+	if step >= len(t.backoff) {
+		step = len(t.backoff) - 1
+	}
+	return t.backoff[step]
+}
+
+// Succeed resets a source's consecutive failures
+func (t *FailureTracker) Succeed(source string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if failures := t.sources[source]; failures != nil {
+		failures.consecutive = 0
+	}
+	t.mu.Unlock()
+}
+
+// expire forgets sources without failures within the window
+func (t *FailureTracker) expire(now time.Time) {
+	for source, failures := range t.sources {
+		if now.Sub(failures.last) > t.window {
+			delete(t.sources, source)
+		}
+	}
+}
+
+// SourceFailures is the failure count of one tracked source
+type SourceFailures struct {
+	Source string
+	Total  int64
+}
+
+// Sources returns the failures of the sources tracked within the window,
+// sorted by source
+func (t *FailureTracker) Sources() []SourceFailures {
+	sources := make([]SourceFailures, 0)
+	if t == nil {
+		return sources
+	}
+	t.mu.Lock()
+	t.expire(time.Now())
+	for source, failures := range t.sources {
+		sources = append(sources, SourceFailures{Source: source, Total: failures.total})
+	}
+	t.mu.Unlock()
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Source < sources[j].Source })
+	return sources
+}
+
+// unauthorized answers a failed admin authentication with 401, after the
+// delay its source has earned. A client that gives up stops the wait.
+func unauthorized(c *gin.Context, failures *FailureTracker, body gin.H) {
+	if delay := failures.Fail(c.ClientIP()); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			timer.Stop()
+		}
+	}
+	c.JSON(http.StatusUnauthorized, body)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons no credential could be read from a request
+var (
+	ErrCredentialMissing = errors.New("no credential presented")
+	ErrCredentialFormat  = errors.New("credential has an unknown scheme")
+)
+
+// CredentialHeaders says where, besides an Authorization header with a
+// scheme prefix, a credential may be presented: in a custom header such as
+// X-Api-Key, for tools that cannot set Authorization, and as a bare token in
+// Authorization. A nil CredentialHeaders accepts prefixed Authorization
+// headers only.
+type CredentialHeaders struct {
+	name     string
+	allowRaw bool
+}
+
+// NewCredentialHeaders creates the accepted credential headers. An empty
+// name disables the custom header.
+func NewCredentialHeaders(name string, allowRaw bool) *CredentialHeaders {
+	return &CredentialHeaders{name: name, allowRaw: allowRaw}
+}
+
+// Name is the custom credential header, empty when there is none
+func (h *CredentialHeaders) Name() string {
+	if h == nil {
+		return ""
+	}
+	return h.name
+}
+
+// Extract reads the credential of a request. Authorization wins over the
+// custom header, and its value must start with one of prefixes, such as
+// "Bearer ", unless bare tokens are allowed. Every form yields the same
+// credential, which callers check as they would any other.
+func (h *CredentialHeaders) Extract(c *gin.Context, prefixes ...string) (string, error) {
+	if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(authHeader, prefix) {
+				return strings.TrimPrefix(authHeader, prefix), nil
+			}
+		}
+		if h != nil && h.allowRaw && !strings.Contains(authHeader, " ") {
+			return authHeader, nil
+		}
+		return "", ErrCredentialFormat
+	}
+	if name := h.Name(); name != "" {
+		if value := strings.TrimSpace(c.GetHeader(name)); value != "" {
+			return value, nil
+		}
+	}
+	return "", ErrCredentialMissing
+}
+
+// missingCredentialMessage tells a caller without a credential where to put it
+func (h *CredentialHeaders) missingCredentialMessage() string {
+	if name := h.Name(); name != "" {
+		return "Authorization or " + name + " header required"
+	}
+	return "Authorization header required"
+}
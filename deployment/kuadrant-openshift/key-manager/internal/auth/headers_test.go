@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCredentialHeadersExtract(t *testing.T) {
+	const key = "maas_7Hq2LmXw9RtBv3KpZs6NcYd1FgJe8UaWo4QiTkMrVnE_2bXk9P"
+
+	tests := []struct {
+		name    string
+		headers *CredentialHeaders
+		set     map[string]string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Bearer prefix",
+			headers: NewCredentialHeaders("", false),
+			set:     map[string]string{"Authorization": "Bearer " + key},
+			want:    key,
+		},
+		{
+			name:    "APIKEY prefix",
+			headers: NewCredentialHeaders("", false),
+			set:     map[string]string{"Authorization": "APIKEY " + key},
+			want:    key,
+		},
+		{
+			name:    "prefix is case sensitive",
+			headers: NewCredentialHeaders("", false),
+			set:     map[string]string{"Authorization": "bearer " + key},
+			wantErr: ErrCredentialFormat,
+		},
+		{
+			name:    "unknown scheme",
+			headers: NewCredentialHeaders("X-Api-Key", true),
+			set:     map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			wantErr: ErrCredentialFormat,
+		},
+		{
+			name:    "X-Api-Key header",
+			headers: NewCredentialHeaders("X-Api-Key", false),
+			set:     map[string]string{"X-Api-Key": key},
+			want:    key,
+		},
+		{
+			name:    "X-Api-Key header is trimmed",
+			headers: NewCredentialHeaders("X-Api-Key", false),
+			set:     map[string]string{"X-Api-Key": "  " + key + " "},
+			want:    key,
+		},
+		{
+			name:    "custom header name",
+			headers: NewCredentialHeaders("X-MaaS-Key", false),
+			set:     map[string]string{"X-MaaS-Key": key},
+			want:    key,
+		},
+		{
+			name:    "header other than the configured one",
+			headers: NewCredentialHeaders("X-MaaS-Key", false),
+			set:     map[string]string{"X-Api-Key": key},
+			wantErr: ErrCredentialMissing,
+		},
+		{
+			name:    "custom header disabled",
+			headers: NewCredentialHeaders("", false),
+			set:     map[string]string{"X-Api-Key": key},
+			wantErr: ErrCredentialMissing,
+		},
+		{
+			name:    "empty custom header",
+			headers: NewCredentialHeaders("X-Api-Key", false),
+			set:     map[string]string{"X-Api-Key": "   "},
+			wantErr: ErrCredentialMissing,
+		},
+		{
+			name:    "raw token allowed",
+			headers: NewCredentialHeaders("", true),
+			set:     map[string]string{"Authorization": key},
+			want:    key,
+		},
+		{
+			name:    "raw token not allowed",
+			headers: NewCredentialHeaders("", false),
+			set:     map[string]string{"Authorization": key},
+			wantErr: ErrCredentialFormat,
+		},
+		{
+			name:    "raw token with a space",
+			headers: NewCredentialHeaders("", true),
+			set:     map[string]string{"Authorization": "Token " + key},
+			wantErr: ErrCredentialFormat,
+		},
+		{
+			name:    "Authorization wins over the custom header",
+			headers: NewCredentialHeaders("X-Api-Key", false),
+			set:     map[string]string{"Authorization": "Bearer " + key, "X-Api-Key": "other-key"},
+			want:    key,
+		},
+		{
+			name:    "malformed Authorization is not rescued by the custom header",
+			headers: NewCredentialHeaders("X-Api-Key", false),
+			set:     map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "X-Api-Key": key},
+			wantErr: ErrCredentialFormat,
+		},
+		{
+			name:    "nil headers accept prefixes",
+			headers: nil,
+			set:     map[string]string{"Authorization": "Bearer " + key},
+			want:    key,
+		},
+		{
+			name:    "nil headers reject raw tokens",
+			headers: nil,
+			set:     map[string]string{"Authorization": key},
+			wantErr: ErrCredentialFormat,
+		},
+		{
+			name:    "nil headers ignore X-Api-Key",
+			headers: nil,
+			set:     map[string]string{"X-Api-Key": key},
+			wantErr: ErrCredentialMissing,
+		},
+		{
+			name:    "nothing presented",
+			headers: NewCredentialHeaders("X-Api-Key", true),
+			wantErr: ErrCredentialMissing,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/models", nil)
+			for name, value := range tt.set {
+				c.Request.Header.Set(name, value)
+			}
+
+			got, err := tt.headers.Extract(c, "Bearer ", "APIKEY ")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Extract() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Extract() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialHeadersMissingMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers *CredentialHeaders
+		want    string
+	}{
+		{name: "custom header", headers: NewCredentialHeaders("X-Api-Key", false), want: "Authorization or X-Api-Key header required"},
+		{name: "no custom header", headers: NewCredentialHeaders("", true), want: "Authorization header required"},
+		{name: "nil", headers: nil, want: "Authorization header required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.headers.missingCredentialMessage(); got != tt.want {
+				t.Errorf("missingCredentialMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// Admin auth modes
+const (
+	// AdminAuthStatic authenticates admins with the admin key and named
+	// credentials
+	AdminAuthStatic = "static"
+	// AdminAuthKubernetes authenticates admins by their Kubernetes identity,
+	// authorized by RBAC on a virtual resource
+	AdminAuthKubernetes = "kubernetes"
+)
+
+// The virtual resource admins must be allowed on. It is never served, so
+// access is granted with RBAC alone, such as a ClusterRole on
+// maas.redhat.com teams.
+const (
+	adminResourceGroup = "maas.redhat.com"
+	adminResource      = "teams"
+)
+
+// IsValidAdminAuthMode checks if an admin auth mode is supported
+func IsValidAdminAuthMode(mode string) bool {
+	return mode == AdminAuthStatic || mode == AdminAuthKubernetes
+}
+
+// KubernetesReviewer authenticates bearer tokens with the TokenReview API and
+// authorizes their user with a SubjectAccessReview. Results are cached
+// briefly per token and verb so requests do not each reach the API server.
+type KubernetesReviewer struct {
+	clientset kubernetes.Interface
+	cacheTTL  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]reviewResult
+}
+
+// reviewResult is a cached review of a token for one verb
+type reviewResult struct {
+	username      string
+	authenticated bool
+	allowed       bool
+	expires       time.Time
+}
+
+// NewKubernetesReviewer creates a reviewer. cacheTTL of 0 disables caching.
+func NewKubernetesReviewer(clientset kubernetes.Interface, cacheTTL time.Duration) *KubernetesReviewer {
+	return &KubernetesReviewer{
+		clientset: clientset,
+		cacheTTL:  cacheTTL,
+		cache:     make(map[string]reviewResult),
+	}
+}
+
+// adminVerb maps a request method to the verb checked on the virtual
+// resource, so read-only access can be granted on its own
+func adminVerb(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "get"
+	}
+	return "update"
+}
+
+// Review authenticates a token and checks its user may perform verb. It
+// returns the username, whether the token is valid and whether access is
+// allowed. Errors reaching the API server are not cached.
+func (r *KubernetesReviewer) Review(token, verb string) (string, bool, bool, error) {
+	cacheKey := fips.SHA256Hex([]byte(token)) + "/" + verb
+
+	r.mu.Lock()
+	cached, ok := r.cache[cacheKey]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.username, cached.authenticated, cached.allowed, nil
+	}
+
+	result, err := r.review(token, verb)
+	if err != nil {
+		return "", false, false, err
+	}
+
+	if r.cacheTTL > 0 {
+		result.expires = time.Now().Add(r.cacheTTL)
+		r.mu.Lock()
+		// Expired entries are dropped as new ones are added
+		for key, entry := range r.cache {
+			if time.Now().After(entry.expires) {
+				delete(r.cache, key)
+			}
+		}
+		r.cache[cacheKey] = result
+		r.mu.Unlock()
+	}
+	return result.username, result.authenticated, result.allowed, nil
+}
+
+// review runs the TokenReview and SubjectAccessReview for a token
+func (r *KubernetesReviewer) review(token, verb string) (reviewResult, error) {
+	tokenReview, err := r.clientset.AuthenticationV1().TokenReviews().Create(context.Background(),
+		&authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}, metav1.CreateOptions{})
+	if err != nil {
+		return reviewResult{}, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !tokenReview.Status.Authenticated {
+		return reviewResult{}, nil
+	}
+
+	user := tokenReview.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	accessReview, err := r.clientset.AuthorizationV1().SubjectAccessReviews().Create(context.Background(),
+		&authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:    adminResourceGroup,
+				Resource: adminResource,
+				Verb:     verb,
+			},
+		}}, metav1.CreateOptions{})
+	if err != nil {
+		return reviewResult{}, fmt.Errorf("failed to review access of %s: %w", user.Username, err)
+	}
+
+	return reviewResult{
+		username:      user.Username,
+		authenticated: true,
+		allowed:       accessReview.Status.Allowed,
+	}, nil
+}
@@ -1,59 +1,300 @@
 package auth
 
 import (
-	"os"
-	"strings"
+	"errors"
+	"fmt"
+	"log"
 
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
-// AdminAuthMiddleware creates a middleware for admin authentication
-func AdminAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		adminKey := getEnvOrDefault("ADMIN_API_KEY", "")
+// Roles set in the request context by AdminAuthMiddleware
+const (
+	ContextRole   = "role"
+	RoleAdmin     = "admin"
+	RoleTeamAdmin = "team-admin"
+)
+
+// TeamTokenResolver resolves a team-admin token to the team it is scoped to
+type TeamTokenResolver interface {
+	ResolveTeamAdminToken(token string) (string, error)
+}
+
+// teamAdminRoutes are the admin routes open to team-admin tokens. Routes with
+// a :team_id parameter must also match the token's team; key routes check
+// the key's team in their handlers.
+var teamAdminRoutes = map[string]bool{
+	"GET /teams/:team_id":                       true,
+	"GET /teams/:team_id/members":               true,
+	"GET /teams/:team_id/models":                true,
+	"GET /teams/:team_id/policies":              true,
+	"GET /teams/:team_id/provisioning":          true,
+	"POST /teams/:team_id/invites":              true,
+	"GET /teams/:team_id/invites":               true,
+	"DELETE /teams/:team_id/invites/:invite_id": true,
+	"POST /teams/:team_id/keys":                 true,
+	"GET /teams/:team_id/keys":                  true,
+	"GET /teams/:team_id/usage":                 true,
+	"GET /teams/:team_id/usage/live":            true,
+	"GET /keys/:key_name":                       true,
+	"PATCH /keys/:key_name":                     true,
+	"DELETE /keys/:key_name":                    true,
+	"POST /keys/:key_name/rotate":               true,
+	"GET /models":                               true,
+	"GET /discover_endpoint":                    true,
+	"GET /whoami":                               true,
+	"POST /auth/session":                        true,
+	"POST /auth/refresh":                        true,
+	"POST /auth/logout":                         true,
+}
+
+// AdminAuthConfig holds the credentials AdminAuthMiddleware accepts. Every
+// field but Headers may be nil.
+type AdminAuthConfig struct {
+	// The platform admin key and named admin credentials
+	AdminKey    *AdminKey
+	Credentials *AdminCredentials
+	// Read-only viewer keys
+	ViewerKeys *ViewerKeys
+	// Authenticates admins by their Kubernetes identity instead of the admin
+	// key, named credentials and viewer keys
+	Reviewer *KubernetesReviewer
+	// JWTs from its issuer grant admin, viewer or team-admin access by their
+	// claims
+	OIDC *OIDCVerifier
+	// Team-admin tokens, restricted to their own team's routes
+	TeamTokens TeamTokenResolver
+	// Counts failed authentications per source and slows down sources that
+	// keep failing
+	Failures *FailureTracker
+	// A client certificate with an allowed SAN grants admin access without
+	// any header
+	CertIdentities *ClientCertIdentities
+	// Session tokens grant the access they were issued for
+	Sessions *Sessions
+	// Where else than a prefixed Authorization header the credential may be
+	// presented
+	Headers *CredentialHeaders
+}
 
+// AdminAuthMiddleware creates a middleware for admin authentication with the
+// credentials in config. Viewers may only make read requests.
+func AdminAuthMiddleware(config AdminAuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		// If no admin key is set, allow access (backward compatibility)
-		if adminKey == "" {
+		if config.Reviewer == nil && config.OIDC == nil && !config.AdminKey.Configured() && !config.Credentials.Configured() && !config.ViewerKeys.Configured() && !config.CertIdentities.Configured() {
 			c.Next()
 			return
 		}
 
-		// Check Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+		// Services presenting an allowed client certificate need no key
+		if san, ok := config.CertIdentities.Match(c.Request.TLS); ok {
+			c.Set(ContextRole, RoleAdmin)
+			c.Set(ContextCredential, "cert:"+san)
+			c.Next()
 			return
 		}
 
-		// Support both "Bearer" and "ADMIN" prefixes
-		var providedKey string
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			providedKey = strings.TrimPrefix(authHeader, "Bearer ")
-		} else if strings.HasPrefix(authHeader, "ADMIN ") {
-			providedKey = strings.TrimPrefix(authHeader, "ADMIN ")
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format. Use: Authorization: ADMIN <key>"})
+		// Any role set means the request authenticated, even if it was then
+		// refused for its role
+		defer func() {
+			if _, ok := c.Get(ContextRole); ok {
+				config.Failures.Succeed(c.ClientIP())
+			}
+		}()
+
+		// Support both "Bearer" and "ADMIN" prefixes, and the custom header
+		providedKey, err := config.Headers.Extract(c, "Bearer ", "ADMIN ")
+		if errors.Is(err, ErrCredentialMissing) {
+			unauthorized(c, config.Failures, gin.H{"error": config.Headers.missingCredentialMessage()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			unauthorized(c, config.Failures, gin.H{"error": "Invalid authorization format. Use: Authorization: ADMIN <key>"})
 			c.Abort()
 			return
 		}
 
+		if config.Sessions.Issues(providedKey) {
+			sessionAuth(c, config.Sessions, providedKey, config.Failures)
+			return
+		}
+		if config.OIDC.Issues(providedKey) {
+			oidcAuth(c, config.OIDC, providedKey, config.Failures)
+			return
+		}
+
+		if config.Reviewer != nil {
+			username, authenticated, allowed, err := config.Reviewer.Review(providedKey, adminVerb(c.Request.Method))
+			if err != nil {
+				log.Printf("Warning: Failed to review admin token: %v", err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to verify Kubernetes identity"})
+				c.Abort()
+				return
+			}
+			if authenticated && !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("User %s is not allowed to %s %s.%s",
+					username, adminVerb(c.Request.Method), adminResource, adminResourceGroup), "code": "role_insufficient"})
+				c.Abort()
+				return
+			}
+			if authenticated {
+				c.Set(ContextRole, RoleAdmin)
+				c.Set(ContextCredential, "kubernetes:"+username)
+				c.Next()
+				return
+			}
+		}
+
 		// Verify admin key
-		if providedKey != adminKey {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin key"})
+		if config.Reviewer == nil && config.AdminKey.Matches(providedKey) {
+			c.Set(ContextRole, RoleAdmin)
+			c.Set(ContextCredential, CredentialBootstrap)
+			c.Next()
+			return
+		}
+		if name, ok := config.Credentials.Match(providedKey); ok && config.Reviewer == nil {
+			c.Set(ContextRole, RoleAdmin)
+			c.Set(ContextCredential, name)
+			c.Next()
+			return
+		}
+		if identity, ok := config.ViewerKeys.Match(providedKey); ok && config.Reviewer == nil {
+			viewerAuth(c, identity)
+			return
+		}
+
+		// Fall back to a team-admin token
+		if config.TeamTokens == nil {
+			unauthorized(c, config.Failures, gin.H{"error": "Invalid admin key"})
 			c.Abort()
 			return
 		}
+		teamID, err := config.TeamTokens.ResolveTeamAdminToken(providedKey)
+		if err != nil {
+			unauthorized(c, config.Failures, gin.H{"error": "Invalid admin key"})
+			c.Abort()
+			return
+		}
+
+		teamAdminAuth(c, []string{teamID})
+	}
+}
+
+// sessionAuth authenticates a request by a session token, granting the role
+// and teams it was issued for
+func sessionAuth(c *gin.Context, sessions *Sessions, token string, failures *FailureTracker) {
+	claims, err := sessions.Verify(token)
+	if err != nil {
+		unauthorized(c, failures, gin.H{"error": err.Error(), "code": TokenErrorCode(err)})
+		c.Abort()
+		return
+	}
+
+	c.Set(ContextSession, claims)
+	credential := "session:" + claims.Subject
+	switch claims.Role {
+	case RoleAdmin:
+		c.Set(ContextRole, RoleAdmin)
+		c.Set(ContextCredential, credential)
+		c.Next()
+	case RoleViewer:
+		viewerAuth(c, credential)
+	case RoleTeamAdmin:
+		c.Set(ContextCredential, credential)
+		teamAdminAuth(c, claims.TeamIDs)
+	default:
+		unauthorized(c, failures, gin.H{"error": "Session token has no known role", "code": TokenErrorCode(ErrTokenMalformed)})
+		c.Abort()
+	}
+}
+
+// oidcAuth authenticates a request by a JWT from the OIDC issuer. Members of
+// the admin group are admins and members of the viewer group viewers;
+// otherwise the token's team-admin claim scopes it to those teams' routes.
+func oidcAuth(c *gin.Context, oidc *OIDCVerifier, token string, failures *FailureTracker) {
+	claims, err := oidc.Verify(token)
+	if errors.Is(err, ErrJWKSUnavailable) {
+		log.Printf("Warning: Failed to verify OIDC token: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Identity provider is unavailable", "code": TokenErrorCode(err)})
+		c.Abort()
+		return
+	}
+	if err != nil {
+		unauthorized(c, failures, gin.H{"error": err.Error(), "code": TokenErrorCode(err)})
+		c.Abort()
+		return
+	}
 
+	if oidc.IsAdmin(claims) {
+		c.Set(ContextRole, RoleAdmin)
+		c.Set(ContextCredential, "oidc:"+claims.Subject)
 		c.Next()
+		return
 	}
+	if oidc.IsViewer(claims) {
+		viewerAuth(c, "oidc:"+claims.Subject)
+		return
+	}
+	if len(claims.AdminTeams) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Token does not grant admin, viewer or team admin access"})
+		c.Abort()
+		return
+	}
+	c.Set(ContextCredential, "oidc:"+claims.Subject)
+	teamAdminAuth(c, claims.AdminTeams)
 }
 
-// getEnvOrDefault gets environment variable or returns default value
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// teamAdminAuth restricts a team-admin request to the team routes of the
+// teams it was granted. Routes without a team are resolved to the only team
+// granted, so a token granting several teams must use team routes.
+func teamAdminAuth(c *gin.Context, teamIDs []string) {
+	if !teamAdminRoutes[c.Request.Method+" "+c.FullPath()] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Team admin tokens cannot access this endpoint"})
+		c.Abort()
+		return
+	}
+
+	teamID := ""
+	if routeTeam := c.Param("team_id"); routeTeam != "" {
+		for _, id := range teamIDs {
+			if id == routeTeam {
+				teamID = id
+			}
+		}
+	} else if len(teamIDs) == 1 {
+		teamID = teamIDs[0]
+	}
+	if teamID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Team admin token is not valid for this team"})
+		c.Abort()
+		return
 	}
-	return defaultValue
-}
\ No newline at end of file
+
+	c.Set(ContextRole, RoleTeamAdmin)
+	c.Set(ContextTeamID, teamID)
+	c.Next()
+}
+
+// Identity names who a request was authenticated as, or is empty when it was
+// not: the credential, or the team of a team-admin token
+func Identity(c *gin.Context) string {
+	if credential := c.GetString(ContextCredential); credential != "" {
+		return credential
+	}
+	if c.GetString(ContextRole) == RoleTeamAdmin {
+		return "team-admin:" + c.GetString(ContextTeamID)
+	}
+	return ""
+}
+
+// ScopedTeam returns the team a request is restricted to when it was
+// authenticated with a team-admin token
+func ScopedTeam(c *gin.Context) (string, bool) {
+	if c.GetString(ContextRole) != RoleTeamAdmin {
+		return "", false
+	}
+	return c.GetString(ContextTeamID), true
+}
@@ -0,0 +1,483 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons a JWT is rejected, each answered with its own error code
+var (
+	ErrTokenMalformed   = errors.New("token is malformed")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrTokenSignature   = errors.New("token signature is invalid")
+	ErrTokenIssuer      = errors.New("token issuer is not trusted")
+	ErrTokenAudience    = errors.New("token audience does not match")
+	// The signing keys could not be fetched, which is not the token's fault
+	ErrJWKSUnavailable = errors.New("identity provider keys are unavailable")
+	// The session token was logged out
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// The session cannot be refreshed any further
+	ErrSessionMaxAge = errors.New("session has reached its maximum age")
+)
+
+// TokenErrorCode returns the error code reported for a rejected token
+func TokenErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return "token_expired"
+	case errors.Is(err, ErrTokenNotYetValid):
+		return "token_not_yet_valid"
+	case errors.Is(err, ErrTokenSignature):
+		return "invalid_signature"
+	case errors.Is(err, ErrTokenIssuer):
+		return "invalid_issuer"
+	case errors.Is(err, ErrTokenAudience):
+		return "invalid_audience"
+	case errors.Is(err, ErrJWKSUnavailable):
+		return "jwks_unavailable"
+	case errors.Is(err, ErrTokenRevoked):
+		return "token_revoked"
+	case errors.Is(err, ErrSessionMaxAge):
+		return "session_max_age"
+	}
+	return "malformed_token"
+}
+
+// jwksMinRefresh keeps tokens with unknown key IDs from making every request
+// fetch the keys again
+const jwksMinRefresh = time.Minute
+
+// jwksMaxBackoff caps the wait between attempts while the provider fails
+const jwksMaxBackoff = 15 * time.Minute
+
+// OIDCConfig configures JWT validation against an OpenID Connect provider
+type OIDCConfig struct {
+	IssuerURL string
+	Audience  string
+	// Members of AdminGroup in GroupsClaim are platform admins, members of
+	// ViewerGroup read-only viewers
+	AdminGroup  string
+	ViewerGroup string
+	GroupsClaim string
+	// TeamAdminClaim lists the teams the subject is a team admin of
+	TeamAdminClaim string
+	// How often the signing keys are fetched again
+	JWKSRefreshInterval time.Duration
+}
+
+// OIDCClaims are the claims of a validated token the key manager acts on
+type OIDCClaims struct {
+	Subject    string
+	Groups     []string
+	AdminTeams []string
+}
+
+// OIDCVerifier validates JWTs issued by an OpenID Connect provider. Its
+// signing keys are discovered and fetched on first use, then cached and
+// fetched again periodically or when a token names an unknown key, so key
+// rotation needs no restart and an unreachable provider only fails the
+// requests that carry its tokens.
+type OIDCVerifier struct {
+	config     OIDCConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	jwksURL   string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// The last attempt, successful or not, and the failures since a success
+	attemptedAt time.Time
+	failures    int
+	fetchErr    error
+	// Closed when the fetch under way completes, nil when none is
+	fetching chan struct{}
+}
+
+// NewOIDCVerifier creates a verifier for an issuer and audience
+func NewOIDCVerifier(config OIDCConfig) (*OIDCVerifier, error) {
+	if config.IssuerURL == "" || config.Audience == "" {
+		return nil, fmt.Errorf("OIDC needs both an issuer URL and an audience")
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.JWKSRefreshInterval <= 0 {
+		config.JWKSRefreshInterval = time.Hour
+	}
+	config.IssuerURL = strings.TrimSuffix(config.IssuerURL, "/")
+	return &OIDCVerifier{
+		config:     config,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		keys:       make(map[string]crypto.PublicKey),
+	}, nil
+}
+
+// Issues reports whether a token claims to come from this verifier's issuer.
+// The claim is not trusted until Verify checks the signature; it only routes
+// the token to OIDC validation rather than other admin credentials.
+func (v *OIDCVerifier) Issues(token string) bool {
+	if v == nil {
+		return false
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return false
+	}
+	return strings.TrimSuffix(claims.Issuer, "/") == v.config.IssuerURL
+}
+
+// Verify checks a token's signature, issuer, audience and validity period and
+// returns its claims
+func (v *OIDCVerifier) Verify(token string) (*OIDCClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	key, err := v.signingKey(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrTokenNotYetValid
+	}
+	if issuer, _ := claims["iss"].(string); strings.TrimSuffix(issuer, "/") != v.config.IssuerURL {
+		return nil, ErrTokenIssuer
+	}
+	if !containsString(claimStrings(claims, "aud"), v.config.Audience) {
+		return nil, ErrTokenAudience
+	}
+
+	subject, _ := claims["sub"].(string)
+	result := &OIDCClaims{
+		Subject: subject,
+		Groups:  claimStrings(claims, v.config.GroupsClaim),
+	}
+	if v.config.TeamAdminClaim != "" {
+		result.AdminTeams = claimStrings(claims, v.config.TeamAdminClaim)
+	}
+	return result, nil
+}
+
+// IsAdmin reports whether validated claims grant platform admin access
+func (v *OIDCVerifier) IsAdmin(claims *OIDCClaims) bool {
+	return v.config.AdminGroup != "" && containsString(claims.Groups, v.config.AdminGroup)
+}
+
+// IsViewer reports whether validated claims grant read-only access
+func (v *OIDCVerifier) IsViewer(claims *OIDCClaims) bool {
+	return v.config.ViewerGroup != "" && containsString(claims.Groups, v.config.ViewerGroup)
+}
+
+// signingKey returns the key a token was signed with, fetching the keys
+// again when they are due or the key ID is unknown. One fetch runs at a time,
+// outside the lock, and attempts are spaced by at least jwksMinRefresh, more
+// after failures, so tokens naming unknown keys cannot hammer the provider.
+func (v *OIDCVerifier) signingKey(keyID string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	key, known := v.cachedKey(keyID)
+	due := time.Since(v.fetchedAt) > v.config.JWKSRefreshInterval
+	fetching := v.fetching
+	if fetching == nil && (!known || due) && time.Since(v.attemptedAt) >= v.retryDelay() {
+		fetching = make(chan struct{})
+		v.fetching = fetching
+		v.attemptedAt = time.Now()
+		go v.refresh(v.jwksURL, fetching)
+	}
+	v.mu.Unlock()
+
+	// Keys already fetched stay in use while the provider is down or a
+	// refresh is under way
+	if known {
+		return key, nil
+	}
+	if fetching != nil {
+		<-fetching
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, known = v.cachedKey(keyID); known {
+		return key, nil
+	}
+	if v.fetchErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrJWKSUnavailable, v.fetchErr)
+	}
+	return nil, ErrTokenSignature
+}
+
+// cachedKey looks a key ID up in the fetched keys. A token without one may
+// use the provider's only key.
+func (v *OIDCVerifier) cachedKey(keyID string) (crypto.PublicKey, bool) {
+	if keyID == "" && len(v.keys) == 1 {
+		for _, only := range v.keys {
+			return only, true
+		}
+	}
+	key, known := v.keys[keyID]
+	return key, known
+}
+
+// retryDelay is how long after the last attempt the keys may be fetched
+// again: jwksMinRefresh, doubling with each further consecutive failure up to
+// jwksMaxBackoff
+func (v *OIDCVerifier) retryDelay() time.Duration {
+	delay := jwksMinRefresh
+	for i := 1; i < v.failures && delay < jwksMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > jwksMaxBackoff {
+		delay = jwksMaxBackoff
+	}
+	return delay
+}
+
+// refresh fetches the keys and records the outcome, then releases the
+// requests waiting on done
+func (v *OIDCVerifier) refresh(jwksURL string, done chan struct{}) {
+	jwksURL, keys, err := v.fetchKeys(jwksURL)
+
+	v.mu.Lock()
+	if err != nil {
+		v.failures++
+		v.fetchErr = err
+	} else {
+		v.jwksURL = jwksURL
+		v.keys = keys
+		v.fetchedAt = time.Now()
+		v.failures = 0
+		v.fetchErr = nil
+	}
+	v.fetching = nil
+	v.mu.Unlock()
+	close(done)
+}
+
+// fetchKeys discovers the JWKS URL unless it is known and fetches the
+// signing keys
+func (v *OIDCVerifier) fetchKeys(jwksURL string) (string, map[string]crypto.PublicKey, error) {
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(v.config.IssuerURL+"/.well-known/openid-configuration", &discovery); err != nil {
+			return "", nil, err
+		}
+		if discovery.JWKSURI == "" {
+			return "", nil, fmt.Errorf("provider configuration has no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(jwksURL, &jwks); err != nil {
+		return "", nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	return jwksURL, keys, nil
+}
+
+// getJSON fetches and decodes a JSON document from the provider
+func (v *OIDCVerifier) getJSON(url string, out interface{}) error {
+	resp, err := v.httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	return nil
+}
+
+// jsonWebKey is an RSA or EC public key of a JWKS
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// publicKey decodes a JWK into a public key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.KeyType)
+}
+
+// verifySignature checks a JWS signature with the key and algorithm named in
+// the token header. The algorithm must match the key's type, so a token
+// cannot pick a weaker check.
+func verifySignature(algorithm string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch algorithm {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return ErrTokenSignature
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "RS") || rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) != nil {
+			return ErrTokenSignature
+		}
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(algorithm, "ES") || len(signature) != 2*size {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(publicKey, digest, r, s) {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrTokenSignature
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// claimStrings reads a claim holding a string or a list of strings. A dotted
+// name reads a nested claim, such as realm_access.roles.
+func claimStrings(claims map[string]interface{}, name string) []string {
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}
+	case []interface{}:
+		values := make([]string, 0, len(typed))
+		for _, item := range typed {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
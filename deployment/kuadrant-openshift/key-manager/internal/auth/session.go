@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// SessionIssuer is the issuer of session tokens, which tells them apart
+// from OIDC tokens
+const SessionIssuer = "maas-key-manager"
+
+// ContextSession holds the verified claims of a session token
+const ContextSession = "session"
+
+const (
+	// sessionKeyCheckInterval bounds how often the signing key file is
+	// checked for a rotated key
+	sessionKeyCheckInterval = 10 * time.Second
+	// minSessionKeyLength is the shortest signing key accepted, in bytes
+	minSessionKeyLength = 32
+)
+
+// SessionClaims are the claims of a session token
+type SessionClaims struct {
+	ID        string   `json:"jti"`
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Role      string   `json:"role"`
+	TeamIDs   []string `json:"teams,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	// AuthTime is when the credential the session started from was
+	// presented, kept across refreshes
+	AuthTime int64 `json:"auth_time,omitempty"`
+}
+
+// AuthenticatedAt is when the session's credential was presented; tokens
+// issued without auth_time fall back to their issue time
+func (c *SessionClaims) AuthenticatedAt() time.Time {
+	if c.AuthTime == 0 {
+		return time.Unix(c.IssuedAt, 0)
+	}
+	return time.Unix(c.AuthTime, 0)
+}
+
+// Sessions issues and verifies short-lived HS256 session tokens, so browser
+// clients such as the GUI never hold a long-lived credential. The signing
+// key is read from a file, typically a mounted Secret, and reloaded when it
+// changes; tokens signed with the previous key stay valid for the grace
+// window. Refreshing never extends a session past maxAge from the original
+// authentication, so the credential has to be presented again. Logged-out
+// tokens are denied until they expire; the denylist is held in memory, so
+// with several replicas a logged-out token stays valid on the others until
+// it expires. A nil Sessions issues nothing.
+type Sessions struct {
+	keyFile  string
+	lifetime time.Duration
+	maxAge   time.Duration
+	grace    time.Duration
+
+	mu            sync.Mutex
+	key           []byte
+	keyModTime    time.Time
+	lastCheck     time.Time
+	previousKey   []byte
+	previousUntil time.Time
+	revoked       map[string]time.Time
+}
+
+// NewSessions loads the signing key and creates the session issuer
+func NewSessions(keyFile string, lifetime, maxAge, grace time.Duration) (*Sessions, error) {
+	s := &Sessions{
+		keyFile:  keyFile,
+		lifetime: lifetime,
+		maxAge:   maxAge,
+		grace:    grace,
+		revoked:  make(map[string]time.Time),
+	}
+	key, modTime, err := s.readKey()
+	if err != nil {
+		return nil, err
+	}
+	s.key = key
+	s.keyModTime = modTime
+	s.lastCheck = time.Now()
+	return s, nil
+}
+
+// readKey reads the signing key file
+func (s *Sessions) readKey() ([]byte, time.Time, error) {
+	info, err := os.Stat(s.keyFile)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat session signing key: %w", err)
+	}
+	data, err := os.ReadFile(s.keyFile)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read session signing key: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) < minSessionKeyLength {
+		return nil, time.Time{}, fmt.Errorf("session signing key must be at least %d bytes", minSessionKeyLength)
+	}
+	return key, info.ModTime(), nil
+}
+
+// keys returns the current signing key and, within the grace window, the
+// previous one, loading a rotated key first
+func (s *Sessions) keys() ([]byte, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastCheck) >= sessionKeyCheckInterval {
+		s.lastCheck = now
+		if info, err := os.Stat(s.keyFile); err == nil && !info.ModTime().Equal(s.keyModTime) {
+			key, modTime, err := s.readKey()
+			if err != nil {
+				log.Printf("Warning: Failed to reload session signing key, keeping the previous one: %v", err)
+			} else if !hmac.Equal(key, s.key) {
+				s.previousKey = s.key
+				s.previousUntil = now.Add(s.grace)
+				s.key = key
+				s.keyModTime = modTime
+				log.Printf("Reloaded session signing key from %s", s.keyFile)
+			} else {
+				s.keyModTime = modTime
+			}
+		}
+	}
+	if s.previousKey != nil && now.After(s.previousUntil) {
+		s.previousKey = nil
+	}
+	return s.key, s.previousKey
+}
+
+// Issue signs a session token for an identity authenticated at authTime. The
+// token expires no later than maxAge after authTime, and none is issued once
+// that has passed.
+func (s *Sessions) Issue(subject, role string, teamIDs []string, authTime time.Time) (string, *SessionClaims, error) {
+	now := time.Now()
+	sessionEnd := authTime.Add(s.maxAge)
+	if !now.Before(sessionEnd) {
+		return "", nil, ErrSessionMaxAge
+	}
+	expiresAt := now.Add(s.lifetime)
+	if expiresAt.After(sessionEnd) {
+		expiresAt = sessionEnd
+	}
+
+	id, err := fips.RandomHex(16)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	claims := &SessionClaims{
+		ID:        id,
+		Issuer:    SessionIssuer,
+		Subject:   subject,
+		Role:      role,
+		TeamIDs:   teamIDs,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		AuthTime:  authTime.Unix(),
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	key, _ := s.keys()
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sessionSignature(key, signed)), claims, nil
+}
+
+// Issues reports whether a token claims to be a session token, without
+// verifying it
+func (s *Sessions) Issues(token string) bool {
+	if s == nil {
+		return false
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	return decodeSegment(parts[1], &claims) == nil && claims.Issuer == SessionIssuer
+}
+
+// Verify checks a session token's signature, expiry and revocation and
+// returns its claims
+func (s *Sessions) Verify(token string) (*SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if header.Algorithm != "HS256" {
+		return nil, ErrTokenSignature
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	signed := parts[0] + "." + parts[1]
+	key, previousKey := s.keys()
+	if !hmac.Equal(signature, sessionSignature(key, signed)) &&
+		(previousKey == nil || !hmac.Equal(signature, sessionSignature(previousKey, signed))) {
+		return nil, ErrTokenSignature
+	}
+
+	var claims SessionClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if claims.Issuer != SessionIssuer {
+		return nil, ErrTokenIssuer
+	}
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrTokenExpired
+	}
+
+	s.mu.Lock()
+	_, revoked := s.revoked[claims.ID]
+	s.mu.Unlock()
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return &claims, nil
+}
+
+// Revoke denies a session token until it expires
+func (s *Sessions) Revoke(claims *SessionClaims) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[claims.ID] = time.Unix(claims.ExpiresAt, 0)
+}
+
+// sessionSignature is the HMAC-SHA256 of a token's header and payload
+func sessionSignature(key []byte, signed string) []byte {
+	return fips.HMACSHA256(key, []byte(signed))
+}
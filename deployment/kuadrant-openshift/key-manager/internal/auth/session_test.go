@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestSessions returns sessions signed with a temporary key
+func newTestSessions(t *testing.T, lifetime, maxAge time.Duration) *Sessions {
+	t.Helper()
+	keyFile := filepath.Join(t.TempDir(), "session.key")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0o600); err != nil {
+		t.Fatalf("failed to write signing key: %v", err)
+	}
+	s, err := NewSessions(keyFile, lifetime, maxAge, time.Minute)
+	if err != nil {
+		t.Fatalf("NewSessions() = %v", err)
+	}
+	return s
+}
+
+func TestSessionIssueCapsExpiryAtMaxAge(t *testing.T) {
+	s := newTestSessions(t, 15*time.Minute, time.Hour)
+
+	tests := []struct {
+		name     string
+		authAge  time.Duration
+		wantLeft time.Duration
+		wantErr  error
+	}{
+		{name: "fresh login", authAge: 0, wantLeft: 15 * time.Minute},
+		{name: "refresh within max age", authAge: 30 * time.Minute, wantLeft: 15 * time.Minute},
+		{name: "refresh near max age", authAge: 55 * time.Minute, wantLeft: 5 * time.Minute},
+		{name: "refresh past max age", authAge: time.Hour, wantErr: ErrSessionMaxAge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authTime := time.Now().Add(-tt.authAge)
+			token, claims, err := s.Issue("admin", "admin", nil, authTime)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Issue() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if claims.AuthTime != authTime.Unix() {
+				t.Errorf("auth_time = %d, want %d", claims.AuthTime, authTime.Unix())
+			}
+			left := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second
+			if diff := left - tt.wantLeft; diff < -time.Second || diff > time.Second {
+				t.Errorf("token valid for %v, want %v", left, tt.wantLeft)
+			}
+			if _, err := s.Verify(token); err != nil {
+				t.Errorf("Verify() = %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionRefreshKeepsAuthTime(t *testing.T) {
+	s := newTestSessions(t, 15*time.Minute, time.Hour)
+	authTime := time.Now().Add(-50 * time.Minute)
+
+	token, _, err := s.Issue("admin", "admin", nil, authTime)
+	if err != nil {
+		t.Fatalf("Issue() = %v", err)
+	}
+	claims, err := s.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+	if got := claims.AuthenticatedAt(); got.Unix() != authTime.Unix() {
+		t.Fatalf("AuthenticatedAt() = %v, want %v", got, authTime)
+	}
+
+	// A refresh carries the original authentication time along
+	_, refreshed, err := s.Issue(claims.Subject, claims.Role, claims.TeamIDs, claims.AuthenticatedAt())
+	if err != nil {
+		t.Fatalf("refresh Issue() = %v", err)
+	}
+	if refreshed.AuthTime != authTime.Unix() {
+		t.Errorf("refreshed auth_time = %d, want %d", refreshed.AuthTime, authTime.Unix())
+	}
+	if want := authTime.Add(time.Hour).Unix(); refreshed.ExpiresAt != want {
+		t.Errorf("refreshed exp = %d, want the max age %d", refreshed.ExpiresAt, want)
+	}
+}
+
+func TestSessionClaimsWithoutAuthTime(t *testing.T) {
+	claims := &SessionClaims{IssuedAt: 1700000000}
+	if got := claims.AuthenticatedAt().Unix(); got != claims.IssuedAt {
+		t.Errorf("AuthenticatedAt() = %d, want the issue time %d", got, claims.IssuedAt)
+	}
+}
@@ -0,0 +1,106 @@
+package budget
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/usage"
+)
+
+// Enforcer periodically accrues period token use from usage metrics and
+// restricts teams whose spend, accrued from priced usage records, exceeds
+// their monthly budget
+type Enforcer struct {
+	teamMgr          *teams.Manager
+	collector        *usage.Collector
+	mode             string
+	overBudgetPolicy string
+}
+
+// NewEnforcer creates a new budget enforcer
+func NewEnforcer(teamMgr *teams.Manager, collector *usage.Collector, mode, overBudgetPolicy string) (*Enforcer, error) {
+	switch mode {
+	case teams.BudgetModeSuspend:
+	case teams.BudgetModeDowngrade:
+		if overBudgetPolicy == "" {
+			return nil, fmt.Errorf("an over-budget policy is required in %s mode", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown budget enforcement mode %s", mode)
+	}
+
+	return &Enforcer{
+		teamMgr:          teamMgr,
+		collector:        collector,
+		mode:             mode,
+		overBudgetPolicy: overBudgetPolicy,
+	}, nil
+}
+
+// Start checks budgets periodically in the background
+func (e *Enforcer) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			e.Check()
+		}
+	}()
+}
+
+// Check accrues usage for every metered team and enforces exceeded budgets
+func (e *Enforcer) Check() {
+	meteredTeams, err := e.teamMgr.ListMeteredTeams()
+	if err != nil {
+		log.Printf("Warning: Budget check failed: %v", err)
+		return
+	}
+
+	for _, team := range meteredTeams {
+		if err := e.checkTeam(team); err != nil {
+			log.Printf("Warning: Budget check failed for team %s: %v", team.TeamID, err)
+		}
+	}
+}
+
+// checkTeam runs the budget cycle for a single team. Teams without a budget
+// only have their period token use accrued for their tier's allowance.
+func (e *Enforcer) checkTeam(team teams.MeteredTeam) error {
+	status, err := e.teamMgr.GetBudgetStatus(team.TeamID)
+	if err != nil {
+		return err
+	}
+
+	if teams.BillingPeriodElapsed(team.PeriodStart, time.Now()) {
+		if status, err = e.teamMgr.ResetBudgetPeriod(team.TeamID); err != nil {
+			return err
+		}
+	}
+
+	// Usage under the over-budget policy is not counted against the team
+	if status != nil && status.Enforcement != "" {
+		return nil
+	}
+
+	teamUsage, err := e.collector.GetTeamUsage(team.TeamID, team.Policy)
+	if err != nil {
+		return fmt.Errorf("failed to collect usage: %w", err)
+	}
+
+	status, err = e.teamMgr.RecordTokenUsage(team.TeamID, teamUsage.TotalTokenUsage)
+	if err != nil {
+		return err
+	}
+
+	if status != nil && status.Exceeded {
+		return e.teamMgr.EnforceBudget(team.TeamID, e.mode, e.overBudgetPolicy)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkInterval bounds how often handshakes look for new certificate files
+const checkInterval = 10 * time.Second
+
+// Reloader serves a TLS certificate from files and loads them again when
+// they change, such as when cert-manager renews a mounted Secret, so
+// rotation needs no restart. A renewal that cannot be loaded, for example
+// while only one of the files has been replaced, keeps the previous
+// certificate in use.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+// NewReloader loads a certificate and key, failing if they cannot be used
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the certificate and key files
+func (r *Reloader) load() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat key: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= checkInterval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				log.Printf("Warning: Failed to reload TLS certificate, keeping the previous one: %v", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// changed reports whether either file was modified since it was loaded
+func (r *Reloader) changed() bool {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)
+}
+
+// LoadCertPool reads PEM CA certificates, such as those trusted to issue
+// client certificates
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// PoolReloader serves CA certificates from a file and loads it again when it
+// changes, so the CAs trusted to issue client certificates can be rotated
+// without a restart. A file that cannot be loaded keeps the previous CAs.
+type PoolReloader struct {
+	caFile string
+
+	mu        sync.Mutex
+	pool      *x509.CertPool
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewPoolReloader loads CA certificates, failing if there are none
+func NewPoolReloader(caFile string) (*PoolReloader, error) {
+	r := &PoolReloader{caFile: caFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the CA file
+func (r *PoolReloader) load() error {
+	info, err := os.Stat(r.caFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat CA file: %w", err)
+	}
+	pool, err := LoadCertPool(r.caFile)
+	if err != nil {
+		return err
+	}
+	r.pool = pool
+	r.modTime = info.ModTime()
+	return nil
+}
+
+// Pool returns the current CA certificates
+func (r *PoolReloader) Pool() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= checkInterval {
+		r.lastCheck = time.Now()
+		if info, err := os.Stat(r.caFile); err == nil && !info.ModTime().Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				log.Printf("Warning: Failed to reload CA file, keeping the previous CAs: %v", err)
+			} else {
+				log.Printf("Reloaded CA certificates from %s", r.caFile)
+			}
+		}
+	}
+	return r.pool
+}
@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Config holds application configuration
 type Config struct {
@@ -8,6 +13,33 @@ type Config struct {
 	Port        string
 	ServiceName string
 
+	// Largest request body accepted, 0 is unlimited
+	MaxRequestBodyBytes int
+	// Networks the admin routes are reachable from, all when empty
+	AdminAllowedCIDRs []string
+	// Proxies whose X-Forwarded-For entries are trusted, none when empty
+	TrustedProxies []string
+	// Restrict crypto to FIPS-approved algorithms and a validated module
+	FIPSMode bool
+
+	// TLS certificate and key, reloaded when they change; both unset serves
+	// plain HTTP
+	TLSCertFile string
+	TLSKeyFile  string
+	// CAs whose client certificates the admin routes require
+	TLSClientCAFile string
+	// Client certificate SANs authenticated as admins; setting any makes
+	// certificates optional, with keys as the fallback
+	ClientCertAllowedSANs []string
+
+	// Session tokens for the GUI, off without a signing key file. Refreshing
+	// stops at the max age, counted from the original authentication. Tokens
+	// signed with a rotated-out key are accepted for the grace window.
+	SessionSigningKeyFile string
+	SessionLifetime       time.Duration
+	SessionMaxAge         time.Duration
+	SessionKeyGrace       time.Duration
+
 	// Kubernetes configuration
 	KeyNamespace        string
 	SecretSelectorLabel string
@@ -16,10 +48,158 @@ type Config struct {
 	// Kuadrant configuration
 	TokenRateLimitPolicyName string
 	AuthPolicyName           string
+	DefaultLimitScope        string
+	UnlimitedTierMode        string
+	PolicyRetryAttempts      int
+	PolicyApplyMode          string
+	GatewayName              string
+	GatewayNamespace         string
+
+	// Endpoint discovery configuration
+	DiscoveryRoute           string
+	DiscoveryRefreshInterval time.Duration
+
+	// Limitador configuration
+	LimitadorURL       string
+	LimitadorNamespace string
+	// How often Limitador counters are collected for live usage
+	LiveUsageInterval time.Duration
+
+	// Prometheus serving gateway metrics for usage history, off when unset,
+	// and how long query results are reused
+	PrometheusURL      string
+	PrometheusCacheTTL time.Duration
 
 	// Default team configuration
 	CreateDefaultTeam bool
+	DefaultTeamTier   string
 	AdminAPIKey       string
+	// Hex SHA-256 of the admin key, configured instead of the key itself
+	AdminAPIKeySHA256 string
+	// Secret the admin key is loaded from, as namespace/name#key
+	AdminAPIKeySecretRef string
+	// How admins authenticate: static (admin key) or kubernetes (RBAC)
+	AdminAuthMode string
+	// How long a Kubernetes token and access review is reused
+	AdminAuthCacheTTL time.Duration
+	// Secret holding the hashes of named admin credentials
+	AdminCredentialsSecret string
+	// Leave admin routes open when no admin credential is configured,
+	// instead of generating an admin key; for development only
+	AllowUnauthenticatedAdmin bool
+	// Header credentials may be sent in instead of Authorization, empty to
+	// disable, and whether Authorization may hold a bare token
+	AuthHeaderName     string
+	AllowRawAuthHeader bool
+	// Static read-only keys
+	ViewerAPIKeys []string
+
+	// OIDC authentication of admins, enabled by the issuer URL
+	OIDCIssuerURL string
+	OIDCAudience  string
+	// Groups in OIDCGroupsClaim that grant admin and read-only access
+	OIDCAdminGroup  string
+	OIDCViewerGroup string
+	OIDCGroupsClaim string
+	// Claim listing the teams the subject is a team admin of
+	OIDCTeamAdminClaim string
+	// How often the provider's signing keys are fetched again
+	OIDCJWKSRefreshInterval time.Duration
+
+	// Key caps, 0 means unlimited
+	MaxKeysPerUser int
+	MaxKeysPerTeam int
+
+	// Key hashing algorithm: sha256, bcrypt or argon2id
+	KeyHashAlgo string
+	// Format of generated API keys, v1 (base64url) or v2 (checksummed)
+	KeyFormat string
+
+	// Self-service configuration
+	SelfServiceMaxKeysPerUser int
+
+	// Team invites, a cap of 0 means unlimited outstanding invites
+	MaxInvitesPerTeam int
+	InviteTTL         time.Duration
+
+	// Webhook configuration
+	WebhookURL    string
+	WebhookSecret string
+	// Accept webhook URLs without a signing secret
+	AllowUnsignedWebhooks bool
+
+	// Budget enforcement configuration
+	BudgetEnforcementMode string
+	BudgetOverPolicy      string
+	BudgetCheckInterval   time.Duration
+
+	// Price per 1K tokens charged for models without one in the pricing
+	// ConfigMap, the currencies prices may be set in, and how often that
+	// ConfigMap is reloaded
+	DefaultInputPer1K      float64
+	DefaultOutputPer1K     float64
+	DefaultCurrency        string
+	PricingCurrencies      []string
+	PricingRefreshInterval time.Duration
+
+	// MaaSTeam custom resource configuration
+	TeamCRDEnabled    bool
+	MigrateTeamsToCRD bool
+
+	// Kubernetes Events for team, policy and key lifecycle
+	EventsEnabled bool
+
+	// Audit log of admin operations: optional file, its rotation size and
+	// how many entries are kept for queries
+	AuditLogFile     string
+	AuditLogMaxBytes int
+	AuditLogRetained int
+
+	// Rate limits of the key manager's own API, 0 disables a limit
+	APIRateLimitPerIP         int
+	APIRateLimitPerCredential int
+	APIRateLimitBurst         int
+	// Consecutive 401s that lock an IP out, and the lockout's first and
+	// longest durations
+	AuthLockoutThreshold   int
+	AuthLockoutDuration    time.Duration
+	AuthLockoutMaxDuration time.Duration
+	// Consecutive failed admin authentications from one source that raise an
+	// alert, the delays of 401s from then on, and how long a source's failures
+	// are remembered
+	AuthFailureAlertThreshold int
+	AuthFailureBackoff        []string
+	AuthFailureWindow         time.Duration
+
+	// CORS for browser callers such as the management GUI, off while no
+	// origin is allowed
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSExposedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// Per-team key namespaces
+	AutoCreateTeamNamespaces bool
+	// Whether the key manager binds KeyNamespaceClusterRole to its service
+	// account in each team key namespace, instead of the operator
+	ManageKeyNamespaceAccess bool
+	KeyNamespaceClusterRole  string
+	ServiceAccountName       string
+	ServiceAccountNamespace  string
+
+	// Member removal and cleanup of the inactive keys it leaves behind
+	MemberRemovalMode          string
+	InactiveKeyRetention       time.Duration
+	InactiveKeyCleanupInterval time.Duration
+
+	// Interval of the policy drift reconciler, 0 disables it
+	PolicyReconcileInterval time.Duration
+
+	// Interval at which the served Kuadrant policy versions are re-discovered,
+	// 0 disables it
+	PolicyDiscoveryInterval time.Duration
 }
 
 // Load loads configuration from environment variables
@@ -29,6 +209,24 @@ func Load() *Config {
 		Port:        getEnvOrDefault("PORT", "8080"),
 		ServiceName: getEnvOrDefault("SERVICE_NAME", "key-manager"),
 
+		MaxRequestBodyBytes: getEnvIntOrDefault("MAX_REQUEST_BODY_BYTES", 1024*1024),
+		AdminAllowedCIDRs:   getEnvListOrDefault("ADMIN_ALLOWED_CIDRS", ""),
+		TrustedProxies:      getEnvListOrDefault("TRUSTED_PROXIES", ""),
+		FIPSMode:            getEnvOrDefault("FIPS_MODE", "false") == "true",
+
+		// TLS configuration
+		TLSCertFile:     getEnvOrDefault("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnvOrDefault("TLS_KEY_FILE", ""),
+		TLSClientCAFile: getEnvOrDefault("TLS_CLIENT_CA_FILE", ""),
+
+		ClientCertAllowedSANs: getEnvListOrDefault("CLIENT_CERT_ALLOWED_SANS", ""),
+
+		// Session tokens
+		SessionSigningKeyFile: getEnvOrDefault("SESSION_SIGNING_KEY_FILE", ""),
+		SessionLifetime:       getEnvDurationOrDefault("SESSION_LIFETIME", 15*time.Minute),
+		SessionMaxAge:         getEnvDurationOrDefault("SESSION_MAX_AGE", 12*time.Hour),
+		SessionKeyGrace:       getEnvDurationOrDefault("SESSION_KEY_GRACE", 15*time.Minute),
+
 		// Kubernetes configuration
 		KeyNamespace:        getEnvOrDefault("KEY_NAMESPACE", "llm"),
 		SecretSelectorLabel: getEnvOrDefault("SECRET_SELECTOR_LABEL", "kuadrant.io/apikeys-by"),
@@ -37,10 +235,132 @@ func Load() *Config {
 		// Kuadrant configuration
 		TokenRateLimitPolicyName: getEnvOrDefault("TOKEN_RATE_LIMIT_POLICY_NAME", "gateway-token-rate-limits"),
 		AuthPolicyName:           getEnvOrDefault("AUTH_POLICY_NAME", "gateway-auth-policy"),
+		DefaultLimitScope:        getEnvOrDefault("DEFAULT_LIMIT_SCOPE", "per_user"),
+		UnlimitedTierMode:        getEnvOrDefault("UNLIMITED_TIER_MODE", "high-limit"),
+		PolicyRetryAttempts:      getEnvIntOrDefault("POLICY_RETRY_ATTEMPTS", 5),
+		PolicyApplyMode:          getEnvOrDefault("POLICY_APPLY_MODE", "apply"),
+		GatewayName:              getEnvOrDefault("GATEWAY_NAME", "inference-gateway"),
+		GatewayNamespace:         getEnvOrDefault("GATEWAY_NAMESPACE", "llm"),
+
+		// Endpoint discovery configuration
+		DiscoveryRoute:           getEnvOrDefault("DISCOVERY_ROUTE", ""),
+		DiscoveryRefreshInterval: getEnvDurationOrDefault("DISCOVERY_REFRESH_INTERVAL", 5*time.Minute),
+
+		// Limitador configuration
+		LimitadorURL:       getEnvOrDefault("LIMITADOR_URL", ""),
+		LimitadorNamespace: getEnvOrDefault("LIMITADOR_NAMESPACE", "llm/inference-gateway"),
+		LiveUsageInterval:  getEnvDurationOrDefault("LIVE_USAGE_INTERVAL", 15*time.Second),
+
+		// Prometheus configuration
+		PrometheusURL:      getEnvOrDefault("PROMETHEUS_URL", ""),
+		PrometheusCacheTTL: getEnvDurationOrDefault("PROMETHEUS_CACHE_TTL", 30*time.Second),
 
 		// Default team configuration
-		CreateDefaultTeam: getEnvOrDefault("CREATE_DEFAULT_TEAM", "true") == "true",
-		AdminAPIKey:       getEnvOrDefault("ADMIN_API_KEY", ""),
+		CreateDefaultTeam:      getEnvOrDefault("CREATE_DEFAULT_TEAM", "true") == "true",
+		DefaultTeamTier:        getEnvOrDefault("DEFAULT_TEAM_TIER", "unlimited-policy"),
+		AdminAPIKey:            getEnvOrDefault("ADMIN_API_KEY", ""),
+		AdminAPIKeySHA256:      getEnvOrDefault("ADMIN_API_KEY_SHA256", ""),
+		AdminAPIKeySecretRef:   getEnvOrDefault("ADMIN_API_KEY_SECRET_REF", ""),
+		AdminCredentialsSecret: getEnvOrDefault("ADMIN_CREDENTIALS_SECRET", "key-manager-admin-credentials"),
+		ViewerAPIKeys:          getEnvListOrDefault("VIEWER_API_KEYS", ""),
+		AdminAuthMode:          getEnvOrDefault("ADMIN_AUTH_MODE", "static"),
+		AdminAuthCacheTTL:      getEnvDurationOrDefault("ADMIN_AUTH_CACHE_TTL", 30*time.Second),
+
+		AllowUnauthenticatedAdmin: getEnvOrDefault("ALLOW_UNAUTHENTICATED_ADMIN", "false") == "true",
+		AuthHeaderName:            getEnvOrDefault("AUTH_HEADER_NAME", "X-Api-Key"),
+		AllowRawAuthHeader:        getEnvOrDefault("ALLOW_RAW_AUTH_HEADER", "false") == "true",
+
+		// OIDC configuration
+		OIDCIssuerURL:           getEnvOrDefault("OIDC_ISSUER_URL", ""),
+		OIDCAudience:            getEnvOrDefault("OIDC_AUDIENCE", ""),
+		OIDCAdminGroup:          getEnvOrDefault("OIDC_ADMIN_GROUP", ""),
+		OIDCViewerGroup:         getEnvOrDefault("OIDC_VIEWER_GROUP", ""),
+		OIDCGroupsClaim:         getEnvOrDefault("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCTeamAdminClaim:      getEnvOrDefault("OIDC_TEAM_ADMIN_CLAIM", ""),
+		OIDCJWKSRefreshInterval: getEnvDurationOrDefault("OIDC_JWKS_REFRESH_INTERVAL", time.Hour),
+
+		// Key caps, 0 means unlimited
+		MaxKeysPerUser: getEnvIntOrDefault("MAX_KEYS_PER_USER", 0),
+		MaxKeysPerTeam: getEnvIntOrDefault("MAX_KEYS_PER_TEAM", 0),
+
+		// Key hashing algorithm
+		KeyHashAlgo: getEnvOrDefault("KEY_HASH_ALGO", "sha256"),
+		KeyFormat:   getEnvOrDefault("KEY_FORMAT", "v1"),
+
+		// Self-service configuration
+		SelfServiceMaxKeysPerUser: getEnvIntOrDefault("SELF_SERVICE_MAX_KEYS_PER_USER", 5),
+
+		// Team invites
+		MaxInvitesPerTeam: getEnvIntOrDefault("MAX_INVITES_PER_TEAM", 20),
+		InviteTTL:         getEnvDurationOrDefault("INVITE_TTL", 72*time.Hour),
+
+		// Webhook configuration
+		WebhookURL:    getEnvOrDefault("WEBHOOK_URL", ""),
+		WebhookSecret: getEnvOrDefault("WEBHOOK_SECRET", ""),
+
+		AllowUnsignedWebhooks: getEnvOrDefault("ALLOW_UNSIGNED_WEBHOOKS", "false") == "true",
+
+		// Budget enforcement configuration
+		BudgetEnforcementMode: getEnvOrDefault("BUDGET_ENFORCEMENT_MODE", "suspend"),
+		BudgetOverPolicy:      getEnvOrDefault("BUDGET_OVER_POLICY", "over-budget"),
+		BudgetCheckInterval:   getEnvDurationOrDefault("BUDGET_CHECK_INTERVAL", 5*time.Minute),
+
+		// Model pricing configuration
+		DefaultInputPer1K:      getEnvFloatOrDefault("PRICING_DEFAULT_INPUT_PER_1K", 0.002),
+		DefaultOutputPer1K:     getEnvFloatOrDefault("PRICING_DEFAULT_OUTPUT_PER_1K", 0.002),
+		DefaultCurrency:        strings.ToUpper(getEnvOrDefault("PRICING_DEFAULT_CURRENCY", "USD")),
+		PricingCurrencies:      getEnvListOrDefault("PRICING_CURRENCIES", "USD,EUR,GBP"),
+		PricingRefreshInterval: getEnvDurationOrDefault("PRICING_REFRESH_INTERVAL", time.Minute),
+
+		// MaaSTeam custom resource configuration, migrating implies enabled
+		TeamCRDEnabled:    getEnvOrDefault("TEAM_CRD_ENABLED", "false") == "true" || getEnvOrDefault("MIGRATE_TEAMS_TO_CRD", "false") == "true",
+		MigrateTeamsToCRD: getEnvOrDefault("MIGRATE_TEAMS_TO_CRD", "false") == "true",
+
+		// Kubernetes Events for team, policy and key lifecycle
+		EventsEnabled: getEnvOrDefault("EVENTS_ENABLED", "true") == "true",
+
+		// Audit log configuration
+		AuditLogFile:     getEnvOrDefault("AUDIT_LOG_FILE", ""),
+		AuditLogMaxBytes: getEnvIntOrDefault("AUDIT_LOG_MAX_BYTES", 10*1024*1024),
+		AuditLogRetained: getEnvIntOrDefault("AUDIT_LOG_RETAINED", 1000),
+
+		// API rate limiting and brute-force protection
+		APIRateLimitPerIP:         getEnvIntOrDefault("API_RATE_LIMIT_PER_IP", 300),
+		APIRateLimitPerCredential: getEnvIntOrDefault("API_RATE_LIMIT_PER_CREDENTIAL", 600),
+		APIRateLimitBurst:         getEnvIntOrDefault("API_RATE_LIMIT_BURST", 30),
+		AuthLockoutThreshold:      getEnvIntOrDefault("AUTH_LOCKOUT_THRESHOLD", 10),
+		AuthLockoutDuration:       getEnvDurationOrDefault("AUTH_LOCKOUT_DURATION", time.Minute),
+		AuthLockoutMaxDuration:    getEnvDurationOrDefault("AUTH_LOCKOUT_MAX_DURATION", time.Hour),
+		AuthFailureAlertThreshold: getEnvIntOrDefault("AUTH_FAILURE_ALERT_THRESHOLD", 5),
+		AuthFailureBackoff:        getEnvListOrDefault("AUTH_FAILURE_BACKOFF", "1s,5s,30s"),
+		AuthFailureWindow:         getEnvDurationOrDefault("AUTH_FAILURE_WINDOW", 15*time.Minute),
+
+		// CORS configuration
+		CORSAllowedOrigins:   getEnvListOrDefault("CORS_ALLOWED_ORIGINS", ""),
+		CORSAllowedMethods:   getEnvListOrDefault("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE"),
+		CORSAllowedHeaders:   getEnvListOrDefault("CORS_ALLOWED_HEADERS", "Authorization,Content-Type"),
+		CORSExposedHeaders:   getEnvListOrDefault("CORS_EXPOSED_HEADERS", "Authorization,Retry-After"),
+		CORSAllowCredentials: getEnvOrDefault("CORS_ALLOW_CREDENTIALS", "false") == "true",
+		CORSMaxAge:           getEnvDurationOrDefault("CORS_MAX_AGE", 10*time.Minute),
+
+		// Per-team key namespaces
+		AutoCreateTeamNamespaces: getEnvOrDefault("AUTO_CREATE_TEAM_NAMESPACES", "false") == "true",
+		ManageKeyNamespaceAccess: getEnvOrDefault("MANAGE_KEY_NAMESPACE_ACCESS", "true") == "true",
+		KeyNamespaceClusterRole:  getEnvOrDefault("KEY_NAMESPACE_CLUSTER_ROLE", "key-manager-maas-secrets"),
+		ServiceAccountName:       getEnvOrDefault("SERVICE_ACCOUNT_NAME", "key-manager"),
+		ServiceAccountNamespace:  getEnvOrDefault("SERVICE_ACCOUNT_NAMESPACE", "platform-services"),
+
+		// Member removal and cleanup of the inactive keys it leaves behind
+		MemberRemovalMode:          getEnvOrDefault("MEMBER_REMOVAL_MODE", "delete"),
+		InactiveKeyRetention:       getEnvDurationOrDefault("INACTIVE_KEY_RETENTION", 30*24*time.Hour),
+		InactiveKeyCleanupInterval: getEnvDurationOrDefault("INACTIVE_KEY_CLEANUP_INTERVAL", time.Hour),
+
+		// Interval of the policy drift reconciler, 0 disables it
+		PolicyReconcileInterval: getEnvDurationOrDefault("POLICY_RECONCILE_INTERVAL", 5*time.Minute),
+
+		// Interval at which the served Kuadrant policy versions are re-discovered,
+		// 0 disables it
+		PolicyDiscoveryInterval: getEnvDurationOrDefault("POLICY_DISCOVERY_INTERVAL", 10*time.Minute),
 	}
 }
 
@@ -50,4 +370,46 @@ func getEnvOrDefault(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getEnvListOrDefault gets a comma-separated environment variable or returns
+// the default list, dropping empty items
+func getEnvListOrDefault(key, defaultValue string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(getEnvOrDefault(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvIntOrDefault gets an integer environment variable or returns default value
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDurationOrDefault gets a duration environment variable or returns default value
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloatOrDefault gets a float environment variable or returns default value
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
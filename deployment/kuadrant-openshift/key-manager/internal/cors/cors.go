@@ -0,0 +1,148 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config lists what cross-origin browser callers, such as the management
+// GUI, may do. Origins are exact (https://gui.example.com), a wildcard over
+// the subdomains of a domain (https://*.example.com), or * for any origin.
+type Config struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Policy answers CORS requests. A nil Policy leaves CORS off.
+type Policy struct {
+	config     Config
+	anyOrigin  bool
+	exact      map[string]bool
+	subdomains []subdomainOrigin
+}
+
+// subdomainOrigin matches the origins of any subdomain of a domain
+type subdomainOrigin struct {
+	scheme string
+	suffix string
+}
+
+// NewPolicy validates a CORS configuration. Wildcards other than a leading
+// subdomain label are rejected, as is allowing any origin with credentials,
+// which would let every site act with a browser's credentials.
+func NewPolicy(config Config) (*Policy, error) {
+	p := &Policy{config: config, exact: make(map[string]bool)}
+	for _, origin := range config.AllowedOrigins {
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			subdomain, err := parseSubdomainOrigin(origin)
+			if err != nil {
+				return nil, err
+			}
+			p.subdomains = append(p.subdomains, subdomain)
+		default:
+			parsed, err := url.Parse(origin)
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" || (parsed.Path != "" && parsed.Path != "/") {
+				return nil, fmt.Errorf("invalid CORS origin %q: must be scheme://host[:port]", origin)
+			}
+			p.exact[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+		}
+	}
+	if p.anyOrigin && config.AllowCredentials {
+		return nil, fmt.Errorf("CORS origin * cannot be combined with allowing credentials")
+	}
+	return p, nil
+}
+
+// parseSubdomainOrigin parses an origin such as https://*.example.com
+func parseSubdomainOrigin(origin string) (subdomainOrigin, error) {
+	scheme, host, found := strings.Cut(origin, "://")
+	if !found || scheme == "" || !strings.HasPrefix(host, "*.") {
+		return subdomainOrigin{}, fmt.Errorf("invalid CORS origin %q: a wildcard may only replace the first label, as in https://*.example.com", origin)
+	}
+	suffix := strings.TrimPrefix(host, "*")
+	// A wildcard over a top-level domain would match unrelated sites
+	if strings.Contains(suffix[1:], "*") || !strings.Contains(suffix[1:], ".") {
+		return subdomainOrigin{}, fmt.Errorf("invalid CORS origin %q: a wildcard needs a domain of at least two labels", origin)
+	}
+	return subdomainOrigin{scheme: strings.ToLower(scheme), suffix: strings.ToLower(suffix)}, nil
+}
+
+// allowed reports whether an origin may make cross-origin requests
+func (p *Policy) allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.exact[origin] {
+		return true
+	}
+	scheme, host, found := strings.Cut(origin, "://")
+	if !found {
+		return false
+	}
+	for _, subdomain := range p.subdomains {
+		if scheme == subdomain.scheme && strings.HasSuffix(host, subdomain.suffix) && len(host) > len(subdomain.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware adds CORS headers for allowed origins and answers preflight
+// requests itself, for every path, so parameterized routes need no OPTIONS
+// route of their own. Install it on the engine ahead of other middleware so
+// their rejections carry the headers too.
+func Middleware(p *Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if p == nil || origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !p.allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		if p.config.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if len(p.config.ExposedHeaders) > 0 {
+				c.Header("Access-Control-Expose-Headers", strings.Join(p.config.ExposedHeaders, ", "))
+			}
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		c.Header("Access-Control-Allow-Methods", strings.Join(p.config.AllowedMethods, ", "))
+		c.Header("Access-Control-Allow-Headers", strings.Join(p.config.AllowedHeaders, ", "))
+		if p.config.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(int(p.config.MaxAge.Seconds())))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,203 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	routeGVR = schema.GroupVersionResource{
+		Group:    "route.openshift.io",
+		Version:  "v1",
+		Resource: "routes",
+	}
+	gatewayGVR = schema.GroupVersionResource{
+		Group:    "gateway.networking.k8s.io",
+		Version:  "v1",
+		Resource: "gateways",
+	}
+)
+
+// Discoverer resolves the public inference endpoint from an OpenShift Route,
+// falling back to the Gateway listener hostname, and caches the result
+type Discoverer struct {
+	kuadrantClient   dynamic.Interface
+	routeNamespace   string
+	routeName        string
+	gatewayNamespace string
+	gatewayName      string
+
+	mu       sync.RWMutex
+	endpoint *Endpoint
+	lastErr  error
+}
+
+// NewDiscoverer creates a new endpoint discoverer. routeRef is "namespace/name"
+// and may be empty to only use the Gateway.
+func NewDiscoverer(kuadrantClient dynamic.Interface, routeRef, gatewayNamespace, gatewayName string) *Discoverer {
+	d := &Discoverer{
+		kuadrantClient:   kuadrantClient,
+		gatewayNamespace: gatewayNamespace,
+		gatewayName:      gatewayName,
+	}
+
+	if namespace, name, found := strings.Cut(routeRef, "/"); found {
+		d.routeNamespace = namespace
+		d.routeName = name
+	}
+
+	return d
+}
+
+// Start performs an initial discovery and refreshes it periodically
+func (d *Discoverer) Start(interval time.Duration) {
+	if err := d.Refresh(); err != nil {
+		log.Printf("Warning: Endpoint discovery failed: %v", err)
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := d.Refresh(); err != nil {
+				log.Printf("Warning: Endpoint discovery refresh failed: %v", err)
+			}
+		}
+	}()
+}
+
+// Refresh re-resolves the endpoint. The previous endpoint is kept on failure.
+func (d *Discoverer) Refresh() error {
+	endpoint, err := d.discover()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastErr = err
+	if err == nil {
+		d.endpoint = endpoint
+	}
+	return err
+}
+
+// Endpoint returns the last discovered endpoint, or nil if none is known
+func (d *Discoverer) Endpoint() *Endpoint {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.endpoint
+}
+
+// LastError returns the error from the most recent discovery attempt
+func (d *Discoverer) LastError() error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.lastErr
+}
+
+// CheckGateway verifies that the configured Gateway exists
+func (d *Discoverer) CheckGateway() error {
+	_, err := d.kuadrantClient.Resource(gatewayGVR).Namespace(d.gatewayNamespace).Get(
+		context.Background(), d.gatewayName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get Gateway %s/%s: %w", d.gatewayNamespace, d.gatewayName, err)
+	}
+	return nil
+}
+
+// discover tries the configured Route first and then the Gateway
+func (d *Discoverer) discover() (*Endpoint, error) {
+	if d.routeName != "" {
+		endpoint, err := d.discoverFromRoute()
+		if err == nil {
+			return endpoint, nil
+		}
+		log.Printf("Warning: Route discovery failed, falling back to Gateway: %v", err)
+	}
+
+	return d.discoverFromGateway()
+}
+
+// discoverFromRoute reads host, path and TLS settings from an OpenShift Route
+func (d *Discoverer) discoverFromRoute() (*Endpoint, error) {
+	route, err := d.kuadrantClient.Resource(routeGVR).Namespace(d.routeNamespace).Get(
+		context.Background(), d.routeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Route %s/%s: %w", d.routeNamespace, d.routeName, err)
+	}
+
+	spec, ok := route.Object["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("route %s/%s has no spec", d.routeNamespace, d.routeName)
+	}
+
+	host, _ := spec["host"].(string)
+	if host == "" {
+		return nil, fmt.Errorf("route %s/%s has no host", d.routeNamespace, d.routeName)
+	}
+
+	path, _ := spec["path"].(string)
+	scheme := "http"
+	if _, ok := spec["tls"].(map[string]interface{}); ok {
+		scheme = "https"
+	}
+
+	return &Endpoint{
+		Host:         host,
+		BasePath:     strings.TrimSuffix(path, "/"),
+		Scheme:       scheme,
+		Source:       fmt.Sprintf("route/%s/%s", d.routeNamespace, d.routeName),
+		DiscoveredAt: time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// discoverFromGateway uses the first listener with a concrete (non-wildcard) hostname
+func (d *Discoverer) discoverFromGateway() (*Endpoint, error) {
+	gateway, err := d.kuadrantClient.Resource(gatewayGVR).Namespace(d.gatewayNamespace).Get(
+		context.Background(), d.gatewayName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Gateway %s/%s: %w", d.gatewayNamespace, d.gatewayName, err)
+	}
+
+	spec, _ := gateway.Object["spec"].(map[string]interface{})
+	listeners, _ := spec["listeners"].([]interface{})
+	for _, listener := range listeners {
+		listenerMap, ok := listener.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		hostname, _ := listenerMap["hostname"].(string)
+		if hostname == "" || strings.HasPrefix(hostname, "*") {
+			continue
+		}
+
+		scheme := "http"
+		if protocol, _ := listenerMap["protocol"].(string); protocol == "HTTPS" {
+			scheme = "https"
+		}
+
+		return &Endpoint{
+			Host:         hostname,
+			Scheme:       scheme,
+			Source:       fmt.Sprintf("gateway/%s/%s", d.gatewayNamespace, d.gatewayName),
+			DiscoveredAt: time.Now().Format(time.RFC3339),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("gateway %s/%s has no listener with a concrete hostname", d.gatewayNamespace, d.gatewayName)
+}
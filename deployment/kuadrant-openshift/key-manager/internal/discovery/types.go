@@ -0,0 +1,32 @@
+package discovery
+
+import "fmt"
+
+// Endpoint describes where clients send inference requests
+type Endpoint struct {
+	Host         string `json:"host"`
+	BasePath     string `json:"base_path"`
+	Scheme       string `json:"scheme"`
+	Source       string `json:"source"`
+	DiscoveredAt string `json:"discovered_at"`
+}
+
+type DiscoverEndpointResponse struct {
+	Endpoint *Endpoint `json:"endpoint"`
+	URL      string    `json:"url"`
+}
+
+// URL returns the base URL of the endpoint
+func (e *Endpoint) URL() string {
+	return fmt.Sprintf("%s://%s%s", e.Scheme, e.Host, e.BasePath)
+}
+
+// ExampleCurl returns a ready-to-run chat completion request against a model
+func (e *Endpoint) ExampleCurl(model string) string {
+	if model == "" {
+		model = "<model-name>"
+	}
+
+	return fmt.Sprintf(`curl -s %s/v1/chat/completions -H "Authorization: APIKEY $API_KEY" -H "Content-Type: application/json" -d '{"model": "%s", "messages": [{"role": "user", "content": "Hello"}], "max_tokens": 50}'`,
+		e.URL(), model)
+}
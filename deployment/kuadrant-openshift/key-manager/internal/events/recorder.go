@@ -0,0 +1,115 @@
+package events
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event reasons for MaaS lifecycle events
+const (
+	ReasonTeamCreated      = "TeamCreated"
+	ReasonTeamDeleted      = "TeamDeleted"
+	ReasonTierChanged      = "TierChanged"
+	ReasonPoliciesApplied  = "PoliciesApplied"
+	ReasonPolicyFailed     = "PolicyFailed"
+	ReasonKeyCreated       = "KeyCreated"
+	ReasonKeyRevoked       = "KeyRevoked"
+	ReasonKeyRotated       = "KeyRotated"
+	ReasonDefaultTeamDrift = "DefaultTeamDrift"
+
+	ReasonPolicyDrift          = "PolicyDrift"
+	ReasonPolicyDriftCorrected = "PolicyDriftCorrected"
+
+	ReasonProvisioningRolledBack = "ProvisioningRolledBack"
+	ReasonProvisioningFailed     = "ProvisioningFailed"
+
+	ReasonAdminAuthFailures = "AdminAuthFailures"
+)
+
+// Recorder emits Kubernetes Events for team, policy and key lifecycle so
+// they show up in kubectl describe and cluster event pipelines. Recording is
+// asynchronous and best effort, and a nil Recorder is disabled.
+type Recorder struct {
+	recorder         record.EventRecorder
+	namespace        string
+	gatewayNamespace string
+	gatewayName      string
+}
+
+// NewRecorder creates an event recorder backed by the cluster's Events API
+func NewRecorder(clientset kubernetes.Interface, component, namespace, gatewayNamespace, gatewayName string) *Recorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: clientset.CoreV1().Events(""),
+	})
+
+	return &Recorder{
+		recorder:         broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component}),
+		namespace:        namespace,
+		gatewayNamespace: gatewayNamespace,
+		gatewayName:      gatewayName,
+	}
+}
+
+// Team records an event on a team's config secret
+func (r *Recorder) Team(teamID, eventType, reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.emit(&corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  r.namespace,
+		Name:       fmt.Sprintf("team-%s-config", teamID),
+	}, eventType, reason, messageFmt, args...)
+}
+
+// Key records an event on an API key secret
+func (r *Recorder) Key(secretName, eventType, reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.emit(&corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  r.namespace,
+		Name:       secretName,
+	}, eventType, reason, messageFmt, args...)
+}
+
+// AdminCredentials records an event on the admin credentials secret, used
+// for attempts to authenticate as an admin
+func (r *Recorder) AdminCredentials(secretName, eventType, reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.emit(&corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  r.namespace,
+		Name:       secretName,
+	}, eventType, reason, messageFmt, args...)
+}
+
+// Gateway records an event on the inference gateway, used for policy changes
+// and for objects that no longer exist
+func (r *Recorder) Gateway(eventType, reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.emit(&corev1.ObjectReference{
+		APIVersion: "gateway.networking.k8s.io/v1",
+		Kind:       "Gateway",
+		Namespace:  r.gatewayNamespace,
+		Name:       r.gatewayName,
+	}, eventType, reason, messageFmt, args...)
+}
+
+func (r *Recorder) emit(ref *corev1.ObjectReference, eventType, reason, messageFmt string, args ...interface{}) {
+	r.recorder.Eventf(ref, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,80 @@
+package fips
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Validated crypto modules the binary can run on
+const (
+	// Go's native FIPS 140-3 module, enabled with GODEBUG=fips140=on
+	ModuleGo = "go-fips140"
+	// BoringCrypto, compiled in with GOEXPERIMENT=boringcrypto
+	ModuleBoring = "boringcrypto"
+)
+
+// Reader is the approved random source, for callers that need an io.Reader
+var Reader io.Reader = rand.Reader
+
+// Check reports whether FIPS mode can be enforced, which needs a validated
+// crypto module to be active
+func Check() error {
+	if Module() == "" {
+		return fmt.Errorf("no FIPS 140 crypto module is active: run with GODEBUG=fips140=on or build with GOEXPERIMENT=boringcrypto")
+	}
+	return nil
+}
+
+// RandomBytes returns n random bytes from the approved random source
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(Reader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// RandomHex returns n random bytes, hex encoded
+func RandomHex(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SHA256 returns the SHA-256 digest of data
+func SHA256(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// SHA256Hex returns the hex encoded SHA-256 digest of data
+func SHA256Hex(data []byte) string {
+	return hex.EncodeToString(SHA256(data))
+}
+
+// HMACSHA256 returns the HMAC-SHA256 of data
+func HMACSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// RestrictTLS limits a TLS configuration to approved versions, cipher
+// suites and curves. TLS 1.3 suites are not configurable and all approved.
+func RestrictTLS(config *tls.Config) {
+	config.MinVersion = tls.VersionTLS12
+	config.CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+	config.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+}
@@ -0,0 +1,112 @@
+package fips
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSHA256Hex(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{data: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{data: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			if got := SHA256Hex([]byte(tt.data)); got != tt.want {
+				t.Errorf("SHA256Hex(%q) = %s, want %s", tt.data, got, tt.want)
+			}
+			if got := hex.EncodeToString(SHA256([]byte(tt.data))); got != tt.want {
+				t.Errorf("SHA256(%q) = %s, want %s", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHMACSHA256(t *testing.T) {
+	// Test cases 1 and 2 of RFC 4231
+	tests := []struct {
+		name string
+		key  []byte
+		data string
+		want string
+	}{
+		{
+			name: "binary key",
+			key:  []byte{0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b},
+			data: "Hi There",
+			want: "b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7",
+		},
+		{
+			name: "short key",
+			key:  []byte("Jefe"),
+			data: "what do ya want for nothing?",
+			want: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(HMACSHA256(tt.key, []byte(tt.data))); got != tt.want {
+				t.Errorf("HMACSHA256() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomHex(t *testing.T) {
+	first, err := RandomHex(32)
+	if err != nil {
+		t.Fatalf("RandomHex() = %v", err)
+	}
+	if len(first) != 64 {
+		t.Errorf("RandomHex(32) is %d characters, want 64", len(first))
+	}
+	if _, err := hex.DecodeString(first); err != nil {
+		t.Errorf("RandomHex(32) = %q is not hex", first)
+	}
+
+	second, err := RandomHex(32)
+	if err != nil {
+		t.Fatalf("RandomHex() = %v", err)
+	}
+	if first == second {
+		t.Errorf("RandomHex(32) returned %q twice", first)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	// Which module is active depends on how the tests are run
+	err := Check()
+	if module := Module(); module == "" && err == nil {
+		t.Errorf("Check() = nil without a crypto module")
+	} else if module != "" && err != nil {
+		t.Errorf("Check() = %v with module %s", err, module)
+	}
+}
+
+func TestRestrictTLS(t *testing.T) {
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS10,
+		CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+	}
+	RestrictTLS(config)
+
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("MinVersion = %x, want TLS 1.2", config.MinVersion)
+	}
+	for _, suite := range config.CipherSuites {
+		if suite == tls.TLS_RSA_WITH_AES_128_CBC_SHA {
+			t.Errorf("CipherSuites kept an unapproved suite")
+		}
+	}
+	for _, curve := range config.CurvePreferences {
+		if curve != tls.CurveP256 && curve != tls.CurveP384 {
+			t.Errorf("CurvePreferences include unapproved curve %v", curve)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !boringcrypto
+
+package fips
+
+import "crypto/fips140"
+
+// Module names the validated crypto module in use, empty when there is none
+func Module() string {
+	if fips140.Enabled() {
+		return ModuleGo
+	}
+	return ""
+}
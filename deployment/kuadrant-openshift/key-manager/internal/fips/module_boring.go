@@ -0,0 +1,13 @@
+//go:build boringcrypto
+
+package fips
+
+import "crypto/boring"
+
+// Module names the validated crypto module in use, empty when there is none
+func Module() string {
+	if boring.Enabled() {
+		return ModuleBoring
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/audit"
+)
+
+// AuditHandler serves the audit log of admin operations
+type AuditHandler struct {
+	auditLog *audit.Log
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditLog *audit.Log) *AuditHandler {
+	return &AuditHandler{
+		auditLog: auditLog,
+	}
+}
+
+// QueryAudit handles GET /admin/audit. since is an RFC 3339 time or a
+// duration back from now, such as 24h; team_id narrows to one team.
+func (h *AuditHandler) QueryAudit(c *gin.Context) {
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+			since = parsed
+		} else if window, err := time.ParseDuration(value); err == nil && window > 0 {
+			since = time.Now().Add(-window)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 time or a duration such as 24h"})
+			return
+		}
+	}
+
+	entries := h.auditLog.Query(since, c.Query("team_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+)
+
+// CredentialsHandler manages named admin credentials
+type CredentialsHandler struct {
+	credentials *auth.AdminCredentials
+}
+
+// NewCredentialsHandler creates a new admin credentials handler
+func NewCredentialsHandler(credentials *auth.AdminCredentials) *CredentialsHandler {
+	return &CredentialsHandler{
+		credentials: credentials,
+	}
+}
+
+// CreateCredentialRequest names a new admin credential
+type CreateCredentialRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// requireBootstrap rejects requests not made with the bootstrap admin key,
+// so a leaked automation credential cannot mint or revoke others
+func requireBootstrap(c *gin.Context) bool {
+	if c.GetString(auth.ContextCredential) != auth.CredentialBootstrap {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin credentials can only be managed with the bootstrap admin key"})
+		return false
+	}
+	return true
+}
+
+// CreateCredential handles POST /admin/credentials
+func (h *CredentialsHandler) CreateCredential(c *gin.Context) {
+	if !requireBootstrap(c) {
+		return
+	}
+	var req CreateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	key, err := h.credentials.Add(req.Name)
+	if err != nil {
+		log.Printf("Failed to create admin credential %s: %v", req.Name, err)
+		if strings.Contains(err.Error(), "invalid credential name") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin credential"})
+		}
+		return
+	}
+
+	log.Printf("Admin credential %s created", req.Name)
+	c.JSON(http.StatusCreated, gin.H{
+		"name": req.Name,
+		"key":  key,
+	})
+}
+
+// ListCredentials handles GET /admin/credentials
+func (h *CredentialsHandler) ListCredentials(c *gin.Context) {
+	if !requireBootstrap(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credentials": h.credentials.Names()})
+}
+
+// DeleteCredential handles DELETE /admin/credentials/:name
+func (h *CredentialsHandler) DeleteCredential(c *gin.Context) {
+	if !requireBootstrap(c) {
+		return
+	}
+	name := c.Param("name")
+
+	if err := h.credentials.Remove(name); err != nil {
+		log.Printf("Failed to delete admin credential %s: %v", name, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete admin credential"})
+		}
+		return
+	}
+
+	log.Printf("Admin credential %s deleted", name)
+	c.JSON(http.StatusOK, gin.H{"message": "Admin credential deleted", "name": name})
+}
+
+// WhoAmI handles GET /whoami, returning the identity and role a request was
+// authenticated with, such as cert:<SAN> for a client certificate
+func (h *CredentialsHandler) WhoAmI(c *gin.Context) {
+	identity := auth.Identity(c)
+	role := c.GetString(auth.ContextRole)
+	if role == "" {
+		// Admin routes are open while no admin credential is configured
+		identity = "anonymous"
+		role = auth.RoleAdmin
+	}
+
+	response := gin.H{
+		"identity": identity,
+		"role":     role,
+	}
+	if teamID, ok := auth.ScopedTeam(c); ok {
+		response["team_id"] = teamID
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/discovery"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/keys"
+)
+
+// DiscoveryHandler handles endpoint discovery
+type DiscoveryHandler struct {
+	discoverer *discovery.Discoverer
+}
+
+// NewDiscoveryHandler creates a new discovery handler
+func NewDiscoveryHandler(discoverer *discovery.Discoverer) *DiscoveryHandler {
+	return &DiscoveryHandler{
+		discoverer: discoverer,
+	}
+}
+
+// DiscoverEndpoint handles GET /discover_endpoint
+func (h *DiscoveryHandler) DiscoverEndpoint(c *gin.Context) {
+	if c.Query("refresh") == "true" {
+		_ = h.discoverer.Refresh()
+	}
+
+	endpoint := h.discoverer.Endpoint()
+	if endpoint == nil {
+		reason := "endpoint has not been discovered"
+		if err := h.discoverer.LastError(); err != nil {
+			reason = err.Error()
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Endpoint discovery failed", "reason": reason})
+		return
+	}
+
+	c.JSON(http.StatusOK, discovery.DiscoverEndpointResponse{
+		Endpoint: endpoint,
+		URL:      endpoint.URL(),
+	})
+}
+
+// keyEndpoint builds the endpoint block returned with a newly created key
+func keyEndpoint(discoverer *discovery.Discoverer, models []string) *keys.KeyEndpoint {
+	endpoint := discoverer.Endpoint()
+	if endpoint == nil {
+		return nil
+	}
+
+	model := ""
+	if len(models) > 0 {
+		model = models[0]
+	}
+
+	return &keys.KeyEndpoint{
+		Host:        endpoint.Host,
+		BasePath:    endpoint.BasePath,
+		URL:         endpoint.URL(),
+		ExampleCurl: endpoint.ExampleCurl(model),
+	}
+}
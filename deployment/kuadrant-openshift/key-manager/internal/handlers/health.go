@@ -1,20 +1,143 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/discovery"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
 )
 
 // HealthHandler handles health check endpoints
-type HealthHandler struct{}
+type HealthHandler struct {
+	secretCache     *teams.SecretCache
+	policyGVRs      *teams.PolicyGVRs
+	teamMgr         *teams.Manager
+	discoverer      *discovery.Discoverer
+	limitadorClient *limitador.Client
+	adminKey        *auth.AdminKey
+	// Whether admin routes require authentication
+	adminAuthEnforced bool
+	// Whether crypto is restricted to FIPS-approved algorithms
+	fipsMode bool
+}
+
+// NewHealthHandler creates a new health handler. secretCache and
+// limitadorClient may be nil.
+func NewHealthHandler(secretCache *teams.SecretCache, policyGVRs *teams.PolicyGVRs, teamMgr *teams.Manager, discoverer *discovery.Discoverer, limitadorClient *limitador.Client, adminKey *auth.AdminKey, adminAuthEnforced, fipsMode bool) *HealthHandler {
+	return &HealthHandler{
+		secretCache:     secretCache,
+		policyGVRs:      policyGVRs,
+		teamMgr:         teamMgr,
+		discoverer:      discoverer,
+		limitadorClient: limitadorClient,
+		adminKey:        adminKey,
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+		adminAuthEnforced: adminAuthEnforced,
+		fipsMode:          fipsMode,
+	}
 }
 
-// HealthCheck handles GET /health
+// HealthCheck handles GET /health. A secret cache that is still warming up
+// does not make the service unhealthy, lookups fall back to the API server.
+// fips_mode and crypto_module report the crypto in use.
 func (h *HealthHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
-}
\ No newline at end of file
+	cryptoModule := fips.Module()
+	if cryptoModule == "" {
+		cryptoModule = "standard"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "healthy",
+		"secret_cache":  h.secretCache.Status(),
+		"fips_mode":     h.fipsMode,
+		"crypto_module": cryptoModule,
+	})
+}
+
+// ReadinessCheck handles GET /readyz. The service is not ready while a
+// Kuadrant policy kind is not served in any version it supports, or while
+// the admin key cannot be read from the Secret it is configured to come
+// from. admin_auth reports whether admin routes require authentication.
+func (h *HealthHandler) ReadinessCheck(c *gin.Context) {
+	policies := gin.H{
+		"token_rate_limit_policy": h.policyGVRs.TokenRateLimitPolicy().GroupVersion().String(),
+		"auth_policy":             h.policyGVRs.AuthPolicy().GroupVersion().String(),
+	}
+	adminAuth := "enforced"
+	if !h.adminAuthEnforced {
+		adminAuth = "disabled"
+	}
+	if err := h.policyGVRs.Err(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":     "not ready",
+			"error":      err.Error(),
+			"policies":   policies,
+			"admin_auth": adminAuth,
+		})
+		return
+	}
+	if err := h.adminKey.Err(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":     "not ready",
+			"error":      err.Error(),
+			"policies":   policies,
+			"admin_auth": adminAuth,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "ready",
+		"policies":   policies,
+		"admin_auth": adminAuth,
+	})
+}
+
+// PolicyHealth handles GET /admin/policies/health. It checks the cluster
+// state team policies depend on and answers 503 when any check fails.
+func (h *HealthHandler) PolicyHealth(c *gin.Context) {
+	checks := []teams.PolicyHealthCheck{
+		teams.RunPolicyCheck("policy_crds", func() (string, string) {
+			if err := h.policyGVRs.Err(); err != nil {
+				return teams.PolicyCheckFailed, err.Error()
+			}
+			return teams.PolicyCheckOK, fmt.Sprintf("TokenRateLimitPolicy %s, AuthPolicy %s",
+				h.policyGVRs.TokenRateLimitPolicy().GroupVersion(), h.policyGVRs.AuthPolicy().GroupVersion())
+		}),
+	}
+	checks = append(checks, h.teamMgr.PolicyHealthChecks()...)
+
+	checks = append(checks, teams.RunPolicyCheck("gateway", func() (string, string) {
+		if err := h.discoverer.CheckGateway(); err != nil {
+			return teams.PolicyCheckFailed, err.Error()
+		}
+		return teams.PolicyCheckOK, "Gateway exists"
+	}))
+
+	checks = append(checks, teams.RunPolicyCheck("limitador", func() (string, string) {
+		if h.limitadorClient == nil {
+			return teams.PolicyCheckSkipped, "LIMITADOR_URL is not configured"
+		}
+		if err := h.limitadorClient.Ping(); err != nil {
+			return teams.PolicyCheckDegraded, err.Error()
+		}
+		return teams.PolicyCheckOK, "Limitador is reachable"
+	}))
+
+	report := teams.PolicyHealthReport{
+		Status:    teams.OverallPolicyHealth(checks),
+		CheckedAt: time.Now().Format(time.RFC3339),
+		Checks:    checks,
+	}
+	if report.Status == teams.PolicyHealthUnhealthy {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/keys"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+)
+
+// InvitesHandler handles team invites and their acceptance
+type InvitesHandler struct {
+	teamMgr           *teams.Manager
+	keyMgr            *keys.Manager
+	maxInvitesPerTeam int
+	inviteTTL         time.Duration
+}
+
+// NewInvitesHandler creates a new invites handler
+func NewInvitesHandler(teamMgr *teams.Manager, keyMgr *keys.Manager, maxInvitesPerTeam int, inviteTTL time.Duration) *InvitesHandler {
+	return &InvitesHandler{
+		teamMgr:           teamMgr,
+		keyMgr:            keyMgr,
+		maxInvitesPerTeam: maxInvitesPerTeam,
+		inviteTTL:         inviteTTL,
+	}
+}
+
+// CreateInvite handles POST /teams/:team_id/invites
+func (h *InvitesHandler) CreateInvite(c *gin.Context) {
+	teamID := c.Param("team_id")
+	var req teams.CreateInviteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindError(c, err)
+			return
+		}
+	}
+
+	ttl := h.inviteTTL
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in, must be a positive duration such as 24h"})
+			return
+		}
+		ttl = parsed
+	}
+
+	if h.maxInvitesPerTeam > 0 {
+		count, err := h.teamMgr.CountOutstandingInvites(teamID)
+		if err != nil {
+			log.Printf("Failed to count invites for team %s: %v", teamID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+			return
+		}
+		if count >= h.maxInvitesPerTeam {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":               "Maximum number of outstanding invites reached for this team",
+				"outstanding_invites": count,
+				"max_invites":         h.maxInvitesPerTeam,
+			})
+			return
+		}
+	}
+
+	invite, err := h.teamMgr.CreateInvite(teamID, &req, ttl)
+	if err != nil {
+		log.Printf("Failed to create invite for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "is archived") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ListInvites handles GET /teams/:team_id/invites
+func (h *InvitesHandler) ListInvites(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	invites, err := h.teamMgr.ListInvites(teamID)
+	if err != nil {
+		log.Printf("Failed to list invites for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invites"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_id": teamID,
+		"invites": invites,
+	})
+}
+
+// RevokeInvite handles DELETE /teams/:team_id/invites/:invite_id
+func (h *InvitesHandler) RevokeInvite(c *gin.Context) {
+	teamID := c.Param("team_id")
+	inviteID := c.Param("invite_id")
+
+	if err := h.teamMgr.RevokeInvite(teamID, inviteID); err != nil {
+		log.Printf("Failed to revoke invite %s for team %s: %v", inviteID, teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Invite revoked successfully",
+		"team_id":   teamID,
+		"invite_id": inviteID,
+	})
+}
+
+// AcceptInvite handles POST /invites/:token/accept. The token itself is the
+// credential, so the route sits outside admin authentication.
+func (h *InvitesHandler) AcceptInvite(c *gin.Context) {
+	var req teams.AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	invite, member, err := h.teamMgr.AcceptInvite(c.Param("token"), &req)
+	if err != nil {
+		log.Printf("Failed to accept invite for user %s: %v", req.UserID, err)
+		if strings.Contains(err.Error(), "invite not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		} else if strings.Contains(err.Error(), "already been used") || strings.Contains(err.Error(), "has expired") {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "already a member") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invite"})
+		}
+		return
+	}
+
+	response := gin.H{
+		"message": "Invite accepted",
+		"team_id": invite.TeamID,
+		"member":  member,
+	}
+
+	// The membership stands even if the first key cannot be created
+	if invite.CreateKey {
+		key, err := h.keyMgr.CreateTeamKey(invite.TeamID, &keys.CreateTeamKeyRequest{
+			UserID:            req.UserID,
+			UserEmail:         req.UserEmail,
+			Alias:             "invite",
+			InheritTeamLimits: true,
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to create first key for user %s in team %s: %v", req.UserID, invite.TeamID, err)
+			response["key_error"] = "Failed to create API key"
+		} else {
+			response["key"] = key
+		}
+	}
+
+	log.Printf("User %s joined team %s through invite %s", req.UserID, invite.TeamID, invite.InviteID)
+	c.JSON(http.StatusCreated, response)
+}
@@ -1,27 +1,41 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/discovery"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/keys"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/pricing"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
 )
 
 // KeysHandler handles key-related endpoints
 type KeysHandler struct {
-	keyMgr  *keys.Manager
-	teamMgr *teams.Manager
+	keyMgr          *keys.Manager
+	teamMgr         *teams.Manager
+	limitadorClient *limitador.Client
+	discoverer      *discovery.Discoverer
+	liveUsage       *teams.LiveUsage
+	costs           *pricing.Engine
 }
 
 // NewKeysHandler creates a new keys handler
-func NewKeysHandler(keyMgr *keys.Manager, teamMgr *teams.Manager) *KeysHandler {
+func NewKeysHandler(keyMgr *keys.Manager, teamMgr *teams.Manager, limitadorClient *limitador.Client, discoverer *discovery.Discoverer, liveUsage *teams.LiveUsage, costs *pricing.Engine) *KeysHandler {
 	return &KeysHandler{
-		keyMgr:  keyMgr,
-		teamMgr: teamMgr,
+		keyMgr:          keyMgr,
+		teamMgr:         teamMgr,
+		limitadorClient: limitadorClient,
+		discoverer:      discoverer,
+		liveUsage:       liveUsage,
+		costs:           costs,
 	}
 }
 
@@ -30,6 +44,15 @@ func (h *KeysHandler) CreateTeamKey(c *gin.Context) {
 	teamID := c.Param("team_id")
 	var req keys.CreateTeamKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := keys.ValidateTags(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := teams.ValidateLimitOverrides(req.TokenLimit, req.RequestLimit, req.TimeWindow); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -43,14 +66,28 @@ func (h *KeysHandler) CreateTeamKey(c *gin.Context) {
 	response, err := h.keyMgr.CreateTeamKey(teamID, &req)
 	if err != nil {
 		log.Printf("Failed to create team key: %v", err)
-		if strings.Contains(err.Error(), "already has an active API key") {
+		var limitErr *keys.KeyLimitError
+		if errors.As(err, &limitErr) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":        limitErr.Error(),
+				"scope":        limitErr.Scope,
+				"current_keys": limitErr.Current,
+				"max_keys":     limitErr.Max,
+			})
+		} else if strings.Contains(err.Error(), "already has an active API key") || strings.Contains(err.Error(), "is archived") ||
+			strings.Contains(err.Error(), "still provisioning") {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "is not allowed for team") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
 		}
 		return
 	}
 
+	// Tell the key holder where to send requests; omitted if discovery failed
+	response.Endpoint = keyEndpoint(h.discoverer, req.Models)
+
 	log.Printf("Team API key created successfully for user %s in team %s", req.UserID, teamID)
 	c.JSON(http.StatusOK, response)
 }
@@ -72,8 +109,15 @@ func (h *KeysHandler) ListTeamKeys(c *gin.Context) {
 		return
 	}
 
+	// Optional ?tag=key:value filters, all of which must match
+	tagFilter, err := keys.ParseTagFilters(c.QueryArray("tag"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get detailed team API keys
-	keys, err := h.keyMgr.ListTeamKeys(teamID)
+	teamKeys, err := h.keyMgr.ListTeamKeys(teamID, tagFilter)
 	if err != nil {
 		log.Printf("Failed to get team keys: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team keys"})
@@ -84,17 +128,76 @@ func (h *KeysHandler) ListTeamKeys(c *gin.Context) {
 		"team_id":     teamID,
 		"team_name":   team.TeamName,
 		"policy":      team.Policy,
-		"keys":        keys,
+		"keys":        teamKeys,
 		"users":       team.Members,
-		"total_keys":  len(keys),
+		"total_keys":  len(teamKeys),
 		"total_users": len(team.Members),
 	})
 }
 
+// GetTeamKey handles GET /keys/:key_name
+func (h *KeysHandler) GetTeamKey(c *gin.Context) {
+	keyName := c.Param("key_name")
+
+	keyInfo, err := h.keyMgr.GetKey(keyName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if teamID, scoped := auth.ScopedTeam(c); scoped && keyInfo["team_id"] != teamID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Team admin token is not valid for this team"})
+		return
+	}
+
+	// Enrich with live counter state for the key owner
+	policy, _ := keyInfo["policy"].(string)
+	userID, _ := keyInfo["user_id"].(string)
+	keyInfo["current_usage"] = h.limitadorClient.CurrentUsage(policy, userID)
+	teamID, _ := keyInfo["team_id"].(string)
+	secretName, _ := keyInfo["secret_name"].(string)
+	keyInfo["live_usage"] = h.liveUsage.Key(teamID, secretName, userID)
+
+	c.JSON(http.StatusOK, keyInfo)
+}
+
+// UpdateTeamKey handles PATCH /keys/:key_name
+func (h *KeysHandler) UpdateTeamKey(c *gin.Context) {
+	keyName := c.Param("key_name")
+	var req keys.UpdateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if !h.authorizeKeyAccess(c, keyName) {
+		return
+	}
+
+	keyInfo, err := h.keyMgr.UpdateKeyTags(keyName, &req)
+	if err != nil {
+		log.Printf("Failed to update key %s: %v", keyName, err)
+		if strings.Contains(err.Error(), "invalid tags") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update API key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, keyInfo)
+}
+
 // DeleteTeamKey handles DELETE /keys/:key_name
 func (h *KeysHandler) DeleteTeamKey(c *gin.Context) {
 	keyName := c.Param("key_name")
 
+	if !h.authorizeKeyAccess(c, keyName) {
+		return
+	}
+
 	keyName, teamID, err := h.keyMgr.DeleteTeamKey(keyName)
 	if err != nil {
 		log.Printf("Failed to delete team key: %v", err)
@@ -131,4 +234,130 @@ func (h *KeysHandler) ListUserKeys(c *gin.Context) {
 		"keys":       keys,
 		"total_keys": len(keys),
 	})
-}
\ No newline at end of file
+}
+
+// ImportKeys handles POST /admin/keys/import
+func (h *KeysHandler) ImportKeys(c *gin.Context) {
+	var req keys.ImportKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if len(req.Keys) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "keys must contain at least one record"})
+		return
+	}
+
+	// Per-row failures are reported in the body; the request itself succeeded
+	c.JSON(http.StatusOK, h.keyMgr.ImportKeys(&req))
+}
+
+// RotateTeamKey handles POST /keys/:key_name/rotate
+func (h *KeysHandler) RotateTeamKey(c *gin.Context) {
+	keyName := c.Param("key_name")
+
+	if !h.authorizeKeyAccess(c, keyName) {
+		return
+	}
+
+	response, err := h.keyMgr.RotateKey(keyName)
+	if err != nil {
+		log.Printf("Failed to rotate team key: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		} else if strings.Contains(err.Error(), "cannot be rotated") || strings.Contains(err.Error(), "is archived") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ExportTeams handles GET /admin/teams/export, reporting each team's
+// accrued cost alongside its configuration
+func (h *KeysHandler) ExportTeams(c *gin.Context) {
+	doc, err := h.keyMgr.ExportTeams()
+	if err != nil {
+		log.Printf("Failed to export teams: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export teams"})
+		return
+	}
+	for i := range doc.Teams {
+		team := &doc.Teams[i]
+		if cost, err := h.costs.TeamCost(team.TeamID); err != nil {
+			log.Printf("Warning: Failed to get cost for team %s: %v", team.TeamID, err)
+		} else {
+			team.Cost = cost
+		}
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// ImportTeams handles POST /admin/teams/import
+func (h *KeysHandler) ImportTeams(c *gin.Context) {
+	var doc keys.TeamsExport
+	if err := c.ShouldBindJSON(&doc); err != nil {
+		bindError(c, err)
+		return
+	}
+	if doc.Version != "" && doc.Version != keys.TeamsExportVersion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported export version %s", doc.Version)})
+		return
+	}
+
+	source := c.DefaultQuery("source", "team-export")
+
+	// Per-team failures are reported in the body; the request itself succeeded
+	c.JSON(http.StatusOK, h.keyMgr.ImportTeams(source, &doc))
+}
+
+// CheckKeyFormatRequest carries a key to check
+type CheckKeyFormatRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+// CheckKeyFormat handles POST /keys/check-format. It is unauthenticated and
+// only checks the key's syntax and checksum, never whether it exists.
+func (h *KeysHandler) CheckKeyFormat(c *gin.Context) {
+	var req CheckKeyFormatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	format, err := keys.ValidateKeyFormat(req.Key)
+	response := gin.H{
+		"valid":  err == nil,
+		"format": format,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// authorizeKeyAccess rejects team-admin requests for keys of other teams.
+// It writes the error response and returns false when access is denied.
+func (h *KeysHandler) authorizeKeyAccess(c *gin.Context, keyName string) bool {
+	teamID, scoped := auth.ScopedTeam(c)
+	if !scoped {
+		return true
+	}
+
+	keyInfo, err := h.keyMgr.GetKey(keyName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return false
+	}
+	if keyInfo["team_id"] != teamID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Team admin token is not valid for this team"})
+		return false
+	}
+
+	return true
+}
@@ -26,7 +26,7 @@ func NewLegacyHandler(keyMgr *keys.Manager) *LegacyHandler {
 func (h *LegacyHandler) GenerateKey(c *gin.Context) {
 	var req keys.GenerateKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
@@ -60,7 +60,7 @@ func (h *LegacyHandler) GenerateKey(c *gin.Context) {
 func (h *LegacyHandler) DeleteKey(c *gin.Context) {
 	var req keys.DeleteKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
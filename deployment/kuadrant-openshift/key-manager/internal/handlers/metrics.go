@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/ratelimit"
+)
+
+// MetricsHandler serves the key manager's own metrics in the Prometheus
+// text format
+type MetricsHandler struct {
+	limiter      *ratelimit.Limiter
+	authFailures *auth.FailureTracker
+}
+
+// NewMetricsHandler creates a new metrics handler. limiter and authFailures
+// may be nil.
+func NewMetricsHandler(limiter *ratelimit.Limiter, authFailures *auth.FailureTracker) *MetricsHandler {
+	return &MetricsHandler{
+		limiter:      limiter,
+		authFailures: authFailures,
+	}
+}
+
+// Metrics handles GET /metrics
+func (h *MetricsHandler) Metrics(c *gin.Context) {
+	counters := h.limiter.Counters()
+
+	var out strings.Builder
+	writeMetric(&out, "maas_key_manager_throttled_requests_total", "counter",
+		"Requests rejected with 429 by the API rate limiter.",
+		fmt.Sprintf(`{scope="ip"} %d`, counters.ThrottledIP),
+		fmt.Sprintf(`{scope="credential"} %d`, counters.ThrottledCredential),
+		fmt.Sprintf(`{scope="lockout"} %d`, counters.LockoutRejections))
+	writeMetric(&out, "maas_key_manager_auth_failures_total", "counter",
+		"Requests answered with 401.",
+		fmt.Sprintf(" %d", counters.AuthFailures))
+	writeMetric(&out, "maas_key_manager_lockouts_total", "counter",
+		"Client IPs locked out after consecutive authentication failures.",
+		fmt.Sprintf(" %d", counters.Lockouts))
+	writeMetric(&out, "maas_key_manager_locked_out_clients", "gauge",
+		"Client IPs currently locked out.",
+		fmt.Sprintf(" %d", counters.LockedOutClients))
+
+	var sourceSamples []string
+	for _, source := range h.authFailures.Sources() {
+		sourceSamples = append(sourceSamples, fmt.Sprintf(`{source=%q} %d`, source.Source, source.Total))
+	}
+	writeMetric(&out, "keymanager_auth_failures_total", "counter",
+		"Failed admin authentications per source address seen within the failure window.",
+		sourceSamples...)
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(out.String()))
+}
+
+// writeMetric writes a metric family, each sample being its labels and value
+func writeMetric(out *strings.Builder, name, kind, help string, samples ...string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, sample := range samples {
+		fmt.Fprintf(out, "%s%s\n", name, sample)
+	}
+}
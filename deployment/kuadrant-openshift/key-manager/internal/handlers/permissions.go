@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/permissions"
+)
+
+// PermissionsHandler reports whether the key manager holds the Kubernetes
+// permissions it needs
+type PermissionsHandler struct {
+	checker *permissions.Checker
+}
+
+// NewPermissionsHandler creates a new permissions handler
+func NewPermissionsHandler(checker *permissions.Checker) *PermissionsHandler {
+	return &PermissionsHandler{
+		checker: checker,
+	}
+}
+
+// CheckPermissions handles GET /admin/permissions. Every required verb and
+// resource is reviewed again, and the answer is 503 when any is missing.
+func (h *PermissionsHandler) CheckPermissions(c *gin.Context) {
+	report := h.checker.Check(c.Request.Context())
+	if report.Status != permissions.StatusOK {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/pricing"
+)
+
+// PricingHandler handles model pricing endpoints
+type PricingHandler struct {
+	registry *pricing.Registry
+}
+
+// NewPricingHandler creates a new pricing handler
+func NewPricingHandler(registry *pricing.Registry) *PricingHandler {
+	return &PricingHandler{
+		registry: registry,
+	}
+}
+
+// ListModelPrices handles GET /admin/pricing. With ?at= it lists the prices
+// that were in effect at that time instead of the latest ones.
+func (h *PricingHandler) ListModelPrices(c *gin.Context) {
+	prices, version := h.registry.List()
+	if at := c.Query("at"); at != "" {
+		when, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC 3339 time"})
+			return
+		}
+		prices = h.registry.ListAt(when)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"version":       version,
+		"currencies":    h.registry.Currencies(),
+		"default_price": h.registry.DefaultPrice(),
+		"models":        prices,
+		"total_models":  len(prices),
+	})
+}
+
+// SetModelPrice handles PUT /admin/pricing/:model_id
+func (h *PricingHandler) SetModelPrice(c *gin.Context) {
+	modelID := c.Param("model_id")
+	var req pricing.SetPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	price, err := h.registry.Set(modelID, &req)
+	if err != nil {
+		log.Printf("Failed to set price of model %s: %v", modelID, err)
+		if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set model price"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, price)
+}
+
+// DeleteModelPrice handles DELETE /admin/pricing/:model_id
+func (h *PricingHandler) DeleteModelPrice(c *gin.Context) {
+	modelID := c.Param("model_id")
+
+	if err := h.registry.Delete(modelID); err != nil {
+		log.Printf("Failed to remove price of model %s: %v", modelID, err)
+		if errors.Is(err, pricing.ErrNoPrice) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Model has no price"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove model price"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Model price removed",
+		"model_id": modelID,
+	})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/discovery"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/keys"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+)
+
+// SelfServiceHandler handles endpoints authenticated by an end-user API key
+type SelfServiceHandler struct {
+	keyMgr         *keys.Manager
+	teamMgr        *teams.Manager
+	maxKeysPerUser int
+	discoverer     *discovery.Discoverer
+}
+
+// NewSelfServiceHandler creates a new self-service handler
+func NewSelfServiceHandler(keyMgr *keys.Manager, teamMgr *teams.Manager, maxKeysPerUser int, discoverer *discovery.Discoverer) *SelfServiceHandler {
+	return &SelfServiceHandler{
+		keyMgr:         keyMgr,
+		teamMgr:        teamMgr,
+		maxKeysPerUser: maxKeysPerUser,
+		discoverer:     discoverer,
+	}
+}
+
+// GetMe handles GET /me
+func (h *SelfServiceHandler) GetMe(c *gin.Context) {
+	secret := c.MustGet(auth.ContextKeySecret).(*corev1.Secret)
+	teamID := secret.Labels["maas/team-id"]
+
+	policy, err := h.teamMgr.GetPolicy(teamID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	// Unlimited teams have no entry in the TokenRateLimitPolicy
+	var limits gin.H
+	if policy == "unlimited-policy" {
+		limits = gin.H{"unlimited": true}
+	} else if tokenLimit, timeWindow, err := h.teamMgr.GetLimits(teamID); err == nil {
+		limits = gin.H{"token_limit": tokenLimit, "time_window": timeWindow}
+	} else {
+		log.Printf("Failed to get limits for team %s: %v", teamID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":    secret.Labels["maas/user-id"],
+		"user_email": secret.Annotations["maas/user-email"],
+		"team_id":    teamID,
+		"team_name":  secret.Annotations["maas/team-name"],
+		"policy":     policy,
+		"limits":     limits,
+		"models":     splitModels(secret.Annotations["maas/models-allowed"]),
+		"key": gin.H{
+			"secret_name": secret.Name,
+			"alias":       secret.Annotations["maas/alias"],
+			"status":      secret.Annotations["maas/status"],
+			"created_at":  secret.Annotations["maas/created-at"],
+		},
+	})
+}
+
+// ListMyKeys handles GET /me/keys
+func (h *SelfServiceHandler) ListMyKeys(c *gin.Context) {
+	teamID := c.GetString(auth.ContextTeamID)
+	userID := c.GetString(auth.ContextUserID)
+
+	userKeys, err := h.keyMgr.ListTeamUserKeys(teamID, userID)
+	if err != nil {
+		log.Printf("Failed to get keys for user %s in team %s: %v", userID, teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":    userID,
+		"team_id":    teamID,
+		"keys":       userKeys,
+		"total_keys": len(userKeys),
+	})
+}
+
+// CreateMyKey handles POST /me/keys
+func (h *SelfServiceHandler) CreateMyKey(c *gin.Context) {
+	secret := c.MustGet(auth.ContextKeySecret).(*corev1.Secret)
+	teamID := c.GetString(auth.ContextTeamID)
+	userID := c.GetString(auth.ContextUserID)
+
+	var req keys.CreateSelfServiceKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if h.maxKeysPerUser > 0 {
+		count, err := h.keyMgr.CountActiveUserKeys(teamID, userID)
+		if err != nil {
+			log.Printf("Failed to count keys for user %s in team %s: %v", userID, teamID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+			return
+		}
+		if count >= h.maxKeysPerUser {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":        "Maximum number of API keys reached for this user",
+				"current_keys": count,
+				"max_keys":     h.maxKeysPerUser,
+			})
+			return
+		}
+	}
+
+	// A key can never grant access to models the calling key is not allowed to use.
+	// Callers without their own override inherit the team allowlist instead.
+	callerModels := splitModels(secret.Annotations["maas/models-allowed"])
+	models := req.Models
+	if len(models) == 0 {
+		if teams.KeyModelsSource(secret) == teams.ModelSourceKey {
+			models = callerModels
+		}
+	} else if len(callerModels) > 0 {
+		for _, model := range models {
+			if !containsString(callerModels, model) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Model not allowed for this key: " + model})
+				return
+			}
+		}
+	}
+
+	response, err := h.keyMgr.CreateTeamKey(teamID, &keys.CreateTeamKeyRequest{
+		UserID:            userID,
+		UserEmail:         secret.Annotations["maas/user-email"],
+		Alias:             req.Alias,
+		Models:            models,
+		InheritTeamLimits: true,
+	})
+	if err != nil {
+		log.Printf("Failed to create self-service key for user %s in team %s: %v", userID, teamID, err)
+		var limitErr *keys.KeyLimitError
+		if errors.As(err, &limitErr) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":        limitErr.Error(),
+				"scope":        limitErr.Scope,
+				"current_keys": limitErr.Current,
+				"max_keys":     limitErr.Max,
+			})
+			return
+		}
+		if strings.Contains(err.Error(), "is archived") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "is not allowed for team") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	response.Endpoint = keyEndpoint(h.discoverer, models)
+
+	log.Printf("Self-service API key created for user %s in team %s", userID, teamID)
+	c.JSON(http.StatusOK, response)
+}
+
+// splitModels parses the comma separated models-allowed annotation
+func splitModels(modelsAllowed string) []string {
+	models := make([]string, 0)
+	for _, model := range strings.Split(modelsAllowed, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/auth"
+)
+
+// SessionHandler exchanges admin and team-admin credentials for short-lived
+// session tokens, so the GUI never keeps a long-lived key in the browser
+type SessionHandler struct {
+	sessions *auth.Sessions
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessions *auth.Sessions) *SessionHandler {
+	return &SessionHandler{
+		sessions: sessions,
+	}
+}
+
+// CreateSession handles POST /auth/session, issuing a session token for the
+// credential the request was authenticated with
+func (h *SessionHandler) CreateSession(c *gin.Context) {
+	role := c.GetString(auth.ContextRole)
+	if role == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session tokens require admin authentication to be configured"})
+		return
+	}
+	var teamIDs []string
+	if teamID, ok := auth.ScopedTeam(c); ok {
+		teamIDs = []string{teamID}
+	}
+	subject := strings.TrimPrefix(auth.Identity(c), "session:")
+	// A session exchanged for a new one keeps its original authentication
+	// time, so it cannot outlive the maximum session age either
+	authTime := time.Now()
+	if value, exists := c.Get(auth.ContextSession); exists {
+		if claims, ok := value.(*auth.SessionClaims); ok {
+			authTime = claims.AuthenticatedAt()
+		}
+	}
+
+	h.issue(c, http.StatusCreated, subject, role, teamIDs, authTime)
+}
+
+// RefreshSession handles POST /auth/refresh, replacing the session token the
+// request was made with by a new one. Sessions past their maximum age are
+// answered with 401, so the client authenticates again.
+func (h *SessionHandler) RefreshSession(c *gin.Context) {
+	claims, ok := sessionClaims(c)
+	if !ok {
+		return
+	}
+
+	if h.issue(c, http.StatusOK, claims.Subject, claims.Role, claims.TeamIDs, claims.AuthenticatedAt()) {
+		h.sessions.Revoke(claims)
+	}
+}
+
+// Logout handles POST /auth/logout, revoking the session token the request
+// was made with
+func (h *SessionHandler) Logout(c *gin.Context) {
+	claims, ok := sessionClaims(c)
+	if !ok {
+		return
+	}
+	h.sessions.Revoke(claims)
+
+	log.Printf("Session of %s logged out", claims.Subject)
+	c.JSON(http.StatusOK, gin.H{"message": "Session logged out"})
+}
+
+// issue writes a new session token, reporting whether one was issued
+func (h *SessionHandler) issue(c *gin.Context, status int, subject, role string, teamIDs []string, authTime time.Time) bool {
+	token, claims, err := h.sessions.Issue(subject, role, teamIDs, authTime)
+	if errors.Is(err, auth.ErrSessionMaxAge) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Session has reached its maximum age, authenticate again",
+			"code":  auth.TokenErrorCode(err),
+		})
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to issue session token for %s: %v", subject, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue session token"})
+		return false
+	}
+
+	response := gin.H{
+		"token":      token,
+		"role":       claims.Role,
+		"expires_at": time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339),
+		"expires_in": int(claims.ExpiresAt - claims.IssuedAt),
+	}
+	if len(claims.TeamIDs) > 0 {
+		response["team_ids"] = claims.TeamIDs
+	}
+	c.JSON(status, response)
+	return true
+}
+
+// sessionClaims returns the claims of the session token a request was made
+// with, answering 400 when it was made with another credential
+func sessionClaims(c *gin.Context) (*auth.SessionClaims, bool) {
+	value, exists := c.Get(auth.ContextSession)
+	claims, ok := value.(*auth.SessionClaims)
+	if !exists || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This endpoint requires a session token"})
+		return nil, false
+	}
+	return claims, true
+}
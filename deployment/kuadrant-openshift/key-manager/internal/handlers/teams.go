@@ -1,25 +1,38 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"sigs.k8s.io/yaml"
 
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
 )
 
 // TeamsHandler handles team-related endpoints
 type TeamsHandler struct {
-	teamMgr *teams.Manager
+	teamMgr         *teams.Manager
+	limitadorClient *limitador.Client
+	defaultTeamTier string
+	// Default for ?mode= when removing members
+	memberRemovalMode string
 }
 
 // NewTeamsHandler creates a new teams handler
-func NewTeamsHandler(teamMgr *teams.Manager) *TeamsHandler {
+func NewTeamsHandler(teamMgr *teams.Manager, limitadorClient *limitador.Client, defaultTeamTier, memberRemovalMode string) *TeamsHandler {
 	return &TeamsHandler{
-		teamMgr: teamMgr,
+		teamMgr:           teamMgr,
+		limitadorClient:   limitadorClient,
+		defaultTeamTier:   defaultTeamTier,
+		memberRemovalMode: memberRemovalMode,
 	}
 }
 
@@ -27,7 +40,7 @@ func NewTeamsHandler(teamMgr *teams.Manager) *TeamsHandler {
 func (h *TeamsHandler) CreateTeam(c *gin.Context) {
 	var req teams.CreateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
 		return
 	}
 
@@ -36,23 +49,65 @@ func (h *TeamsHandler) CreateTeam(c *gin.Context) {
 		req.Policy = "unlimited-policy"
 	}
 
-	err := h.teamMgr.Create(&req)
+	// Async creation returns once the config is stored and applies policies later
+	async := c.Query("async") == "true"
+
+	var err error
+	if async {
+		err = h.teamMgr.CreateAsync(&req)
+	} else {
+		err = h.teamMgr.Create(&req)
+	}
 	if err != nil {
 		log.Printf("Failed to create team: %v", err)
+		var provErr *teams.ProvisioningError
 		if strings.Contains(err.Error(), "already exists") {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "queue is full") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "validation failed") || strings.Contains(err.Error(), "invalid webhook URL") ||
+			strings.Contains(err.Error(), "does not exist") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if errors.As(err, &provErr) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":       "Failed to create team",
+				"step":        provErr.Step,
+				"cause":       provErr.Err.Error(),
+				"rolled_back": provErr.RolledBack,
+				"leaked":      provErr.Leaked,
+			})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
 		}
 		return
 	}
 
+	if async {
+		log.Printf("Team creation accepted: %s (%s)", req.TeamID, req.TeamName)
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":    "Team creation accepted",
+			"team_id":    req.TeamID,
+			"status":     teams.ProvisioningPending,
+			"status_url": fmt.Sprintf("/teams/%s/provisioning", req.TeamID),
+		})
+		return
+	}
+
 	response := teams.CreateTeamResponse{
-		TeamID:      req.TeamID,
-		TeamName:    req.TeamName,
-		Description: req.Description,
-		Policy:      req.Policy,
-		CreatedAt:   time.Now().Format(time.RFC3339),
+		TeamID:           req.TeamID,
+		TeamName:         req.TeamName,
+		Description:      req.Description,
+		Policy:           req.Policy,
+		CreatedAt:        time.Now().Format(time.RFC3339),
+		BudgetUSDMonthly: req.BudgetUSDMonthly,
+		LimitScope:       req.LimitScope,
+		Namespace:        req.Namespace,
+		OwnerUserID:      req.OwnerUserID,
+		OwnerEmail:       req.OwnerEmail,
+		ParentTeamID:     req.ParentTeamID,
+	}
+	if rates, err := h.teamMgr.GetRates(req.TeamID); err == nil {
+		response.InheritedLimits = rates
 	}
 
 	log.Printf("Team created successfully: %s (%s)", req.TeamID, req.TeamName)
@@ -61,14 +116,30 @@ func (h *TeamsHandler) CreateTeam(c *gin.Context) {
 
 // ListTeams handles GET /teams
 func (h *TeamsHandler) ListTeams(c *gin.Context) {
-	teams, err := h.teamMgr.List()
+	opts := &teams.ListTeamsOptions{
+		Tier:            c.Query("tier"),
+		NameContains:    c.Query("name_contains"),
+		Sort:            c.Query("sort"),
+		Descending:      c.Query("order") == "desc",
+		IncludeArchived: c.Query("include_archived") == "true",
+	}
+	if opts.Sort != "" && opts.Sort != teams.TeamSortName && opts.Sort != teams.TeamSortCreatedAt {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of name, created_at"})
+		return
+	}
+	if order := c.Query("order"); order != "" && order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be one of asc, desc"})
+		return
+	}
+
+	teamList, err := h.teamMgr.List(opts)
 	if err != nil {
 		log.Printf("Failed to list teams: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list teams"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"teams": teams, "total_teams": len(teams)})
+	c.JSON(http.StatusOK, gin.H{"teams": teamList, "total_teams": len(teamList)})
 }
 
 // GetTeam handles GET /teams/:team_id
@@ -94,6 +165,32 @@ func (h *TeamsHandler) GetTeam(c *gin.Context) {
 		"user_count":  len(team.Members),
 	}
 
+	if team.OwnerUserID != "" {
+		response["owner_user_id"] = team.OwnerUserID
+		response["owner_email"] = team.OwnerEmail
+	}
+	if team.ParentTeamID != "" {
+		response["parent_team_id"] = team.ParentTeamID
+	}
+
+	// Enrich with live counter state for all team members
+	response["current_usage"] = h.limitadorClient.CurrentUsage(team.Policy, "")
+
+	// Parents also show each sub-team's own usage and the shared limit
+	if len(team.SubTeams) > 0 {
+		subteams := make([]gin.H, 0, len(team.SubTeams))
+		for _, subteam := range team.SubTeams {
+			subteams = append(subteams, gin.H{
+				"team_id":       subteam.TeamID,
+				"team_name":     subteam.TeamName,
+				"policy":        subteam.Policy,
+				"current_usage": h.limitadorClient.CurrentTeamUsage(subteam.Policy, subteam.TeamID),
+			})
+		}
+		response["sub_teams"] = subteams
+		response["sub_team_usage"] = h.limitadorClient.CurrentUsage(teams.SubteamLimitName(team.TeamID), "")
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -102,16 +199,33 @@ func (h *TeamsHandler) UpdateTeam(c *gin.Context) {
 	teamID := c.Param("team_id")
 	var req teams.UpdateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		bindError(c, err)
+		return
+	}
+
+	if req.TeamID != nil && *req.TeamID != teamID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team_id cannot be changed"})
+		return
+	}
+
+	if req.BudgetUSDMonthly != nil && *req.BudgetUSDMonthly < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "budget_usd_monthly must not be negative"})
+		return
+	}
+
+	if (req.MaxKeysPerUser != nil && *req.MaxKeysPerUser < 0) || (req.MaxKeysPerTeam != nil && *req.MaxKeysPerTeam < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_keys_per_user and max_keys_per_team must not be negative"})
 		return
 	}
 
-	err := h.teamMgr.Update(teamID, &req)
+	result, err := h.teamMgr.Update(teamID, &req)
 	if err != nil {
 		log.Printf("Failed to update team %s: %v", teamID, err)
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
-		} else if strings.Contains(err.Error(), "does not exist") {
+		} else if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "invalid webhook URL") ||
+			strings.Contains(err.Error(), "requires a signing secret") || strings.Contains(err.Error(), "invalid model_limits") || strings.Contains(err.Error(), "invalid token_limit") ||
+			strings.Contains(err.Error(), "invalid time_window") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
@@ -121,7 +235,724 @@ func (h *TeamsHandler) UpdateTeam(c *gin.Context) {
 
 	log.Printf("Team %s updated successfully", teamID)
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Team updated successfully",
+		"message":           "Team updated successfully",
+		"team_id":           teamID,
+		"changed_fields":    result.ChangedFields,
+		"policies_resynced": result.PoliciesResynced,
+	})
+}
+
+// UpdateDefaultTeam handles PUT /admin/default-team
+func (h *TeamsHandler) UpdateDefaultTeam(c *gin.Context) {
+	var req teams.UpdateDefaultTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	result, err := h.teamMgr.UpdateDefaultTeam(&req)
+	if err != nil {
+		log.Printf("Failed to update default team: %v", err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Default team not found, use POST /admin/default-team/recreate"})
+		} else if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "is required") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update default team"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RecreateDefaultTeam handles POST /admin/default-team/recreate
+func (h *TeamsHandler) RecreateDefaultTeam(c *gin.Context) {
+	var req teams.RecreateDefaultTeamRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindError(c, err)
+			return
+		}
+	}
+
+	tier := req.Tier
+	if tier == "" {
+		tier = h.defaultTeamTier
+	}
+
+	err := h.teamMgr.RecreateDefaultTeam(tier)
+	if err != nil {
+		log.Printf("Failed to recreate default team: %v", err)
+		if strings.Contains(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "validation failed") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recreate default team"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Default team recreated successfully",
+		"team_id": teams.DefaultTeamID,
+		"tier":    tier,
+	})
+}
+
+// ChangeTier handles POST /teams/:team_id/tier
+func (h *TeamsHandler) ChangeTier(c *gin.Context) {
+	teamID := c.Param("team_id")
+	var req teams.ChangeTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	result, err := h.teamMgr.ChangeTier(teamID, &req)
+	if err != nil {
+		log.Printf("Failed to change tier for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "does not exist") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change team tier"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ResetBudget handles POST /teams/:team_id/budget/reset
+func (h *TeamsHandler) ResetBudget(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	status, err := h.teamMgr.GetBudgetStatus(teamID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+	if status == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Team has no budget configured"})
+		return
+	}
+
+	status, err = h.teamMgr.ResetBudgetPeriod(teamID)
+	if err != nil {
+		log.Printf("Failed to reset budget for team %s: %v", teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset billing period"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Billing period reset successfully",
+		"team_id": teamID,
+		"budget":  status,
+	})
+}
+
+// AddTeamMember handles POST /teams/:team_id/members
+func (h *TeamsHandler) AddTeamMember(c *gin.Context) {
+	teamID := c.Param("team_id")
+	var req teams.AddUserToTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	member, err := h.teamMgr.AddMember(teamID, &req)
+	if err != nil {
+		log.Printf("Failed to add member to team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "already a member") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add team member"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// PropagateMetadata handles POST /teams/:team_id/propagate-metadata
+func (h *TeamsHandler) PropagateMetadata(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	result, err := h.teamMgr.PropagateMetadata(teamID)
+	if err != nil {
+		log.Printf("Failed to propagate metadata for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to propagate team metadata"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// TestWebhook handles POST /teams/:team_id/webhook/test
+func (h *TeamsHandler) TestWebhook(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	if err := h.teamMgr.TestWebhook(teamID); err != nil {
+		log.Printf("Webhook test failed for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "no webhook configured") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_id":   teamID,
+		"delivered": true,
+	})
+}
+
+// GetTeamModels handles GET /teams/:team_id/models
+func (h *TeamsHandler) GetTeamModels(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	models, err := h.teamMgr.GetEffectiveModels(teamID)
+	if err != nil {
+		log.Printf("Failed to get models for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team models"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models)
+}
+
+// GetTeamPolicies handles GET /teams/:team_id/policies
+func (h *TeamsHandler) GetTeamPolicies(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	status, err := h.teamMgr.GetPolicyStatus(teamID)
+	if err != nil {
+		log.Printf("Failed to get policy status for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team policies"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// PreviewTeamPolicies handles GET and POST /teams/:team_id/policies/preview.
+// GET previews a move to the tier in the query; POST may also set ad-hoc limits.
+func (h *TeamsHandler) PreviewTeamPolicies(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	req := teams.PolicyPreviewRequest{Tier: c.Query("tier")}
+	if c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindError(c, err)
+			return
+		}
+	}
+
+	preview, err := h.teamMgr.PreviewPolicies(teamID, &req)
+	if err != nil {
+		log.Printf("Failed to preview policies for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview team policies"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// ApplyTeams handles POST /admin/teams/apply. The manifest may be sent as
+// JSON or YAML.
+func (h *TeamsHandler) ApplyTeams(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		bindError(c, err)
+		return
+	}
+
+	var req teams.ApplyTeamsRequest
+	if err := yaml.UnmarshalStrict(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid manifest: %v", err)})
+		return
+	}
+	if len(req.Teams) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "manifest must list at least one team"})
+		return
+	}
+
+	result, err := h.teamMgr.ApplyTeams(&req)
+	if err != nil {
+		log.Printf("Failed to apply team manifest: %v", err)
+		if strings.Contains(err.Error(), "manifest") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply team manifest"})
+		}
+		return
+	}
+
+	// Per-team failures are reported in the body; the request itself succeeded
+	c.JSON(http.StatusOK, result)
+}
+
+// GetProvisioningStatus handles GET /teams/:team_id/provisioning
+func (h *TeamsHandler) GetProvisioningStatus(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	status, err := h.teamMgr.GetProvisioningStatus(teamID)
+	if err != nil {
+		log.Printf("Failed to get provisioning status for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get provisioning status"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetProvisioningOrphans handles GET /admin/provisioning/orphans
+func (h *TeamsHandler) GetProvisioningOrphans(c *gin.Context) {
+	report, err := h.teamMgr.FindOrphans()
+	if err != nil {
+		log.Printf("Failed to find orphaned team resources: %v", err)
+		if strings.Contains(err.Error(), "not configured") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find orphaned team resources"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetPolicyCompliance handles GET /admin/policies/compliance
+func (h *TeamsHandler) GetPolicyCompliance(c *gin.Context) {
+	opts := &teams.ComplianceOptions{TeamID: c.Query("team_id")}
+	var err error
+	if value := c.Query("offset"); value != "" {
+		if opts.Offset, err = strconv.Atoi(value); err != nil || opts.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if opts.Limit, err = strconv.Atoi(value); err != nil || opts.Limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative integer"})
+			return
+		}
+	}
+
+	report, err := h.teamMgr.CheckCompliance(opts)
+	if err != nil {
+		log.Printf("Failed to check policy compliance: %v", err)
+		if strings.Contains(err.Error(), "not configured") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check policy compliance"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// SyncAllPolicies handles POST /admin/policies/sync-all
+func (h *TeamsHandler) SyncAllPolicies(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	result, err := h.teamMgr.SyncAllPolicies(dryRun)
+	if err != nil {
+		log.Printf("Failed to sync all team policies: %v", err)
+		if strings.Contains(err.Error(), "not configured") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync team policies"})
+		}
+		return
+	}
+
+	// Report partial failures so the sync can be run again
+	if result.Failed > 0 {
+		c.JSON(http.StatusMultiStatus, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportPolicies handles GET /admin/policies/export
+func (h *TeamsHandler) ExportPolicies(c *gin.Context) {
+	manifests, err := h.teamMgr.ExportPolicies()
+	if err != nil {
+		log.Printf("Failed to export policies: %v", err)
+		if strings.Contains(err.Error(), "not configured") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export policies"})
+		}
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml", manifests)
+}
+
+// ValidateTeamPolicies handles POST /teams/:team_id/policies/validate
+func (h *TeamsHandler) ValidateTeamPolicies(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	validation, err := h.teamMgr.ValidatePolicies(teamID)
+	if err != nil {
+		log.Printf("Failed to validate policies for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not configured") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate team policies"})
+		}
+		return
+	}
+
+	if !validation.Valid {
+		c.JSON(http.StatusUnprocessableEntity, validation)
+		return
+	}
+	c.JSON(http.StatusOK, validation)
+}
+
+// GetTeamLimitStatus handles GET /teams/:team_id/limits/status
+func (h *TeamsHandler) GetTeamLimitStatus(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	status, err := h.teamMgr.GetLimitStatus(teamID)
+	if err != nil {
+		log.Printf("Failed to get limit status for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "not configured") {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get team limit status"})
+		}
+		return
+	}
+
+	// Live counters are optional; the limits are still reported without them
+	if h.limitadorClient == nil {
+		status.CountersUnavailable = true
+		status.CountersReason = "LIMITADOR_URL is not configured"
+	} else if counters, err := h.limitadorClient.GetCounters(); err != nil {
+		log.Printf("Warning: Failed to get Limitador counters: %v", err)
+		status.CountersUnavailable = true
+		status.CountersReason = "limitador is unreachable"
+	} else {
+		status.AttachCounters(counters, h.teamMgr.CounterOwners(teamID))
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SyncTeamPolicies handles POST /teams/:team_id/policies/sync
+func (h *TeamsHandler) SyncTeamPolicies(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	var req teams.SyncPoliciesRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindError(c, err)
+			return
+		}
+	}
+
+	status, err := h.teamMgr.SyncPolicies(teamID, req.LimitScope)
+	if err != nil {
+		log.Printf("Failed to sync policies for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "invalid limit scope") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync team policies"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetTeamModels handles PUT /teams/:team_id/models
+func (h *TeamsHandler) SetTeamModels(c *gin.Context) {
+	teamID := c.Param("team_id")
+	var req teams.SetTeamModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	models, err := h.teamMgr.SetTeamModels(teamID, req.Models)
+	if err != nil {
+		log.Printf("Failed to set models for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "invalid model name") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set team models"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models)
+}
+
+// ListTeamMembers handles GET /teams/:team_id/members
+func (h *TeamsHandler) ListTeamMembers(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	members, err := h.teamMgr.ListMembers(teamID)
+	if err != nil {
+		log.Printf("Failed to list members of team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list team members"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_id":       teamID,
+		"members":       members,
+		"total_members": len(members),
+	})
+}
+
+// UpdateTeamMember handles PATCH /teams/:team_id/members/:user_id
+func (h *TeamsHandler) UpdateTeamMember(c *gin.Context) {
+	teamID := c.Param("team_id")
+	userID := c.Param("user_id")
+	var req teams.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	member, err := h.teamMgr.UpdateMember(teamID, userID, &req)
+	if err != nil {
+		log.Printf("Failed to update member %s of team %s: %v", userID, teamID, err)
+		if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "not a member") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team member"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveTeamMember handles DELETE /teams/:team_id/members/:user_id
+func (h *TeamsHandler) RemoveTeamMember(c *gin.Context) {
+	teamID := c.Param("team_id")
+	userID := c.Param("user_id")
+
+	mode := c.DefaultQuery("mode", h.memberRemovalMode)
+
+	result, err := h.teamMgr.RemoveMember(teamID, userID, mode)
+	if err != nil {
+		log.Printf("Failed to remove %s from team %s: %v", userID, teamID, err)
+		if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "invalid mode") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "not a member") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "transfer ownership") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove team member"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Team member removed successfully",
+		"team_id":          teamID,
+		"user_id":          userID,
+		"mode":             result.Mode,
+		"deleted_keys":     result.DeletedKeys,
+		"deactivated_keys": result.DeactivatedKeys,
+	})
+}
+
+// TransferOwnership handles POST /teams/:team_id/transfer-ownership
+func (h *TeamsHandler) TransferOwnership(c *gin.Context) {
+	teamID := c.Param("team_id")
+	var req teams.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	result, err := h.teamMgr.TransferOwnership(teamID, &req)
+	if err != nil {
+		log.Printf("Failed to transfer ownership of team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "team not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "not a member") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "already owns") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer team ownership"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateAdminToken handles POST /teams/:team_id/admin-tokens
+func (h *TeamsHandler) CreateAdminToken(c *gin.Context) {
+	teamID := c.Param("team_id")
+	var req teams.CreateAdminTokenRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindError(c, err)
+			return
+		}
+	}
+
+	token, err := h.teamMgr.CreateAdminToken(teamID, &req)
+	if err != nil {
+		log.Printf("Failed to create admin token for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create admin token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// ListAdminTokens handles GET /teams/:team_id/admin-tokens
+func (h *TeamsHandler) ListAdminTokens(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	tokens, err := h.teamMgr.ListAdminTokens(teamID)
+	if err != nil {
+		log.Printf("Failed to list admin tokens for team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list admin tokens"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"team_id": teamID,
+		"tokens":  tokens,
+	})
+}
+
+// RevokeAdminToken handles DELETE /teams/:team_id/admin-tokens/:token_id
+func (h *TeamsHandler) RevokeAdminToken(c *gin.Context) {
+	teamID := c.Param("team_id")
+	tokenID := c.Param("token_id")
+
+	err := h.teamMgr.RevokeAdminToken(teamID, tokenID)
+	if err != nil {
+		log.Printf("Failed to revoke admin token %s for team %s: %v", tokenID, teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Admin token not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke admin token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Admin token revoked successfully",
+		"team_id":  teamID,
+		"token_id": tokenID,
+	})
+}
+
+// ArchiveTeam handles POST /teams/:team_id/archive
+func (h *TeamsHandler) ArchiveTeam(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	if err := h.teamMgr.Archive(teamID); err != nil {
+		log.Printf("Failed to archive team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "already archived") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive team"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team archived successfully",
+		"team_id": teamID,
+	})
+}
+
+// UnarchiveTeam handles POST /teams/:team_id/unarchive
+func (h *TeamsHandler) UnarchiveTeam(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	if err := h.teamMgr.Unarchive(teamID); err != nil {
+		log.Printf("Failed to unarchive team %s: %v", teamID, err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "not archived") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unarchive team"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Team unarchived successfully",
 		"team_id": teamID,
 	})
 }
@@ -129,18 +960,43 @@ func (h *TeamsHandler) UpdateTeam(c *gin.Context) {
 // DeleteTeam handles DELETE /teams/:team_id
 func (h *TeamsHandler) DeleteTeam(c *gin.Context) {
 	teamID := c.Param("team_id")
+	dryRun := c.Query("dry_run") == "true"
+
+	// Deleting cascades to every key, member and policy entry of the team,
+	// so the caller must name the team a second time
+	if !dryRun && c.Query("confirm") != teamID && c.GetHeader("X-Confirm-Delete") != teamID {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error": fmt.Sprintf("Deleting team %s removes all of its keys, members and policies. "+
+				"Confirm by repeating the team ID in ?confirm=%s or the X-Confirm-Delete header, "+
+				"or preview with ?dry_run=true.", teamID, teamID),
+		})
+		return
+	}
 
-	err := h.teamMgr.Delete(teamID)
+	result, err := h.teamMgr.Delete(teamID, dryRun)
 	if err != nil {
 		log.Printf("Failed to delete team %s: %v", teamID, err)
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else if strings.Contains(err.Error(), "sub-teams") || strings.Contains(err.Error(), "deletion-protected") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete team"})
 		}
 		return
 	}
 
+	if dryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// Report partial failures so the cascade can be retried
+	if result.Failed > 0 {
+		c.JSON(http.StatusMultiStatus, result)
+		return
+	}
+
 	log.Printf("Team deleted successfully: %s", teamID)
-	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully", "team_id": teamID})
+	c.JSON(http.StatusOK, result)
 }
\ No newline at end of file
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+)
+
+// TiersHandler handles tier policy endpoints
+type TiersHandler struct {
+	teamMgr *teams.Manager
+}
+
+// NewTiersHandler creates a new tiers handler
+func NewTiersHandler(teamMgr *teams.Manager) *TiersHandler {
+	return &TiersHandler{
+		teamMgr: teamMgr,
+	}
+}
+
+// GetTierPolicy handles GET /admin/policies/tiers/:tier
+func (h *TiersHandler) GetTierPolicy(c *gin.Context) {
+	tier := c.Param("tier")
+
+	policy, err := h.teamMgr.GetTierPolicy(tier)
+	if err != nil {
+		log.Printf("Failed to get tier %s: %v", tier, err)
+		writeTierError(c, err, "Failed to get tier")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// CreateTierPolicy handles POST /admin/policies/tiers
+func (h *TiersHandler) CreateTierPolicy(c *gin.Context) {
+	var req teams.CreateTierPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	policy, err := h.teamMgr.CreateTierPolicy(&req)
+	if err != nil {
+		log.Printf("Failed to create tier %s: %v", req.Tier, err)
+		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "is built in") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			writeTierError(c, err, "Failed to create tier")
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// UpdateTierPolicy handles PUT /admin/policies/tiers/:tier
+func (h *TiersHandler) UpdateTierPolicy(c *gin.Context) {
+	tier := c.Param("tier")
+	var req teams.UpdateTierPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	result, err := h.teamMgr.UpdateTierPolicy(tier, &req)
+	if err != nil {
+		log.Printf("Failed to update tier %s: %v", tier, err)
+		writeTierError(c, err, "Failed to update tier")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// writeTierError maps tier policy errors to responses
+func writeTierError(c *gin.Context, err error, message string) {
+	if strings.Contains(err.Error(), "does not exist") {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	} else if strings.Contains(err.Error(), "invalid") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else if strings.Contains(err.Error(), "not configured") {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/pricing"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/usage"
 )
@@ -21,10 +23,14 @@ type UsageHandler struct {
 	config       *rest.Config
 	keyNamespace string
 	collector    *usage.Collector
+	teamMgr      *teams.Manager
+	history      *usage.History
+	liveUsage    *teams.LiveUsage
+	costs        *pricing.Engine
 }
 
 // NewUsageHandler creates a new usage handler
-func NewUsageHandler(clientset *kubernetes.Clientset, config *rest.Config, keyNamespace string) *UsageHandler {
+func NewUsageHandler(clientset *kubernetes.Clientset, config *rest.Config, keyNamespace string, teamMgr *teams.Manager, history *usage.History, liveUsage *teams.LiveUsage, costs *pricing.Engine) *UsageHandler {
 	collector := usage.NewCollector(clientset, config, keyNamespace)
 	
 	return &UsageHandler{
@@ -32,6 +38,10 @@ func NewUsageHandler(clientset *kubernetes.Clientset, config *rest.Config, keyNa
 		config:       config,
 		keyNamespace: keyNamespace,
 		collector:    collector,
+		teamMgr:      teamMgr,
+		history:      history,
+		liveUsage:    liveUsage,
+		costs:        costs,
 	}
 }
 
@@ -61,6 +71,12 @@ func (h *UsageHandler) GetUserUsage(c *gin.Context) {
 func (h *UsageHandler) GetTeamUsage(c *gin.Context) {
 	teamID := c.Param("team_id")
 
+	rangeName := c.DefaultQuery("range", usage.DefaultRange)
+	if !usage.IsValidRange(rangeName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range must be one of 24h, 7d, 30d"})
+		return
+	}
+
 	// Validate team exists
 	teamSecret, err := h.clientset.CoreV1().Secrets(h.keyNamespace).Get(
 		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
@@ -86,9 +102,30 @@ func (h *UsageHandler) GetTeamUsage(c *gin.Context) {
 
 	// Enrich with team metadata
 	teamUsage.TeamName = teamSecret.Annotations["maas/team-name"]
+	teamUsage.Budget = teams.BudgetStatusFromAnnotations(teamSecret.Annotations)
+	if h.teamMgr != nil {
+		if allowance, err := h.teamMgr.GetTokenAllowance(teamID); err != nil {
+			log.Printf("Warning: Failed to get token allowance for team %s: %v", teamID, err)
+		} else {
+			teamUsage.Allowance = allowance
+		}
+	}
+	if cost, err := h.costs.TeamCost(teamID); err != nil {
+		log.Printf("Warning: Failed to get cost for team %s: %v", teamID, err)
+	} else {
+		teamUsage.Cost = cost
+	}
+
+	keyNamespace := h.keyNamespace
+	if ns := teamSecret.Annotations["maas/key-namespace"]; ns != "" {
+		keyNamespace = ns
+	}
+	if h.history != nil {
+		teamUsage.History = h.history.TeamUsage(c.Request.Context(), policyName, h.teamUserIDs(teamID, keyNamespace), rangeName)
+	}
 
 	// Enrich with user emails from secrets
-	err = h.enrichTeamUsage(teamUsage)
+	err = h.enrichTeamUsage(teamUsage, keyNamespace)
 	if err != nil {
 		log.Printf("Failed to enrich team usage data: %v", err)
 		// Continue with basic data even if enrichment fails
@@ -97,6 +134,91 @@ func (h *UsageHandler) GetTeamUsage(c *gin.Context) {
 	c.JSON(http.StatusOK, teamUsage)
 }
 
+// GetTeamLiveUsage handles GET /teams/:team_id/usage/live (admin only),
+// returning the team's Limitador counters as of the last collection
+func (h *UsageHandler) GetTeamLiveUsage(c *gin.Context) {
+	teamID := c.Param("team_id")
+
+	// Validate team exists
+	_, err := h.clientset.CoreV1().Secrets(h.keyNamespace).Get(
+		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.liveUsage.Team(teamID))
+}
+
+// usageRecordsRequest is a batch of usage records to charge
+type usageRecordsRequest struct {
+	Records []pricing.UsageRecord `json:"records" binding:"required"`
+}
+
+// RecordUsage handles POST /admin/usage/records, charging each record to its
+// team and key at the current model prices. The whole batch is validated
+// before anything is charged.
+func (h *UsageHandler) RecordUsage(c *gin.Context) {
+	var req usageRecordsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	fields := make([]FieldError, 0)
+	teamExists := make(map[string]bool)
+	for i, record := range req.Records {
+		field := fmt.Sprintf("records[%d]", i)
+		if err := h.costs.Validate(record); err != nil {
+			fields = append(fields, FieldError{Field: field, Error: err.Error()})
+			continue
+		}
+		exists, checked := teamExists[record.TeamID]
+		if !checked {
+			exists = h.teamMgr.Exists(record.TeamID)
+			teamExists[record.TeamID] = exists
+		}
+		if !exists {
+			fields = append(fields, FieldError{Field: field, Error: fmt.Sprintf("team %s does not exist", record.TeamID)})
+		}
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid usage records", "fields": fields})
+		return
+	}
+
+	charges, duplicates, err := h.costs.Record(req.Records)
+	if err != nil {
+		// Teams charged before the failure keep their charges; records with
+		// an id can be sent again without charging them twice
+		log.Printf("Failed to record usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":    "Failed to record usage",
+			"recorded": len(charges),
+		})
+		return
+	}
+
+	cost := make(map[string]float64)
+	unpriced := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, charge := range charges {
+		cost[charge.Currency] += charge.Cost
+		if charge.DefaultPrice && !seen[charge.Model] {
+			seen[charge.Model] = true
+			unpriced = append(unpriced, charge.Model)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recorded":        len(charges),
+		"duplicates":      duplicates,
+		"cost":            cost,
+		"unpriced_models": unpriced,
+		"charges":         charges,
+	})
+}
+
 // enrichUserUsage adds team names and other metadata to user usage
 func (h *UsageHandler) enrichUserUsage(userUsage *types.UserUsage) error {
 	// Get all team config secrets to map policies to teams
@@ -137,14 +259,46 @@ func (h *UsageHandler) enrichUserUsage(userUsage *types.UserUsage) error {
 	return nil
 }
 
+// teamUserIDs returns the users whose usage counts towards a team: its
+// members, including those only known from their keys
+func (h *UsageHandler) teamUserIDs(teamID, keyNamespace string) []string {
+	userIDs := make([]string, 0)
+	if h.teamMgr != nil {
+		members, err := h.teamMgr.ListMembers(teamID)
+		if err == nil {
+			for _, member := range members {
+				userIDs = append(userIDs, member.UserID)
+			}
+			return userIDs
+		}
+		log.Printf("Warning: Failed to list members of team %s: %v", teamID, err)
+	}
+
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
+	secrets, err := h.clientset.CoreV1().Secrets(keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		log.Printf("Warning: Failed to list keys of team %s: %v", teamID, err)
+		return userIDs
+	}
+	seen := make(map[string]bool)
+	for _, secret := range secrets.Items {
+		if userID := secret.Labels["maas/user-id"]; userID != "" && !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
+}
+
 // enrichTeamUsage adds user emails and other metadata to team usage
-func (h *UsageHandler) enrichTeamUsage(teamUsage *types.TeamUsage) error {
+func (h *UsageHandler) enrichTeamUsage(teamUsage *types.TeamUsage, keyNamespace string) error {
 	for i, userUsage := range teamUsage.UserBreakdown {
 		// Find user's API key secret to get email
 		labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s,maas/user-id=%s", 
 			teamUsage.TeamID, userUsage.UserID)
 		
-		secrets, err := h.clientset.CoreV1().Secrets(h.keyNamespace).List(
+		secrets, err := h.clientset.CoreV1().Secrets(keyNamespace).List(
 			context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
 		if err != nil {
 			log.Printf("Failed to get user secrets for %s: %v", userUsage.UserID, err)
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a problem with one field of a request body
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// ConfigureBinding makes JSON request bodies strict: unknown fields, such as
+// a misspelt tokenlimit, are rejected instead of silently dropped, and
+// validation errors name fields as they are spelt in JSON
+func ConfigureBinding() {
+	binding.EnableDecoderDisallowUnknownFields = true
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// BodyLimitMiddleware caps request bodies at maxBytes. Reading past the cap
+// fails, which handlers answer with 413 through bindError.
+func BodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// bindError answers a request whose body could not be read or bound, listing
+// the fields at fault when they are known
+func bindError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit),
+		})
+		return
+	}
+
+	fields := make([]FieldError, 0)
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrors):
+		for _, fieldErr := range validationErrors {
+			message := "is required"
+			if fieldErr.Tag() != "required" {
+				message = fmt.Sprintf("failed the %s check", fieldErr.Tag())
+			}
+			fields = append(fields, FieldError{Field: fieldErr.Field(), Error: message})
+		}
+	case errors.As(err, &typeError):
+		fields = append(fields, FieldError{Field: typeError.Field, Error: "must be a " + typeError.Type.String()})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		fields = append(fields, FieldError{Field: field, Error: "is not a known field"})
+	case errors.Is(err, io.EOF):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body is required"})
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":  "Invalid request body",
+		"fields": fields,
+	})
+}
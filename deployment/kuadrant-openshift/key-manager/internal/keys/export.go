@@ -0,0 +1,262 @@
+package keys
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
+)
+
+// TeamsExportVersion is the format version of team export documents
+const TeamsExportVersion = "v1"
+
+// ImportStatusExisting marks a team that was already present on import
+const ImportStatusExisting = "existing"
+
+// keyStatusPendingRotation marks keys without a usable plaintext value
+const keyStatusPendingRotation = "pending-rotation"
+
+// ExportTeams exports every team with its members, rendered policy limits and
+// key metadata. Plaintext keys are never exported.
+func (m *Manager) ExportTeams() (*TeamsExport, error) {
+	teamExports, err := m.teamMgr.ExportTeams()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &TeamsExport{
+		Version:    TeamsExportVersion,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Teams:      make([]ExportedTeam, 0, len(teamExports)),
+	}
+	for _, team := range teamExports {
+		teamKeys, err := m.exportTeamKeys(team.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Teams = append(doc.Teams, ExportedTeam{TeamExport: team, Keys: teamKeys})
+	}
+
+	log.Printf("Exported %d teams", len(doc.Teams))
+	return doc, nil
+}
+
+// exportTeamKeys returns the metadata of a team's API keys
+func (m *Manager) exportTeamKeys(teamID string) ([]ExportedKey, error) {
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.teamMgr.KeyNamespace(teamID)).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys of team %s: %w", teamID, err)
+	}
+
+	exported := make([]ExportedKey, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		key := ExportedKey{
+			SecretName: secret.Name,
+			UserID:     secret.Labels["maas/user-id"],
+			UserEmail:  secret.Annotations["maas/user-email"],
+			Alias:      secret.Annotations["maas/alias"],
+			Tags:       tagsFromLabels(secret.Labels),
+			Status:     secret.Annotations["maas/status"],
+			CreatedAt:  secret.Annotations["maas/created-at"],
+			HashAlgo:   secret.Annotations["maas/hash-algo"],
+			KeySHA256:  secret.Labels["maas/key-sha256"],
+			KeyHash:    secret.Annotations["maas/key-hash"],
+			KeyID:      secret.Labels[labelKeyID],
+			KeyFormat:  secret.Annotations[annotationKeyFormat],
+		}
+		if key.HashAlgo == "" {
+			key.HashAlgo = HashAlgoSHA256
+		}
+		// Keys following the team or tier allowlist pick it up again on import
+		if teams.KeyModelsSource(secret) == teams.ModelSourceKey {
+			key.Models = teams.SplitModels(secret.Annotations["maas/models-allowed"])
+		}
+		exported = append(exported, key)
+	}
+
+	return exported, nil
+}
+
+// ImportTeams recreates exported teams, their members and keys. Teams are
+// handled independently and existing teams and keys are skipped, so an import
+// can be re-run. Imported keys await rotation since plaintext never leaves
+// the source cluster.
+func (m *Manager) ImportTeams(source string, doc *TeamsExport) *ImportTeamsResponse {
+	response := &ImportTeamsResponse{
+		Source:  source,
+		Results: make([]ImportTeamResult, 0, len(doc.Teams)),
+	}
+
+	for i := range doc.Teams {
+		team := &doc.Teams[i]
+		result := ImportTeamResult{TeamID: team.TeamID, Keys: make([]ImportKeyResult, 0, len(team.Keys))}
+
+		created, added, err := m.teamMgr.ImportTeam(&team.TeamExport)
+		result.MembersAdded = added
+		if err != nil {
+			result.Status = ImportStatusFailed
+			result.Error = err.Error()
+			response.Failed++
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		for j := range team.Keys {
+			record := &team.Keys[j]
+			keyResult := ImportKeyResult{Index: j, UserID: record.UserID, TeamID: team.TeamID}
+
+			secretName, err := m.importExportedKey(team.TeamID, source, record)
+			switch {
+			case err == nil:
+				keyResult.Status = ImportStatusImported
+				keyResult.SecretName = secretName
+				result.KeysImported++
+			case secretName != "":
+				keyResult.Status = ImportStatusDuplicate
+				keyResult.SecretName = secretName
+				keyResult.Error = err.Error()
+				result.KeysDuplicate++
+			default:
+				keyResult.Status = ImportStatusFailed
+				keyResult.Error = err.Error()
+				result.KeysFailed++
+			}
+			result.Keys = append(result.Keys, keyResult)
+		}
+
+		// Archive only once the keys exist so they are suspended with the team
+		if created && team.Status == "archived" {
+			if err := m.teamMgr.Archive(team.TeamID); err != nil {
+				log.Printf("Warning: Failed to archive imported team %s: %v", team.TeamID, err)
+			}
+		}
+
+		if created {
+			result.Status = ImportStatusImported
+			response.Imported++
+		} else {
+			result.Status = ImportStatusExisting
+			response.Existing++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	log.Printf("Team import from %s: %d imported, %d existing, %d failed",
+		source, response.Imported, response.Existing, response.Failed)
+	return response
+}
+
+// importExportedKey recreates an exported key in the pending rotation state.
+// When the key already exists the name of the existing secret is returned
+// alongside the error.
+func (m *Manager) importExportedKey(teamID, source string, record *ExportedKey) (string, error) {
+	if !ValidateUserID(record.UserID) {
+		return "", fmt.Errorf("invalid user_id")
+	}
+	if err := ValidateTags(record.Tags); err != nil {
+		return "", fmt.Errorf("invalid tags: %w", err)
+	}
+
+	createdAt := time.Now().Format(time.RFC3339)
+	if record.CreatedAt != "" {
+		parsed, err := time.Parse(time.RFC3339, record.CreatedAt)
+		if err != nil {
+			return "", fmt.Errorf("created_at must be RFC3339")
+		}
+		createdAt = parsed.Format(time.RFC3339)
+	}
+
+	var key *storedKey
+	switch {
+	case record.KeyHash != "":
+		hasher, err := NewHasher(record.HashAlgo)
+		if err != nil || !hasher.Salted() {
+			return "", fmt.Errorf("key_hash requires a salted hash_algo")
+		}
+		if m.fipsMode && !IsFIPSApprovedHashAlgo(hasher.Name()) {
+			return "", fmt.Errorf("invalid hash_algo %s: not FIPS approved", hasher.Name())
+		}
+		if existing, err := m.findKeySecretBySaltedHash(teamID, record.KeyHash); err == nil {
+			return existing.Name, fmt.Errorf("API key already exists")
+		}
+		if record.KeyID != "" && !isKeyID(record.KeyID) {
+			return "", fmt.Errorf("key_id must be 32 hex characters")
+		}
+		key = &storedKey{hash: record.KeyHash, algo: hasher.Name(), salted: true, id: record.KeyID}
+	case record.KeySHA256 != "":
+		keyHash := strings.ToLower(record.KeySHA256)
+		if decoded, err := hex.DecodeString(keyHash); err != nil || len(decoded) < 16 {
+			return "", fmt.Errorf("key_sha256 must be at least 32 hex characters")
+		}
+		if existing, err := m.findKeySecretByHash(keyHash); err == nil {
+			return existing.Name, fmt.Errorf("API key already exists")
+		}
+		key = &storedKey{hash: keyHash, algo: HashAlgoSHA256}
+	default:
+		return "", fmt.Errorf("one of key_sha256 or key_hash is required")
+	}
+	if IsValidKeyFormat(record.KeyFormat) {
+		key.format = record.KeyFormat
+	}
+
+	teamMember, err := m.resolveTeamMember(teamID, record.UserID, record.UserEmail)
+	if err != nil {
+		return "", err
+	}
+
+	createReq := &CreateTeamKeyRequest{
+		UserID:    record.UserID,
+		UserEmail: teamMember.UserEmail,
+		Alias:     record.Alias,
+		Models:    record.Models,
+		Tags:      record.Tags,
+	}
+	secret, err := m.buildKeySecret(teamID, createReq, key, teamMember)
+	if err != nil {
+		return "", err
+	}
+
+	secret.Annotations["maas/imported-from"] = source
+	secret.Annotations["maas/created-at"] = createdAt
+	secret.Annotations["maas/status"] = keyStatusPendingRotation
+
+	created, err := m.createUniqueKeySecret(secret, record.UserID, teamID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create key secret: %w", err)
+	}
+
+	m.webhooks.Dispatch(webhook.EventKeyCreated, teamID, record.UserID, created.Name)
+	m.events.Key(created.Name, corev1.EventTypeNormal, events.ReasonKeyCreated,
+		"API key imported from %s for user %s in team %s, pending rotation", source, record.UserID, teamID)
+
+	return created.Name, nil
+}
+
+// findKeySecretBySaltedHash locates a team key secret by its stored salted hash
+func (m *Manager) findKeySecretBySaltedHash(teamID, keyHash string) (*corev1.Secret, error) {
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.teamMgr.KeyNamespace(teamID)).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+
+	for i := range secrets.Items {
+		if secrets.Items[i].Annotations["maas/key-hash"] == keyHash {
+			return &secrets.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found")
+}
@@ -3,14 +3,106 @@ package keys
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"math/big"
 	"regexp"
+	"strings"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// Key formats, recorded in the maas/key-format annotation of each key
+const (
+	// KeyFormatV1 is 48 random base64url characters
+	KeyFormatV1 = "v1"
+	// KeyFormatV2 is maas_<payload>_<checksum>: 43 random base62 characters
+	// and their CRC-32 in 6 base62 characters, so a truncated or mistyped
+	// key can be recognized without looking it up
+	KeyFormatV2 = "v2"
 )
 
+const (
+	keyPrefix           = "maas_"
+	keyPayloadLength    = 43
+	keyChecksumLength   = 6
+	legacyKeyLength     = 48
+	base62Alphabet      = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base64URLAlphabet   = base62Alphabet + "-_"
+	annotationKeyFormat = "maas/key-format"
+)
+
+// IsValidKeyFormat reports whether a key format can be generated
+func IsValidKeyFormat(format string) bool {
+	return format == KeyFormatV1 || format == KeyFormatV2
+}
+
+// GenerateKey generates an API key in a format
+func GenerateKey(format string) (string, error) {
+	if format != KeyFormatV2 {
+		return GenerateSecureToken(legacyKeyLength)
+	}
+
+	payload := make([]byte, keyPayloadLength)
+	alphabetSize := big.NewInt(int64(len(base62Alphabet)))
+	for i := range payload {
+		n, err := rand.Int(fips.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		payload[i] = base62Alphabet[n.Int64()]
+	}
+	return keyPrefix + string(payload) + "_" + keyChecksum(string(payload)), nil
+}
+
+// keyChecksum encodes the CRC-32 of a key payload in base62
+func keyChecksum(payload string) string {
+	sum := crc32.ChecksumIEEE([]byte(payload))
+	checksum := make([]byte, keyChecksumLength)
+	for i := keyChecksumLength - 1; i >= 0; i-- {
+		checksum[i] = base62Alphabet[sum%62]
+		sum /= 62
+	}
+	return string(checksum)
+}
+
+// ValidateKeyFormat checks that a key is syntactically well formed and
+// returns its format. It says nothing about whether the key exists.
+func ValidateKeyFormat(key string) (string, error) {
+	if strings.HasPrefix(key, keyPrefix) {
+		parts := strings.Split(strings.TrimPrefix(key, keyPrefix), "_")
+		if len(parts) != 2 || len(parts[0]) != keyPayloadLength || len(parts[1]) != keyChecksumLength {
+			return KeyFormatV2, fmt.Errorf("key has the wrong length; it may be truncated")
+		}
+		if !onlyCharacters(parts[0], base62Alphabet) || !onlyCharacters(parts[1], base62Alphabet) {
+			return KeyFormatV2, fmt.Errorf("key contains invalid characters")
+		}
+		if keyChecksum(parts[0]) != parts[1] {
+			return KeyFormatV2, fmt.Errorf("key checksum does not match; it may be mistyped")
+		}
+		return KeyFormatV2, nil
+	}
+	if len(key) == legacyKeyLength && onlyCharacters(key, base64URLAlphabet) {
+		return KeyFormatV1, nil
+	}
+	return "", fmt.Errorf("key is not in a recognized format")
+}
+
+// onlyCharacters reports whether s consists of characters of alphabet
+func onlyCharacters(s, alphabet string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(alphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
 // GenerateSecureToken generates a cryptographically secure random token
 func GenerateSecureToken(length int) (string, error) {
 	// Generate random bytes
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
+	bytes, err := fips.RandomBytes(length)
+	if err != nil {
 		return "", err
 	}
 
@@ -34,4 +126,4 @@ func isValidUserID(userID string) bool {
 // ValidateUserID validates a user ID using Kubernetes naming rules
 func ValidateUserID(userID string) bool {
 	return isValidUserID(userID)
-}
\ No newline at end of file
+}
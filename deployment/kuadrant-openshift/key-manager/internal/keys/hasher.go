@@ -0,0 +1,153 @@
+package keys
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// Supported key hashing algorithms
+const (
+	HashAlgoSHA256   = "sha256"
+	HashAlgoBcrypt   = "bcrypt"
+	HashAlgoArgon2id = "argon2id"
+)
+
+// Hasher hashes API keys for storage and verifies presented keys against stored hashes
+type Hasher interface {
+	// Name is recorded in the maas/hash-algo annotation of every key secret
+	Name() string
+	Hash(apiKey string) (string, error)
+	Verify(apiKey, hash string) bool
+	// Salted hashes cannot be looked up by label, their keys are found by key ID
+	Salted() bool
+}
+
+// NewHasher returns the hasher for an algorithm name
+func NewHasher(algo string) (Hasher, error) {
+	switch algo {
+	case "", HashAlgoSHA256:
+		return sha256Hasher{}, nil
+	case HashAlgoBcrypt:
+		return bcryptHasher{cost: bcrypt.DefaultCost}, nil
+	case HashAlgoArgon2id:
+		return argon2idHasher{time: 1, memory: 64 * 1024, threads: 4, keyLen: 32}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key hash algorithm %q", algo)
+	}
+}
+
+// IsFIPSApprovedHashAlgo reports whether an algorithm may be used in FIPS
+// mode. bcrypt and argon2id are not approved.
+func IsFIPSApprovedHashAlgo(algo string) bool {
+	return algo == "" || algo == HashAlgoSHA256
+}
+
+// sha256Hasher is the unsalted default, suitable for long random tokens
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string { return HashAlgoSHA256 }
+
+func (sha256Hasher) Salted() bool { return false }
+
+func (sha256Hasher) Hash(apiKey string) (string, error) {
+	return hashAPIKey(apiKey), nil
+}
+
+func (sha256Hasher) Verify(apiKey, hash string) bool {
+	computed := hashAPIKey(apiKey)
+	// Only a full digest verifies, truncated label values never do
+	if len(hash) != len(computed) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// bcryptHasher uses golang.org/x/crypto/bcrypt
+type bcryptHasher struct {
+	cost int
+}
+
+func (bcryptHasher) Name() string { return HashAlgoBcrypt }
+
+func (bcryptHasher) Salted() bool { return true }
+
+func (h bcryptHasher) Hash(apiKey string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(apiKey), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (bcryptHasher) Verify(apiKey, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(apiKey)) == nil
+}
+
+// argon2idHasher encodes hashes in the PHC string format
+type argon2idHasher struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	keyLen  uint32
+}
+
+func (argon2idHasher) Name() string { return HashAlgoArgon2id }
+
+func (argon2idHasher) Salted() bool { return true }
+
+func (h argon2idHasher) Hash(apiKey string) (string, error) {
+	salt, err := fips.RandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(apiKey), salt, h.time, h.memory, h.threads, h.keyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.time, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (argon2idHasher) Verify(apiKey, hash string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashAlgoArgon2id {
+		return false
+	}
+
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(apiKey), salt, iterations, memory, threads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// randomSuffix returns a random hex string used to name secrets of salted keys
+func randomSuffix(length int) (string, error) {
+	suffix, err := fips.RandomHex((length + 1) / 2)
+	if err != nil {
+		return "", err
+	}
+	return suffix[:length], nil
+}
+
+// hashAPIKey returns the hex encoded SHA256 hash of an API key
+func hashAPIKey(apiKey string) string {
+	return fips.SHA256Hex([]byte(apiKey))
+}
@@ -0,0 +1,101 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsFIPSApprovedHashAlgo(t *testing.T) {
+	tests := []struct {
+		algo string
+		want bool
+	}{
+		{algo: "", want: true},
+		{algo: HashAlgoSHA256, want: true},
+		{algo: HashAlgoBcrypt, want: false},
+		{algo: HashAlgoArgon2id, want: false},
+		{algo: "md5", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo, func(t *testing.T) {
+			if got := IsFIPSApprovedHashAlgo(tt.algo); got != tt.want {
+				t.Errorf("IsFIPSApprovedHashAlgo(%q) = %v, want %v", tt.algo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFIPSModeRejectsSaltedImports(t *testing.T) {
+	m := &Manager{fipsMode: true}
+
+	tests := []struct {
+		algo string
+		hash string
+	}{
+		{algo: HashAlgoBcrypt, hash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{algo: HashAlgoArgon2id, hash: "$argon2id$v=19$m=65536,t=1,p=4$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo, func(t *testing.T) {
+			_, err := m.importExportedKey("team-a", "backup", &ExportedKey{UserID: "alice", HashAlgo: tt.algo, KeyHash: tt.hash})
+			if err == nil || !strings.Contains(err.Error(), "not FIPS approved") {
+				t.Errorf("importExportedKey() = %v, want a FIPS error", err)
+			}
+		})
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		algo     string
+		wantName string
+		salted   bool
+	}{
+		{algo: "", wantName: HashAlgoSHA256},
+		{algo: HashAlgoSHA256, wantName: HashAlgoSHA256},
+		{algo: HashAlgoBcrypt, wantName: HashAlgoBcrypt, salted: true},
+		{algo: HashAlgoArgon2id, wantName: HashAlgoArgon2id, salted: true},
+	}
+
+	const apiKey = "maas_7Hq2LmXw9RtBv3KpZs6NcYd1FgJe8UaWo4QiTkMrVnE_2bXk9P"
+	for _, tt := range tests {
+		t.Run(tt.wantName, func(t *testing.T) {
+			hasher, err := NewHasher(tt.algo)
+			if err != nil {
+				t.Fatalf("NewHasher(%q) = %v", tt.algo, err)
+			}
+			if hasher.Name() != tt.wantName || hasher.Salted() != tt.salted {
+				t.Errorf("NewHasher(%q) = %s salted %v, want %s salted %v", tt.algo, hasher.Name(), hasher.Salted(), tt.wantName, tt.salted)
+			}
+
+			hash, err := hasher.Hash(apiKey)
+			if err != nil {
+				t.Fatalf("Hash() = %v", err)
+			}
+			if !hasher.Verify(apiKey, hash) {
+				t.Errorf("Verify() rejected the key it hashed")
+			}
+			if hasher.Verify(apiKey+"x", hash) {
+				t.Errorf("Verify() accepted another key")
+			}
+		})
+	}
+
+	if _, err := NewHasher("md5"); err == nil {
+		t.Errorf("NewHasher(\"md5\") = nil error")
+	}
+}
+
+func TestSHA256HasherRejectsTruncatedHashes(t *testing.T) {
+	const apiKey = "maas_7Hq2LmXw9RtBv3KpZs6NcYd1FgJe8UaWo4QiTkMrVnE_2bXk9P"
+	hash := hashAPIKey(apiKey)
+
+	if (sha256Hasher{}).Verify(apiKey, hash[:63]) {
+		t.Errorf("Verify() accepted a truncated hash")
+	}
+	if (sha256Hasher{}).Verify(apiKey, "") {
+		t.Errorf("Verify() accepted an empty hash")
+	}
+}
@@ -0,0 +1,164 @@
+package keys
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
+)
+
+// Per-row outcomes of a key import
+const (
+	ImportStatusImported  = "imported"
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusFailed    = "failed"
+)
+
+// ImportKeys brings existing API keys over from another system. Each record is
+// handled independently and duplicates are skipped, so a partially failed
+// import can be re-submitted as a whole. Plaintext keys are never logged.
+func (m *Manager) ImportKeys(req *ImportKeysRequest) *ImportKeysResponse {
+	response := &ImportKeysResponse{
+		Source:  req.Source,
+		Results: make([]ImportKeyResult, 0, len(req.Keys)),
+	}
+
+	for i := range req.Keys {
+		record := &req.Keys[i]
+		result := ImportKeyResult{Index: i, UserID: record.UserID, TeamID: record.TeamID}
+
+		secretName, err := m.importKey(req.Source, record)
+		switch {
+		case err == nil:
+			result.Status = ImportStatusImported
+			result.SecretName = secretName
+			response.Imported++
+		case secretName != "":
+			result.Status = ImportStatusDuplicate
+			result.SecretName = secretName
+			result.Error = err.Error()
+			response.Duplicates++
+		default:
+			result.Status = ImportStatusFailed
+			result.Error = err.Error()
+			response.Failed++
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	log.Printf("Key import from %s: %d imported, %d duplicates, %d failed",
+		req.Source, response.Imported, response.Duplicates, response.Failed)
+
+	if response.Imported > 0 {
+		if err := m.restartAuthorino(); err != nil {
+			log.Printf("Warning: Failed to restart Authorino after key import: %v", err)
+		}
+	}
+
+	return response
+}
+
+// importKey imports a single record. When the key already exists the name of
+// the existing secret is returned alongside the error.
+func (m *Manager) importKey(source string, record *ImportKeyRecord) (string, error) {
+	if !ValidateUserID(record.UserID) {
+		return "", fmt.Errorf("invalid user_id")
+	}
+	if (record.APIKey == "") == (record.KeySHA256 == "") {
+		return "", fmt.Errorf("exactly one of api_key or key_sha256 is required")
+	}
+
+	createdAt := time.Now().Format(time.RFC3339)
+	if record.CreatedAt != "" {
+		parsed, err := time.Parse(time.RFC3339, record.CreatedAt)
+		if err != nil {
+			return "", fmt.Errorf("created_at must be RFC3339")
+		}
+		createdAt = parsed.Format(time.RFC3339)
+	}
+
+	if !m.teamMgr.Exists(record.TeamID) {
+		return "", fmt.Errorf("team not found")
+	}
+	if m.teamMgr.IsArchived(record.TeamID) {
+		return "", fmt.Errorf("team %s is archived", record.TeamID)
+	}
+
+	teamMember, err := m.resolveTeamMember(record.TeamID, record.UserID, record.UserEmail)
+	if err != nil {
+		return "", err
+	}
+	if record.Policy != "" && record.Policy != teamMember.Policy {
+		return "", fmt.Errorf("policy %s does not match team policy %s", record.Policy, teamMember.Policy)
+	}
+
+	var key *storedKey
+	if record.APIKey != "" {
+		if existing, err := m.findKeySecret(record.APIKey); err == nil {
+			return existing.Name, fmt.Errorf("API key already exists")
+		}
+		if key, err = m.hashKey(record.APIKey); err != nil {
+			return "", err
+		}
+	} else {
+		keyHash := strings.ToLower(record.KeySHA256)
+		if decoded, err := hex.DecodeString(keyHash); err != nil || len(decoded) != 32 {
+			return "", fmt.Errorf("key_sha256 must be a hex encoded SHA256 digest")
+		}
+		if existing, err := m.findKeySecretByHash(keyHash); err == nil {
+			return existing.Name, fmt.Errorf("API key already exists")
+		}
+		key = &storedKey{hash: keyHash, algo: HashAlgoSHA256}
+	}
+
+	createReq := &CreateTeamKeyRequest{
+		UserID:    record.UserID,
+		UserEmail: teamMember.UserEmail,
+		Models:    record.Models,
+	}
+	secret, err := m.buildKeySecret(record.TeamID, createReq, key, teamMember)
+	if err != nil {
+		return "", err
+	}
+
+	secret.Annotations["maas/imported-from"] = source
+	secret.Annotations["maas/created-at"] = createdAt
+	if key.plaintext == "" {
+		// Authorino needs the plaintext, so hash-only keys must be rotated
+		// before they can be used
+		secret.Annotations["maas/status"] = keyStatusPendingRotation
+	}
+
+	created, err := m.createUniqueKeySecret(secret, record.UserID, record.TeamID)
+	if err != nil {
+		return "", fmt.Errorf("failed to create key secret: %w", err)
+	}
+
+	m.webhooks.Dispatch(webhook.EventKeyCreated, record.TeamID, record.UserID, created.Name)
+	m.events.Key(created.Name, corev1.EventTypeNormal, events.ReasonKeyCreated,
+		"API key imported from %s for user %s in team %s", source, record.UserID, record.TeamID)
+
+	return created.Name, nil
+}
+
+// findKeySecretByHash locates a key secret by its unsalted SHA256 hash
+func (m *Manager) findKeySecretByHash(keyHash string) (*corev1.Secret, error) {
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/key-sha256=%s", keyHash[:32])
+	secrets, err := m.teamMgr.ListKeySecrets(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("API key not found")
+	}
+
+	return &secrets[0], nil
+}
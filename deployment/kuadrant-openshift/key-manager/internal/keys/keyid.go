@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// labelKeyID indexes salted keys by their non-secret key ID
+	labelKeyID = "maas/key-id"
+	// keyIDLength is the number of leading payload characters forming the
+	// key ID. They are treated as public, the rest of the payload keeps its
+	// entropy.
+	keyIDLength = 12
+)
+
+// keyID derives the lookup ID of a salted key from the leading characters of
+// its random payload, so a presented key is verified against its own secret
+// only. Hashing them keeps the label value valid whatever the key starts with.
+func keyID(apiKey string) string {
+	payload := strings.TrimPrefix(apiKey, keyPrefix)
+	if len(payload) > keyIDLength {
+		payload = payload[:keyIDLength]
+	}
+	return hashAPIKey(payload)[:32]
+}
+
+// isKeyID reports whether a value has the shape of a key ID
+func isKeyID(id string) bool {
+	decoded, err := hex.DecodeString(id)
+	return err == nil && len(decoded) == 16 && id == strings.ToLower(id)
+}
+
+// BackfillKeyIDs labels salted keys created before key IDs existed. Their ID
+// is derived from the plaintext kept for Authorino; keys imported by hash only
+// have none and become usable again once rotated.
+func (m *Manager) BackfillKeyIDs() error {
+	secrets, err := m.teamMgr.ListKeySecrets(fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,!maas/key-sha256,!%s", labelKeyID))
+	if err != nil {
+		return err
+	}
+
+	labeled := 0
+	for i := range secrets {
+		secret := &secrets[i]
+		apiKey := string(secret.Data["api_key"])
+		if apiKey == "" || secret.Annotations["maas/key-hash"] == "" {
+			continue
+		}
+
+		if secret.Labels == nil {
+			secret.Labels = make(map[string]string)
+		}
+		secret.Labels[labelKeyID] = keyID(apiKey)
+		_, err := m.clientset.CoreV1().Secrets(secret.Namespace).Update(
+			context.Background(), secret, metav1.UpdateOptions{})
+		if err != nil {
+			log.Printf("Warning: Failed to label key %s with its key ID: %v", secret.Name, err)
+			continue
+		}
+		labeled++
+	}
+
+	if labeled > 0 {
+		log.Printf("Labeled %d salted API keys with their key ID", labeled)
+	}
+	return nil
+}
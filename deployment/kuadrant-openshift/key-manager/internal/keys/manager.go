@@ -2,11 +2,11 @@ package keys
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,22 +15,46 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
 	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
+)
+
+// Reasons a known API key cannot be used, as opposed to an unknown one
+var (
+	ErrKeyInactive = errors.New("API key is not active")
+	ErrKeyExpired  = errors.New("API key is expired")
 )
 
 // Manager handles API key operations
 type Manager struct {
-	clientset    *kubernetes.Clientset
-	keyNamespace string
-	teamMgr      *teams.Manager
+	clientset      *kubernetes.Clientset
+	keyNamespace   string
+	teamMgr        *teams.Manager
+	maxKeysPerUser int
+	maxKeysPerTeam int
+	webhooks       *webhook.Dispatcher
+	hasher         Hasher
+	events         *events.Recorder
+	keyFormat      string
+	// Only FIPS-approved key hashes are verified or imported
+	fipsMode bool
 }
 
-// NewManager creates a new key manager
-func NewManager(clientset *kubernetes.Clientset, keyNamespace string, teamMgr *teams.Manager) *Manager {
+// NewManager creates a new key manager, a zero key cap means unlimited.
+// New and rotated keys are generated in keyFormat.
+func NewManager(clientset *kubernetes.Clientset, keyNamespace string, teamMgr *teams.Manager, maxKeysPerUser, maxKeysPerTeam int, webhooks *webhook.Dispatcher, hasher Hasher, recorder *events.Recorder, keyFormat string, fipsMode bool) *Manager {
 	return &Manager{
-		clientset:    clientset,
-		keyNamespace: keyNamespace,
-		teamMgr:      teamMgr,
+		clientset:      clientset,
+		keyNamespace:   keyNamespace,
+		teamMgr:        teamMgr,
+		maxKeysPerUser: maxKeysPerUser,
+		maxKeysPerTeam: maxKeysPerTeam,
+		webhooks:       webhooks,
+		hasher:         hasher,
+		events:         recorder,
+		keyFormat:      keyFormat,
+		fipsMode:       fipsMode,
 	}
 }
 
@@ -40,58 +64,31 @@ func (m *Manager) CreateTeamKey(teamID string, req *CreateTeamKeyRequest) (*Crea
 	if !m.teamMgr.Exists(teamID) {
 		return nil, fmt.Errorf("team not found")
 	}
+	if m.teamMgr.IsArchived(teamID) {
+		return nil, fmt.Errorf("team %s is archived", teamID)
+	}
+	if m.teamMgr.IsProvisioning(teamID) {
+		return nil, fmt.Errorf("team %s is still provisioning", teamID)
+	}
 
-	// Get team policy
-	teamPolicy, err := m.teamMgr.GetPolicy(teamID)
+	teamMember, err := m.resolveTeamMember(teamID, req.UserID, req.UserEmail)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get team policy: %w", err)
+		return nil, err
 	}
 
-	// Build team member info
-	var teamMember *teams.TeamMember
-	if teamID == "default" {
-		// For default team, auto-create membership info
-		userEmail := fmt.Sprintf("%s@default.local", req.UserID)
-		teamMember = &teams.TeamMember{
-			UserID:    req.UserID,
-			UserEmail: userEmail,
-			Role:      "member",
-			TeamID:    teamID,
-			TeamName:  "Default Team",
-			Policy:    teamPolicy,
-		}
-	} else {
-		// For non-default teams, validate membership or create new
-		teamMember, err = m.validateTeamMembership(teamID, req.UserID)
-		if err != nil {
-			// User is not yet a member, create new membership info from request
-			userEmail := req.UserEmail
-			if userEmail == "" {
-				userEmail = fmt.Sprintf("%s@company.com", req.UserID)
-			}
-			
-			// Get team details for member creation
-			teamDetails, err := m.teamMgr.Get(teamID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get team details: %w", err)
-			}
-			
-			teamMember = &teams.TeamMember{
-				UserID:    req.UserID,
-				UserEmail: userEmail,
-				Role:      "member",
-				TeamID:    teamID,
-				TeamName:  teamDetails.TeamName,
-				Policy:    teamPolicy,
-			}
-		} else {
-			// Update policy from team config for existing member
-			teamMember.Policy = teamPolicy
-		}
+	// Keys may only narrow the team's model allowlist
+	models, modelsSource, err := m.teamMgr.ResolveKeyModels(teamID, req.Models)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enforce key caps before anything is created
+	if err := m.checkKeyLimits(teamID, req.UserID); err != nil {
+		return nil, err
 	}
 
 	// Generate API key
-	apiKey, err := GenerateSecureToken(48)
+	apiKey, err := GenerateKey(m.keyFormat)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate API key: %w", err)
 	}
@@ -103,6 +100,9 @@ func (m *Manager) CreateTeamKey(teamID string, req *CreateTeamKeyRequest) (*Crea
 	}
 
 	log.Printf("API key created for team %s, team policies will apply automatically", teamID)
+	m.webhooks.Dispatch(webhook.EventKeyCreated, teamID, req.UserID, keySecret.Name)
+	m.events.Key(keySecret.Name, corev1.EventTypeNormal, events.ReasonKeyCreated,
+		"API key created for user %s in team %s", req.UserID, teamID)
 
 	// Restart Authorino to reload API key configuration immediately
 	// This is critical for the new API key to be discovered by Kuadrant
@@ -114,7 +114,7 @@ func (m *Manager) CreateTeamKey(teamID string, req *CreateTeamKeyRequest) (*Crea
 	}
 
 	// Get inherited policies
-	inheritedPolicies := m.buildInheritedPolicies(teamMember)
+	inheritedPolicies := m.buildInheritedPolicies(teamMember, models, modelsSource)
 
 	response := &CreateTeamKeyResponse{
 		APIKey:            apiKey,
@@ -148,40 +148,29 @@ func (m *Manager) CreateLegacyKey(req *GenerateKeyRequest) (*CreateTeamKeyRespon
 
 // DeleteKey deletes an API key by its value
 func (m *Manager) DeleteKey(apiKey string) (string, error) {
-	// Create SHA256 hash of the provided key
-	hasher := sha256.New()
-	hasher.Write([]byte(apiKey))
-	keyHash := hex.EncodeToString(hasher.Sum(nil))
-
-	// Find and delete secret by label selector (use truncated hash)
-	labelSelector := fmt.Sprintf("maas/key-sha256=%s", keyHash[:32])
-
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(context.Background(), metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
+	secret, err := m.findKeySecret(apiKey)
 	if err != nil {
-		return "", fmt.Errorf("failed to find API key: %w", err)
-	}
-
-	if len(secrets.Items) == 0 {
-		return "", fmt.Errorf("API key not found")
+		return "", err
 	}
 
 	// Delete the secret
-	secretName := secrets.Items[0].Name
-	err = m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
+	secretName := secret.Name
+	err = m.clientset.CoreV1().Secrets(secret.Namespace).Delete(context.Background(), secretName, metav1.DeleteOptions{})
 	if err != nil {
 		return "", fmt.Errorf("failed to delete API key: %w", err)
 	}
 
+	m.webhooks.Dispatch(webhook.EventKeyDeleted, secret.Labels["maas/team-id"], secret.Labels["maas/user-id"], secretName)
+	m.events.Team(secret.Labels["maas/team-id"], corev1.EventTypeNormal, events.ReasonKeyRevoked,
+		"API key %s revoked", secretName)
+
 	return secretName, nil
 }
 
 // DeleteTeamKey deletes a specific team API key by name
 func (m *Manager) DeleteTeamKey(keyName string) (string, string, error) {
 	// Get key secret to validate it exists and get team info
-	keySecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
-		context.Background(), keyName, metav1.GetOptions{})
+	keySecret, err := m.teamMgr.FindKeySecret(keyName)
 	if err != nil {
 		return "", "", fmt.Errorf("API key not found: %w", err)
 	}
@@ -192,20 +181,108 @@ func (m *Manager) DeleteTeamKey(keyName string) (string, string, error) {
 	}
 
 	// Delete the key secret
-	err = m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(
+	err = m.clientset.CoreV1().Secrets(keySecret.Namespace).Delete(
 		context.Background(), keyName, metav1.DeleteOptions{})
 	if err != nil {
 		return "", "", fmt.Errorf("failed to delete API key: %w", err)
 	}
 
 	log.Printf("Team API key deleted successfully: %s from team %s", keyName, teamID)
+	m.webhooks.Dispatch(webhook.EventKeyDeleted, teamID, keySecret.Labels["maas/user-id"], keyName)
+	m.events.Team(teamID, corev1.EventTypeNormal, events.ReasonKeyRevoked, "API key %s revoked", keyName)
 	return keyName, teamID, nil
 }
 
-// ListTeamKeys lists all API keys for a team with details
-func (m *Manager) ListTeamKeys(teamID string) ([]map[string]interface{}, error) {
+// GetKey returns the details of a single API key by secret name
+func (m *Manager) GetKey(keyName string) (map[string]interface{}, error) {
+	secret, err := m.teamMgr.FindKeySecret(keyName)
+	if err != nil {
+		return nil, fmt.Errorf("API key not found: %w", err)
+	}
+
+	if secret.Labels["kuadrant.io/apikeys-by"] != "rhcl-keys" {
+		return nil, fmt.Errorf("API key not found")
+	}
+
+	keyInfo := map[string]interface{}{
+		"secret_name":    secret.Name,
+		"user_id":        secret.Labels["maas/user-id"],
+		"user_email":     secret.Annotations["maas/user-email"],
+		"team_id":        secret.Labels["maas/team-id"],
+		"team_name":      secret.Annotations["maas/team-name"],
+		"role":           secret.Labels["maas/team-role"],
+		"policy":         secret.Annotations["maas/policy"],
+		"models_allowed": secret.Annotations["maas/models-allowed"],
+		"status":         secret.Annotations["maas/status"],
+		"created_at":     secret.Annotations["maas/created-at"],
+		"tags":           tagsFromLabels(secret.Labels),
+	}
+
+	// Add alias if present
+	if alias, exists := secret.Annotations["maas/alias"]; exists {
+		keyInfo["alias"] = alias
+	}
+
+	// Add custom limits if present
+	if customLimits, exists := secret.Annotations["maas/custom-limits"]; exists {
+		var limits map[string]interface{}
+		if err := json.Unmarshal([]byte(customLimits), &limits); err == nil {
+			keyInfo["custom_limits"] = limits
+		}
+	}
+
+	return keyInfo, nil
+}
+
+// UpdateKeyTags adds, changes and removes tags on an API key
+func (m *Manager) UpdateKeyTags(keyName string, req *UpdateKeyRequest) (map[string]interface{}, error) {
+	secret, err := m.teamMgr.FindKeySecret(keyName)
+	if err != nil {
+		return nil, fmt.Errorf("API key not found: %w", err)
+	}
+
+	if secret.Labels["kuadrant.io/apikeys-by"] != "rhcl-keys" {
+		return nil, fmt.Errorf("API key not found")
+	}
+
+	// Compute resulting tag set and validate it as a whole
+	tags := tagsFromLabels(secret.Labels)
+	for _, key := range req.RemoveTags {
+		delete(tags, key)
+	}
+	for key, value := range req.Tags {
+		tags[key] = value
+	}
+	if err := ValidateTags(tags); err != nil {
+		return nil, fmt.Errorf("invalid tags: %w", err)
+	}
+
+	for label := range tagsFromLabels(secret.Labels) {
+		delete(secret.Labels, tagLabelPrefix+label)
+	}
+	for key, value := range tags {
+		secret.Labels[tagLabelPrefix+key] = value
+	}
+
+	_, err = m.clientset.CoreV1().Secrets(secret.Namespace).Update(
+		context.Background(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update API key: %w", err)
+	}
+
+	log.Printf("Updated tags on API key %s", keyName)
+	m.webhooks.Dispatch(webhook.EventKeyUpdated, secret.Labels["maas/team-id"], secret.Labels["maas/user-id"], keyName)
+	return m.GetKey(keyName)
+}
+
+// ListTeamKeys lists all API keys for a team with details, optionally
+// restricted to keys carrying all of the given tags
+func (m *Manager) ListTeamKeys(teamID string, tags map[string]string) ([]map[string]interface{}, error) {
 	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+	if len(tags) > 0 {
+		labelSelector += "," + tagSelector(tags)
+	}
+	secrets, err := m.clientset.CoreV1().Secrets(m.teamMgr.KeyNamespace(teamID)).List(
 		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
 		return nil, err
@@ -222,6 +299,7 @@ func (m *Manager) ListTeamKeys(teamID string) ([]map[string]interface{}, error)
 			"models_allowed": secret.Annotations["maas/models-allowed"],
 			"status":         secret.Annotations["maas/status"],
 			"created_at":     secret.Annotations["maas/created-at"],
+			"tags":           tagsFromLabels(secret.Labels),
 		}
 
 		// Add alias if present
@@ -246,14 +324,13 @@ func (m *Manager) ListTeamKeys(teamID string) ([]map[string]interface{}, error)
 // ListUserKeys lists all API keys for a user across all teams
 func (m *Manager) ListUserKeys(userID string) ([]map[string]interface{}, error) {
 	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/user-id=%s", userID)
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
-		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	secrets, err := m.teamMgr.ListKeySecrets(labelSelector)
 	if err != nil {
 		return nil, err
 	}
 
 	keys := make([]map[string]interface{}, 0)
-	for _, secret := range secrets.Items {
+	for _, secret := range secrets {
 		keyInfo := map[string]interface{}{
 			"secret_name":    secret.Name,
 			"team_id":        secret.Labels["maas/team-id"],
@@ -264,6 +341,7 @@ func (m *Manager) ListUserKeys(userID string) ([]map[string]interface{}, error)
 			"models_allowed": secret.Annotations["maas/models-allowed"],
 			"status":         secret.Annotations["maas/status"],
 			"created_at":     secret.Annotations["maas/created-at"],
+			"tags":           tagsFromLabels(secret.Labels),
 		}
 
 		// Add alias if present
@@ -285,11 +363,164 @@ func (m *Manager) ListUserKeys(userID string) ([]map[string]interface{}, error)
 	return keys, nil
 }
 
-// validateTeamMembership validates team membership from existing API key
+// ResolveKey looks up the secret backing an end-user API key and rejects keys
+// that are no longer usable (suspended, deactivated or expired)
+func (m *Manager) ResolveKey(apiKey string) (*corev1.Secret, error) {
+	secret, err := m.findKeySecret(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if status := secret.Annotations["maas/status"]; status != "" && status != "active" {
+		return nil, fmt.Errorf("%w: status %s", ErrKeyInactive, status)
+	}
+
+	if expiresAt := secret.Annotations["maas/expires-at"]; expiresAt != "" {
+		expiry, err := time.Parse(time.RFC3339, expiresAt)
+		if err == nil && time.Now().After(expiry) {
+			return nil, ErrKeyExpired
+		}
+	}
+
+	return secret, nil
+}
+
+// findKeySecret locates the secret for an API key. Unsalted SHA256 keys are
+// found directly by label; keys hashed with a salted algorithm are found by
+// their key ID label and only those candidates are verified.
+func (m *Manager) findKeySecret(apiKey string) (*corev1.Secret, error) {
+	keyHash := hashAPIKey(apiKey)
+
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/key-sha256=%s", keyHash[:32])
+	secrets, err := m.teamMgr.ListKeySecrets(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+
+	if len(secrets) > 0 {
+		return &secrets[0], nil
+	}
+
+	// Fall back to keys stored with a salted hash, none of which is FIPS
+	// approved
+	if m.fipsMode {
+		return nil, fmt.Errorf("API key not found")
+	}
+	labelSelector = fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,%s=%s", labelKeyID, keyID(apiKey))
+	candidates, err := m.teamMgr.ListKeySecrets(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", err)
+	}
+
+	for i, candidate := range candidates {
+		algo := candidate.Annotations["maas/hash-algo"]
+		if algo == "" || algo == HashAlgoSHA256 {
+			continue
+		}
+
+		hasher, err := NewHasher(algo)
+		if err != nil {
+			log.Printf("Warning: Skipping key %s with unknown hash algorithm %s", candidate.Name, algo)
+			continue
+		}
+
+		if hasher.Verify(apiKey, candidate.Annotations["maas/key-hash"]) {
+			return &candidates[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("API key not found")
+}
+
+// ListTeamUserKeys lists the API keys a user holds within a single team
+func (m *Manager) ListTeamUserKeys(teamID, userID string) ([]map[string]interface{}, error) {
+	userKeys := make([]map[string]interface{}, 0)
+
+	teamKeys, err := m.ListTeamKeys(teamID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range teamKeys {
+		if key["user_id"] == userID {
+			userKeys = append(userKeys, key)
+		}
+	}
+
+	return userKeys, nil
+}
+
+// CountActiveUserKeys counts the active API keys a user holds within a team
+func (m *Manager) CountActiveUserKeys(teamID, userID string) (int, error) {
+	return m.countActiveKeys(fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s,maas/user-id=%s", teamID, userID))
+}
+
+// checkKeyLimits verifies that one more key fits within the user and team caps.
+// Team config annotations override the globally configured caps.
+func (m *Manager) checkKeyLimits(teamID, userID string) error {
+	maxPerUser, maxPerTeam := m.maxKeysPerUser, m.maxKeysPerTeam
+
+	userOverride, teamOverride, err := m.teamMgr.GetKeyLimitOverrides(teamID)
+	if err != nil {
+		return fmt.Errorf("failed to get team key limits: %w", err)
+	}
+	if userOverride >= 0 {
+		maxPerUser = userOverride
+	}
+	if teamOverride >= 0 {
+		maxPerTeam = teamOverride
+	}
+
+	if maxPerUser > 0 {
+		count, err := m.CountActiveUserKeys(teamID, userID)
+		if err != nil {
+			return err
+		}
+		if count >= maxPerUser {
+			return &KeyLimitError{Scope: "user", Current: count, Max: maxPerUser}
+		}
+	}
+
+	if maxPerTeam > 0 {
+		count, err := m.countActiveKeys(fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID))
+		if err != nil {
+			return err
+		}
+		if count >= maxPerTeam {
+			return &KeyLimitError{Scope: "team", Current: count, Max: maxPerTeam}
+		}
+	}
+
+	return nil
+}
+
+// countActiveKeys counts key secrets matching a label selector that are still active
+func (m *Manager) countActiveKeys(labelSelector string) (int, error) {
+	secrets, err := m.teamMgr.ListKeySecrets(labelSelector)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list keys: %w", err)
+	}
+
+	count := 0
+	for _, secret := range secrets {
+		if status := secret.Annotations["maas/status"]; status == "" || status == "active" {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// validateTeamMembership validates team membership from the membership
+// record, falling back to an existing API key
 func (m *Manager) validateTeamMembership(teamID, userID string) (*teams.TeamMember, error) {
+	if member, err := m.teamMgr.GetMember(teamID, userID); err == nil {
+		return member, nil
+	}
+
 	// Look for any existing API key for this user in this team to validate membership
 	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s,maas/user-id=%s", teamID, userID)
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+	secrets, err := m.clientset.CoreV1().Secrets(m.teamMgr.KeyNamespace(teamID)).List(
 		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
 		return nil, fmt.Errorf("failed to check user membership: %w", err)
@@ -314,24 +545,135 @@ func (m *Manager) validateTeamMembership(teamID, userID string) (*teams.TeamMemb
 	return member, nil
 }
 
+// resolveTeamMember builds the membership info stamped onto a new key secret
+func (m *Manager) resolveTeamMember(teamID, userID, userEmail string) (*teams.TeamMember, error) {
+	// Get team policy
+	teamPolicy, err := m.teamMgr.GetPolicy(teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team policy: %w", err)
+	}
+
+	// Build team member info
+	var teamMember *teams.TeamMember
+	if teamID == "default" {
+		// For default team, auto-create membership info
+		teamMember = &teams.TeamMember{
+			UserID:    userID,
+			UserEmail: fmt.Sprintf("%s@default.local", userID),
+			Role:      "member",
+			TeamID:    teamID,
+			TeamName:  "Default Team",
+			Policy:    teamPolicy,
+		}
+	} else {
+		// For non-default teams, validate membership or create new
+		teamMember, err = m.validateTeamMembership(teamID, userID)
+		if err != nil {
+			// User is not yet a member, create new membership info from request
+			if userEmail == "" {
+				userEmail = fmt.Sprintf("%s@company.com", userID)
+			}
+			
+			// Get team details for member creation
+			teamDetails, err := m.teamMgr.Get(teamID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get team details: %w", err)
+			}
+			
+			teamMember = &teams.TeamMember{
+				UserID:    userID,
+				UserEmail: userEmail,
+				Role:      "member",
+				TeamID:    teamID,
+				TeamName:  teamDetails.TeamName,
+				Policy:    teamPolicy,
+			}
+		} else {
+			// Update policy from team config for existing member
+			teamMember.Policy = teamPolicy
+		}
+	}
+
+	return teamMember, nil
+}
+
 // createKeySecret creates the API key secret with team context
 func (m *Manager) createKeySecret(teamID string, req *CreateTeamKeyRequest, apiKey string, teamMember *teams.TeamMember) (*corev1.Secret, error) {
-	// Create SHA256 hash of the key
-	hasher := sha256.New()
-	hasher.Write([]byte(apiKey))
-	keyHash := hex.EncodeToString(hasher.Sum(nil))
+	key, err := m.hashKey(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := m.buildKeySecret(teamID, req, key, teamMember)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.createUniqueKeySecret(secret, req.UserID, teamID)
+}
+
+// storedKey is an API key in the form it is persisted on its secret
+type storedKey struct {
+	plaintext string // empty when only the hash is known
+	hash      string
+	algo      string
+	salted    bool
+	id        string // lookup ID of salted keys, empty when not known
+	format    string // empty when not a recognized format
+}
+
+// hashKey hashes a plaintext API key with the configured hasher
+func (m *Manager) hashKey(apiKey string) (*storedKey, error) {
+	keyHash, err := m.hasher.Hash(apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash API key: %w", err)
+	}
+
+	format, err := ValidateKeyFormat(apiKey)
+	if err != nil {
+		format = ""
+	}
+
+	key := &storedKey{
+		plaintext: apiKey,
+		hash:      keyHash,
+		algo:      m.hasher.Name(),
+		salted:    m.hasher.Salted(),
+		format:    format,
+	}
+	if key.salted {
+		key.id = keyID(apiKey)
+	}
+	return key, nil
+}
+
+// buildKeySecret builds the API key secret object without creating it
+func (m *Manager) buildKeySecret(teamID string, req *CreateTeamKeyRequest, key *storedKey, teamMember *teams.TeamMember) (*corev1.Secret, error) {
+	// Salted hashes are not label-safe, so their secrets get a random suffix
+	nameSuffix := key.hash
+	if key.salted {
+		var err error
+		if nameSuffix, err = randomSuffix(secretNameSuffixLength); err != nil {
+			return nil, fmt.Errorf("failed to generate secret name: %w", err)
+		}
+	}
 
 	// Create secret name with team context
-	secretName := fmt.Sprintf("apikey-%s-%s-%s", req.UserID, teamID, keyHash[:8])
+	secretName := keySecretName(req.UserID, teamID, nameSuffix)
 
-	// Build models allowed list
-	modelsAllowed := strings.Join(req.Models, ",")
+	// Build models allowed list, preferring the team allowlist over the tier
+	// default when the key does not narrow it
+	models, modelsSource, err := m.teamMgr.ResolveKeyModels(teamID, req.Models)
+	if err != nil {
+		return nil, err
+	}
+	modelsAllowed := strings.Join(models, ",")
 
 	// Create enhanced secret with full team context
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretName,
-			Namespace: m.keyNamespace,
+			Namespace: m.teamMgr.KeyNamespace(teamID),
 			Labels: map[string]string{
 				"kuadrant.io/auth-secret": "true",        // Required for working AuthPolicy
 				"kuadrant.io/apikeys-by":  "rhcl-keys",   // Required for key listing functions
@@ -339,7 +681,6 @@ func (m *Manager) createKeySecret(teamID string, req *CreateTeamKeyRequest, apiK
 				"maas/user-id":            req.UserID,
 				"maas/team-id":            teamID,
 				"maas/team-role":          teamMember.Role,
-				"maas/key-sha256":         keyHash[:32],
 				"maas/resource-type":      "team-key",
 				// Policy targeting label - this is how Kuadrant policies find API keys
 				fmt.Sprintf("maas/policy-%s", teamMember.Policy): "true",
@@ -350,15 +691,56 @@ func (m *Manager) createKeySecret(teamID string, req *CreateTeamKeyRequest, apiK
 				"maas/team-name":             teamMember.TeamName,
 				"maas/user-email":            teamMember.UserEmail,
 				"maas/models-allowed":        modelsAllowed,
+				"maas/models-source":         modelsSource,
 				"maas/policy":                teamMember.Policy,
 				"maas/created-at":            time.Now().Format(time.RFC3339),
 				"maas/status":                "active",
+				"maas/hash-algo":             key.algo,
+				// Original IDs, since the secret name may truncate them
+				"maas/user-id": req.UserID,
+				"maas/team-id": teamID,
 			},
 		},
 		Type: corev1.SecretTypeOpaque,
-		StringData: map[string]string{
-			"api_key": apiKey,
-		},
+	}
+
+	// Keys imported by hash only have no plaintext for Authorino to match
+	if key.plaintext != "" {
+		secret.StringData = map[string]string{
+			"api_key": key.plaintext,
+		}
+	}
+
+	if key.format != "" {
+		secret.Annotations[annotationKeyFormat] = key.format
+	}
+
+	// Unsalted hashes are stored as a label for direct lookup, salted ones
+	// can only be verified so they are kept in an annotation and found by
+	// their key ID
+	if key.salted {
+		secret.Annotations["maas/key-hash"] = key.hash
+		if key.id != "" {
+			secret.Labels[labelKeyID] = key.id
+		}
+	} else {
+		secret.Labels["maas/key-sha256"] = key.hash[:32]
+	}
+
+	// Sub-team keys also count against their parent's shared limit
+	if parentID := m.teamMgr.ParentTeam(teamID); parentID != "" {
+		secret.Labels[teams.LabelParentTeamID] = parentID
+	}
+
+	// Carry the member's individual rate overrides onto the key
+	if teamMember.TokenLimit > 0 {
+		secret.Annotations["maas/token-limit"] = strconv.Itoa(teamMember.TokenLimit)
+	}
+	if teamMember.RequestLimit > 0 {
+		secret.Annotations["maas/request-limit"] = strconv.Itoa(teamMember.RequestLimit)
+	}
+	if teamMember.TimeWindow != "" {
+		secret.Annotations["maas/time-window"] = teamMember.TimeWindow
 	}
 
 	// Add alias if provided
@@ -366,23 +748,29 @@ func (m *Manager) createKeySecret(teamID string, req *CreateTeamKeyRequest, apiK
 		secret.Annotations["maas/alias"] = req.Alias
 	}
 
+	// Add user tags as labels so they can be used in selectors
+	for key, value := range req.Tags {
+		secret.Labels[tagLabelPrefix+key] = value
+	}
+
 	// Add custom limits as JSON if provided
 	if req.CustomLimits != nil && len(req.CustomLimits) > 0 {
 		customLimitsJSON, _ := json.Marshal(req.CustomLimits)
 		secret.Annotations["maas/custom-limits"] = string(customLimitsJSON)
 	}
 
-	return m.clientset.CoreV1().Secrets(m.keyNamespace).Create(
-		context.Background(), secret, metav1.CreateOptions{})
+	return secret, nil
 }
 
 // buildInheritedPolicies builds the inherited policies response
-func (m *Manager) buildInheritedPolicies(teamMember *teams.TeamMember) map[string]interface{} {
+func (m *Manager) buildInheritedPolicies(teamMember *teams.TeamMember, models []string, modelsSource string) map[string]interface{} {
 	return map[string]interface{}{
-		"policy":    teamMember.Policy,
-		"team_id":   teamMember.TeamID,
-		"team_name": teamMember.TeamName,
-		"role":      teamMember.Role,
+		"policy":        teamMember.Policy,
+		"team_id":       teamMember.TeamID,
+		"team_name":     teamMember.TeamName,
+		"role":          teamMember.Role,
+		"models":        models,
+		"models_source": modelsSource,
 	}
 }
 
@@ -400,4 +788,4 @@ func (m *Manager) restartAuthorino() error {
 		metav1.PatchOptions{})
 
 	return err
-}
\ No newline at end of file
+}
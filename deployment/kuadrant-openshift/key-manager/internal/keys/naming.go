@@ -0,0 +1,93 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/redact"
+)
+
+const (
+	// secretNameSuffixLength is the number of hex characters identifying a key
+	secretNameSuffixLength = 16
+	// maxSecretNameComponent bounds the user and team parts of a secret name
+	maxSecretNameComponent = 63
+	// maxSecretNameAttempts bounds retries after a name collision
+	maxSecretNameAttempts = 5
+)
+
+// keySecretName builds a valid DNS-1123 secret name for an API key. User and
+// team components are sanitized and truncated deterministically, so the same
+// inputs always produce the same name; the suffix keeps names unique.
+func keySecretName(userID, teamID, suffix string) string {
+	if len(suffix) > secretNameSuffixLength {
+		suffix = suffix[:secretNameSuffixLength]
+	}
+
+	name := fmt.Sprintf("apikey-%s-%s-%s",
+		sanitizeNameComponent(userID, "user"),
+		sanitizeNameComponent(teamID, "team"),
+		sanitizeNameComponent(suffix, "key"))
+
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength], "-.")
+	}
+
+	return name
+}
+
+// sanitizeNameComponent lowercases a value, replaces characters not allowed
+// in a DNS-1123 label with hyphens and truncates it to maxSecretNameComponent
+func sanitizeNameComponent(value, fallback string) string {
+	var b strings.Builder
+	lastHyphen := false
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			lastHyphen = false
+		} else if !lastHyphen {
+			b.WriteRune('-')
+			lastHyphen = true
+		}
+	}
+
+	component := strings.Trim(b.String(), "-")
+	if len(component) > maxSecretNameComponent {
+		component = strings.TrimRight(component[:maxSecretNameComponent], "-")
+	}
+	if component == "" {
+		return fallback
+	}
+	return component
+}
+
+// createUniqueKeySecret creates a key secret, picking a new random suffix
+// whenever the generated name is already taken
+func (m *Manager) createUniqueKeySecret(secret *corev1.Secret, userID, teamID string) (*corev1.Secret, error) {
+	for attempt := 1; ; attempt++ {
+		created, err := m.clientset.CoreV1().Secrets(secret.Namespace).Create(
+			context.Background(), secret, metav1.CreateOptions{})
+		if err == nil {
+			return created, nil
+		}
+		if !apierrors.IsAlreadyExists(err) || attempt >= maxSecretNameAttempts {
+			// The secret carries the new key, which the error may echo
+			return nil, redact.Error(err)
+		}
+
+		suffix, suffixErr := randomSuffix(secretNameSuffixLength)
+		if suffixErr != nil {
+			return nil, fmt.Errorf("failed to generate secret name: %w", suffixErr)
+		}
+
+		log.Printf("Warning: Secret name %s already exists, retrying with a new suffix", secret.Name)
+		secret.Name = keySecretName(userID, teamID, suffix)
+	}
+}
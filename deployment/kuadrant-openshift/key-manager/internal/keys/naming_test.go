@@ -0,0 +1,128 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestSanitizeNameComponent(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		fallback string
+		want     string
+	}{
+		{name: "valid", value: "alice", fallback: "user", want: "alice"},
+		{name: "uppercase", value: "Alice", fallback: "user", want: "alice"},
+		{name: "email", value: "alice@example.com", fallback: "user", want: "alice-example-com"},
+		{name: "runs of invalid characters", value: "a__b..c", fallback: "user", want: "a-b-c"},
+		{name: "leading and trailing invalid characters", value: "_alice_", fallback: "user", want: "alice"},
+		{name: "non-ASCII", value: "zoë", fallback: "user", want: "zo"},
+		{name: "empty", value: "", fallback: "user", want: "user"},
+		{name: "only invalid characters", value: "@@@", fallback: "team", want: "team"},
+		{name: "long", value: strings.Repeat("a", 100), fallback: "user", want: strings.Repeat("a", maxSecretNameComponent)},
+		{name: "long ending in a hyphen when cut", value: strings.Repeat("a", 62) + "-bbb", fallback: "user", want: strings.Repeat("a", 62)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeNameComponent(tt.value, tt.fallback); got != tt.want {
+				t.Errorf("sanitizeNameComponent(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySecretName(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		teamID string
+		suffix string
+		want   string
+	}{
+		{
+			name:   "plain IDs",
+			userID: "alice",
+			teamID: "team-a",
+			suffix: "0123456789abcdef",
+			want:   "apikey-alice-team-a-0123456789abcdef",
+		},
+		{
+			name:   "invalid characters",
+			userID: "Alice@Example.com",
+			teamID: "Team_A",
+			suffix: "0123456789abcdef",
+			want:   "apikey-alice-example-com-team-a-0123456789abcdef",
+		},
+		{
+			name:   "long suffix is truncated",
+			userID: "alice",
+			teamID: "team-a",
+			suffix: strings.Repeat("f", 64),
+			want:   "apikey-alice-team-a-" + strings.Repeat("f", secretNameSuffixLength),
+		},
+		{
+			name:   "empty IDs use fallbacks",
+			userID: "",
+			teamID: "!!",
+			suffix: "",
+			want:   "apikey-user-team-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keySecretName(tt.userID, tt.teamID, tt.suffix); got != tt.want {
+				t.Errorf("keySecretName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySecretNameBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		teamID string
+	}{
+		{name: "long user ID", userID: strings.Repeat("u", 300), teamID: "team-a"},
+		{name: "long team ID", userID: "alice", teamID: strings.Repeat("t", 300)},
+		{name: "both long", userID: strings.Repeat("u", 300), teamID: strings.Repeat("t", 300)},
+		{name: "long with invalid characters", userID: strings.Repeat("A.b_", 100), teamID: strings.Repeat("-x@", 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := keySecretName(tt.userID, tt.teamID, "0123456789abcdef")
+			if len(name) > validation.DNS1123SubdomainMaxLength {
+				t.Errorf("keySecretName() is %d characters, more than %d", len(name), validation.DNS1123SubdomainMaxLength)
+			}
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+				t.Errorf("keySecretName() = %q is not a valid secret name: %v", name, errs)
+			}
+			if !strings.HasSuffix(name, "-0123456789abcdef") {
+				t.Errorf("keySecretName() = %q lost its suffix", name)
+			}
+		})
+	}
+}
+
+func TestKeySecretNameDeterministic(t *testing.T) {
+	userID := strings.Repeat("Alice.Example_", 20)
+	teamID := strings.Repeat("Team/A ", 20)
+
+	first := keySecretName(userID, teamID, "0123456789abcdef")
+	for i := 0; i < 10; i++ {
+		if got := keySecretName(userID, teamID, "0123456789abcdef"); got != first {
+			t.Fatalf("keySecretName() = %q, then %q for the same inputs", first, got)
+		}
+	}
+
+	// Only the suffix tells keys of the same user and team apart
+	if other := keySecretName(userID, teamID, "fedcba9876543210"); other == first {
+		t.Errorf("keySecretName() = %q for different suffixes", other)
+	}
+}
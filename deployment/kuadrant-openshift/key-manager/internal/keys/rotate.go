@@ -0,0 +1,92 @@
+package keys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/redact"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
+)
+
+// RotateKey issues a new value for an existing API key, keeping its name,
+// team and metadata. The old value stops working once Authorino reloads.
+// Keys imported without plaintext become usable through rotation.
+func (m *Manager) RotateKey(keyName string) (*RotateKeyResponse, error) {
+	secret, err := m.teamMgr.FindKeySecret(keyName)
+	if err != nil {
+		return nil, fmt.Errorf("API key not found: %w", err)
+	}
+	if secret.Labels["kuadrant.io/apikeys-by"] != "rhcl-keys" {
+		return nil, fmt.Errorf("API key not found")
+	}
+
+	teamID := secret.Labels["maas/team-id"]
+	if m.teamMgr.IsArchived(teamID) {
+		return nil, fmt.Errorf("team %s is archived", teamID)
+	}
+	status := secret.Annotations["maas/status"]
+	if status != "" && status != "active" && status != keyStatusPendingRotation {
+		return nil, fmt.Errorf("API key with status %s cannot be rotated", status)
+	}
+
+	apiKey, err := GenerateKey(m.keyFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key, err := m.hashKey(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data["api_key"] = []byte(key.plaintext)
+
+	delete(secret.Labels, "maas/key-sha256")
+	delete(secret.Labels, labelKeyID)
+	delete(secret.Annotations, "maas/key-hash")
+	delete(secret.Annotations, annotationKeyFormat)
+	if key.format != "" {
+		secret.Annotations[annotationKeyFormat] = key.format
+	}
+	if key.salted {
+		secret.Annotations["maas/key-hash"] = key.hash
+		secret.Labels[labelKeyID] = key.id
+	} else {
+		secret.Labels["maas/key-sha256"] = key.hash[:32]
+	}
+
+	rotatedAt := time.Now().Format(time.RFC3339)
+	secret.Annotations["maas/hash-algo"] = key.algo
+	secret.Annotations["maas/status"] = "active"
+	secret.Annotations["maas/rotated-at"] = rotatedAt
+
+	_, err = m.clientset.CoreV1().Secrets(secret.Namespace).Update(
+		context.Background(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update API key: %w", redact.Error(err))
+	}
+
+	log.Printf("API key %s rotated for team %s", keyName, teamID)
+	m.webhooks.Dispatch(webhook.EventKeyRotated, teamID, secret.Labels["maas/user-id"], keyName)
+	m.events.Key(keyName, corev1.EventTypeNormal, events.ReasonKeyRotated, "API key %s rotated", keyName)
+
+	if err := m.restartAuthorino(); err != nil {
+		log.Printf("Warning: Failed to restart Authorino after key rotation: %v", err)
+	}
+
+	return &RotateKeyResponse{
+		APIKey:     apiKey,
+		SecretName: keyName,
+		UserID:     secret.Labels["maas/user-id"],
+		TeamID:     teamID,
+		RotatedAt:  rotatedAt,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// tagLabelPrefix is prepended to every user supplied tag key
+	tagLabelPrefix = "maas-tag/"
+	// maxTagsPerKey caps the number of tags a single key can carry
+	maxTagsPerKey = 10
+)
+
+// ValidateTags validates tag keys and values against Kubernetes label syntax
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > maxTagsPerKey {
+		return fmt.Errorf("a key can have at most %d tags, got %d", maxTagsPerKey, len(tags))
+	}
+
+	for key, value := range tags {
+		if errs := validation.IsQualifiedName(tagLabelPrefix + key); len(errs) > 0 {
+			return fmt.Errorf("invalid tag key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid value for tag %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+
+	return nil
+}
+
+// ParseTagFilters parses ?tag=key:value query values into a tag map
+func ParseTagFilters(filters []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, filter := range filters {
+		key, value, found := strings.Cut(filter, ":")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid tag filter %q, expected key:value", filter)
+		}
+		tags[key] = value
+	}
+
+	if err := ValidateTags(tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// tagSelector builds a label selector fragment matching all given tags
+func tagSelector(tags map[string]string) string {
+	selectors := make([]string, 0, len(tags))
+	for key, value := range tags {
+		selectors = append(selectors, fmt.Sprintf("%s%s=%s", tagLabelPrefix, key, value))
+	}
+	sort.Strings(selectors)
+	return strings.Join(selectors, ",")
+}
+
+// tagsFromLabels extracts user tags from a key secret's labels
+func tagsFromLabels(labels map[string]string) map[string]string {
+	tags := make(map[string]string)
+	for label, value := range labels {
+		if key, found := strings.CutPrefix(label, tagLabelPrefix); found {
+			tags[key] = value
+		}
+	}
+	return tags
+}
@@ -1,5 +1,11 @@
 package keys
 
+import (
+	"fmt"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+)
+
 // API key structures
 type CreateTeamKeyRequest struct {
 	UserID            string                 `json:"user_id" binding:"required"`
@@ -12,6 +18,13 @@ type CreateTeamKeyRequest struct {
 	RequestLimit int                    `json:"request_limit,omitempty"`
 	TimeWindow   string                 `json:"time_window,omitempty"`
 	CustomLimits map[string]interface{} `json:"custom_limits"`
+	// Free-form labels, stored as maas-tag/<key>=<value> on the secret
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+type UpdateKeyRequest struct {
+	Tags       map[string]string `json:"tags,omitempty"`
+	RemoveTags []string          `json:"remove_tags,omitempty"`
 }
 
 type CreateTeamKeyResponse struct {
@@ -22,6 +35,15 @@ type CreateTeamKeyResponse struct {
 	Policy            string                 `json:"policy"`
 	CreatedAt         string                 `json:"created_at"`
 	InheritedPolicies map[string]interface{} `json:"inherited_policies"`
+	Endpoint          *KeyEndpoint           `json:"endpoint,omitempty"`
+}
+
+// KeyEndpoint tells a new key holder where to send requests
+type KeyEndpoint struct {
+	Host        string `json:"host"`
+	BasePath    string `json:"base_path"`
+	URL         string `json:"url"`
+	ExampleCurl string `json:"example_curl"`
 }
 
 // Legacy structures (keep for backward compatibility)
@@ -32,3 +54,113 @@ type GenerateKeyRequest struct {
 type DeleteKeyRequest struct {
 	Key string `json:"key" binding:"required"`
 }
+
+// Self-service structures
+type CreateSelfServiceKeyRequest struct {
+	Alias  string   `json:"alias"`
+	Models []string `json:"models"`
+}
+
+// KeyLimitError is returned when creating a key would exceed a configured cap
+type KeyLimitError struct {
+	Scope   string `json:"scope"`
+	Current int    `json:"current_keys"`
+	Max     int    `json:"max_keys"`
+}
+
+func (e *KeyLimitError) Error() string {
+	return fmt.Sprintf("maximum number of API keys per %s reached (%d/%d)", e.Scope, e.Current, e.Max)
+}
+
+// Import structures
+type ImportKeyRecord struct {
+	APIKey    string   `json:"api_key,omitempty"`
+	KeySHA256 string   `json:"key_sha256,omitempty"`
+	UserID    string   `json:"user_id"`
+	UserEmail string   `json:"user_email,omitempty"`
+	TeamID    string   `json:"team_id"`
+	Policy    string   `json:"policy,omitempty"`
+	Models    []string `json:"models"`
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+type ImportKeysRequest struct {
+	Source string            `json:"source" binding:"required"`
+	Keys   []ImportKeyRecord `json:"keys" binding:"required"`
+}
+
+type ImportKeyResult struct {
+	Index      int    `json:"index"`
+	UserID     string `json:"user_id"`
+	TeamID     string `json:"team_id"`
+	Status     string `json:"status"`
+	SecretName string `json:"secret_name,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type ImportKeysResponse struct {
+	Source     string            `json:"source"`
+	Imported   int               `json:"imported"`
+	Duplicates int               `json:"duplicates"`
+	Failed     int               `json:"failed"`
+	Results    []ImportKeyResult `json:"results"`
+}
+
+// Team migration structures
+type TeamsExport struct {
+	Version    string         `json:"version"`
+	ExportedAt string         `json:"exported_at"`
+	Teams      []ExportedTeam `json:"teams" binding:"required"`
+}
+
+type ExportedTeam struct {
+	teams.TeamExport
+	Keys []ExportedKey `json:"keys"`
+}
+
+// ExportedKey is an API key's metadata without its plaintext. Unsalted keys
+// carry the SHA256 prefix used for lookups, salted keys their full hash and
+// key ID.
+type ExportedKey struct {
+	SecretName string            `json:"secret_name"`
+	UserID     string            `json:"user_id"`
+	UserEmail  string            `json:"user_email,omitempty"`
+	Alias      string            `json:"alias,omitempty"`
+	Models     []string          `json:"models,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Status     string            `json:"status"`
+	CreatedAt  string            `json:"created_at"`
+	HashAlgo   string            `json:"hash_algo"`
+	KeySHA256  string            `json:"key_sha256,omitempty"`
+	KeyHash    string            `json:"key_hash,omitempty"`
+	KeyID      string            `json:"key_id,omitempty"`
+	KeyFormat  string            `json:"key_format,omitempty"`
+}
+
+type ImportTeamsResponse struct {
+	Source   string             `json:"source"`
+	Imported int                `json:"imported"`
+	Existing int                `json:"existing"`
+	Failed   int                `json:"failed"`
+	Results  []ImportTeamResult `json:"results"`
+}
+
+type ImportTeamResult struct {
+	TeamID        string            `json:"team_id"`
+	Status        string            `json:"status"`
+	Error         string            `json:"error,omitempty"`
+	MembersAdded  int               `json:"members_added"`
+	KeysImported  int               `json:"keys_imported"`
+	KeysDuplicate int               `json:"keys_duplicate"`
+	KeysFailed    int               `json:"keys_failed"`
+	Keys          []ImportKeyResult `json:"keys"`
+}
+
+// Rotation structures
+type RotateKeyResponse struct {
+	APIKey     string `json:"api_key"`
+	SecretName string `json:"secret_name"`
+	UserID     string `json:"user_id"`
+	TeamID     string `json:"team_id"`
+	RotatedAt  string `json:"rotated_at"`
+}
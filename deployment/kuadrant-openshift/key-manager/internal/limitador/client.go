@@ -0,0 +1,218 @@
+package limitador
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Counter variables set by the TokenRateLimitPolicy limit scopes
+const (
+	userCounterVariable = "auth.identity.userid"
+	teamCounterVariable = `auth.identity.metadata.labels["maas/team-id"]`
+)
+
+// Client reads live counter state from the Limitador HTTP API
+type Client struct {
+	baseURL    string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Limitador client, returns nil if no URL is configured
+func NewClient(baseURL, namespace string) *Client {
+	if baseURL == "" {
+		return nil
+	}
+
+	return &Client{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		namespace: namespace,
+		httpClient: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+// GetCounters fetches all active counters in the configured limits namespace
+func (c *Client) GetCounters() ([]Counter, error) {
+	countersURL := fmt.Sprintf("%s/counters/%s", c.baseURL, url.PathEscape(c.namespace))
+
+	resp, err := c.httpClient.Get(countersURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch counters from %s: %w", countersURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("limitador returned status %d", resp.StatusCode)
+	}
+
+	var counters []Counter
+	if err := json.NewDecoder(resp.Body).Decode(&counters); err != nil {
+		return nil, fmt.Errorf("failed to decode limitador counters: %w", err)
+	}
+
+	return counters, nil
+}
+
+// Ping checks that the Limitador HTTP API answers
+func (c *Client) Ping() error {
+	statusURL := fmt.Sprintf("%s/status", c.baseURL)
+
+	resp, err := c.httpClient.Get(statusURL)
+	if err != nil {
+		return fmt.Errorf("failed to reach limitador at %s: %w", statusURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("limitador returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CurrentUsage returns the active window usage for a policy, optionally
+// narrowed to a single user. It never fails: when Limitador cannot be reached
+// the result is flagged as unavailable instead.
+func (c *Client) CurrentUsage(policyName, userID string) *CurrentUsage {
+	return c.currentUsage(policyName, func(counter Counter) bool {
+		return userID == "" || counter.SetVariables[userCounterVariable] == userID
+	})
+}
+
+// CurrentTeamUsage returns the live state of a policy's counters kept for a
+// single team. Counters keyed per user cannot be attributed to a team and are
+// left out.
+func (c *Client) CurrentTeamUsage(policyName, teamID string) *CurrentUsage {
+	return c.currentUsage(policyName, func(counter Counter) bool {
+		return counter.SetVariables[teamCounterVariable] == teamID
+	})
+}
+
+// currentUsage summarizes the counters of a policy accepted by keep
+func (c *Client) currentUsage(policyName string, keep func(Counter) bool) *CurrentUsage {
+	usage := &CurrentUsage{Limits: []LimitUsage{}}
+
+	if c == nil {
+		usage.UsageUnavailable = true
+		usage.Reason = "LIMITADOR_URL is not configured"
+		return usage
+	}
+
+	counters, err := c.GetCounters()
+	if err != nil {
+		log.Printf("Warning: Failed to get Limitador counters: %v", err)
+		usage.UsageUnavailable = true
+		usage.Reason = "limitador is unreachable"
+		return usage
+	}
+
+	for _, counter := range counters {
+		if !MatchesPolicy(counter.Limit, policyName) || !keep(counter) {
+			continue
+		}
+
+		counterUser := counter.SetVariables[userCounterVariable]
+
+		usage.Limits = append(usage.Limits, LimitUsage{
+			LimitName:       counter.Limit.Name,
+			UserID:          counterUser,
+			Limit:           counter.Limit.MaxValue,
+			WindowSeconds:   counter.Limit.Seconds,
+			Consumed:        counter.Limit.MaxValue - counter.Remaining,
+			Remaining:       counter.Remaining,
+			ResetsInSeconds: counter.ExpiresInSeconds,
+			Exhausted:       counter.Remaining <= 0,
+		})
+	}
+
+	return usage
+}
+
+// LimitCounters returns the counters of one TokenRateLimitPolicy limit whose
+// variables hold one of owners, such as a team ID, user IDs or key hashes
+func LimitCounters(counters []Counter, limitName string, owners map[string]bool) []LimitUsage {
+	usage := make([]LimitUsage, 0)
+	for _, counter := range counters {
+		if !MatchesLimit(counter.Limit, limitName) {
+			continue
+		}
+		owned := false
+		for _, value := range counter.SetVariables {
+			if owners[value] {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			continue
+		}
+
+		usage = append(usage, LimitUsage{
+			LimitName:       counter.Limit.Name,
+			UserID:          counter.SetVariables[userCounterVariable],
+			Limit:           counter.Limit.MaxValue,
+			WindowSeconds:   counter.Limit.Seconds,
+			Consumed:        counter.Limit.MaxValue - counter.Remaining,
+			Remaining:       counter.Remaining,
+			ResetsInSeconds: counter.ExpiresInSeconds,
+			Exhausted:       counter.Remaining <= 0,
+		})
+	}
+	return usage
+}
+
+// MatchesLimit checks whether a Limitador limit was generated for exactly one
+// TokenRateLimitPolicy limit. Kuadrant names them limit.<name>__<hash>, with
+// hyphens converted to underscores, and a plain name is accepted as well.
+func MatchesLimit(limit Limit, limitName string) bool {
+	for _, name := range []string{limitName, strings.ReplaceAll(limitName, "-", "_")} {
+		if limit.Name == name || strings.HasPrefix(limit.Name, "limit."+name+"__") {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesPolicy checks whether a Limitador limit was generated for a policy:
+// either it is the policy's own limit, or one of its conditions compares the
+// group against exactly the policy name, as every limit of the policy does.
+// Names and values are compared whole, so policy a never matches a-b.
+func MatchesPolicy(limit Limit, policyName string) bool {
+	if policyName == "" {
+		return false
+	}
+
+	if MatchesLimit(limit, policyName) {
+		return true
+	}
+
+	for _, condition := range limit.Conditions {
+		if conditionValue(condition) == policyName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// conditionValue returns the string literal a condition compares against,
+// such as gold in exists(g, g == "gold"), or "" when there is none
+func conditionValue(condition string) string {
+	i := strings.LastIndex(condition, "==")
+	if i < 0 {
+		return ""
+	}
+	literal := strings.TrimRight(strings.TrimSpace(condition[i+2:]), ") ")
+	value, err := strconv.Unquote(literal)
+	if err != nil {
+		return ""
+	}
+	return value
+}
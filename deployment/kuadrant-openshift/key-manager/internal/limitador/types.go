@@ -0,0 +1,36 @@
+package limitador
+
+// Limitador HTTP API structures
+type Limit struct {
+	Namespace  string   `json:"namespace"`
+	MaxValue   int64    `json:"max_value"`
+	Seconds    int64    `json:"seconds"`
+	Name       string   `json:"name"`
+	Conditions []string `json:"conditions"`
+	Variables  []string `json:"variables"`
+}
+
+type Counter struct {
+	Limit            Limit             `json:"limit"`
+	SetVariables     map[string]string `json:"set_variables"`
+	Remaining        int64             `json:"remaining"`
+	ExpiresInSeconds int64             `json:"expires_in_seconds"`
+}
+
+// Usage structures returned to API clients
+type LimitUsage struct {
+	LimitName       string `json:"limit_name"`
+	UserID          string `json:"user_id,omitempty"`
+	Limit           int64  `json:"limit"`
+	WindowSeconds   int64  `json:"window_seconds"`
+	Consumed        int64  `json:"consumed"`
+	Remaining       int64  `json:"remaining"`
+	ResetsInSeconds int64  `json:"resets_in_seconds"`
+	Exhausted       bool   `json:"exhausted"`
+}
+
+type CurrentUsage struct {
+	Limits           []LimitUsage `json:"limits"`
+	UsageUnavailable bool         `json:"usage_unavailable,omitempty"`
+	Reason           string       `json:"reason,omitempty"`
+}
@@ -0,0 +1,232 @@
+package permissions
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Outcomes of a permission check
+const (
+	StatusOK      = "ok"
+	StatusMissing = "missing"
+)
+
+// Requirement is an API permission the key manager needs. An empty
+// namespace means cluster-wide.
+type Requirement struct {
+	Group       string `json:"group,omitempty"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Verb        string `json:"verb"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	// What the permission is needed for
+	Purpose string `json:"purpose"`
+}
+
+// MissingPermission is a requirement the key manager was not granted, or
+// whose review failed
+type MissingPermission struct {
+	Requirement
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the outcome of checking every requirement
+type Report struct {
+	Status    string              `json:"status"`
+	Checked   int                 `json:"checked"`
+	Missing   []MissingPermission `json:"missing"`
+	CheckedAt string              `json:"checked_at"`
+}
+
+// Options describe the deployment the requirements are derived from
+type Options struct {
+	KeyNamespace     string
+	GatewayNamespace string
+	// "namespace/name" of the discovery Route, may be empty
+	DiscoveryRoute string
+	// "namespace/name/key" of the admin key Secret, may be empty
+	AdminKeySecretRef string
+	// ClusterRole bound in team key namespaces, empty when the key manager
+	// does not manage their RoleBindings
+	KeyNamespaceClusterRole string
+	AutoCreateNamespaces    bool
+	KubernetesAdminAuth     bool
+	TeamCRD                 bool
+	Events                  bool
+}
+
+// Required lists every permission the key manager needs with the given
+// options. Secrets are only ever listed and watched by MaaS label or by
+// name, but RBAC cannot express a label, so they are requested per verb.
+func Required(opts Options) []Requirement {
+	var required []Requirement
+	add := func(group, resource, namespace, purpose string, verbs ...string) {
+		for _, verb := range verbs {
+			required = append(required, Requirement{
+				Group:     group,
+				Resource:  resource,
+				Verb:      verb,
+				Namespace: namespace,
+				Purpose:   purpose,
+			})
+		}
+	}
+
+	add("", "secrets", opts.KeyNamespace, "API key, team and member records",
+		"get", "list", "watch", "create", "update", "delete")
+	add("", "configmaps", opts.KeyNamespace, "tier definitions and model pricing", "get", "create", "update")
+	add("kuadrant.io", "tokenratelimitpolicies", opts.KeyNamespace, "team limits", "get", "update")
+	add("kuadrant.io", "authpolicies", opts.KeyNamespace, "team groups", "get", "update")
+	add("gateway.networking.k8s.io", "gateways", opts.GatewayNamespace, "endpoint discovery", "get")
+	add("serving.kserve.io", "inferenceservices", "", "model listing", "list")
+	for _, deployment := range []string{"authorino", "kuadrant-operator-controller-manager"} {
+		required = append(required, Requirement{
+			Group:     "apps",
+			Resource:  "deployments",
+			Verb:      "patch",
+			Namespace: "kuadrant-system",
+			Name:      deployment,
+			Purpose:   "policy reload",
+		})
+	}
+	if namespace, _, found := strings.Cut(opts.DiscoveryRoute, "/"); found {
+		add("route.openshift.io", "routes", namespace, "endpoint discovery", "get")
+	}
+	if opts.AdminKeySecretRef != "" {
+		if parts := strings.SplitN(opts.AdminKeySecretRef, "/", 3); len(parts) == 3 && parts[0] != opts.KeyNamespace {
+			add("", "secrets", parts[0], "admin key", "get", "list", "watch")
+		}
+	}
+
+	add("", "namespaces", "", "team key namespaces", "get")
+	if opts.AutoCreateNamespaces {
+		add("", "namespaces", "", "team key namespaces", "create")
+	}
+	if opts.KeyNamespaceClusterRole != "" {
+		add("rbac.authorization.k8s.io", "rolebindings", "", "team key namespace access",
+			"get", "create", "update", "delete")
+		required = append(required, Requirement{
+			Group:    "rbac.authorization.k8s.io",
+			Resource: "clusterroles",
+			Verb:     "bind",
+			Name:     opts.KeyNamespaceClusterRole,
+			Purpose:  "team key namespace access",
+		})
+	}
+	if opts.KubernetesAdminAuth {
+		add("authentication.k8s.io", "tokenreviews", "", "admin authentication", "create")
+		add("authorization.k8s.io", "subjectaccessreviews", "", "admin authorization", "create")
+	}
+	if opts.TeamCRD {
+		add("maas.redhat.com", "maasteams", opts.KeyNamespace, "MaaSTeam resources",
+			"get", "list", "create", "update", "delete")
+		required = append(required, Requirement{
+			Group:       "maas.redhat.com",
+			Resource:    "maasteams",
+			Subresource: "status",
+			Verb:        "update",
+			Namespace:   opts.KeyNamespace,
+			Purpose:     "MaaSTeam resources",
+		})
+	}
+	if opts.Events {
+		add("", "events", opts.KeyNamespace, "lifecycle events", "create", "patch")
+		if opts.GatewayNamespace != opts.KeyNamespace {
+			add("", "events", opts.GatewayNamespace, "lifecycle events", "create", "patch")
+		}
+	}
+	return required
+}
+
+// Checker asks the API server, with SelfSubjectAccessReviews, whether the
+// key manager holds every permission it needs, so a missing grant is
+// reported up front instead of failing whichever request first needs it
+type Checker struct {
+	clientset    kubernetes.Interface
+	requirements []Requirement
+}
+
+// NewChecker creates a permission checker for a list of requirements
+func NewChecker(clientset kubernetes.Interface, requirements []Requirement) *Checker {
+	return &Checker{
+		clientset:    clientset,
+		requirements: requirements,
+	}
+}
+
+// Check reviews every requirement
+func (c *Checker) Check(ctx context.Context) *Report {
+	report := &Report{
+		Status:    StatusOK,
+		Checked:   len(c.requirements),
+		Missing:   make([]MissingPermission, 0),
+		CheckedAt: time.Now().Format(time.RFC3339),
+	}
+	for _, requirement := range c.requirements {
+		review, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx,
+			&authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:       requirement.Group,
+					Resource:    requirement.Resource,
+					Subresource: requirement.Subresource,
+					Verb:        requirement.Verb,
+					Namespace:   requirement.Namespace,
+					Name:        requirement.Name,
+				},
+			}}, metav1.CreateOptions{})
+		if err != nil {
+			report.Missing = append(report.Missing, MissingPermission{Requirement: requirement, Error: err.Error()})
+			continue
+		}
+		if !review.Status.Allowed {
+			report.Missing = append(report.Missing, MissingPermission{Requirement: requirement, Reason: review.Status.Reason})
+		}
+	}
+	if len(report.Missing) > 0 {
+		report.Status = StatusMissing
+	}
+	return report
+}
+
+// CheckAndLog reviews every requirement and logs the missing ones, for the
+// startup self-check
+func (c *Checker) CheckAndLog() {
+	report := c.Check(context.Background())
+	for _, missing := range report.Missing {
+		if missing.Error != "" {
+			log.Printf("Warning: Failed to check permission to %s: %s", missing.describe(), missing.Error)
+			continue
+		}
+		log.Printf("Warning: Missing permission to %s, needed for %s", missing.describe(), missing.Purpose)
+	}
+	if report.Status == StatusOK {
+		log.Printf("All %d required permissions are granted", report.Checked)
+	}
+}
+
+// describe renders a requirement as "verb resource in namespace"
+func (r Requirement) describe() string {
+	resource := r.Resource
+	if r.Group != "" {
+		resource += "." + r.Group
+	}
+	if r.Subresource != "" {
+		resource += "/" + r.Subresource
+	}
+	if r.Name != "" {
+		resource += " " + r.Name
+	}
+	scope := "cluster-wide"
+	if r.Namespace != "" {
+		scope = "in namespace " + r.Namespace
+	}
+	return r.Verb + " " + resource + " " + scope
+}
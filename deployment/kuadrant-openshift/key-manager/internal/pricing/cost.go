@@ -0,0 +1,311 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/teams"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
+)
+
+// UsageRecord is the token use of one or more requests to a model
+type UsageRecord struct {
+	// Chosen by the sender; a record whose ID was already charged to its team
+	// is skipped, so retries are not charged twice
+	ID               string `json:"id,omitempty"`
+	TeamID           string `json:"team_id"`
+	KeyName          string `json:"key_name,omitempty"`
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	// Requests the record covers, 1 when unset
+	Requests int64 `json:"requests,omitempty"`
+	// When the usage happened, which picks the price charged; now when unset
+	Timestamp time.Time `json:"timestamp,omitempty"`
+}
+
+// Charge is what a usage record cost, and at which pricing version
+type Charge struct {
+	UsageRecord
+	Cost         float64 `json:"cost"`
+	Currency     string  `json:"currency"`
+	PriceVersion int64   `json:"price_version"`
+	// The model had no price and was charged the default
+	DefaultPrice bool `json:"default_price,omitempty"`
+}
+
+const (
+	// maxRecordIDLength bounds the IDs usage records are deduplicated by
+	maxRecordIDLength = 128
+	// maxLedgerRecords is how many record IDs a team's ledger remembers
+	maxLedgerRecords = 1000
+)
+
+// budgetCurrency is the currency of team budgets. Charges in other currencies
+// are reported but not converted, so they do not count toward a budget.
+const budgetCurrency = "USD"
+
+// Engine converts usage records into cost at the registry's prices and
+// accumulates it per team, model and key. Each record is charged at the price
+// in effect when the usage happened. A team's costs are kept in a ledger on
+// its config secret, so they survive restarts and are shared by replicas, and
+// its USD cost is added to its budget spend.
+type Engine struct {
+	registry *Registry
+	teamMgr  *teams.Manager
+}
+
+// ledger is the cost a team has accrued, as stored on its config secret
+type ledger struct {
+	Since  string                      `json:"since"`
+	Models map[string]*types.ModelCost `json:"models"`
+	Keys   map[string]*types.KeyCost   `json:"keys"`
+	// Digests of the latest record IDs charged, oldest first
+	Records []string `json:"records,omitempty"`
+}
+
+// NewEngine creates a cost engine over a pricing registry
+func NewEngine(registry *Registry, teamMgr *teams.Manager) *Engine {
+	return &Engine{
+		registry: registry,
+		teamMgr:  teamMgr,
+	}
+}
+
+// Validate checks a usage record before anything is charged
+func (e *Engine) Validate(record UsageRecord) error {
+	if record.TeamID == "" {
+		return fmt.Errorf("team_id is required")
+	}
+	if record.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if record.PromptTokens < 0 || record.CompletionTokens < 0 || record.Requests < 0 {
+		return fmt.Errorf("token and request counts must be non-negative")
+	}
+	if len(record.ID) > maxRecordIDLength {
+		return fmt.Errorf("id must be at most %d characters", maxRecordIDLength)
+	}
+	return nil
+}
+
+// Record charges usage records to their teams and keys. A team's records are
+// stored in one update, and records whose ID the team was already charged for
+// are skipped, so a batch that failed part way can be sent again. It returns
+// the new charges and how many records were skipped as duplicates.
+func (e *Engine) Record(records []UsageRecord) ([]*Charge, int, error) {
+	teamIDs := make([]string, 0)
+	byTeam := make(map[string][]UsageRecord)
+	now := time.Now().UTC()
+	for i, record := range records {
+		if err := e.Validate(record); err != nil {
+			return nil, 0, fmt.Errorf("invalid usage record %d: %w", i, err)
+		}
+		if record.Requests == 0 {
+			record.Requests = 1
+		}
+		if record.Timestamp.IsZero() {
+			record.Timestamp = now
+		}
+		if _, ok := byTeam[record.TeamID]; !ok {
+			teamIDs = append(teamIDs, record.TeamID)
+		}
+		byTeam[record.TeamID] = append(byTeam[record.TeamID], record)
+	}
+
+	charges := make([]*Charge, 0, len(records))
+	duplicates := 0
+	for _, teamID := range teamIDs {
+		var teamCharges []*Charge
+		var skipped int
+		_, err := e.teamMgr.RecordCost(teamID, func(stored string) (string, float64, error) {
+			teamCharges, skipped = nil, 0
+			team, err := parseLedger(stored)
+			if err != nil {
+				return "", 0, err
+			}
+
+			spend := 0.0
+			for _, record := range byTeam[teamID] {
+				if record.ID != "" {
+					if !team.claimRecord(record.ID) {
+						skipped++
+						continue
+					}
+				}
+				charge := e.charge(record)
+				team.add(charge)
+				teamCharges = append(teamCharges, charge)
+				if charge.Currency == budgetCurrency {
+					spend += charge.Cost
+				}
+			}
+
+			data, err := json.Marshal(team)
+			if err != nil {
+				return "", 0, err
+			}
+			return string(data), spend, nil
+		})
+		if err != nil {
+			return charges, duplicates, fmt.Errorf("failed to charge team %s: %w", teamID, err)
+		}
+		charges = append(charges, teamCharges...)
+		duplicates += skipped
+	}
+
+	return charges, duplicates, nil
+}
+
+// charge prices a usage record at the price in effect when it happened
+func (e *Engine) charge(record UsageRecord) *Charge {
+	price, known := e.registry.PriceAt(record.Model, record.Timestamp)
+	return &Charge{
+		UsageRecord: record,
+		Cost: float64(record.PromptTokens)/1000*price.InputPer1K +
+			float64(record.CompletionTokens)/1000*price.OutputPer1K,
+		Currency:     price.Currency,
+		PriceVersion: price.Version,
+		DefaultPrice: !known,
+	}
+}
+
+// parseLedger decodes a stored ledger, starting an empty one when there is none
+func parseLedger(stored string) (*ledger, error) {
+	team := &ledger{
+		Since:  time.Now().UTC().Format(time.RFC3339),
+		Models: make(map[string]*types.ModelCost),
+		Keys:   make(map[string]*types.KeyCost),
+	}
+	if stored == "" {
+		return team, nil
+	}
+	if err := json.Unmarshal([]byte(stored), team); err != nil {
+		return nil, fmt.Errorf("invalid cost ledger: %w", err)
+	}
+	if team.Models == nil {
+		team.Models = make(map[string]*types.ModelCost)
+	}
+	if team.Keys == nil {
+		team.Keys = make(map[string]*types.KeyCost)
+	}
+	return team, nil
+}
+
+// add accrues a charge to the ledger's model and key
+func (l *ledger) add(charge *Charge) {
+	model, ok := l.Models[charge.Model]
+	if !ok {
+		model = &types.ModelCost{Model: charge.Model, Cost: make(map[string]float64), PriceVersions: []int64{}}
+		l.Models[charge.Model] = model
+	}
+	model.Cost[charge.Currency] += charge.Cost
+	model.PromptTokens += charge.PromptTokens
+	model.CompletionTokens += charge.CompletionTokens
+	model.Requests += charge.Requests
+	model.DefaultPrice = model.DefaultPrice || charge.DefaultPrice
+	if !containsVersion(model.PriceVersions, charge.PriceVersion) {
+		model.PriceVersions = append(model.PriceVersions, charge.PriceVersion)
+		sort.Slice(model.PriceVersions, func(i, j int) bool { return model.PriceVersions[i] < model.PriceVersions[j] })
+	}
+
+	if charge.KeyName != "" {
+		key, ok := l.Keys[charge.KeyName]
+		if !ok {
+			key = &types.KeyCost{KeyName: charge.KeyName, Cost: make(map[string]float64)}
+			l.Keys[charge.KeyName] = key
+		}
+		key.Cost[charge.Currency] += charge.Cost
+		key.PromptTokens += charge.PromptTokens
+		key.CompletionTokens += charge.CompletionTokens
+		key.Requests += charge.Requests
+	}
+}
+
+// claimRecord remembers a record ID, reporting false when it was already
+// charged. Only the latest maxLedgerRecords IDs are kept.
+func (l *ledger) claimRecord(id string) bool {
+	digest := fips.SHA256Hex([]byte(id))[:32]
+	for _, seen := range l.Records {
+		if seen == digest {
+			return false
+		}
+	}
+	l.Records = append(l.Records, digest)
+	if len(l.Records) > maxLedgerRecords {
+		l.Records = l.Records[len(l.Records)-maxLedgerRecords:]
+	}
+	return true
+}
+
+// containsVersion reports whether versions holds version
+func containsVersion(versions []int64, version int64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamCost returns the cost accumulated by a team, zero when it has none
+func (e *Engine) TeamCost(teamID string) (*types.CostSummary, error) {
+	summary := &types.CostSummary{
+		Cost:   make(map[string]float64),
+		Models: []types.ModelCost{},
+		Keys:   []types.KeyCost{},
+	}
+
+	stored, err := e.teamMgr.CostLedger(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == "" {
+		return summary, nil
+	}
+	team, err := parseLedger(stored)
+	if err != nil {
+		return nil, err
+	}
+	summary.Since = team.Since
+
+	for _, model := range team.Models {
+		entry := *model
+		entry.Cost = roundCosts(model.Cost)
+		summary.Models = append(summary.Models, entry)
+
+		for currency, cost := range model.Cost {
+			summary.Cost[currency] += cost
+		}
+		summary.PromptTokens += model.PromptTokens
+		summary.CompletionTokens += model.CompletionTokens
+		summary.Requests += model.Requests
+		if model.DefaultPrice {
+			summary.UnpricedModels = append(summary.UnpricedModels, model.Model)
+		}
+	}
+	for _, key := range team.Keys {
+		entry := *key
+		entry.Cost = roundCosts(key.Cost)
+		summary.Keys = append(summary.Keys, entry)
+	}
+	summary.Cost = roundCosts(summary.Cost)
+
+	sort.Slice(summary.Models, func(i, j int) bool { return summary.Models[i].Model < summary.Models[j].Model })
+	sort.Slice(summary.Keys, func(i, j int) bool { return summary.Keys[i].KeyName < summary.Keys[j].KeyName })
+	sort.Strings(summary.UnpricedModels)
+	return summary, nil
+}
+
+// roundCosts rounds each amount to a millionth of its currency for reporting
+func roundCosts(costs map[string]float64) map[string]float64 {
+	rounded := make(map[string]float64, len(costs))
+	for currency, amount := range costs {
+		rounded[currency] = math.Round(amount*1e6) / 1e6
+	}
+	return rounded
+}
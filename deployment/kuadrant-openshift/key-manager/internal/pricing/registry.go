@@ -0,0 +1,471 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapName holds the model prices, one JSON entry per model, and the
+// history of every change
+const ConfigMapName = "maas-model-pricing"
+
+// versionAnnotation counts the changes made to the prices
+const versionAnnotation = "maas/pricing-version"
+
+// historyKeyPrefix starts the ConfigMap keys of past changes. Model IDs
+// start with an alphanumeric, so they cannot collide.
+const historyKeyPrefix = "_history."
+
+// modelIDPattern matches model IDs that can be ConfigMap keys
+var modelIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,252}$`)
+
+// currencyPattern matches ISO 4217 currency codes
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ErrNoPrice is returned when removing the price of a model that has none
+var ErrNoPrice = errors.New("model has no price")
+
+// IsValidModelID reports whether a model can be priced
+func IsValidModelID(modelID string) bool {
+	return modelIDPattern.MatchString(modelID)
+}
+
+// Price is what a model's tokens cost per 1000 tokens. Version is the
+// pricing version that set it, 0 for the default price.
+type Price struct {
+	InputPer1K    float64 `json:"input_per_1k"`
+	OutputPer1K   float64 `json:"output_per_1k"`
+	Currency      string  `json:"currency"`
+	EffectiveDate string  `json:"effective_date"`
+	Version       int64   `json:"version"`
+	UpdatedAt     string  `json:"updated_at,omitempty"`
+}
+
+// ModelPrice is the price of one model
+type ModelPrice struct {
+	ModelID string `json:"model_id"`
+	Price
+}
+
+// priceChange is an entry of the price history: a model priced, or its
+// price removed when Price is nil, from an effective date on
+type priceChange struct {
+	ModelID       string `json:"model_id"`
+	Price         *Price `json:"price,omitempty"`
+	EffectiveDate string `json:"effective_date"`
+	Version       int64  `json:"version"`
+}
+
+// effective returns when a change applies from
+func (c priceChange) effective() time.Time {
+	effective, _ := time.Parse(time.RFC3339, c.EffectiveDate)
+	return effective
+}
+
+// SetPriceRequest creates or updates the price of a model. Currency defaults
+// to the registry's default currency and the effective date to now.
+type SetPriceRequest struct {
+	InputPer1K    *float64 `json:"input_per_1k" binding:"required"`
+	OutputPer1K   *float64 `json:"output_per_1k" binding:"required"`
+	Currency      string   `json:"currency"`
+	EffectiveDate string   `json:"effective_date"`
+}
+
+// isValidAmount reports whether a price is a finite, non-negative number
+func isValidAmount(amount float64) bool {
+	return amount >= 0 && !math.IsInf(amount, 0)
+}
+
+// parseEffectiveDate reads an effective date given as RFC 3339 or as a day
+func parseEffectiveDate(value string) (time.Time, error) {
+	if effective, err := time.Parse(time.RFC3339, value); err == nil {
+		return effective.UTC(), nil
+	}
+	if effective, err := time.Parse("2006-01-02", value); err == nil {
+		return effective, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid effective_date: must be RFC 3339 or YYYY-MM-DD")
+}
+
+// Registry keeps the model prices of a ConfigMap, and their history, in
+// memory. Every change bumps the pricing version and is added to the
+// history, so usage can be charged at the prices in effect when it
+// happened. Models without a price are charged the default price.
+type Registry struct {
+	clientset    kubernetes.Interface
+	namespace    string
+	defaultPrice Price
+	currencies   []string
+
+	mu      sync.RWMutex
+	prices  map[string]Price
+	history map[string][]priceChange
+	version int64
+}
+
+// NewRegistry creates a pricing registry over the ConfigMap of a namespace,
+// accepting prices in the given currencies. Call Start to load it.
+func NewRegistry(clientset kubernetes.Interface, namespace string, defaultPrice Price, currencies []string) (*Registry, error) {
+	r := &Registry{
+		clientset:    clientset,
+		namespace:    namespace,
+		defaultPrice: defaultPrice,
+		currencies:   make([]string, 0, len(currencies)),
+		prices:       make(map[string]Price),
+		history:      make(map[string][]priceChange),
+	}
+	for _, currency := range currencies {
+		if !currencyPattern.MatchString(currency) {
+			return nil, fmt.Errorf("invalid currency %q: must be a three-letter ISO 4217 code", currency)
+		}
+		r.currencies = append(r.currencies, currency)
+	}
+	if err := r.validate(defaultPrice); err != nil {
+		return nil, fmt.Errorf("default price: %w", err)
+	}
+	return r, nil
+}
+
+// Start loads the prices now and then every interval in the background, so
+// edits made through other replicas or by hand are picked up
+func (r *Registry) Start(interval time.Duration) {
+	if err := r.Refresh(); err != nil {
+		log.Printf("Warning: Failed to load model pricing: %v", err)
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.Refresh(); err != nil {
+				log.Printf("Warning: Failed to reload model pricing: %v", err)
+			}
+		}
+	}()
+}
+
+// Refresh reloads the prices. A missing ConfigMap means there are none.
+func (r *Registry) Refresh() error {
+	configMap, err := r.clientset.CoreV1().ConfigMaps(r.namespace).Get(
+		context.Background(), ConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		r.load(&corev1.ConfigMap{})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get model pricing: %w", err)
+	}
+	r.load(configMap)
+	return nil
+}
+
+// load replaces the prices and history with those of a ConfigMap
+func (r *Registry) load(configMap *corev1.ConfigMap) {
+	prices := make(map[string]Price, len(configMap.Data))
+	history := make(map[string][]priceChange)
+	for key, data := range configMap.Data {
+		if strings.HasPrefix(key, historyKeyPrefix) {
+			var change priceChange
+			if err := json.Unmarshal([]byte(data), &change); err != nil {
+				log.Printf("Warning: Ignoring invalid pricing history entry %s: %v", key, err)
+				continue
+			}
+			history[change.ModelID] = append(history[change.ModelID], change)
+			continue
+		}
+
+		var price Price
+		if err := json.Unmarshal([]byte(data), &price); err != nil {
+			log.Printf("Warning: Ignoring invalid price of model %s: %v", key, err)
+			continue
+		}
+		if err := r.validate(price); err != nil {
+			log.Printf("Warning: Ignoring invalid price of model %s: %v", key, err)
+			continue
+		}
+		prices[key] = price
+	}
+	for _, changes := range history {
+		sortChanges(changes)
+	}
+	version, _ := strconv.ParseInt(configMap.Annotations[versionAnnotation], 10, 64)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prices = prices
+	r.history = history
+	r.version = version
+}
+
+// sortChanges orders changes by effective date, then by version
+func sortChanges(changes []priceChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i].effective(), changes[j].effective()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return changes[i].Version < changes[j].Version
+	})
+}
+
+// validate checks that a price can be charged
+func (r *Registry) validate(price Price) error {
+	if !isValidAmount(price.InputPer1K) {
+		return fmt.Errorf("invalid input_per_1k: must be a non-negative number")
+	}
+	if !isValidAmount(price.OutputPer1K) {
+		return fmt.Errorf("invalid output_per_1k: must be a non-negative number")
+	}
+	for _, currency := range r.currencies {
+		if price.Currency == currency {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid currency %q: must be one of %s", price.Currency, strings.Join(r.currencies, ", "))
+}
+
+// Lookup returns the price of a model now, or the default price and false
+// when it has none
+func (r *Registry) Lookup(modelID string) (Price, bool) {
+	return r.PriceAt(modelID, time.Now())
+}
+
+// PriceAt returns the price a model had at a time, or the default price and
+// false when it had none. Prices set before the history was kept apply from
+// their effective date.
+func (r *Registry) PriceAt(modelID string, at time.Time) (Price, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if changes, ok := r.history[modelID]; ok {
+		for i := len(changes) - 1; i >= 0; i-- {
+			if changes[i].effective().After(at) {
+				continue
+			}
+			if changes[i].Price == nil {
+				break
+			}
+			return *changes[i].Price, true
+		}
+		return r.defaultPrice, false
+	}
+
+	if price, ok := r.prices[modelID]; ok {
+		effective, err := time.Parse(time.RFC3339, price.EffectiveDate)
+		if err != nil || !effective.After(at) {
+			return price, true
+		}
+	}
+	return r.defaultPrice, false
+}
+
+// List returns the current price of every model, the latest change of
+// each whatever its effective date, and the pricing version
+func (r *Registry) List() ([]ModelPrice, int64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prices := make([]ModelPrice, 0, len(r.prices))
+	for modelID, price := range r.prices {
+		prices = append(prices, ModelPrice{ModelID: modelID, Price: price})
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].ModelID < prices[j].ModelID })
+	return prices, r.version
+}
+
+// ListAt returns the price every model had at a time
+func (r *Registry) ListAt(at time.Time) []ModelPrice {
+	r.mu.RLock()
+	modelIDs := make([]string, 0, len(r.prices)+len(r.history))
+	for modelID := range r.prices {
+		modelIDs = append(modelIDs, modelID)
+	}
+	for modelID := range r.history {
+		if _, ok := r.prices[modelID]; !ok {
+			modelIDs = append(modelIDs, modelID)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(modelIDs)
+	prices := make([]ModelPrice, 0, len(modelIDs))
+	for _, modelID := range modelIDs {
+		if price, ok := r.PriceAt(modelID, at); ok {
+			prices = append(prices, ModelPrice{ModelID: modelID, Price: price})
+		}
+	}
+	return prices
+}
+
+// DefaultPrice is charged for models without a price
+func (r *Registry) DefaultPrice() Price {
+	return r.defaultPrice
+}
+
+// Currencies are the currencies prices may be set in
+func (r *Registry) Currencies() []string {
+	return r.currencies
+}
+
+// Set creates or updates the price of a model
+func (r *Registry) Set(modelID string, req *SetPriceRequest) (*ModelPrice, error) {
+	if !IsValidModelID(modelID) {
+		return nil, fmt.Errorf("invalid model ID %q", modelID)
+	}
+	now := time.Now().UTC()
+	effective := now
+	if req.EffectiveDate != "" {
+		parsed, err := parseEffectiveDate(req.EffectiveDate)
+		if err != nil {
+			return nil, err
+		}
+		effective = parsed
+	}
+	price := Price{
+		InputPer1K:    *req.InputPer1K,
+		OutputPer1K:   *req.OutputPer1K,
+		Currency:      strings.ToUpper(req.Currency),
+		EffectiveDate: effective.Format(time.RFC3339),
+		UpdatedAt:     now.Format(time.RFC3339),
+	}
+	if price.Currency == "" {
+		price.Currency = r.defaultPrice.Currency
+	}
+	if err := r.validate(price); err != nil {
+		return nil, err
+	}
+
+	err := r.update(func(configMap *corev1.ConfigMap, version int64) error {
+		price.Version = version
+		data, err := json.Marshal(price)
+		if err != nil {
+			return err
+		}
+		configMap.Data[modelID] = string(data)
+		return addChange(configMap, priceChange{
+			ModelID:       modelID,
+			Price:         &price,
+			EffectiveDate: price.EffectiveDate,
+			Version:       version,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save price of model %s: %w", modelID, err)
+	}
+
+	log.Printf("Price of model %s set to %g input, %g output %s per 1K tokens from %s (pricing version %d)",
+		modelID, price.InputPer1K, price.OutputPer1K, price.Currency, price.EffectiveDate, price.Version)
+	return &ModelPrice{ModelID: modelID, Price: price}, nil
+}
+
+// Delete removes the price of a model. Its past prices stay in the history,
+// so usage from before is still charged at them.
+func (r *Registry) Delete(modelID string) error {
+	var version int64
+	err := r.update(func(configMap *corev1.ConfigMap, next int64) error {
+		if _, ok := configMap.Data[modelID]; !ok {
+			return fmt.Errorf("%w: %s", ErrNoPrice, modelID)
+		}
+		version = next
+		delete(configMap.Data, modelID)
+		return addChange(configMap, priceChange{
+			ModelID:       modelID,
+			EffectiveDate: time.Now().UTC().Format(time.RFC3339),
+			Version:       next,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrNoPrice) {
+			return err
+		}
+		return fmt.Errorf("failed to remove price of model %s: %w", modelID, err)
+	}
+
+	log.Printf("Price of model %s removed (pricing version %d)", modelID, version)
+	return nil
+}
+
+// addChange records a change in the history of a ConfigMap
+func addChange(configMap *corev1.ConfigMap, change priceChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	configMap.Data[fmt.Sprintf("%s%08d", historyKeyPrefix, change.Version)] = string(data)
+	return nil
+}
+
+// update applies a change to the ConfigMap under the next pricing version,
+// creating the ConfigMap on first use, and reloads the registry from it
+func (r *Registry) update(change func(configMap *corev1.ConfigMap, version int64) error) error {
+	configMaps := r.clientset.CoreV1().ConfigMaps(r.namespace)
+	var saved *corev1.ConfigMap
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(context.Background(), ConfigMapName, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if create {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ConfigMapName,
+					Namespace: r.namespace,
+					Labels: map[string]string{
+						"maas/managed-by":    "key-manager",
+						"maas/resource-type": "model-pricing",
+					},
+				},
+			}
+		} else if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		if configMap.Annotations == nil {
+			configMap.Annotations = make(map[string]string)
+		}
+
+		version, _ := strconv.ParseInt(configMap.Annotations[versionAnnotation], 10, 64)
+		version++
+		if err := change(configMap, version); err != nil {
+			return err
+		}
+		configMap.Annotations[versionAnnotation] = strconv.FormatInt(version, 10)
+
+		if create {
+			saved, err = configMaps.Create(context.Background(), configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently, retry as an update
+				return apierrors.NewConflict(corev1.Resource("configmaps"), ConfigMapName, err)
+			}
+			return err
+		}
+		saved, err = configMaps.Update(context.Background(), configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	r.load(saved)
+	return nil
+}
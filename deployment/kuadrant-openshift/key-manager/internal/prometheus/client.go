@@ -0,0 +1,200 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client runs PromQL queries against the Prometheus HTTP API. Results are
+// cached for a short TTL, keyed by the query and window rather than the
+// exact time, so dashboards refreshing every few seconds reach Prometheus
+// at most once per TTL.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	samples []Sample
+	series  []Series
+	expires time.Time
+}
+
+// NewClient creates a new Prometheus client, returns nil if no URL is
+// configured. A non-positive cacheTTL disables the cache.
+func NewClient(baseURL string, cacheTTL time.Duration) *Client {
+	if baseURL == "" {
+		return nil
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cacheEntry),
+	}
+}
+
+// Query evaluates an instant query now
+func (c *Client) Query(ctx context.Context, query string) ([]Sample, error) {
+	cacheKey := "query|" + query
+	if entry, ok := c.cached(cacheKey); ok {
+		return entry.samples, nil
+	}
+
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatTime(time.Now()))
+	data, err := c.get(ctx, "/api/v1/query", params)
+	if err != nil {
+		return nil, err
+	}
+	if data.ResultType != "vector" {
+		return nil, fmt.Errorf("prometheus returned %s for an instant query", data.ResultType)
+	}
+
+	samples := make([]Sample, 0, len(data.Result))
+	for _, result := range data.Result {
+		_, value, err := parsePoint(result.Value)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, Sample{Labels: result.Metric, Value: value})
+	}
+
+	c.store(cacheKey, cacheEntry{samples: samples})
+	return samples, nil
+}
+
+// QueryRange evaluates a query every step over the window ending now
+func (c *Client) QueryRange(ctx context.Context, query string, window, step time.Duration) ([]Series, error) {
+	cacheKey := fmt.Sprintf("range|%s|%s|%s", query, window, step)
+	if entry, ok := c.cached(cacheKey); ok {
+		return entry.series, nil
+	}
+
+	// Aligning to the step keeps each point covering a whole step
+	end := time.Now().Truncate(step)
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(end.Add(-window)))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	data, err := c.get(ctx, "/api/v1/query_range", params)
+	if err != nil {
+		return nil, err
+	}
+	if data.ResultType != "matrix" {
+		return nil, fmt.Errorf("prometheus returned %s for a range query", data.ResultType)
+	}
+
+	series := make([]Series, 0, len(data.Result))
+	for _, result := range data.Result {
+		points := make([]Point, 0, len(result.Values))
+		for _, value := range result.Values {
+			at, parsed, err := parsePoint(value)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, Point{Time: at, Value: parsed})
+		}
+		series = append(series, Series{Labels: result.Metric, Points: points})
+	}
+
+	c.store(cacheKey, cacheEntry{series: series})
+	return series, nil
+}
+
+// get calls a query endpoint and returns its data
+func (c *Client) get(ctx context.Context, path string, params url.Values) (*apiData, error) {
+	queryURL := c.baseURL + path + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prometheus at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	var body apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode prometheus response (status %d): %w", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed (%s): %s", body.ErrorType, body.Error)
+	}
+	return &body.Data, nil
+}
+
+// cached returns an unexpired cache entry
+func (c *Client) cached(key string) (cacheEntry, bool) {
+	if c.cacheTTL <= 0 {
+		return cacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// store caches a result, dropping expired entries
+func (c *Client) store(key string, entry cacheEntry) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.cache {
+		if now.After(e.expires) {
+			delete(c.cache, k)
+		}
+	}
+	entry.expires = now.Add(c.cacheTTL)
+	c.cache[key] = entry
+}
+
+// parsePoint parses a [timestamp, "value"] pair
+func parsePoint(pair []interface{}) (time.Time, float64, error) {
+	if len(pair) != 2 {
+		return time.Time{}, 0, fmt.Errorf("malformed prometheus sample")
+	}
+	timestamp, ok := pair[0].(float64)
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("malformed prometheus timestamp")
+	}
+	text, ok := pair[1].(string)
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("malformed prometheus value")
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("malformed prometheus value %q", text)
+	}
+	sec := int64(timestamp)
+	return time.Unix(sec, int64((timestamp-float64(sec))*1e9)).UTC(), value, nil
+}
+
+// formatTime renders a time as Unix seconds
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
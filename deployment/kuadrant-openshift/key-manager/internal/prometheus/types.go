@@ -0,0 +1,42 @@
+package prometheus
+
+import "time"
+
+// Prometheus HTTP API structures
+type apiResponse struct {
+	Status    string  `json:"status"`
+	Data      apiData `json:"data"`
+	ErrorType string  `json:"errorType"`
+	Error     string  `json:"error"`
+}
+
+type apiData struct {
+	ResultType string      `json:"resultType"`
+	Result     []apiResult `json:"result"`
+}
+
+type apiResult struct {
+	Metric map[string]string `json:"metric"`
+	// [timestamp, "value"] for instant vectors
+	Value []interface{} `json:"value"`
+	// [[timestamp, "value"], ...] for range matrices
+	Values [][]interface{} `json:"values"`
+}
+
+// Sample is one series of an instant query
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Point is one value of a range query
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is one series of a range query
+type Series struct {
+	Labels map[string]string
+	Points []Point
+}
@@ -0,0 +1,241 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// Idle buckets and lockouts are forgotten after this long
+const (
+	idleTimeout   = 10 * time.Minute
+	sweepInterval = time.Minute
+)
+
+// Config sets the key manager's own request limits. A zero rate or
+// threshold turns that check off.
+type Config struct {
+	// Requests per minute per client IP and per presented credential
+	IPPerMinute         int
+	CredentialPerMinute int
+	// Requests that may be made at once before the rate applies
+	Burst int
+	// Consecutive 401s from one IP before it is locked out
+	LockoutThreshold int
+	// First lockout, doubled by each further lockout up to LockoutMax
+	LockoutDuration time.Duration
+	LockoutMax      time.Duration
+}
+
+// Counters are the limiter's totals since start
+type Counters struct {
+	ThrottledIP         int64
+	ThrottledCredential int64
+	AuthFailures        int64
+	Lockouts            int64
+	LockoutRejections   int64
+	LockedOutClients    int
+}
+
+// bucket is a token bucket refilled continuously at its rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token if one is available, otherwise reporting how long
+// until one is
+func (b *bucket) take(now time.Time, perSecond float64, burst int) (bool, time.Duration) {
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// failures tracks consecutive authentication failures of one IP
+type failures struct {
+	consecutive int
+	lockouts    int
+	lockedUntil time.Time
+	last        time.Time
+}
+
+// Limiter throttles requests per client IP and per credential with token
+// buckets, and locks out IPs that keep failing authentication for a period
+// that grows with each lockout. A nil Limiter allows everything.
+type Limiter struct {
+	config Config
+
+	mu          sync.Mutex
+	ipBuckets   map[string]*bucket
+	credBuckets map[string]*bucket
+	failures    map[string]*failures
+	lastSweep   time.Time
+
+	throttledIP         atomic.Int64
+	throttledCredential atomic.Int64
+	authFailures        atomic.Int64
+	lockouts            atomic.Int64
+	lockoutRejections   atomic.Int64
+}
+
+// NewLimiter creates a limiter
+func NewLimiter(config Config) *Limiter {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.LockoutMax < config.LockoutDuration {
+		config.LockoutMax = config.LockoutDuration
+	}
+	return &Limiter{
+		config:      config,
+		ipBuckets:   make(map[string]*bucket),
+		credBuckets: make(map[string]*bucket),
+		failures:    make(map[string]*failures),
+		lastSweep:   time.Now(),
+	}
+}
+
+// Allow decides whether a request from an IP with a credential may proceed.
+// When it may not, it returns why and how long the client should wait. The
+// credential is only kept as a hash.
+func (l *Limiter) Allow(ip, credential string) (bool, string, time.Duration) {
+	if l == nil {
+		return true, "", 0
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	if f := l.failures[ip]; f != nil && now.Before(f.lockedUntil) {
+		l.lockoutRejections.Add(1)
+		return false, "Too many failed authentication attempts", f.lockedUntil.Sub(now)
+	}
+	if l.config.IPPerMinute > 0 {
+		if ok, wait := take(l.ipBuckets, ip, now, l.config.IPPerMinute, l.config.Burst); !ok {
+			l.throttledIP.Add(1)
+			return false, "Too many requests from this client", wait
+		}
+	}
+	if l.config.CredentialPerMinute > 0 && credential != "" {
+		if ok, wait := take(l.credBuckets, fips.SHA256Hex([]byte(credential)), now, l.config.CredentialPerMinute, l.config.Burst); !ok {
+			l.throttledCredential.Add(1)
+			return false, "Too many requests with this credential", wait
+		}
+	}
+	return true, "", 0
+}
+
+// take takes a token from a key's bucket, creating it full
+func take(buckets map[string]*bucket, key string, now time.Time, perMinute, burst int) (bool, time.Duration) {
+	b := buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(burst), last: now}
+		buckets[key] = b
+	}
+	return b.take(now, float64(perMinute)/60, burst)
+}
+
+// Result records the outcome of a request. A 401 counts towards locking the
+// IP out; any other status resets its count.
+func (l *Limiter) Result(ip string, status int) {
+	if l == nil || l.config.LockoutThreshold <= 0 {
+		return
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f := l.failures[ip]
+	if status != 401 {
+		if f != nil {
+			f.consecutive = 0
+		}
+		return
+	}
+
+	l.authFailures.Add(1)
+	if f == nil {
+		f = &failures{}
+		l.failures[ip] = f
+	}
+	f.consecutive++
+	f.last = now
+	if f.consecutive < l.config.LockoutThreshold {
+		return
+	}
+
+	lockout := l.config.LockoutDuration
+	for i := 0; i < f.lockouts && lockout < l.config.LockoutMax; i++ {
+		lockout *= 2
+	}
+	if lockout > l.config.LockoutMax {
+		lockout = l.config.LockoutMax
+	}
+	f.lockouts++
+	f.consecutive = 0
+	f.lockedUntil = now.Add(lockout)
+	l.lockouts.Add(1)
+}
+
+// sweep forgets buckets that have refilled and IPs whose failures are idle,
+// so clients that stopped calling do not accumulate
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for _, buckets := range []map[string]*bucket{l.ipBuckets, l.credBuckets} {
+		for key, b := range buckets {
+			if now.Sub(b.last) > idleTimeout {
+				delete(buckets, key)
+			}
+		}
+	}
+	for ip, f := range l.failures {
+		if now.After(f.lockedUntil) && now.Sub(f.last) > idleTimeout {
+			delete(l.failures, ip)
+		}
+	}
+}
+
+// Counters returns the limiter's totals
+func (l *Limiter) Counters() Counters {
+	if l == nil {
+		return Counters{}
+	}
+	counters := Counters{
+		ThrottledIP:         l.throttledIP.Load(),
+		ThrottledCredential: l.throttledCredential.Load(),
+		AuthFailures:        l.authFailures.Load(),
+		Lockouts:            l.lockouts.Load(),
+		LockoutRejections:   l.lockoutRejections.Load(),
+	}
+	now := time.Now()
+	l.mu.Lock()
+	for _, f := range l.failures {
+		if now.Before(f.lockedUntil) {
+			counters.LockedOutClients++
+		}
+	}
+	l.mu.Unlock()
+	return counters
+}
+
+// retryAfter renders a wait as whole seconds for the Retry-After header
+func retryAfter(wait time.Duration) string {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
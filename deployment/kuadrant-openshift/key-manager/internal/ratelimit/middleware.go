@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exemptPaths are probed and scraped by the platform and never throttled
+var exemptPaths = map[string]bool{
+	"/health":  true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Middleware throttles requests with the limiter, answering 429 with a
+// Retry-After header, and feeds it the outcome of each request. The
+// Authorization header is the credential limited, whichever kind it holds,
+// or else credentialHeader when it is set.
+func Middleware(l *Limiter, credentialHeader string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil || exemptPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		ip := c.ClientIP()
+		credential := c.GetHeader("Authorization")
+		if credential == "" && credentialHeader != "" {
+			credential = c.GetHeader(credentialHeader)
+		}
+		allowed, reason, wait := l.Allow(ip, credential)
+		if !allowed {
+			c.Header("Retry-After", retryAfter(wait))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": reason})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+		l.Result(ip, c.Writer.Status())
+	}
+}
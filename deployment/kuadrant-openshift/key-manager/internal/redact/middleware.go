@@ -0,0 +1,37 @@
+package redact
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorBodyWriter redacts the bodies of error responses
+type errorBodyWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *errorBodyWriter) Write(data []byte) (int, error) {
+	if w.Status() < http.StatusBadRequest {
+		return w.ResponseWriter.Write(data)
+	}
+	if _, err := w.ResponseWriter.Write([]byte(String(string(data)))); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *errorBodyWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Middleware redacts error responses, so a failure whose message carries key
+// material, such as a client-go error echoing a Secret, does not hand it to
+// the client. Successful responses, which return newly created keys on
+// purpose, are left alone.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &errorBodyWriter{ResponseWriter: c.Writer}
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Mask replaces redacted values
+const Mask = "[REDACTED]"
+
+var (
+	// Credentials after an Authorization scheme
+	authorizationPattern = regexp.MustCompile(`\b(Bearer|ADMIN|APIKEY|Basic) [A-Za-z0-9._~+/=-]+`)
+	// Values of fields holding key material, in JSON, YAML, query strings
+	// and Go's printing of maps and structs, such as a Secret's StringData
+	fieldPattern = regexp.MustCompile(`((?:"|\b)(?:api_key|apiKey|APIKey|admin_key|token|password)"?\s*[:=]\s*"?)[^\s",}\]&]+`)
+	// Runs of characters keys and tokens are made of
+	tokenRunPattern = regexp.MustCompile(`[A-Za-z0-9_-]{48,}`)
+)
+
+// String masks credentials and anything shaped like key material in s:
+// generated API keys (48 base64url characters) and tokens and admin
+// credentials (64 hex characters). Hex runs include SHA-256 hashes, which
+// are masked too rather than risk missing a token.
+func String(s string) string {
+	s = authorizationPattern.ReplaceAllString(s, "$1 "+Mask)
+	s = fieldPattern.ReplaceAllString(s, "${1}"+Mask)
+	return tokenRunPattern.ReplaceAllStringFunc(s, func(run string) string {
+		if looksLikeKey(run) {
+			return Mask
+		}
+		return run
+	})
+}
+
+// looksLikeKey tells random key material from long names. Kubernetes names
+// are lowercase, so a run with uppercase letters or underscores is taken to
+// be a base64url key, and a run of exactly 64 hex digits a token.
+func looksLikeKey(run string) bool {
+	if len(run) == 64 && strings.Trim(strings.ToLower(run), "0123456789abcdef") == "" {
+		return true
+	}
+	return strings.ContainsRune(run, '_') || strings.IndexFunc(run, unicode.IsUpper) >= 0
+}
+
+// Error returns err with its message redacted, for errors such as client-go
+// ones that may echo the object they failed on. The original error stays
+// reachable through errors.Is and errors.As.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := String(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{message: message, err: err}
+}
+
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string { return e.message }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Writer redacts everything written through it, for use as the output of
+// the standard logger and gin's request log. Each write is redacted on its
+// own, which suits loggers writing a line at a time.
+type Writer struct {
+	out io.Writer
+}
+
+// NewWriter creates a redacting writer
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Write redacts p and writes it, reporting p's length as written
+func (w *Writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
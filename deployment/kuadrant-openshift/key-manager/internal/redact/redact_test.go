@@ -0,0 +1,159 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// A generated key in the current format: prefix, 43 base62 characters
+	// and a checksum
+	generatedKey = "maas_7Hq2LmXw9RtBv3KpZs6NcYd1FgJe8UaWo4QiTkMrVnE_2bXk9P"
+	// A generated key in the legacy format: 48 base64url characters
+	legacyKey = "Zk3-pQ7rVb_Lx2NwHs9TmYc4JdGa6UeKo1RiF8zBq5XtWnMh"
+	// The admin key and team tokens are 64 hex characters
+	adminKey  = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	teamToken = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		leaked string
+	}{
+		{name: "generated key", input: "key " + generatedKey + " is invalid", leaked: generatedKey},
+		{name: "legacy key", input: "key " + legacyKey + " is invalid", leaked: legacyKey},
+		{name: "admin key", input: "admin key " + adminKey + " rejected", leaked: adminKey},
+		{name: "bearer token", input: "Authorization: Bearer short.jwt-token", leaked: "short.jwt-token"},
+		{name: "bearer team token", input: "Authorization: Bearer " + teamToken, leaked: teamToken},
+		{name: "ADMIN scheme", input: "Authorization: ADMIN s3cret", leaked: "s3cret"},
+		{name: "APIKEY scheme", input: "Authorization: APIKEY abc123", leaked: "abc123"},
+		{name: "Basic scheme", input: "Authorization: Basic dXNlcjpwYXNz", leaked: "dXNlcjpwYXNz"},
+		{name: "JSON field", input: `{"api_key":"short-key","team":"a"}`, leaked: "short-key"},
+		{name: "JSON admin key field", input: `{"admin_key": "hunter2"}`, leaked: "hunter2"},
+		{name: "query string", input: "GET /keys?token=abc&team=a", leaked: "abc"},
+		{name: "Go map printing", input: "StringData:map[api_key:short-key]", leaked: "short-key"},
+		{name: "struct field", input: "{APIKey:short-key Team:a}", leaked: "short-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := String(tt.input)
+			if strings.Contains(got, tt.leaked) {
+				t.Errorf("String(%q) = %q, still contains %q", tt.input, got, tt.leaked)
+			}
+			if !strings.Contains(got, Mask) {
+				t.Errorf("String(%q) = %q, want %s", tt.input, got, Mask)
+			}
+		})
+	}
+}
+
+func TestStringKeepsNames(t *testing.T) {
+	tests := []string{
+		"",
+		"team not found",
+		"secrets \"apikey-alice-team-a-0123456789abcdef\" not found",
+		"tokenratelimitpolicies.kuadrant.io \"gateway-token-rate-limits\" is forbidden",
+		"deployment " + strings.Repeat("a-very-long-lowercase-name-", 3) + " failed",
+		"Bearer",
+	}
+
+	for _, input := range tests {
+		if got := String(input); got != input {
+			t.Errorf("String(%q) = %q, want it unchanged", input, got)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	notFound := errors.New("not found")
+	err := fmt.Errorf("failed to create secret with api_key=%s: %w", generatedKey, notFound)
+
+	redacted := Error(err)
+	if strings.Contains(redacted.Error(), generatedKey) {
+		t.Errorf("Error() = %q, still contains the key", redacted)
+	}
+	if !errors.Is(redacted, notFound) {
+		t.Errorf("Error() lost the wrapped error")
+	}
+
+	clean := errors.New("team not found")
+	if got := Error(clean); got != clean {
+		t.Errorf("Error() = %v, want the same error when nothing is redacted", got)
+	}
+	if Error(nil) != nil {
+		t.Errorf("Error(nil) is not nil")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+
+	line := "Created key " + generatedKey + " with admin key " + adminKey + "\n"
+	n, err := w.Write([]byte(line))
+	if err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if n != len(line) {
+		t.Errorf("Write() = %d, want %d", n, len(line))
+	}
+	if strings.Contains(out.String(), generatedKey) || strings.Contains(out.String(), adminKey) {
+		t.Errorf("Write() wrote %q", out.String())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/error", func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to store key " + generatedKey + " for Bearer " + teamToken,
+		})
+	})
+	r.GET("/plain-error", func(c *gin.Context) {
+		c.String(http.StatusUnauthorized, "invalid admin key "+adminKey)
+	})
+	r.POST("/keys", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"api_key": generatedKey})
+	})
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		status    int
+		secrets   []string
+		wantShown bool
+	}{
+		{name: "JSON error", method: http.MethodGet, path: "/error", status: http.StatusInternalServerError, secrets: []string{generatedKey, teamToken}},
+		{name: "plain error", method: http.MethodGet, path: "/plain-error", status: http.StatusUnauthorized, secrets: []string{adminKey}},
+		{name: "created key", method: http.MethodPost, path: "/keys", status: http.StatusCreated, secrets: []string{generatedKey}, wantShown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			for _, secret := range tt.secrets {
+				if shown := strings.Contains(w.Body.String(), secret); shown != tt.wantShown {
+					t.Errorf("body = %q, shows %q: %v, want %v", w.Body.String(), secret, shown, tt.wantShown)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// CreateAdminToken mints a token that grants team-admin access to one team.
+// Only its hash is stored; the plaintext is returned once.
+func (m *Manager) CreateAdminToken(teamID string, req *CreateAdminTokenRequest) (*CreateAdminTokenResponse, error) {
+	if !m.Exists(teamID) {
+		return nil, fmt.Errorf("team not found")
+	}
+
+	token, err := fips.RandomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	tokenHash := hashAdminToken(token)
+	tokenID := tokenHash[:12]
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("team-%s-admin-token-%s", teamID, tokenID),
+			Namespace: m.keyNamespace,
+			Labels: map[string]string{
+				"maas/resource-type": "team-admin-token",
+				"maas/team-id":       teamID,
+				"maas/token-id":      tokenID,
+				"maas/token-sha256":  tokenHash[:32],
+			},
+			Annotations: map[string]string{
+				"maas/description": req.Description,
+				"maas/created-at":  time.Now().Format(time.RFC3339),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"token_hash": tokenHash,
+		},
+	}
+
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Create(
+		context.Background(), secret, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin token: %w", err)
+	}
+
+	log.Printf("Team admin token %s issued for team %s", tokenID, teamID)
+	return &CreateAdminTokenResponse{
+		Token: token,
+		AdminToken: AdminToken{
+			TokenID:     tokenID,
+			TeamID:      teamID,
+			Description: req.Description,
+			CreatedAt:   secret.Annotations["maas/created-at"],
+		},
+	}, nil
+}
+
+// ListAdminTokens lists the team-admin tokens issued for a team
+func (m *Manager) ListAdminTokens(teamID string) ([]AdminToken, error) {
+	if !m.Exists(teamID) {
+		return nil, fmt.Errorf("team not found")
+	}
+
+	labelSelector := fmt.Sprintf("maas/resource-type=team-admin-token,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list admin tokens: %w", err)
+	}
+
+	tokens := make([]AdminToken, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		tokens = append(tokens, AdminToken{
+			TokenID:     secret.Labels["maas/token-id"],
+			TeamID:      teamID,
+			Description: secret.Annotations["maas/description"],
+			CreatedAt:   secret.Annotations["maas/created-at"],
+		})
+	}
+
+	return tokens, nil
+}
+
+// RevokeAdminToken deletes a team-admin token
+func (m *Manager) RevokeAdminToken(teamID, tokenID string) error {
+	labelSelector := fmt.Sprintf("maas/resource-type=team-admin-token,maas/team-id=%s,maas/token-id=%s", teamID, tokenID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return fmt.Errorf("failed to find admin token: %w", err)
+	}
+	if len(secrets.Items) == 0 {
+		return fmt.Errorf("admin token not found")
+	}
+
+	err = m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(
+		context.Background(), secrets.Items[0].Name, metav1.DeleteOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to revoke admin token: %w", err)
+	}
+
+	log.Printf("Team admin token %s revoked for team %s", tokenID, teamID)
+	return nil
+}
+
+// ResolveTeamAdminToken returns the team a team-admin token is scoped to
+func (m *Manager) ResolveTeamAdminToken(token string) (string, error) {
+	tokenHash := hashAdminToken(token)
+
+	labelSelector := fmt.Sprintf("maas/resource-type=team-admin-token,maas/token-sha256=%s", tokenHash[:32])
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve admin token: %w", err)
+	}
+
+	for _, secret := range secrets.Items {
+		if string(secret.Data["token_hash"]) == tokenHash {
+			return secret.Labels["maas/team-id"], nil
+		}
+	}
+
+	return "", fmt.Errorf("admin token not found")
+}
+
+// hashAdminToken returns the hex encoded SHA256 hash of a team-admin token
+func hashAdminToken(token string) string {
+	return fips.SHA256Hex([]byte(token))
+}
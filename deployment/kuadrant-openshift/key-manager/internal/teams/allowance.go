@@ -0,0 +1,88 @@
+package teams
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
+)
+
+// MonthlyAllowanceWindow is the shortest rate window treated as a tier's
+// monthly token allowance. Limitador counts it over a rolling 30 days, which
+// approximates a calendar month.
+const MonthlyAllowanceWindow = 720 * time.Hour
+
+// tierAllowance returns the tier's monthly token allowance: its limited rate
+// with the longest window of at least MonthlyAllowanceWindow
+func (m *Manager) tierAllowance(policy string) (RateLimit, bool) {
+	if m.policyMgr == nil {
+		return RateLimit{}, false
+	}
+	rates, err := m.policyMgr.GetPolicyRates(policy)
+	if err != nil {
+		return RateLimit{}, false
+	}
+
+	var allowance RateLimit
+	var longest time.Duration
+	for _, rate := range rates {
+		window, err := time.ParseDuration(rate.Window)
+		if err != nil || rate.Limit <= 0 || window < MonthlyAllowanceWindow || window <= longest {
+			continue
+		}
+		allowance, longest = rate, window
+	}
+	return allowance, longest > 0
+}
+
+// GetTokenAllowance reports the team's monthly token allowance against the
+// tokens it used this billing period, and when it will run out at the
+// current pace. It is nil when the team's tier has no monthly allowance.
+func (m *Manager) GetTokenAllowance(teamID string) (*types.TokenAllowance, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+	rate, ok := m.tierAllowance(teamSecret.Annotations["maas/policy"])
+	if !ok {
+		return nil, nil
+	}
+
+	consumed, _ := strconv.ParseInt(teamSecret.Annotations[annotationPeriodTokens], 10, 64)
+	allowance := &types.TokenAllowance{
+		TokenLimit:  int64(rate.Limit),
+		TimeWindow:  rate.Window,
+		Consumed:    consumed,
+		Remaining:   int64(rate.Limit) - consumed,
+		PeriodStart: teamSecret.Annotations[annotationPeriodStart],
+	}
+	if allowance.Remaining <= 0 {
+		allowance.Remaining = 0
+		allowance.Exhausted = true
+		return allowance, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, allowance.PeriodStart)
+	if err != nil {
+		return allowance, nil
+	}
+	allowance.ProjectedExhaustion = projectExhaustion(start, time.Now(), consumed, allowance.Remaining)
+	return allowance, nil
+}
+
+// projectExhaustion extrapolates the pace of use since start to when the
+// remaining tokens run out. It is empty while nothing has been used or the
+// pace would not exhaust them before the billing period ends.
+func projectExhaustion(start, now time.Time, consumed, remaining int64) string {
+	elapsed := now.Sub(start)
+	if consumed <= 0 || elapsed <= 0 {
+		return ""
+	}
+
+	perSecond := float64(consumed) / elapsed.Seconds()
+	exhaustion := now.Add(time.Duration(float64(remaining)/perSecond) * time.Second)
+	if exhaustion.After(start.AddDate(0, 1, 0)) {
+		return ""
+	}
+	return exhaustion.Format(time.RFC3339)
+}
@@ -0,0 +1,206 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Outcomes of applying a team manifest entry
+const (
+	ApplyActionCreated   = "created"
+	ApplyActionUpdated   = "updated"
+	ApplyActionUnchanged = "unchanged"
+	ApplyActionPruned    = "pruned"
+	ApplyActionFailed    = "failed"
+)
+
+// ApplyTeams reconciles the cluster to a team manifest. Missing teams are
+// created, existing ones are updated through the regular team update, and
+// with prune set teams absent from the manifest are archived. Archived teams
+// listed in the manifest are unarchived. The default team is never pruned.
+func (m *Manager) ApplyTeams(req *ApplyTeamsRequest) (*ApplyTeamsResponse, error) {
+	// Reject the whole manifest up front rather than applying half of it
+	listed := make(map[string]bool)
+	for _, spec := range req.Teams {
+		if !isValidTeamID(spec.TeamID) {
+			return nil, fmt.Errorf("invalid team_id %q in manifest", spec.TeamID)
+		}
+		if listed[spec.TeamID] {
+			return nil, fmt.Errorf("team %s is listed more than once in manifest", spec.TeamID)
+		}
+		if spec.TokenLimit < 0 || floatValue(spec.BudgetUSDMonthly) < 0 {
+			return nil, fmt.Errorf("invalid manifest entry for team %s: limits must not be negative", spec.TeamID)
+		}
+		listed[spec.TeamID] = true
+	}
+
+	response := &ApplyTeamsResponse{
+		Prune: req.Prune,
+		Teams: make([]ApplyTeamResult, 0, len(req.Teams)),
+	}
+
+	for i := range req.Teams {
+		spec := &req.Teams[i]
+		result := ApplyTeamResult{TeamID: spec.TeamID, ChangedFields: make([]string, 0)}
+
+		var err error
+		if m.Exists(spec.TeamID) {
+			result.ChangedFields, err = m.applyTeamSpec(spec)
+			if len(result.ChangedFields) > 0 {
+				result.Action = ApplyActionUpdated
+			} else {
+				result.Action = ApplyActionUnchanged
+			}
+		} else {
+			err = m.createFromSpec(spec)
+			result.Action = ApplyActionCreated
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to apply team %s: %v", spec.TeamID, err)
+			result.Action = ApplyActionFailed
+			result.Error = err.Error()
+		}
+		response.add(result)
+	}
+
+	if req.Prune {
+		secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+			context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list team secrets: %w", err)
+		}
+
+		unlisted := make([]string, 0)
+		for _, secret := range secrets.Items {
+			teamID := secret.Labels["maas/team-id"]
+			if teamID == "" || listed[teamID] || teamID == DefaultTeamID ||
+				secret.Annotations[annotationTeamStatus] == teamStatusArchived {
+				continue
+			}
+			unlisted = append(unlisted, teamID)
+		}
+		sort.Strings(unlisted)
+
+		for _, teamID := range unlisted {
+			result := ApplyTeamResult{TeamID: teamID, Action: ApplyActionPruned, ChangedFields: []string{"status"}}
+			if err := m.Archive(teamID); err != nil {
+				log.Printf("Warning: Failed to prune team %s: %v", teamID, err)
+				result.Action = ApplyActionFailed
+				result.Error = err.Error()
+			}
+			response.add(result)
+		}
+	}
+
+	log.Printf("Applied team manifest: %d created, %d updated, %d unchanged, %d pruned, %d failed",
+		response.Created, response.Updated, response.Unchanged, response.Pruned, response.Failed)
+	return response, nil
+}
+
+// createFromSpec creates a team listed in a manifest
+func (m *Manager) createFromSpec(spec *TeamSpec) error {
+	policy := spec.Tier
+	if policy == "" {
+		policy = "unlimited-policy"
+	}
+
+	err := m.Create(&CreateTeamRequest{
+		TeamID:           spec.TeamID,
+		TeamName:         spec.TeamName,
+		Description:      spec.Description,
+		Policy:           policy,
+		TokenLimit:       spec.TokenLimit,
+		TimeWindow:       spec.TimeWindow,
+		BudgetUSDMonthly: floatValue(spec.BudgetUSDMonthly),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(spec.ModelsAllowed) > 0 {
+		if _, err := m.SetTeamModels(spec.TeamID, spec.ModelsAllowed); err != nil {
+			return fmt.Errorf("failed to set model allowlist: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyTeamSpec brings an existing team in line with its manifest entry and
+// returns the fields that changed. Fields left out of the entry are kept.
+func (m *Manager) applyTeamSpec(spec *TeamSpec) ([]string, error) {
+	changed := make([]string, 0)
+
+	if m.IsArchived(spec.TeamID) {
+		if err := m.Unarchive(spec.TeamID); err != nil {
+			return changed, err
+		}
+		changed = append(changed, "status")
+	}
+
+	update := &UpdateTeamRequest{BudgetUSDMonthly: spec.BudgetUSDMonthly}
+	if spec.TeamName != "" {
+		update.TeamName = &spec.TeamName
+	}
+	if spec.Description != "" {
+		update.Description = &spec.Description
+	}
+	if spec.Tier != "" {
+		update.Policy = &spec.Tier
+	}
+	if spec.TokenLimit > 0 {
+		update.TokenLimit = &spec.TokenLimit
+	}
+	if spec.TimeWindow != "" {
+		update.TimeWindow = &spec.TimeWindow
+	}
+
+	result, err := m.Update(spec.TeamID, update)
+	if err != nil {
+		return changed, err
+	}
+	changed = append(changed, result.ChangedFields...)
+
+	if spec.ModelsAllowed != nil {
+		current, err := m.GetTeamModels(spec.TeamID)
+		if err != nil {
+			return changed, err
+		}
+		if strings.Join(current, ",") != strings.Join(spec.ModelsAllowed, ",") {
+			if _, err := m.SetTeamModels(spec.TeamID, spec.ModelsAllowed); err != nil {
+				return changed, fmt.Errorf("failed to set model allowlist: %w", err)
+			}
+			changed = append(changed, "models_allowed")
+		}
+	}
+
+	return changed, nil
+}
+
+// add records a team result and counts it under its action
+func (r *ApplyTeamsResponse) add(result ApplyTeamResult) {
+	switch result.Action {
+	case ApplyActionCreated:
+		r.Created++
+	case ApplyActionUpdated:
+		r.Updated++
+	case ApplyActionUnchanged:
+		r.Unchanged++
+	case ApplyActionPruned:
+		r.Pruned++
+	case ApplyActionFailed:
+		r.Failed++
+	}
+	r.Teams = append(r.Teams, result)
+}
+
+func floatValue(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,126 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ArchivedPolicy is the zero-limit policy archived teams are moved to
+const ArchivedPolicy = "archived-policy"
+
+// Team config annotations used for archival
+const (
+	annotationTeamStatus       = "maas/status"
+	annotationArchivedAt       = "maas/archived-at"
+	annotationPreArchivePolicy = "maas/pre-archive-policy"
+	teamStatusArchived         = "archived"
+)
+
+// IsArchived reports whether a team has been archived
+func (m *Manager) IsArchived(teamID string) bool {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return false
+	}
+	return teamSecret.Annotations[annotationTeamStatus] == teamStatusArchived
+}
+
+// Archive freezes a team without deleting it: all keys are suspended and the
+// team is moved to a zero-limit policy. Keys and history are kept.
+func (m *Manager) Archive(teamID string) error {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return err
+	}
+	if teamSecret.Annotations[annotationTeamStatus] == teamStatusArchived {
+		return fmt.Errorf("team %s is already archived", teamID)
+	}
+
+	previousPolicy := teamSecret.Annotations["maas/policy"]
+
+	if err := m.setTeamKeysSuspended(teamID, suspendReasonArchived, true); err != nil {
+		return err
+	}
+
+	if m.policyMgr != nil {
+		if err := m.ensureBlockingPolicy(ArchivedPolicy); err != nil {
+			return fmt.Errorf("failed to create archived policy: %w", err)
+		}
+		if _, err := m.ChangeTier(teamID, &ChangeTierRequest{Tier: ArchivedPolicy, Propagate: true}); err != nil {
+			return fmt.Errorf("failed to apply archived policy: %w", err)
+		}
+	}
+
+	// ChangeTier rewrote the secret, so pick up its latest version
+	if teamSecret, err = m.getTeamSecret(teamID); err != nil {
+		return err
+	}
+	teamSecret.Annotations[annotationTeamStatus] = teamStatusArchived
+	teamSecret.Annotations[annotationArchivedAt] = time.Now().Format(time.RFC3339)
+	teamSecret.Annotations[annotationPreArchivePolicy] = previousPolicy
+
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+		context.Background(), teamSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to archive team: %w", err)
+	}
+
+	m.syncTeamCRByID(teamID)
+
+	log.Printf("Team %s archived", teamID)
+	return nil
+}
+
+// Unarchive restores an archived team's policy and reactivates the keys that
+// were suspended by archival
+func (m *Manager) Unarchive(teamID string) error {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return err
+	}
+	if teamSecret.Annotations[annotationTeamStatus] != teamStatusArchived {
+		return fmt.Errorf("team %s is not archived", teamID)
+	}
+
+	previousPolicy := teamSecret.Annotations[annotationPreArchivePolicy]
+	if m.policyMgr != nil && previousPolicy != "" {
+		if _, err := m.ChangeTier(teamID, &ChangeTierRequest{Tier: previousPolicy, Propagate: true}); err != nil {
+			return fmt.Errorf("failed to restore policy %s: %w", previousPolicy, err)
+		}
+	}
+
+	if err := m.setTeamKeysSuspended(teamID, suspendReasonArchived, false); err != nil {
+		return err
+	}
+
+	if teamSecret, err = m.getTeamSecret(teamID); err != nil {
+		return err
+	}
+	delete(teamSecret.Annotations, annotationTeamStatus)
+	delete(teamSecret.Annotations, annotationArchivedAt)
+	delete(teamSecret.Annotations, annotationPreArchivePolicy)
+
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+		context.Background(), teamSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to unarchive team: %w", err)
+	}
+
+	m.syncTeamCRByID(teamID)
+
+	log.Printf("Team %s unarchived", teamID)
+	return nil
+}
+
+// ensureBlockingPolicy adds a zero-limit policy entry, such as the archived
+// or over-budget policy, unless it already exists
+func (m *Manager) ensureBlockingPolicy(policy string) error {
+	if m.policyMgr == nil || m.policyMgr.PolicyExists(policy) {
+		return nil
+	}
+	return m.policyMgr.AddBlockingLimitToTokenRateLimit(policy)
+}
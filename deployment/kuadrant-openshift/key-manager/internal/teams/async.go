@@ -0,0 +1,145 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+)
+
+// Provisioning states of a team created asynchronously. Teams without a
+// provisioning status were created synchronously and are ready.
+const (
+	ProvisioningPending = "pending"
+	ProvisioningReady   = "ready"
+	ProvisioningFailed  = "failed"
+)
+
+// Team config annotations tracking asynchronous provisioning
+const (
+	annotationProvisioningStatus    = "maas/provisioning-status"
+	annotationProvisioningError     = "maas/provisioning-error"
+	annotationProvisioningUpdatedAt = "maas/provisioning-updated-at"
+)
+
+// provisioningQueueSize bounds the teams waiting for their policies
+const provisioningQueueSize = 100
+
+// provisioningJob is the policy work left for a team created asynchronously
+type provisioningJob struct {
+	req          *CreateTeamRequest
+	sharedPolicy bool
+}
+
+// CreateAsync validates a team and stores its config as pending, leaving the
+// policy updates to the provisioning worker. Progress is reported by
+// GetProvisioningStatus.
+func (m *Manager) CreateAsync(req *CreateTeamRequest) error {
+	plan, sharedPolicy, err := m.prepareTeam(req, ProvisioningPending)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case m.provisioning <- &provisioningJob{req: req, sharedPolicy: sharedPolicy}:
+	default:
+		return m.rollback(plan, "Queue", fmt.Errorf("provisioning queue is full"))
+	}
+
+	log.Printf("Team %s queued for provisioning with policy reference: %s", req.TeamID, req.Policy)
+	return nil
+}
+
+// StartProvisioningWorker applies the policies of asynchronously created
+// teams in the background, one team at a time
+func (m *Manager) StartProvisioningWorker() {
+	go func() {
+		for job := range m.provisioning {
+			m.provisionTeam(job)
+		}
+	}()
+}
+
+// provisionTeam applies a pending team's policies. On failure the policy
+// entries it added are rolled back and the team is kept as failed so the
+// error can be read; syncing its policies later makes it ready.
+func (m *Manager) provisionTeam(job *provisioningJob) {
+	req := job.req
+	plan := &provisioningPlan{teamID: req.TeamID}
+
+	if step, err := m.applyTeamPolicies(plan, req, job.sharedPolicy); err != nil {
+		err = m.rollback(plan, step, err)
+		m.setProvisioningStatus(req.TeamID, ProvisioningFailed, err.Error())
+		m.events.Team(req.TeamID, corev1.EventTypeWarning, events.ReasonProvisioningFailed,
+			"Provisioning team %s failed: %v", req.TeamID, err)
+		log.Printf("Warning: Provisioning team %s failed: %v", req.TeamID, err)
+	} else {
+		m.setProvisioningStatus(req.TeamID, ProvisioningReady, "")
+		m.events.Team(req.TeamID, corev1.EventTypeNormal, events.ReasonTeamCreated,
+			"Team %s created with policy %s", req.TeamID, req.Policy)
+		log.Printf("Team %s provisioned with policy reference: %s", req.TeamID, req.Policy)
+	}
+
+	// The team exists either way, so it gets its owner and custom resource
+	m.registerOwner(req)
+	m.syncTeamCRByID(req.TeamID)
+}
+
+// GetProvisioningStatus reports how far asynchronous creation of a team got
+func (m *Manager) GetProvisioningStatus(teamID string) (*ProvisioningStatusResponse, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := teamSecret.Annotations[annotationProvisioningStatus]
+	if status == "" {
+		status = ProvisioningReady
+	}
+	return &ProvisioningStatusResponse{
+		TeamID:    teamID,
+		Status:    status,
+		Error:     teamSecret.Annotations[annotationProvisioningError],
+		UpdatedAt: teamSecret.Annotations[annotationProvisioningUpdatedAt],
+	}, nil
+}
+
+// IsProvisioning reports whether a team's policies are still being applied
+func (m *Manager) IsProvisioning(teamID string) bool {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return false
+	}
+	return teamSecret.Annotations[annotationProvisioningStatus] == ProvisioningPending
+}
+
+// setProvisioningStatus records a team's provisioning state on its config
+func (m *Manager) setProvisioningStatus(teamID, status, message string) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		teamSecret, err := m.getTeamSecret(teamID)
+		if err != nil {
+			return err
+		}
+
+		teamSecret.Annotations[annotationProvisioningStatus] = status
+		teamSecret.Annotations[annotationProvisioningUpdatedAt] = time.Now().Format(time.RFC3339)
+		if message == "" {
+			delete(teamSecret.Annotations, annotationProvisioningError)
+		} else {
+			teamSecret.Annotations[annotationProvisioningError] = message
+		}
+
+		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), teamSecret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to record provisioning status %s for team %s: %v", status, teamID, err)
+	}
+}
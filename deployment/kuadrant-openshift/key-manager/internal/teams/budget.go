@@ -0,0 +1,401 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
+)
+
+// Budget enforcement modes applied once a team exceeds its monthly budget
+const (
+	BudgetModeSuspend   = "suspend"
+	BudgetModeDowngrade = "downgrade"
+)
+
+// Team config annotations used for budget tracking
+const (
+	annotationBudget          = "maas/budget-usd-monthly"
+	annotationSpend           = "maas/spend-current"
+	annotationSpendTokens     = "maas/spend-last-tokens"
+	annotationPeriodStart     = "maas/billing-period-start"
+	annotationPeriodTokens    = "maas/period-tokens"
+	annotationBudgetEnforced  = "maas/budget-enforced"
+	annotationPreBudgetPolicy = "maas/pre-budget-policy"
+	annotationSuspendedReason = "maas/suspended-reason"
+	annotationCostLedger      = "maas/cost-ledger"
+)
+
+// MeteredTeam identifies a team whose token usage is accrued each billing
+// period, because it has a monthly budget or its tier a monthly allowance
+type MeteredTeam struct {
+	TeamID      string
+	Policy      string
+	PeriodStart string
+}
+
+// BudgetStatusFromAnnotations builds a budget status from team config
+// annotations, returning nil when the team has no budget
+func BudgetStatusFromAnnotations(annotations map[string]string) *types.BudgetStatus {
+	budget, err := strconv.ParseFloat(annotations[annotationBudget], 64)
+	if err != nil || budget <= 0 {
+		return nil
+	}
+
+	spend, _ := strconv.ParseFloat(annotations[annotationSpend], 64)
+	return &types.BudgetStatus{
+		BudgetUSDMonthly: budget,
+		SpendUSD:         spend,
+		PercentConsumed:  spend / budget * 100,
+		PeriodStart:      annotations[annotationPeriodStart],
+		Exceeded:         spend >= budget,
+		Enforcement:      annotations[annotationBudgetEnforced],
+	}
+}
+
+// ListMeteredTeams returns all teams with a monthly budget configured or on
+// a tier with a monthly token allowance
+func (m *Manager) ListMeteredTeams() ([]MeteredTeam, error) {
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+
+	// Tiers are shared, so each is only read once
+	allowances := make(map[string]bool)
+	teams := make([]MeteredTeam, 0)
+	for _, secret := range secrets.Items {
+		policy := secret.Annotations["maas/policy"]
+		metered := BudgetStatusFromAnnotations(secret.Annotations) != nil
+		if !metered && policy != "" {
+			hasAllowance, ok := allowances[policy]
+			if !ok {
+				_, hasAllowance = m.tierAllowance(policy)
+				allowances[policy] = hasAllowance
+			}
+			metered = hasAllowance
+		}
+		if metered {
+			teams = append(teams, MeteredTeam{
+				TeamID:      secret.Labels["maas/team-id"],
+				Policy:      policy,
+				PeriodStart: secret.Annotations[annotationPeriodStart],
+			})
+		}
+	}
+
+	return teams, nil
+}
+
+// GetBudgetStatus returns the team's budget status, or nil without a budget
+func (m *Manager) GetBudgetStatus(teamID string) (*types.BudgetStatus, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	return BudgetStatusFromAnnotations(teamSecret.Annotations), nil
+}
+
+// RecordTokenUsage accrues the tokens used this billing period from the
+// team's cumulative token counter. Only the growth since the last observation
+// is counted; a counter that went backwards is treated as reset. Spend is
+// accrued separately by RecordCost. The update is retried on conflict against
+// the latest secret, so writes from other replicas are not overwritten. The
+// returned status is nil when the team has no budget.
+func (m *Manager) RecordTokenUsage(teamID string, totalTokens int64) (*types.BudgetStatus, error) {
+	var status *types.BudgetStatus
+	var alert *webhook.Event
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		teamSecret, err := m.getTeamSecret(teamID)
+		if err != nil {
+			return err
+		}
+
+		lastTokens, err := strconv.ParseInt(teamSecret.Annotations[annotationSpendTokens], 10, 64)
+		if err != nil {
+			// First observation only sets the baseline
+			lastTokens = totalTokens
+		}
+
+		delta := totalTokens - lastTokens
+		if delta < 0 {
+			delta = totalTokens
+		}
+
+		periodTokens, _ := strconv.ParseInt(teamSecret.Annotations[annotationPeriodTokens], 10, 64)
+
+		teamSecret.Annotations[annotationSpendTokens] = strconv.FormatInt(totalTokens, 10)
+		teamSecret.Annotations[annotationPeriodTokens] = strconv.FormatInt(periodTokens+delta, 10)
+		if teamSecret.Annotations[annotationPeriodStart] == "" {
+			teamSecret.Annotations[annotationPeriodStart] = time.Now().Format(time.RFC3339)
+		}
+
+		// Alert once per period as each threshold is crossed
+		status = BudgetStatusFromAnnotations(teamSecret.Annotations)
+		var threshold string
+		alert, threshold = budgetAlert(teamID, teamSecret.Annotations[annotationBudgetAlerted], status)
+		if alert != nil {
+			teamSecret.Annotations[annotationBudgetAlerted] = threshold
+		}
+
+		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), teamSecret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record team token usage: %w", err)
+	}
+
+	if alert != nil {
+		m.notifyTeam(teamID, *alert)
+	}
+
+	return status, nil
+}
+
+// CostLedger returns the cost ledger last stored for a team, empty when its
+// usage was never priced
+func (m *Manager) CostLedger(teamID string) (string, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return "", err
+	}
+	return teamSecret.Annotations[annotationCostLedger], nil
+}
+
+// RecordCost stores the team's cost ledger as rewritten by update from the
+// current one, and adds the USD cost update returns to the team's spend. Both
+// are written in one update that is retried on conflict, so charges from
+// concurrent replicas are neither lost nor doubled; update may therefore run
+// more than once. The returned status is nil when the team has no budget.
+func (m *Manager) RecordCost(teamID string, update func(ledger string) (string, float64, error)) (*types.BudgetStatus, error) {
+	var status *types.BudgetStatus
+	var alert *webhook.Event
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		teamSecret, err := m.getTeamSecret(teamID)
+		if err != nil {
+			return err
+		}
+
+		ledger, usd, err := update(teamSecret.Annotations[annotationCostLedger])
+		if err != nil {
+			return err
+		}
+		spend, _ := strconv.ParseFloat(teamSecret.Annotations[annotationSpend], 64)
+
+		teamSecret.Annotations[annotationCostLedger] = ledger
+		teamSecret.Annotations[annotationSpend] = strconv.FormatFloat(spend+usd, 'f', -1, 64)
+		if teamSecret.Annotations[annotationPeriodStart] == "" {
+			teamSecret.Annotations[annotationPeriodStart] = time.Now().Format(time.RFC3339)
+		}
+
+		// Alert once per period as each threshold is crossed
+		status = BudgetStatusFromAnnotations(teamSecret.Annotations)
+		var threshold string
+		alert, threshold = budgetAlert(teamID, teamSecret.Annotations[annotationBudgetAlerted], status)
+		if alert != nil {
+			teamSecret.Annotations[annotationBudgetAlerted] = threshold
+		}
+
+		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), teamSecret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record team cost: %w", err)
+	}
+
+	if alert != nil {
+		m.notifyTeam(teamID, *alert)
+	}
+
+	return status, nil
+}
+
+// EnforceBudget restricts an over-budget team, either by suspending all of
+// its keys or by moving it to the over-budget policy. It is a no-op when the
+// team is already restricted.
+func (m *Manager) EnforceBudget(teamID, mode, overBudgetPolicy string) error {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return err
+	}
+	if teamSecret.Annotations[annotationBudgetEnforced] != "" {
+		return nil
+	}
+
+	switch mode {
+	case BudgetModeSuspend:
+		if err := m.setTeamKeysSuspended(teamID, suspendReasonBudget, true); err != nil {
+			return err
+		}
+	case BudgetModeDowngrade:
+		if err := m.ensureBlockingPolicy(overBudgetPolicy); err != nil {
+			return fmt.Errorf("failed to create over-budget policy: %w", err)
+		}
+		teamSecret.Annotations[annotationPreBudgetPolicy] = teamSecret.Annotations["maas/policy"]
+		if _, err := m.ChangeTier(teamID, &ChangeTierRequest{Tier: overBudgetPolicy, Propagate: true}); err != nil {
+			return fmt.Errorf("failed to apply over-budget policy: %w", err)
+		}
+		// ChangeTier rewrote the secret, so pick up its latest version
+		latest, err := m.getTeamSecret(teamID)
+		if err != nil {
+			return err
+		}
+		latest.Annotations[annotationPreBudgetPolicy] = teamSecret.Annotations[annotationPreBudgetPolicy]
+		teamSecret = latest
+	default:
+		return fmt.Errorf("unknown budget enforcement mode %s", mode)
+	}
+
+	teamSecret.Annotations[annotationBudgetEnforced] = mode
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+		context.Background(), teamSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to mark team over budget: %w", err)
+	}
+
+	log.Printf("Team %s exceeded its monthly budget, enforcement mode: %s", teamID, mode)
+	return nil
+}
+
+// ResetBudgetPeriod starts a new billing period: spend is zeroed and any
+// budget enforcement on the team is lifted. The reset itself is retried on
+// conflict against the latest secret.
+func (m *Manager) ResetBudgetPeriod(teamID string) (*types.BudgetStatus, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch teamSecret.Annotations[annotationBudgetEnforced] {
+	case BudgetModeSuspend:
+		if err := m.setTeamKeysSuspended(teamID, suspendReasonBudget, false); err != nil {
+			return nil, err
+		}
+	case BudgetModeDowngrade:
+		if previous := teamSecret.Annotations[annotationPreBudgetPolicy]; previous != "" {
+			if _, err := m.ChangeTier(teamID, &ChangeTierRequest{Tier: previous, Propagate: true}); err != nil {
+				return nil, fmt.Errorf("failed to restore policy %s: %w", previous, err)
+			}
+		}
+	}
+
+	var status *types.BudgetStatus
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		teamSecret, err := m.getTeamSecret(teamID)
+		if err != nil {
+			return err
+		}
+
+		delete(teamSecret.Annotations, annotationBudgetEnforced)
+		delete(teamSecret.Annotations, annotationPreBudgetPolicy)
+		delete(teamSecret.Annotations, annotationBudgetAlerted)
+		teamSecret.Annotations[annotationSpend] = "0"
+		teamSecret.Annotations[annotationPeriodTokens] = "0"
+		teamSecret.Annotations[annotationPeriodStart] = time.Now().Format(time.RFC3339)
+
+		status = BudgetStatusFromAnnotations(teamSecret.Annotations)
+		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), teamSecret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset billing period: %w", err)
+	}
+
+	log.Printf("Billing period reset for team %s", teamID)
+	return status, nil
+}
+
+// BillingPeriodElapsed reports whether a billing period that began at
+// periodStart started more than a month ago
+func BillingPeriodElapsed(periodStart string, now time.Time) bool {
+	start, err := time.Parse(time.RFC3339, periodStart)
+	if err != nil {
+		return false
+	}
+	return !now.Before(start.AddDate(0, 1, 0))
+}
+
+// Reasons recorded on keys suspended for a whole team
+const (
+	suspendReasonBudget   = "budget"
+	suspendReasonArchived = "archived"
+)
+
+// setTeamKeysSuspended suspends or restores team keys. Suspended keys lose the
+// app label Authorino selects on, so they stop authenticating immediately.
+// Only keys suspended for the given reason are restored.
+func (m *Manager) setTeamKeysSuspended(teamID, reason string, suspend bool) error {
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.KeyNamespace(teamID)).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return fmt.Errorf("failed to list team API keys: %w", err)
+	}
+
+	suspended := make([]webhook.Event, 0)
+	for _, secret := range secrets.Items {
+		status := secret.Annotations["maas/status"]
+		if suspend {
+			if status != "" && status != "active" {
+				continue
+			}
+			secret.Annotations["maas/status"] = "suspended"
+			secret.Annotations[annotationSuspendedReason] = reason
+			delete(secret.Labels, "app")
+		} else {
+			if status != "suspended" || secret.Annotations[annotationSuspendedReason] != reason {
+				continue
+			}
+			secret.Annotations["maas/status"] = "active"
+			delete(secret.Annotations, annotationSuspendedReason)
+			secret.Labels["app"] = "llm-gateway"
+		}
+
+		_, err = m.clientset.CoreV1().Secrets(secret.Namespace).Update(
+			context.Background(), &secret, metav1.UpdateOptions{})
+		if err != nil {
+			log.Printf("Warning: Failed to update API key %s suspension: %v", secret.Name, err)
+			continue
+		}
+		if suspend {
+			event := webhook.NewEvent(webhook.EventKeySuspended, teamID, secret.Labels["maas/user-id"], secret.Name)
+			event.Details = map[string]interface{}{"reason": reason}
+			suspended = append(suspended, event)
+		}
+	}
+	m.notifyTeam(teamID, suspended...)
+
+	if m.policyMgr != nil {
+		if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+			log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// getTeamSecret fetches the team config secret
+func (m *Manager) getTeamSecret(teamID string) (*corev1.Secret, error) {
+	teamSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
+		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("team not found: %w", err)
+	}
+	if teamSecret.Annotations == nil {
+		teamSecret.Annotations = make(map[string]string)
+	}
+	return teamSecret, nil
+}
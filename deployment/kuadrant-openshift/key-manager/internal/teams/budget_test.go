@@ -0,0 +1,101 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// concurrentSecretWrite makes the first update of a secret lose a race: the
+// annotations are written to the stored secret as another replica would, and
+// the update fails with a conflict
+func concurrentSecretWrite(clientset *k8sfake.Clientset, annotations map[string]string) {
+	raced := false
+	clientset.PrependReactor("update", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if raced {
+			return false, nil, nil
+		}
+		raced = true
+
+		update := action.(k8stesting.UpdateAction)
+		name := update.GetObject().(*corev1.Secret).Name
+		stored, err := clientset.Tracker().Get(action.GetResource(), action.GetNamespace(), name)
+		if err != nil {
+			return true, nil, err
+		}
+		current := stored.(*corev1.Secret).DeepCopy()
+		for key, value := range annotations {
+			current.Annotations[key] = value
+		}
+		if err := clientset.Tracker().Update(action.GetResource(), current, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), name,
+			errors.New("the object has been modified"))
+	})
+}
+
+// teamAnnotations reads a team config secret's annotations back
+func teamAnnotations(t *testing.T, clientset *k8sfake.Clientset, teamID string) map[string]string {
+	t.Helper()
+	secret, err := clientset.CoreV1().Secrets(testNamespace).Get(context.Background(), "team-"+teamID+"-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get team config secret: %v", err)
+	}
+	return secret.Annotations
+}
+
+func TestRecordTokenUsageRetriesOnConflict(t *testing.T) {
+	secret := testTeamSecret("team-a", "free")
+	secret.Annotations[annotationSpendTokens] = "100"
+	secret.Annotations[annotationPeriodTokens] = "100"
+	clientset := k8sfake.NewSimpleClientset(secret)
+	// Another replica records a charge in the meantime
+	concurrentSecretWrite(clientset, map[string]string{annotationSpend: "2.5"})
+	m := NewManager(clientset, testNamespace, nil, nil, nil, nil, false, false, nil, nil)
+
+	if _, err := m.RecordTokenUsage("team-a", 150); err != nil {
+		t.Fatalf("RecordTokenUsage() = %v", err)
+	}
+
+	annotations := teamAnnotations(t, clientset, "team-a")
+	if got := annotations[annotationPeriodTokens]; got != "150" {
+		t.Errorf("period tokens = %s, want 150", got)
+	}
+	if got := annotations[annotationSpend]; got != "2.5" {
+		t.Errorf("spend = %s, want the concurrent charge 2.5 kept", got)
+	}
+}
+
+func TestResetBudgetPeriodRetriesOnConflict(t *testing.T) {
+	secret := testTeamSecret("team-a", "free")
+	secret.Annotations[annotationBudget] = "100"
+	secret.Annotations[annotationSpend] = "80"
+	secret.Annotations[annotationPeriodTokens] = "5000"
+	clientset := k8sfake.NewSimpleClientset(secret)
+	// An admin raises the budget in the meantime
+	concurrentSecretWrite(clientset, map[string]string{annotationBudget: "200"})
+	m := NewManager(clientset, testNamespace, nil, nil, nil, nil, false, false, nil, nil)
+
+	if _, err := m.ResetBudgetPeriod("team-a"); err != nil {
+		t.Fatalf("ResetBudgetPeriod() = %v", err)
+	}
+
+	annotations := teamAnnotations(t, clientset, "team-a")
+	if got := annotations[annotationSpend]; got != "0" {
+		t.Errorf("spend = %s, want 0", got)
+	}
+	if got := annotations[annotationPeriodTokens]; got != "0" {
+		t.Errorf("period tokens = %s, want 0", got)
+	}
+	if got := annotations[annotationBudget]; got != "200" {
+		t.Errorf("budget = %s, want the concurrent change 200 kept", got)
+	}
+}
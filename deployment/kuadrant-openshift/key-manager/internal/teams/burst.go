@@ -0,0 +1,148 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SetPolicyBurst caps short spikes of a policy with a second rate on its
+// limit, enforced independently of the sustained rate. A zero burstLimit
+// removes the cap.
+func (p *PolicyManager) SetPolicyBurst(policyName string, burstLimit int, burstWindow string) error {
+	var missing bool
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		limitConfig, ok := limits[policyName].(map[string]interface{})
+		missing = !ok
+		if missing {
+			return
+		}
+
+		rates := limitRates(limitConfig)
+		if len(rates) == 0 {
+			missing = true
+			return
+		}
+		mainWindow := stringValue(rates[0]["window"])
+
+		updated := []interface{}{rates[0]}
+		for _, rate := range rates[1:] {
+			if !isBurstRate(rate, mainWindow) {
+				updated = append(updated, rate)
+			}
+		}
+		if burstLimit > 0 {
+			updated = append(updated, map[string]interface{}{
+				"limit":  burstLimit,
+				"window": burstWindow,
+			})
+		}
+		limitConfig["rates"] = updated
+	})
+	if err != nil {
+		return err
+	}
+	if missing {
+		return fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", policyName)
+	}
+
+	log.Printf("Updated TokenRateLimitPolicy burst limit for policy %s: %d per %s", policyName, burstLimit, burstWindow)
+	return nil
+}
+
+// GetPolicyBurst returns the burst limit and window of a policy, zero and
+// empty when it has none
+func (p *PolicyManager) GetPolicyBurst(policyName string) (int, string, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+
+	limits, _, _ := unstructured.NestedMap(policyObj.Object, "spec", "limits")
+	limitConfig, ok := limits[policyName].(map[string]interface{})
+	if !ok {
+		return 0, "", fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", policyName)
+	}
+
+	rates := limitRates(limitConfig)
+	if len(rates) == 0 {
+		return 0, "", nil
+	}
+	mainWindow := stringValue(rates[0]["window"])
+	for _, rate := range rates[1:] {
+		if isBurstRate(rate, mainWindow) {
+			return int(numberValue(rate["limit"])), stringValue(rate["window"]), nil
+		}
+	}
+	return 0, "", nil
+}
+
+// validateBurst checks a burst limit against the sustained rate it sits on.
+// The burst window must be shorter than the main window, and the burst must
+// allow at least the sustained rate's share of that window, or it would
+// throttle steady traffic.
+func validateBurst(tokenLimit int, timeWindow string, burstLimit int, burstWindow string) error {
+	if burstLimit < 0 {
+		return fmt.Errorf("invalid burst_limit: must not be negative")
+	}
+	if burstLimit == 0 {
+		return nil
+	}
+	if !IsValidTimeWindow(burstWindow) {
+		return fmt.Errorf("invalid burst_window %q: must be a duration such as 10s or 1m", burstWindow)
+	}
+
+	mainDuration, err := time.ParseDuration(timeWindow)
+	if err != nil {
+		return fmt.Errorf("invalid time_window %q: %v", timeWindow, err)
+	}
+	burstDuration, err := time.ParseDuration(burstWindow)
+	if err != nil {
+		return fmt.Errorf("invalid burst_window %q: %v", burstWindow, err)
+	}
+	if burstDuration >= mainDuration {
+		return fmt.Errorf("invalid burst_window: %s must be shorter than the time window %s", burstWindow, timeWindow)
+	}
+
+	sustained := float64(tokenLimit) * float64(burstDuration) / float64(mainDuration)
+	if float64(burstLimit) < sustained {
+		return fmt.Errorf("invalid burst_limit: %d per %s is below the sustained rate of %.0f per %s",
+			burstLimit, burstWindow, sustained, burstWindow)
+	}
+	return nil
+}
+
+// isBurstRate reports whether a secondary rate is a burst cap, a rate with a
+// shorter window than the main one
+func isBurstRate(rate map[string]interface{}, mainWindow string) bool {
+	mainDuration, err := time.ParseDuration(mainWindow)
+	if err != nil {
+		return false
+	}
+	duration, err := time.ParseDuration(stringValue(rate["window"]))
+	return err == nil && duration < mainDuration
+}
+
+// limitRates returns the rates of a limit, whether it was read from the
+// cluster or built locally
+func limitRates(limitConfig map[string]interface{}) []map[string]interface{} {
+	rates := make([]map[string]interface{}, 0)
+	switch items := limitConfig["rates"].(type) {
+	case []map[string]interface{}:
+		rates = append(rates, items...)
+	case []interface{}:
+		for _, item := range items {
+			if rate, ok := item.(map[string]interface{}); ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+	return rates
+}
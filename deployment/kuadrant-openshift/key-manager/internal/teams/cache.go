@@ -0,0 +1,202 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// teamIDIndex indexes cached secrets by their maas/team-id label
+const teamIDIndex = "team-id"
+
+// SecretCache keeps API key and membership secrets of the shared key
+// namespace in memory, indexed by team, so team listings and lookups do not
+// list secrets on every request. Until it has synced, and for teams with
+// their own key namespace, callers fall back to listing directly. A nil
+// SecretCache is never synced.
+type SecretCache struct {
+	namespace string
+	keys      cache.SharedIndexInformer
+	members   cache.SharedIndexInformer
+}
+
+// NewSecretCache creates a cache over the key and team member secrets of a
+// namespace. Call Start to begin watching.
+func NewSecretCache(clientset kubernetes.Interface, namespace string) *SecretCache {
+	return &SecretCache{
+		namespace: namespace,
+		keys:      newSecretInformer(clientset, namespace, "kuadrant.io/apikeys-by=rhcl-keys"),
+		members:   newSecretInformer(clientset, namespace, "maas/resource-type=team-member"),
+	}
+}
+
+// newSecretInformer watches the secrets of a namespace matching a label
+// selector, indexed by team
+func newSecretInformer(clientset kubernetes.Interface, namespace, labelSelector string) cache.SharedIndexInformer {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = labelSelector
+		}))
+
+	informer := factory.Core().V1().Secrets().Informer()
+	err := informer.AddIndexers(cache.Indexers{
+		teamIDIndex: func(obj interface{}) ([]string, error) {
+			secret, ok := obj.(*corev1.Secret)
+			if !ok || secret.Labels["maas/team-id"] == "" {
+				return nil, nil
+			}
+			return []string{secret.Labels["maas/team-id"]}, nil
+		},
+	})
+	if err != nil {
+		// Only fails once the informer has started, which it has not
+		log.Printf("Warning: Failed to index secrets by team: %v", err)
+	}
+	return informer
+}
+
+// Start begins watching secrets in the background
+func (c *SecretCache) Start() {
+	if c == nil {
+		return
+	}
+	go c.keys.Run(make(chan struct{}))
+	go c.members.Run(make(chan struct{}))
+	log.Printf("Secret cache started for namespace %s", c.namespace)
+}
+
+// Synced reports whether the cache has completed its initial list
+func (c *SecretCache) Synced() bool {
+	return c != nil && c.keys.HasSynced() && c.members.HasSynced()
+}
+
+// Status describes the cache for health reporting
+func (c *SecretCache) Status() map[string]interface{} {
+	if c == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+
+	status := map[string]interface{}{
+		"enabled":   true,
+		"synced":    c.Synced(),
+		"namespace": c.namespace,
+	}
+	if c.Synced() {
+		status["keys"] = len(c.keys.GetStore().ListKeys())
+		status["members"] = len(c.members.GetStore().ListKeys())
+	}
+	return status
+}
+
+// teamKeys returns the cached key secrets of a team in a namespace, or false
+// when the cache cannot answer for it
+func (c *SecretCache) teamKeys(namespace, teamID string) ([]*corev1.Secret, bool) {
+	if !c.Synced() || namespace != c.namespace {
+		return nil, false
+	}
+	objs, err := c.keys.GetIndexer().ByIndex(teamIDIndex, teamID)
+	if err != nil {
+		log.Printf("Warning: Secret cache lookup for team %s failed: %v", teamID, err)
+		return nil, false
+	}
+	return toSecrets(objs), true
+}
+
+// allKeys returns every cached key secret, or false when not synced
+func (c *SecretCache) allKeys() ([]*corev1.Secret, bool) {
+	if !c.Synced() {
+		return nil, false
+	}
+	return toSecrets(c.keys.GetStore().List()), true
+}
+
+// allMembers returns every cached membership secret, or false when not synced
+func (c *SecretCache) allMembers() ([]*corev1.Secret, bool) {
+	if !c.Synced() {
+		return nil, false
+	}
+	return toSecrets(c.members.GetStore().List()), true
+}
+
+// toSecrets converts cached objects to secrets. They are shared with the
+// cache and must not be modified.
+func toSecrets(objs []interface{}) []*corev1.Secret {
+	secrets := make([]*corev1.Secret, 0, len(objs))
+	for _, obj := range objs {
+		if secret, ok := obj.(*corev1.Secret); ok {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// teamKeySecrets returns a team's key secrets, from the cache when it can
+// answer and by listing them otherwise
+func (m *Manager) teamKeySecrets(teamID string) ([]*corev1.Secret, error) {
+	namespace := m.KeyNamespace(teamID)
+	if secrets, ok := m.secretCache.teamKeys(namespace, teamID); ok {
+		return secrets, nil
+	}
+
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	return secretPointers(secrets.Items), nil
+}
+
+// listKeyAndMemberSecrets returns every key secret and membership secret.
+// Once synced the cache serves the shared key namespace, and only per-team
+// key namespaces are listed.
+func (m *Manager) listKeyAndMemberSecrets() ([]*corev1.Secret, []*corev1.Secret, error) {
+	cachedKeys, keysOK := m.secretCache.allKeys()
+	cachedMembers, membersOK := m.secretCache.allMembers()
+	if !keysOK || !membersOK {
+		keySecrets, err := m.ListKeySecrets("kuadrant.io/apikeys-by=rhcl-keys")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list API keys: %w", err)
+		}
+		memberSecrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+			context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-member"})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list team members: %w", err)
+		}
+		return secretPointers(keySecrets), secretPointers(memberSecrets.Items), nil
+	}
+
+	namespaces, err := m.KeyNamespaces()
+	if err != nil {
+		return nil, nil, err
+	}
+	keySecrets := cachedKeys
+	for _, namespace := range namespaces {
+		if namespace == m.keyNamespace {
+			continue
+		}
+		secrets, err := m.clientset.CoreV1().Secrets(namespace).List(
+			context.Background(), metav1.ListOptions{LabelSelector: "kuadrant.io/apikeys-by=rhcl-keys"})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list API keys in namespace %s: %w", namespace, err)
+		}
+		keySecrets = append(keySecrets, secretPointers(secrets.Items)...)
+	}
+	return keySecrets, cachedMembers, nil
+}
+
+// secretPointers returns pointers to the secrets of a list
+func secretPointers(items []corev1.Secret) []*corev1.Secret {
+	secrets := make([]*corev1.Secret, 0, len(items))
+	for i := range items {
+		secrets = append(secrets, &items[i])
+	}
+	return secrets
+}
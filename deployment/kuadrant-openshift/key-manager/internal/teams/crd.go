@@ -0,0 +1,226 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// maasTeamGVR identifies the MaaSTeam custom resource
+var maasTeamGVR = schema.GroupVersionResource{
+	Group:    "maas.redhat.com",
+	Version:  "v1alpha1",
+	Resource: "maasteams",
+}
+
+// CRDStore mirrors team definitions into MaaSTeam custom resources so they
+// are visible to kubectl and GitOps tooling. A nil store is disabled.
+type CRDStore struct {
+	client    dynamic.Interface
+	namespace string
+}
+
+// NewCRDStore creates a new MaaSTeam store
+func NewCRDStore(client dynamic.Interface, namespace string) *CRDStore {
+	return &CRDStore{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// Sync creates or updates the MaaSTeam for a team config secret and records
+// its status
+func (s *CRDStore) Sync(teamSecret *corev1.Secret, policiesApplied bool, keyCount int) error {
+	if s == nil {
+		return nil
+	}
+
+	teamID := teamSecret.Labels["maas/team-id"]
+	spec := teamSpecFromAnnotations(teamSecret.Annotations)
+
+	resource := s.client.Resource(maasTeamGVR).Namespace(s.namespace)
+	existing, err := resource.Get(context.Background(), teamID, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		team := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "maas.redhat.com/v1alpha1",
+			"kind":       "MaaSTeam",
+			"metadata": map[string]interface{}{
+				"name":      teamID,
+				"namespace": s.namespace,
+				"labels": map[string]interface{}{
+					"maas/team-id": teamID,
+				},
+			},
+			"spec": spec,
+		}}
+		existing, err = resource.Create(context.Background(), team, metav1.CreateOptions{})
+	} else if err == nil {
+		existing.Object["spec"] = spec
+		existing, err = resource.Update(context.Background(), existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync MaaSTeam %s: %w", teamID, err)
+	}
+
+	existing.Object["status"] = map[string]interface{}{
+		"policiesApplied": policiesApplied,
+		"keyCount":        int64(keyCount),
+		"observedAt":      time.Now().Format(time.RFC3339),
+	}
+	if _, err := resource.UpdateStatus(context.Background(), existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update MaaSTeam %s status: %w", teamID, err)
+	}
+
+	return nil
+}
+
+// Delete removes the MaaSTeam for a team, ignoring teams without one
+func (s *CRDStore) Delete(teamID string) error {
+	if s == nil {
+		return nil
+	}
+
+	err := s.client.Resource(maasTeamGVR).Namespace(s.namespace).Delete(
+		context.Background(), teamID, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete MaaSTeam %s: %w", teamID, err)
+	}
+	return nil
+}
+
+// Get returns the MaaSTeam for a team
+func (s *CRDStore) Get(teamID string) (*unstructured.Unstructured, error) {
+	if s == nil {
+		return nil, fmt.Errorf("MaaSTeam store is disabled")
+	}
+	return s.client.Resource(maasTeamGVR).Namespace(s.namespace).Get(
+		context.Background(), teamID, metav1.GetOptions{})
+}
+
+// List returns all MaaSTeams in the namespace
+func (s *CRDStore) List() ([]unstructured.Unstructured, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	list, err := s.client.Resource(maasTeamGVR).Namespace(s.namespace).List(
+		context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MaaSTeams: %w", err)
+	}
+	return list.Items, nil
+}
+
+// MigrateTeamsToCRD creates a MaaSTeam for every legacy team config secret.
+// It is safe to run repeatedly; existing resources are updated in place.
+func (m *Manager) MigrateTeamsToCRD() (int, error) {
+	if m.crdStore == nil {
+		return 0, fmt.Errorf("MaaSTeam store is disabled")
+	}
+
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+
+	migrated := 0
+	for i := range secrets.Items {
+		teamID := secrets.Items[i].Labels["maas/team-id"]
+		if err := m.syncTeamCR(&secrets.Items[i]); err != nil {
+			log.Printf("Warning: Failed to migrate team %s to MaaSTeam: %v", teamID, err)
+			continue
+		}
+		migrated++
+	}
+
+	log.Printf("Migrated %d of %d teams to MaaSTeam resources", migrated, len(secrets.Items))
+	return migrated, nil
+}
+
+// syncTeamCR mirrors a team config secret into its MaaSTeam
+func (m *Manager) syncTeamCR(teamSecret *corev1.Secret) error {
+	if m.crdStore == nil {
+		return nil
+	}
+
+	teamID := teamSecret.Labels["maas/team-id"]
+	policiesApplied := m.policyMgr != nil && m.policyMgr.PolicyExists(teamSecret.Annotations["maas/policy"])
+
+	keyCount := 0
+	if keys, err := m.getTeamAPIKeys(teamID); err == nil {
+		keyCount = len(keys)
+	}
+
+	return m.crdStore.Sync(teamSecret, policiesApplied, keyCount)
+}
+
+// syncTeamCRByID mirrors a team into its MaaSTeam, logging failures since the
+// secret remains the source of truth
+func (m *Manager) syncTeamCRByID(teamID string) {
+	if m.crdStore == nil {
+		return
+	}
+
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err == nil {
+		err = m.syncTeamCR(teamSecret)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to sync MaaSTeam for team %s: %v", teamID, err)
+	}
+}
+
+// teamSpecFromAnnotations builds a MaaSTeam spec from team config annotations
+func teamSpecFromAnnotations(annotations map[string]string) map[string]interface{} {
+	spec := map[string]interface{}{
+		"displayName": annotations["maas/team-name"],
+		"description": annotations["maas/description"],
+		"tier":        annotations["maas/policy"],
+	}
+
+	limits := map[string]interface{}{}
+	if value := annotationInt(annotations, "maas/max-keys-per-user"); value >= 0 {
+		limits["maxKeysPerUser"] = int64(value)
+	}
+	if value := annotationInt(annotations, "maas/max-keys-per-team"); value >= 0 {
+		limits["maxKeysPerTeam"] = int64(value)
+	}
+	if len(limits) > 0 {
+		spec["limits"] = limits
+	}
+
+	if budget, err := strconv.ParseFloat(annotations[annotationBudget], 64); err == nil && budget > 0 {
+		spec["budget"] = map[string]interface{}{"usdMonthly": budget}
+	}
+
+	return spec
+}
+
+// teamFromCR builds a team summary for a MaaSTeam that has no config secret
+func teamFromCR(team *unstructured.Unstructured) map[string]interface{} {
+	displayName, _, _ := unstructured.NestedString(team.Object, "spec", "displayName")
+	description, _, _ := unstructured.NestedString(team.Object, "spec", "description")
+	tier, _, _ := unstructured.NestedString(team.Object, "spec", "tier")
+
+	return map[string]interface{}{
+		"team_id":      team.GetName(),
+		"team_name":    displayName,
+		"description":  description,
+		"policy":       tier,
+		"created_at":   team.GetCreationTimestamp().Format(time.RFC3339),
+		"key_count":    0,
+		"member_count": 0,
+		"user_count":   0,
+		"status":       "unprovisioned",
+	}
+}
@@ -0,0 +1,101 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// What happens to a user's keys when they are removed from a team
+const (
+	RemovalModeDelete     = "delete"
+	RemovalModeDeactivate = "deactivate"
+)
+
+// Key status and annotation for keys deactivated on member removal
+const (
+	keyStatusInactive       = "inactive"
+	annotationDeactivatedAt = "maas/deactivated-at"
+)
+
+// IsValidRemovalMode reports whether mode is a supported member removal mode
+func IsValidRemovalMode(mode string) bool {
+	return mode == RemovalModeDelete || mode == RemovalModeDeactivate
+}
+
+// deactivateKey marks a key inactive and drops the app label Authorino
+// selects on, so it stops authenticating while its metadata is kept
+func (m *Manager) deactivateKey(key *corev1.Secret) error {
+	key.Annotations["maas/status"] = keyStatusInactive
+	key.Annotations[annotationDeactivatedAt] = time.Now().Format(time.RFC3339)
+	delete(key.Annotations, annotationSuspendedReason)
+	delete(key.Labels, "app")
+
+	_, err := m.clientset.CoreV1().Secrets(key.Namespace).Update(
+		context.Background(), key, metav1.UpdateOptions{})
+	return err
+}
+
+// PurgeInactiveKeys deletes keys that have been inactive for longer than the
+// retention period and returns how many were deleted
+func (m *Manager) PurgeInactiveKeys(retention time.Duration) (int, error) {
+	keys, err := m.ListKeySecrets("kuadrant.io/apikeys-by=rhcl-keys")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-retention)
+	purged := 0
+	for _, key := range keys {
+		if key.Annotations["maas/status"] != keyStatusInactive {
+			continue
+		}
+		deactivatedAt, err := time.Parse(time.RFC3339, key.Annotations[annotationDeactivatedAt])
+		if err != nil || deactivatedAt.After(cutoff) {
+			continue
+		}
+
+		err = m.clientset.CoreV1().Secrets(key.Namespace).Delete(
+			context.Background(), key.Name, metav1.DeleteOptions{})
+		if err != nil {
+			log.Printf("Warning: Failed to purge inactive API key %s: %v", key.Name, err)
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		log.Printf("Purged %d API keys inactive for more than %s", purged, retention)
+	}
+	return purged, nil
+}
+
+// StartInactiveKeyCleanup purges expired inactive keys periodically in the
+// background. A zero interval or retention disables the cleanup.
+func (m *Manager) StartInactiveKeyCleanup(interval, retention time.Duration) {
+	if interval <= 0 || retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := m.PurgeInactiveKeys(retention); err != nil {
+				log.Printf("Warning: Inactive key cleanup failed: %v", err)
+			}
+		}
+	}()
+}
+
+// checkRemovalMode validates a member removal mode
+func checkRemovalMode(mode string) error {
+	if !IsValidRemovalMode(mode) {
+		return fmt.Errorf("invalid mode %s, must be one of %s, %s", mode, RemovalModeDelete, RemovalModeDeactivate)
+	}
+	return nil
+}
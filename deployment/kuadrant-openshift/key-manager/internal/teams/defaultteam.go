@@ -0,0 +1,75 @@
+package teams
+
+import (
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+)
+
+// DefaultTeamID is the team for users without a team assignment
+const DefaultTeamID = "default"
+
+// EnsureDefaultTeam creates the default team on the given tier if it does not
+// exist. An existing default team is never changed here; when its tier has
+// drifted from the configured one the drift is logged and recorded as an event.
+func (m *Manager) EnsureDefaultTeam(tier string) error {
+	teamSecret, err := m.getTeamSecret(DefaultTeamID)
+	if err != nil {
+		if err := m.createDefaultTeam(tier); err != nil {
+			return err
+		}
+		log.Printf("Default team created with tier %s", tier)
+		return nil
+	}
+
+	current := teamSecret.Annotations["maas/policy"]
+	if current != tier && teamSecret.Annotations[annotationTeamStatus] != teamStatusArchived {
+		log.Printf("Warning: Default team is on tier %s but DEFAULT_TEAM_TIER is %s, use PUT /admin/default-team to change it",
+			current, tier)
+		m.events.Team(DefaultTeamID, corev1.EventTypeWarning, events.ReasonDefaultTeamDrift,
+			"Default team tier %s differs from configured tier %s", current, tier)
+		return nil
+	}
+
+	log.Printf("Default team already exists, skipping creation")
+	return nil
+}
+
+// UpdateDefaultTeam changes the default team's tier and limits through the
+// regular team update, so policies and keys are resynced the same way
+func (m *Manager) UpdateDefaultTeam(req *UpdateDefaultTeamRequest) (*UpdateTeamResponse, error) {
+	if req.Tier == nil && req.TokenLimit == nil && req.TimeWindow == nil {
+		return nil, fmt.Errorf("at least one of tier, token_limit or time_window is required")
+	}
+
+	return m.Update(DefaultTeamID, &UpdateTeamRequest{
+		Policy:     req.Tier,
+		TokenLimit: req.TokenLimit,
+		TimeWindow: req.TimeWindow,
+	})
+}
+
+// RecreateDefaultTeam rebuilds the default team after it was deleted
+func (m *Manager) RecreateDefaultTeam(tier string) error {
+	if m.Exists(DefaultTeamID) {
+		return fmt.Errorf("team %s already exists", DefaultTeamID)
+	}
+	if err := m.createDefaultTeam(tier); err != nil {
+		return err
+	}
+
+	log.Printf("Default team recreated with tier %s", tier)
+	return nil
+}
+
+func (m *Manager) createDefaultTeam(tier string) error {
+	return m.Create(&CreateTeamRequest{
+		TeamID:      DefaultTeamID,
+		TeamName:    "Default Team",
+		Description: "Default team for simple MaaS deployments - users without team assignment",
+		Policy:      tier,
+	})
+}
@@ -0,0 +1,70 @@
+package teams
+
+import "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+// PolicyEngine maintains the limits and groups teams are enforced by. The
+// Manager only talks to policies through it; PolicyManager is the Kuadrant
+// implementation.
+type PolicyEngine interface {
+	// Names of the shared policies and the defaults they are written with
+	TokenRateLimitPolicyName() string
+	AuthPolicyName() string
+	DefaultLimitScope() string
+	UnlimitedMode() string
+
+	// Team groups and limits
+	AddTeamToAuthPolicy(policyName string) error
+	RemoveTeamFromAuthPolicy(policyName string) error
+	AddTeamToTokenRateLimit(policyName string, tokenLimit int, timeWindow, scope string, teamTokenLimit int) error
+	AddBlockingLimitToTokenRateLimit(policyName string) error
+	RemoveTeamFromTokenRateLimit(policyName string) error
+	PolicyExists(policyName string) bool
+	GetPolicyLimitScope(policyName string) (string, int, error)
+	GetPolicyRates(policyName string) ([]RateLimit, error)
+	SetPolicyRates(policyName string, rates []RateLimit) error
+	GetPolicyBurst(policyName string) (int, string, error)
+	SetPolicyBurst(policyName string, burstLimit int, burstWindow string) error
+	GetModelLimits(policyName string) (map[string]ModelLimit, error)
+	SetModelLimits(policyName string, modelLimits map[string]ModelLimit) error
+
+	// Member and subteam limits
+	SetUserLimit(teamID, userID string, tokenLimit int, timeWindow string) error
+	RemoveUserLimit(teamID, userID string) error
+	RemoveTeamUserLimits(teamID string) error
+	GetTeamUserLimits(teamID string) ([]MemberLimit, error)
+	SetSubteamLimit(parentID string, tokenLimit int, timeWindow string) (bool, error)
+	RemoveSubteamLimit(parentID string) error
+
+	// Inspecting and exporting the policies
+	GetPolicyStatus(policyName string) []PolicyStatus
+	ListPolicyEntries() (map[string]bool, map[string]bool, error)
+	TokenRateLimits() (map[string]interface{}, error)
+	PolicyVersions() string
+	RenderPolicyChange(oldPolicy string, removeOld bool, newPolicy string, tokenLimit int, timeWindow, scope string, teamTokenLimit int) ([]*unstructured.Unstructured, []PolicyChange, error)
+	ExportPolicies() ([]byte, error)
+
+	// RestartKuadrantComponents makes the enforcing components reload
+	RestartKuadrantComponents() error
+}
+
+var _ PolicyEngine = (*PolicyManager)(nil)
+
+// TokenRateLimitPolicyName is the TokenRateLimitPolicy teams share
+func (p *PolicyManager) TokenRateLimitPolicyName() string {
+	return p.tokenRateLimitPolicyName
+}
+
+// AuthPolicyName is the AuthPolicy teams share
+func (p *PolicyManager) AuthPolicyName() string {
+	return p.authPolicyName
+}
+
+// DefaultLimitScope applies to policies created without an explicit scope
+func (p *PolicyManager) DefaultLimitScope() string {
+	return p.defaultLimitScope
+}
+
+// UnlimitedMode is how unlimited-policy is written
+func (p *PolicyManager) UnlimitedMode() string {
+	return p.unlimitedMode
+}
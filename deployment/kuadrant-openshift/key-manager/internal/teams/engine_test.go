@@ -0,0 +1,225 @@
+package teams
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPolicyEngineTeamLifecycle(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	var engine PolicyEngine = p
+
+	// Create
+	if err := engine.AddTeamToAuthPolicy("gold"); err != nil {
+		t.Fatalf("AddTeamToAuthPolicy() = %v", err)
+	}
+	if err := engine.AddTeamToTokenRateLimit("gold", 5000, "1h", "", 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	if !engine.PolicyExists("gold") {
+		t.Fatalf("PolicyExists(gold) = false after adding it")
+	}
+	if scope, _, err := engine.GetPolicyLimitScope("gold"); err != nil || scope != LimitScopePerUser {
+		t.Errorf("GetPolicyLimitScope() = %q, %v, want the default scope", scope, err)
+	}
+	if !strings.Contains(authRego(t, client), `groups[_] == "gold"`) {
+		t.Errorf("gold group is not allowed by the AuthPolicy")
+	}
+
+	// Update an existing entry, keeping its burst cap and model limits
+	if err := engine.SetPolicyBurst("gold", 500, "1m"); err != nil {
+		t.Fatalf("SetPolicyBurst() = %v", err)
+	}
+	if err := engine.SetModelLimits("gold", map[string]ModelLimit{"granite": {TokenLimit: 100, TimeWindow: "1m"}}); err != nil {
+		t.Fatalf("SetModelLimits() = %v", err)
+	}
+	if err := engine.AddTeamToTokenRateLimit("gold", 8000, "1h", "", 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	rates, err := engine.GetPolicyRates("gold")
+	if err != nil || len(rates) != 1 || rates[0].Limit != 8000 || rates[0].Window != "1h" {
+		t.Errorf("GetPolicyRates() = %v, %v, want 8000 per 1h", rates, err)
+	}
+	if burstLimit, burstWindow, err := engine.GetPolicyBurst("gold"); err != nil || burstLimit != 500 || burstWindow != "1m" {
+		t.Errorf("GetPolicyBurst() = %d, %q, %v, want 500 per 1m", burstLimit, burstWindow, err)
+	}
+	if models, err := engine.GetModelLimits("gold"); err != nil || models["granite"].TokenLimit != 100 {
+		t.Errorf("GetModelLimits() = %v, %v, want the granite limit", models, err)
+	}
+
+	// Delete, along with the model limits
+	if err := engine.RemoveTeamFromTokenRateLimit("gold"); err != nil {
+		t.Fatalf("RemoveTeamFromTokenRateLimit() = %v", err)
+	}
+	if err := engine.RemoveTeamFromAuthPolicy("gold"); err != nil {
+		t.Fatalf("RemoveTeamFromAuthPolicy() = %v", err)
+	}
+	if engine.PolicyExists("gold") {
+		t.Errorf("PolicyExists(gold) = true after removing it")
+	}
+	for name := range policyLimits(t, client) {
+		if strings.HasPrefix(name, "gold") {
+			t.Errorf("limit %s was left behind", name)
+		}
+	}
+	if strings.Contains(authRego(t, client), `"gold"`) {
+		t.Errorf("gold group is still allowed by the AuthPolicy")
+	}
+	if _, ok := policyLimits(t, client)["free"]; !ok {
+		t.Errorf("limit of another policy was dropped")
+	}
+}
+
+func TestPolicyEngineUsesResolvedVersions(t *testing.T) {
+	resolved := &PolicyGVRs{
+		tokenRateLimitPolicy: schema.GroupVersionResource{Group: kuadrantGroup, Version: "v1", Resource: "tokenratelimitpolicies"},
+		authPolicy:           schema.GroupVersionResource{Group: kuadrantGroup, Version: "v1beta3", Resource: "authpolicies"},
+	}
+
+	// Both versions of each policy are served, as during a Kuadrant upgrade
+	tokenRateLimitV1 := testTokenRateLimitPolicy()
+	tokenRateLimitV1.SetAPIVersion("kuadrant.io/v1")
+	authV1beta3 := testAuthPolicy()
+	authV1beta3.SetAPIVersion("kuadrant.io/v1beta3")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		testTokenRateLimitPolicy(), testAuthPolicy(), tokenRateLimitV1, authV1beta3)
+
+	p, _ := newFakePolicyManager(3)
+	p.kuadrantClient = client
+	p.gvrs = resolved
+
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	if err := p.AddTeamToAuthPolicy("gold"); err != nil {
+		t.Fatalf("AddTeamToAuthPolicy() = %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		gvr := action.GetResource()
+		if gvr != resolved.TokenRateLimitPolicy() && gvr != resolved.AuthPolicy() {
+			t.Errorf("%s sent for %s, want only the resolved versions", action.GetVerb(), gvr)
+		}
+	}
+
+	hasGold := func(gvr schema.GroupVersionResource, name string) bool {
+		obj, err := client.Tracker().Get(gvr, testNamespace, name)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", gvr, err)
+		}
+		policyObj := obj.(*unstructured.Unstructured)
+		limits, _, _ := unstructured.NestedMap(policyObj.Object, "spec", "limits")
+		rego, _, _ := unstructured.NestedString(policyObj.Object, "spec", "rules", "authorization", "allow-groups", "opa", "rego")
+		_, limited := limits["gold"]
+		return limited || strings.Contains(rego, `"gold"`)
+	}
+	defaults := DefaultPolicyGVRs()
+	if !hasGold(resolved.TokenRateLimitPolicy(), testTokenRateLimitPolicyName) || !hasGold(resolved.AuthPolicy(), testAuthPolicyName) {
+		t.Errorf("resolved policy versions were not written")
+	}
+	if hasGold(defaults.TokenRateLimitPolicy(), testTokenRateLimitPolicyName) || hasGold(defaults.AuthPolicy(), testAuthPolicyName) {
+		t.Errorf("default policy versions were written")
+	}
+}
+
+func TestPolicyEngineUnlimitedTier(t *testing.T) {
+	tests := []struct {
+		mode      string
+		wantEntry bool
+	}{
+		{mode: UnlimitedModeExempt, wantEntry: false},
+		{mode: UnlimitedModeHighLimit, wantEntry: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			p, client := newFakePolicyManager(3)
+			p.unlimitedMode = tt.mode
+
+			// Requested limits are ignored for the unlimited tier
+			if err := p.AddTeamToTokenRateLimit("unlimited-policy", 10, "1m", LimitScopePerTeam, 0); err != nil {
+				t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+			}
+
+			limitConfig, ok := policyLimits(t, client)["unlimited-policy"].(map[string]interface{})
+			if ok != tt.wantEntry {
+				t.Fatalf("unlimited-policy has an entry: %v, want %v", ok, tt.wantEntry)
+			}
+			if ok {
+				rates := limitRates(limitConfig)
+				if len(rates) != 1 || numberValue(rates[0]["limit"]) != unlimitedTokenLimit {
+					t.Errorf("unlimited-policy rates = %v, want the high limit", rates)
+				}
+			}
+			if !p.PolicyExists("unlimited-policy") {
+				t.Errorf("PolicyExists(unlimited-policy) = false")
+			}
+		})
+	}
+}
+
+func TestPolicyEngineExportOnly(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	p.applyMode = ApplyModeExportOnly
+	before := p.PolicyVersions()
+
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	if err := p.AddTeamToAuthPolicy("gold"); err != nil {
+		t.Fatalf("AddTeamToAuthPolicy() = %v", err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("%s sent for %s in export-only mode", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	if _, ok := policyLimits(t, client)["gold"]; ok {
+		t.Errorf("gold limit was written in export-only mode")
+	}
+	if p.PolicyVersions() == before {
+		t.Errorf("PolicyVersions() did not change after recorded writes")
+	}
+
+	exported, err := p.ExportPolicies()
+	if err != nil {
+		t.Fatalf("ExportPolicies() = %v", err)
+	}
+	for _, want := range []string{"kind: AuthPolicy", "kind: TokenRateLimitPolicy", "gold:", `groups[_] == "gold"`} {
+		if !strings.Contains(string(exported), want) {
+			t.Errorf("ExportPolicies() is missing %q:\n%s", want, exported)
+		}
+	}
+	if strings.Contains(string(exported), "resourceVersion") {
+		t.Errorf("ExportPolicies() kept server-managed metadata:\n%s", exported)
+	}
+}
+
+func TestManagerPolicyEngine(t *testing.T) {
+	if _, err := (&Manager{}).ExportPolicies(); err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Errorf("ExportPolicies() without a policy engine = %v, want not configured", err)
+	}
+
+	// A nil *PolicyManager means no policy management too
+	var none *PolicyManager
+	if m := NewManager(k8sfake.NewSimpleClientset(), testNamespace, none, nil, nil, nil, false, false, nil, nil); m.policyMgr != nil {
+		t.Errorf("NewManager() kept a nil *PolicyManager as a policy engine")
+	}
+
+	p, _ := newFakePolicyManager(3)
+	m := &Manager{policyMgr: p}
+	exported, err := m.ExportPolicies()
+	if err != nil {
+		t.Fatalf("ExportPolicies() = %v", err)
+	}
+	if !strings.Contains(string(exported), testTokenRateLimitPolicyName) {
+		t.Errorf("ExportPolicies() = %s, want the engine's policies", exported)
+	}
+}
@@ -0,0 +1,174 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExportTeams returns the configuration, registered members and rendered
+// policy limits of every team, sorted by team ID with sub-teams after all
+// top-level teams so an import creates parents first
+func (m *Manager) ExportTeams() ([]TeamExport, error) {
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+
+	exports := make([]TeamExport, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		export, err := m.exportTeam(&secrets.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *export)
+	}
+
+	sort.Slice(exports, func(i, j int) bool {
+		iSub, jSub := exports[i].ParentTeamID != "", exports[j].ParentTeamID != ""
+		if iSub != jSub {
+			return jSub
+		}
+		return exports[i].TeamID < exports[j].TeamID
+	})
+	return exports, nil
+}
+
+// exportTeam builds the export of a single team config secret
+func (m *Manager) exportTeam(teamSecret *corev1.Secret) (*TeamExport, error) {
+	teamID := teamSecret.Labels["maas/team-id"]
+	annotations := teamSecret.Annotations
+
+	status := annotations[annotationTeamStatus]
+	if status == "" {
+		status = "active"
+	}
+
+	// Archived teams are exported with the policy they are restored to
+	policy := annotations["maas/policy"]
+	if status == teamStatusArchived && annotations[annotationPreArchivePolicy] != "" {
+		policy = annotations[annotationPreArchivePolicy]
+	}
+
+	export := &TeamExport{
+		TeamID:        teamID,
+		TeamName:      annotations["maas/team-name"],
+		Description:   annotations["maas/description"],
+		Policy:        policy,
+		Status:        status,
+		CreatedAt:     annotations["maas/created-at"],
+		ModelsAllowed: SplitModels(annotations[annotationModelsAllowed]),
+		WebhookURL:    annotations[annotationWebhookURL],
+		Namespace:     annotations[annotationKeyNamespace],
+		OwnerUserID:   annotations[annotationOwnerUserID],
+		OwnerEmail:    annotations[annotationOwnerEmail],
+		ParentTeamID:  teamSecret.Labels[LabelParentTeamID],
+		Members:       make([]TeamMember, 0),
+	}
+	if budget, err := strconv.ParseFloat(annotations[annotationBudget], 64); err == nil {
+		export.BudgetUSDMonthly = budget
+	}
+	if maxKeys := annotationInt(annotations, "maas/max-keys-per-user"); maxKeys >= 0 {
+		export.MaxKeysPerUser = &maxKeys
+	}
+	if maxKeys := annotationInt(annotations, "maas/max-keys-per-team"); maxKeys >= 0 {
+		export.MaxKeysPerTeam = &maxKeys
+	}
+
+	if m.policyMgr != nil {
+		if tokenLimit, timeWindow, err := m.tierLimits(policy); err == nil {
+			export.Limits = &PolicyExport{TokenLimit: tokenLimit, TimeWindow: timeWindow}
+			export.Limits.LimitScope, export.Limits.TeamTokenLimit, _ = m.policyMgr.GetPolicyLimitScope(policy)
+		} else {
+			log.Printf("Warning: Failed to read limits of policy %s for team %s: %v", policy, teamID, err)
+		}
+	}
+
+	// Members only known from their keys come back with the keys themselves
+	members, err := m.ListMembers(teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export members of team %s: %w", teamID, err)
+	}
+	for _, member := range members {
+		if member.Registered {
+			export.Members = append(export.Members, member)
+		}
+	}
+
+	return export, nil
+}
+
+// ImportTeam recreates an exported team, re-rendering its policies, and
+// registers its members. Teams that already exist are left as they are and
+// only missing members are added. It reports whether the team was created
+// and how many members were added.
+func (m *Manager) ImportTeam(export *TeamExport) (bool, int, error) {
+	created := false
+	if !m.Exists(export.TeamID) {
+		req := &CreateTeamRequest{
+			TeamID:           export.TeamID,
+			TeamName:         export.TeamName,
+			Description:      export.Description,
+			Policy:           export.Policy,
+			BudgetUSDMonthly: export.BudgetUSDMonthly,
+			WebhookURL:       export.WebhookURL,
+			Namespace:        export.Namespace,
+			OwnerUserID:      export.OwnerUserID,
+			OwnerEmail:       export.OwnerEmail,
+			ParentTeamID:     export.ParentTeamID,
+		}
+		if export.Limits != nil {
+			req.TokenLimit = export.Limits.TokenLimit
+			req.TimeWindow = export.Limits.TimeWindow
+			req.LimitScope = export.Limits.LimitScope
+			req.TeamTokenLimit = export.Limits.TeamTokenLimit
+		}
+		if err := m.Create(req); err != nil {
+			return false, 0, err
+		}
+		created = true
+
+		if len(export.ModelsAllowed) > 0 {
+			if _, err := m.SetTeamModels(export.TeamID, export.ModelsAllowed); err != nil {
+				return created, 0, fmt.Errorf("failed to restore model allowlist: %w", err)
+			}
+		}
+		if export.MaxKeysPerUser != nil || export.MaxKeysPerTeam != nil {
+			_, err := m.Update(export.TeamID, &UpdateTeamRequest{
+				MaxKeysPerUser: export.MaxKeysPerUser,
+				MaxKeysPerTeam: export.MaxKeysPerTeam,
+			})
+			if err != nil {
+				return created, 0, fmt.Errorf("failed to restore key caps: %w", err)
+			}
+		}
+	}
+
+	added := 0
+	for _, member := range export.Members {
+		_, err := m.AddMember(export.TeamID, &AddUserToTeamRequest{
+			UserID:       member.UserID,
+			UserEmail:    member.UserEmail,
+			Role:         member.Role,
+			TokenLimit:   member.TokenLimit,
+			RequestLimit: member.RequestLimit,
+			TimeWindow:   member.TimeWindow,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "already a member") {
+				continue
+			}
+			return created, added, fmt.Errorf("failed to import member %s: %w", member.UserID, err)
+		}
+		added++
+	}
+
+	return created, added, nil
+}
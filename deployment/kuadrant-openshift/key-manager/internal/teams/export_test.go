@@ -0,0 +1,52 @@
+package teams
+
+import (
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExportImportKeepsTierLimits(t *testing.T) {
+	p, _ := newFakePolicyManager(3)
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-a", "gold"), testTeamSecret("team-b", "free"))
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	exports, err := m.ExportTeams()
+	if err != nil {
+		t.Fatalf("ExportTeams() = %v", err)
+	}
+	want := map[string]PolicyExport{
+		"team-a": {TokenLimit: 5000, TimeWindow: "1h", LimitScope: LimitScopePerUser},
+		"team-b": {TokenLimit: 100, TimeWindow: "1m", LimitScope: LimitScopePerUser},
+	}
+	if len(exports) != len(want) {
+		t.Fatalf("ExportTeams() = %d teams, want %d", len(exports), len(want))
+	}
+	for _, export := range exports {
+		if export.Limits == nil || *export.Limits != want[export.TeamID] {
+			t.Errorf("%s exported limits %+v, want %+v", export.TeamID, export.Limits, want[export.TeamID])
+		}
+	}
+
+	// Importing into an empty cluster renders the same gold limit
+	target, client := newFakePolicyManager(3)
+	imported := NewManager(k8sfake.NewSimpleClientset(), testNamespace, target, nil, nil, nil, false, false, nil, nil)
+	for i := range exports {
+		if _, _, err := imported.ImportTeam(&exports[i]); err != nil {
+			t.Fatalf("ImportTeam(%s) = %v", exports[i].TeamID, err)
+		}
+	}
+	limitConfig, ok := policyLimits(t, client)["gold"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("gold limit was not imported")
+	}
+	if rates := limitRates(limitConfig); len(rates) != 1 || numberValue(rates[0]["limit"]) != 5000 || rates[0]["window"] != "1h" {
+		t.Errorf("imported gold rates = %v, want 5000 per 1h", rates)
+	}
+	if tokenLimit, timeWindow, err := target.GetPolicyLimits("free"); err != nil || tokenLimit != 100 || timeWindow != "1m" {
+		t.Errorf("imported free limits = %d per %s, %v, want 100 per 1m", tokenLimit, timeWindow, err)
+	}
+}
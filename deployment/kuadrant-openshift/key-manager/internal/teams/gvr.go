@@ -0,0 +1,149 @@
+package teams
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// kuadrantGroup is the API group of the Kuadrant policies
+const kuadrantGroup = "kuadrant.io"
+
+// Versions each Kuadrant policy kind may be served in, preferred first
+var (
+	tokenRateLimitPolicyVersions = []string{"v1", "v1beta1", "v1alpha1"}
+	authPolicyVersions           = []string{"v1", "v1beta3", "v1beta2"}
+)
+
+// PolicyGVRs are the Kuadrant policy resources in the versions the cluster
+// serves. Every read and write of a policy kind uses the same resolved
+// version, which a refresh may move when Kuadrant is upgraded.
+type PolicyGVRs struct {
+	mu                   sync.RWMutex
+	tokenRateLimitPolicy schema.GroupVersionResource
+	authPolicy           schema.GroupVersionResource
+	// Set when a policy kind is not served in any supported version
+	err      error
+	resolved bool
+}
+
+// DefaultPolicyGVRs returns the policy versions used when the cluster's
+// served versions are not known
+func DefaultPolicyGVRs() *PolicyGVRs {
+	return &PolicyGVRs{
+		tokenRateLimitPolicy: schema.GroupVersionResource{Group: kuadrantGroup, Version: "v1alpha1", Resource: "tokenratelimitpolicies"},
+		authPolicy:           schema.GroupVersionResource{Group: kuadrantGroup, Version: "v1", Resource: "authpolicies"},
+	}
+}
+
+// ResolvePolicyGVRs picks the most preferred served version of each policy
+// kind. Kinds that are not served keep their default version and are
+// reported in Err, so the service can still start and report not ready.
+func ResolvePolicyGVRs(client discovery.DiscoveryInterface) *PolicyGVRs {
+	gvrs := DefaultPolicyGVRs()
+	gvrs.Refresh(client)
+	return gvrs
+}
+
+// TokenRateLimitPolicy returns the TokenRateLimitPolicy resource in use
+func (g *PolicyGVRs) TokenRateLimitPolicy() schema.GroupVersionResource {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.tokenRateLimitPolicy
+}
+
+// AuthPolicy returns the AuthPolicy resource in use
+func (g *PolicyGVRs) AuthPolicy() schema.GroupVersionResource {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.authPolicy
+}
+
+// Err reports a policy kind the cluster does not serve in any supported
+// version
+func (g *PolicyGVRs) Err() error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.err
+}
+
+// Refresh re-discovers the served versions. A kind that is no longer served
+// keeps the version last used.
+func (g *PolicyGVRs) Refresh(client discovery.DiscoveryInterface) {
+	tokenRateLimitVersion, tokenRateLimitServed := servedVersion(client, "tokenratelimitpolicies", tokenRateLimitPolicyVersions)
+	authVersion, authServed := servedVersion(client, "authpolicies", authPolicyVersions)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	missing := make([]string, 0)
+	changed := false
+	if tokenRateLimitServed {
+		changed = changed || g.tokenRateLimitPolicy.Version != tokenRateLimitVersion
+		g.tokenRateLimitPolicy.Version = tokenRateLimitVersion
+	} else {
+		missing = append(missing, "TokenRateLimitPolicy")
+	}
+	if authServed {
+		changed = changed || g.authPolicy.Version != authVersion
+		g.authPolicy.Version = authVersion
+	} else {
+		missing = append(missing, "AuthPolicy")
+	}
+
+	g.err = nil
+	if len(missing) > 0 {
+		g.err = fmt.Errorf("%s not served by the cluster in any supported version", strings.Join(missing, " and "))
+		log.Printf("Warning: %v", g.err)
+	}
+	if changed || !g.resolved {
+		g.resolved = true
+		log.Printf("Using TokenRateLimitPolicy %s and AuthPolicy %s",
+			g.tokenRateLimitPolicy.GroupVersion(), g.authPolicy.GroupVersion())
+	}
+}
+
+// StartRefresh periodically re-discovers the served versions, so an upgrade
+// that promotes a policy kind is picked up without a restart. A non-positive
+// interval disables it.
+func (g *PolicyGVRs) StartRefresh(client discovery.DiscoveryInterface, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			g.Refresh(client)
+		}
+	}()
+	log.Printf("Policy version discovery started, interval %s", interval)
+}
+
+// servedVersion returns the first of versions in which the cluster serves a
+// Kuadrant resource
+func servedVersion(client discovery.DiscoveryInterface, resource string, versions []string) (string, bool) {
+	for _, version := range versions {
+		groupVersion := schema.GroupVersion{Group: kuadrantGroup, Version: version}.String()
+		resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.Printf("Warning: Failed to discover %s resources: %v", groupVersion, err)
+			}
+			continue
+		}
+		for _, served := range resources.APIResources {
+			if served.Name == resource {
+				return version, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,136 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Outcomes of a single policy health check
+const (
+	PolicyCheckOK       = "ok"
+	PolicyCheckDegraded = "degraded"
+	PolicyCheckFailed   = "failed"
+	PolicyCheckSkipped  = "skipped"
+)
+
+// Overall policy health
+const (
+	PolicyHealthHealthy   = "healthy"
+	PolicyHealthDegraded  = "degraded"
+	PolicyHealthUnhealthy = "unhealthy"
+)
+
+// RunPolicyCheck runs a health check and records how long it took. The
+// check returns its outcome and a message explaining it.
+func RunPolicyCheck(name string, check func() (string, string)) PolicyHealthCheck {
+	start := time.Now()
+	status, message := check()
+	return PolicyHealthCheck{
+		Name:      name,
+		Status:    status,
+		Message:   message,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// OverallPolicyHealth is unhealthy when any check failed and degraded when
+// any check is degraded
+func OverallPolicyHealth(checks []PolicyHealthCheck) string {
+	health := PolicyHealthHealthy
+	for _, check := range checks {
+		switch check.Status {
+		case PolicyCheckFailed:
+			return PolicyHealthUnhealthy
+		case PolicyCheckDegraded:
+			health = PolicyHealthDegraded
+		}
+	}
+	return health
+}
+
+// PolicyHealthChecks checks the Kuadrant policies the teams depend on: that
+// both policies exist and are accepted and enforced, that every team's
+// entries are in place and that the custom tier definitions parse
+func (m *Manager) PolicyHealthChecks() []PolicyHealthCheck {
+	if m.policyMgr == nil {
+		return []PolicyHealthCheck{{Name: "policies", Status: PolicyCheckSkipped, Message: "policy management is not configured"}}
+	}
+
+	checks := make([]PolicyHealthCheck, 0)
+
+	// The policy name is only used to look for an entry, which is not checked here
+	for _, status := range m.policyMgr.GetPolicyStatus("") {
+		status := status
+		checks = append(checks, RunPolicyCheck(status.Kind, func() (string, string) {
+			return policyResourceHealth(status)
+		}))
+	}
+
+	checks = append(checks,
+		RunPolicyCheck("team_policies", func() (string, string) {
+			report, err := m.CheckCompliance(nil)
+			if err != nil {
+				return PolicyCheckFailed, err.Error()
+			}
+			message := fmt.Sprintf("%d of %d teams have all their policy entries", report.CompliantTeams, report.Teams)
+			if report.CompliantTeams < report.Teams {
+				return PolicyCheckDegraded, message
+			}
+			return PolicyCheckOK, message
+		}),
+		RunPolicyCheck("custom_tiers", m.tierDefinitionsHealth),
+	)
+	return checks
+}
+
+// policyResourceHealth summarizes the live state of one shared policy
+func policyResourceHealth(status PolicyStatus) (string, string) {
+	switch {
+	case status.Error != "":
+		return PolicyCheckFailed, status.Error
+	case !status.Exists:
+		return PolicyCheckFailed, fmt.Sprintf("%s %s/%s not found", status.Kind, status.Namespace, status.Name)
+	case status.Accepted == nil || status.Accepted.Status != "True":
+		return PolicyCheckDegraded, fmt.Sprintf("%s %s is not accepted%s", status.Kind, status.Name, conditionReason(status.Accepted))
+	case status.Enforced == nil || status.Enforced.Status != "True":
+		return PolicyCheckDegraded, fmt.Sprintf("%s %s is not enforced%s", status.Kind, status.Name, conditionReason(status.Enforced))
+	}
+	return PolicyCheckOK, fmt.Sprintf("%s %s is accepted and enforced", status.Kind, status.Name)
+}
+
+func conditionReason(condition *PolicyCondition) string {
+	if condition == nil || condition.Reason == "" {
+		return ""
+	}
+	return ": " + condition.Reason
+}
+
+// tierDefinitionsHealth reports custom tier definitions that no longer parse
+// and are being ignored
+func (m *Manager) tierDefinitionsHealth() (string, string) {
+	configMap, err := m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Get(
+		context.Background(), tierConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return PolicyCheckOK, "no custom tiers defined"
+	}
+	if err != nil {
+		return PolicyCheckFailed, fmt.Sprintf("failed to get custom tiers: %v", err)
+	}
+
+	invalid := make([]string, 0)
+	for tier, data := range configMap.Data {
+		var definition TierDefinition
+		if err := json.Unmarshal([]byte(data), &definition); err != nil {
+			invalid = append(invalid, tier)
+		}
+	}
+	if len(invalid) > 0 {
+		return PolicyCheckDegraded, fmt.Sprintf("%d custom tiers are ignored because their definition does not parse: %v", len(invalid), invalid)
+	}
+	return PolicyCheckOK, fmt.Sprintf("%d custom tiers defined", len(configMap.Data))
+}
@@ -0,0 +1,252 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/fips"
+)
+
+// Invite secret annotations
+const (
+	annotationInviteRole      = "maas/team-role"
+	annotationInviteExpiresAt = "maas/expires-at"
+	annotationInviteCreateKey = "maas/create-key"
+	annotationInviteUsedAt    = "maas/used-at"
+	annotationInviteUsedBy    = "maas/used-by"
+)
+
+// CreateInvite mints a single-use token that lets a user join a team with
+// the given role. Only its hash is stored; the plaintext is returned once.
+func (m *Manager) CreateInvite(teamID string, req *CreateInviteRequest, ttl time.Duration) (*CreateInviteResponse, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if teamSecret.Annotations[annotationTeamStatus] == teamStatusArchived {
+		return nil, fmt.Errorf("team %s is archived", teamID)
+	}
+
+	role := req.Role
+	if role == "" {
+		role = "member"
+	}
+	if !IsValidRole(role) {
+		return nil, fmt.Errorf("invalid role %s, must be one of member, admin, viewer", role)
+	}
+
+	token, err := fips.RandomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	tokenHash := hashAdminToken(token)
+	inviteID := tokenHash[:12]
+
+	now := time.Now()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("team-%s-invite-%s", teamID, inviteID),
+			Namespace: m.keyNamespace,
+			Labels: map[string]string{
+				"maas/resource-type": "team-invite",
+				"maas/team-id":       teamID,
+				"maas/invite-id":     inviteID,
+				"maas/token-sha256":  tokenHash[:32],
+			},
+			Annotations: map[string]string{
+				"maas/description":        req.Description,
+				"maas/created-at":         now.Format(time.RFC3339),
+				annotationInviteRole:      role,
+				annotationInviteExpiresAt: now.Add(ttl).Format(time.RFC3339),
+				annotationInviteCreateKey: fmt.Sprintf("%t", req.CreateKey),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"token_hash": tokenHash,
+		},
+	}
+
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Create(
+		context.Background(), secret, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	log.Printf("Invite %s issued for team %s with role %s", inviteID, teamID, role)
+	return &CreateInviteResponse{
+		Token:  token,
+		Invite: inviteFromSecret(secret, now),
+	}, nil
+}
+
+// ListInvites lists the invites issued for a team, used and expired ones
+// included
+func (m *Manager) ListInvites(teamID string) ([]Invite, error) {
+	if !m.Exists(teamID) {
+		return nil, fmt.Errorf("team not found")
+	}
+
+	secrets, err := m.listInviteSecrets(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invites := make([]Invite, 0, len(secrets))
+	for i := range secrets {
+		invites = append(invites, inviteFromSecret(&secrets[i], now))
+	}
+	return invites, nil
+}
+
+// CountOutstandingInvites counts a team's invites that can still be accepted
+func (m *Manager) CountOutstandingInvites(teamID string) (int, error) {
+	secrets, err := m.listInviteSecrets(teamID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	count := 0
+	for i := range secrets {
+		if inviteFromSecret(&secrets[i], now).Status == InviteStatusPending {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// RevokeInvite deletes an invite so it can no longer be accepted
+func (m *Manager) RevokeInvite(teamID, inviteID string) error {
+	labelSelector := fmt.Sprintf("maas/resource-type=team-invite,maas/team-id=%s,maas/invite-id=%s", teamID, inviteID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return fmt.Errorf("failed to find invite: %w", err)
+	}
+	if len(secrets.Items) == 0 {
+		return fmt.Errorf("invite not found")
+	}
+
+	err = m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(
+		context.Background(), secrets.Items[0].Name, metav1.DeleteOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+
+	log.Printf("Invite %s revoked for team %s", inviteID, teamID)
+	return nil
+}
+
+// AcceptInvite consumes an invite token and adds the user to the invite's
+// team. The invite is marked used before the membership is created, so of
+// two concurrent accepts only one succeeds.
+func (m *Manager) AcceptInvite(token string, req *AcceptInviteRequest) (*Invite, *TeamMember, error) {
+	secret, err := m.findInviteSecret(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	invite := inviteFromSecret(secret, now)
+	switch invite.Status {
+	case InviteStatusUsed:
+		return nil, nil, fmt.Errorf("invite has already been used")
+	case InviteStatusExpired:
+		return nil, nil, fmt.Errorf("invite has expired")
+	}
+
+	// The update carries the resource version that was read, so a concurrent
+	// accept of the same invite fails with a conflict
+	secret.Annotations[annotationInviteUsedAt] = now.Format(time.RFC3339)
+	secret.Annotations[annotationInviteUsedBy] = req.UserID
+	used, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+		context.Background(), secret, metav1.UpdateOptions{})
+	if err != nil {
+		if apierrors.IsConflict(err) {
+			return nil, nil, fmt.Errorf("invite has already been used")
+		}
+		return nil, nil, fmt.Errorf("failed to consume invite: %w", err)
+	}
+
+	member, err := m.AddMember(invite.TeamID, &AddUserToTeamRequest{
+		UserID:    req.UserID,
+		UserEmail: req.UserEmail,
+		Role:      invite.Role,
+	})
+	if err != nil {
+		// Give the invite back so the user can retry once the cause is fixed
+		delete(used.Annotations, annotationInviteUsedAt)
+		delete(used.Annotations, annotationInviteUsedBy)
+		if _, revertErr := m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), used, metav1.UpdateOptions{}); revertErr != nil {
+			log.Printf("Warning: Failed to release invite %s after failed accept: %v", invite.InviteID, revertErr)
+		}
+		return nil, nil, err
+	}
+
+	invite = inviteFromSecret(used, now)
+	log.Printf("Invite %s accepted by user %s for team %s", invite.InviteID, req.UserID, invite.TeamID)
+	return &invite, member, nil
+}
+
+// findInviteSecret returns the invite secret matching a token
+func (m *Manager) findInviteSecret(token string) (*corev1.Secret, error) {
+	tokenHash := hashAdminToken(token)
+
+	labelSelector := fmt.Sprintf("maas/resource-type=team-invite,maas/token-sha256=%s", tokenHash[:32])
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve invite: %w", err)
+	}
+
+	for i := range secrets.Items {
+		if string(secrets.Items[i].Data["token_hash"]) == tokenHash {
+			return &secrets.Items[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("invite not found")
+}
+
+// listInviteSecrets returns the invite secrets of a team
+func (m *Manager) listInviteSecrets(teamID string) ([]corev1.Secret, error) {
+	labelSelector := fmt.Sprintf("maas/resource-type=team-invite,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+	return secrets.Items, nil
+}
+
+// inviteFromSecret builds an invite from its secret, deriving its status at now
+func inviteFromSecret(secret *corev1.Secret, now time.Time) Invite {
+	invite := Invite{
+		InviteID:    secret.Labels["maas/invite-id"],
+		TeamID:      secret.Labels["maas/team-id"],
+		Role:        secret.Annotations[annotationInviteRole],
+		Description: secret.Annotations["maas/description"],
+		CreateKey:   secret.Annotations[annotationInviteCreateKey] == "true",
+		CreatedAt:   secret.Annotations["maas/created-at"],
+		ExpiresAt:   secret.Annotations[annotationInviteExpiresAt],
+		UsedAt:      secret.Annotations[annotationInviteUsedAt],
+		UsedBy:      secret.Annotations[annotationInviteUsedBy],
+		Status:      InviteStatusPending,
+	}
+
+	if invite.UsedAt != "" {
+		invite.Status = InviteStatusUsed
+	} else if expiresAt, err := time.Parse(time.RFC3339, invite.ExpiresAt); err != nil || !now.Before(expiresAt) {
+		invite.Status = InviteStatusExpired
+	}
+	return invite
+}
@@ -0,0 +1,273 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Kinds of limit a team is held to
+const (
+	LimitKindTier    = "tier"
+	LimitKindTeam    = "team"
+	LimitKindSubteam = "subteam"
+	LimitKindModel   = "model"
+	LimitKindMember  = "member"
+)
+
+// How an applied limit compares to the one the team's config renders
+const (
+	LimitStatusInSync    = "in_sync"
+	LimitStatusDiffers   = "differs"
+	LimitStatusMissing   = "missing"
+	LimitStatusUnmanaged = "unmanaged"
+)
+
+// GetLimitStatus lists the TokenRateLimitPolicy limits a team is held to,
+// each with the rates its config renders and the rates applied. A policy
+// that cannot be read is reported in the result rather than failing it.
+func (m *Manager) GetLimitStatus(teamID string) (*TeamLimitStatus, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TeamLimitStatus{
+		TeamID: teamID,
+		Policy: teamSecret.Annotations["maas/policy"],
+		Limits: make([]LimitStatus, 0),
+	}
+	if status.Policy == "" {
+		status.Policy = "unlimited-policy"
+	}
+
+	limits, err := m.policyMgr.TokenRateLimits()
+	if err != nil {
+		status.PolicyError = err.Error()
+		limits = map[string]interface{}{}
+	}
+	status.LimitScope, _, _ = m.policyMgr.GetPolicyLimitScope(status.Policy)
+
+	desired := m.desiredLimits(teamSecret, status.Policy)
+
+	for name, limitConfig := range limits {
+		kind, ok := limitKind(teamID, status.Policy, name, limitConfig)
+		if !ok {
+			continue
+		}
+		entry := LimitStatus{Name: name, Kind: kind, Applied: appliedRates(limitConfig)}
+		if limit, managed := desired[name]; managed {
+			entry.Desired = limit.rates
+			entry.Status = LimitStatusInSync
+			if !sameRates(entry.Desired, entry.Applied) {
+				entry.Status = LimitStatusDiffers
+			}
+			delete(desired, name)
+		} else if kind == LimitKindModel || kind == LimitKindMember {
+			// Left behind by an override the team no longer has
+			entry.Status = LimitStatusDiffers
+		} else {
+			entry.Status = LimitStatusUnmanaged
+		}
+		status.Limits = append(status.Limits, entry)
+	}
+	// Only meaningful when the policy was read; otherwise every limit would
+	// be reported missing
+	if status.PolicyError == "" {
+		for name, limit := range desired {
+			status.Limits = append(status.Limits, LimitStatus{
+				Name:    name,
+				Kind:    limit.kind,
+				Status:  LimitStatusMissing,
+				Desired: limit.rates,
+			})
+		}
+	}
+
+	sort.Slice(status.Limits, func(i, j int) bool {
+		return status.Limits[i].Name < status.Limits[j].Name
+	})
+	for i := range status.Limits {
+		status.Limits[i].Counters = make([]limitador.LimitUsage, 0)
+	}
+	return status, nil
+}
+
+// desiredLimit is a limit the key manager renders for a team
+type desiredLimit struct {
+	kind  string
+	rates []RateLimit
+}
+
+// desiredLimits renders each limit the key manager keeps for a team, by
+// name. Limits of built-in tiers are not rendered from config and are left
+// out.
+func (m *Manager) desiredLimits(teamSecret *corev1.Secret, policy string) map[string]desiredLimit {
+	teamID := teamSecret.Labels["maas/team-id"]
+	desired := make(map[string]desiredLimit)
+
+	if definition, ok := m.customTier(policy); ok {
+		spec := m.desiredTierSpec(definition)
+		rates := append([]RateLimit{}, spec.Rates...)
+		if spec.BurstLimit > 0 {
+			rates = append(rates, RateLimit{Limit: spec.BurstLimit, Window: spec.BurstWindow})
+		}
+		desired[policy] = desiredLimit{LimitKindTier, rates}
+		if spec.TeamTokenLimit > 0 && len(spec.Rates) > 0 {
+			desired[teamLimitName(policy)] = desiredLimit{LimitKindTeam,
+				[]RateLimit{{Limit: spec.TeamTokenLimit, Window: spec.Rates[0].Window}}}
+		}
+	}
+
+	tierLimit, tierWindow, err := m.tierLimits(policy)
+	if err != nil {
+		tierLimit, tierWindow = 0, ""
+	}
+	if subteams, err := m.listSubteams(teamID); err == nil && len(subteams) > 0 && tierLimit > 0 {
+		desired[SubteamLimitName(teamID)] = desiredLimit{LimitKindSubteam,
+			[]RateLimit{{Limit: tierLimit, Window: tierWindow}}}
+	}
+
+	if modelLimits, recorded := modelLimitsOf(teamSecret); recorded {
+		for model, limit := range modelLimits {
+			desired[modelLimitName(policy, model)] = desiredLimit{LimitKindModel,
+				[]RateLimit{{Limit: limit.TokenLimit, Window: limit.TimeWindow}}}
+		}
+	}
+
+	if members, err := m.ListMembers(teamID); err == nil {
+		for _, member := range members {
+			if member.TokenLimit <= 0 {
+				continue
+			}
+			window := member.TimeWindow
+			if window == "" {
+				window = tierWindow
+			}
+			if window == "" {
+				window = "1h"
+			}
+			if tighterRate(member.TokenLimit, window, tierLimit, tierWindow) {
+				desired[UserLimitName(teamID, member.UserID)] = desiredLimit{LimitKindMember,
+					[]RateLimit{{Limit: member.TokenLimit, Window: window}}}
+			}
+		}
+	}
+	return desired
+}
+
+// limitKind tells which of a team's limits a TokenRateLimitPolicy limit is,
+// if it is one of them
+func limitKind(teamID, policy, name string, limitConfig interface{}) (string, bool) {
+	switch {
+	case name == policy:
+		return LimitKindTier, true
+	case name == teamLimitName(policy):
+		return LimitKindTeam, true
+	case name == SubteamLimitName(teamID):
+		return LimitKindSubteam, true
+	}
+	if _, ok := limitModel(policy, name, limitConfig); ok {
+		return LimitKindModel, true
+	}
+	if _, ok := userLimitOf(teamID, name, limitConfig); ok {
+		return LimitKindMember, true
+	}
+	return "", false
+}
+
+// appliedRates returns every rate of a limit, burst cap included
+func appliedRates(limitConfig interface{}) []RateLimit {
+	limitMap, ok := limitConfig.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	rates := make([]RateLimit, 0)
+	for _, rate := range limitRates(limitMap) {
+		rates = append(rates, RateLimit{
+			Limit:  int(numberValue(rate["limit"])),
+			Window: stringValue(rate["window"]),
+		})
+	}
+	return rates
+}
+
+// sameRates compares two lists of rates regardless of order
+func sameRates(a, b []RateLimit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[RateLimit]int, len(a))
+	for _, rate := range a {
+		counts[rate]++
+	}
+	for _, rate := range b {
+		if counts[rate] == 0 {
+			return false
+		}
+		counts[rate]--
+	}
+	return true
+}
+
+// CounterOwners returns the values a team's Limitador counters can be keyed
+// on: its ID, its members' user IDs and the hashes of its keys
+func (m *Manager) CounterOwners(teamID string) map[string]bool {
+	owners := map[string]bool{teamID: true}
+	if members, err := m.ListMembers(teamID); err == nil {
+		for _, member := range members {
+			owners[member.UserID] = true
+		}
+	}
+
+	namespace := m.keyNamespace
+	if teamSecret, err := m.getTeamSecret(teamID); err == nil {
+		namespace = m.keyNamespaceOf(teamSecret)
+	}
+	keys, err := m.clientset.CoreV1().Secrets(namespace).List(context.Background(),
+		metav1.ListOptions{LabelSelector: fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)})
+	if err == nil {
+		for _, key := range keys.Items {
+			if hash := key.Labels["maas/key-sha256"]; hash != "" {
+				owners[hash] = true
+			}
+		}
+	}
+	return owners
+}
+
+// AttachCounters adds to each limit the live counters kept for the team and
+// marks limits with an exhausted counter
+func (s *TeamLimitStatus) AttachCounters(counters []limitador.Counter, owners map[string]bool) {
+	for i := range s.Limits {
+		limit := &s.Limits[i]
+		limit.Counters = limitador.LimitCounters(counters, limit.Name, owners)
+		for _, counter := range limit.Counters {
+			if counter.Exhausted {
+				limit.Exhausted = true
+			}
+		}
+	}
+}
+
+// TokenRateLimits reads the limits of the TokenRateLimitPolicy
+func (p *PolicyManager) TokenRateLimits() (map[string]interface{}, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+
+	limits, _, _ := unstructured.NestedMap(policyObj.Object, "spec", "limits")
+	return limits, nil
+}
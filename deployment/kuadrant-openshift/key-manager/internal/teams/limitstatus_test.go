@@ -0,0 +1,57 @@
+package teams
+
+import (
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetLimitStatusDesiredSubteamLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		appliedLimit int
+		wantStatus   string
+	}{
+		{name: "parent tier limit applied", appliedLimit: 100, wantStatus: LimitStatusInSync},
+		{name: "default limit applied", appliedLimit: 100000, wantStatus: LimitStatusDiffers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newFakePolicyManager(3)
+			if _, err := p.SetSubteamLimit("team-p", tt.appliedLimit, "1m"); err != nil {
+				t.Fatalf("SetSubteamLimit() = %v", err)
+			}
+			subteam := testTeamSecret("team-c", "free")
+			subteam.Labels[LabelParentTeamID] = "team-p"
+			clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-p", "free"), subteam)
+			m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+			status, err := m.GetLimitStatus("team-p")
+			if err != nil {
+				t.Fatalf("GetLimitStatus() = %v", err)
+			}
+			if status.PolicyError != "" {
+				t.Fatalf("GetLimitStatus() could not read the policy: %s", status.PolicyError)
+			}
+
+			var found bool
+			for _, limit := range status.Limits {
+				if limit.Name != SubteamLimitName("team-p") {
+					continue
+				}
+				found = true
+				// The free tier allows 100 tokens per minute
+				if len(limit.Desired) != 1 || limit.Desired[0] != (RateLimit{Limit: 100, Window: "1m"}) {
+					t.Errorf("desired rates = %v, want the parent's 100 per 1m", limit.Desired)
+				}
+				if limit.Kind != LimitKindSubteam || limit.Status != tt.wantStatus {
+					t.Errorf("sub-team limit is %s %s, want %s %s", limit.Kind, limit.Status, LimitKindSubteam, tt.wantStatus)
+				}
+			}
+			if !found {
+				t.Errorf("GetLimitStatus() = %+v, want the sub-team limit", status.Limits)
+			}
+		})
+	}
+}
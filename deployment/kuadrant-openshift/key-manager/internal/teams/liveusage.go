@@ -0,0 +1,303 @@
+package teams
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
+)
+
+// Scopes of a live counter: what its descriptor was keyed on
+const (
+	LiveScopeTeam     = "team"
+	LiveScopeUser     = "user"
+	LiveScopeKey      = "key"
+	LiveScopeSubteams = "subteams"
+)
+
+// LiveCounter is a Limitador counter attributed to a team
+type LiveCounter struct {
+	LimitName string `json:"limit_name"`
+	Scope     string `json:"scope"`
+	UserID    string `json:"user_id,omitempty"`
+	KeyName   string `json:"key_name,omitempty"`
+	// The key was deleted while its counter's window was still open
+	KeyDeleted      bool  `json:"key_deleted,omitempty"`
+	Limit           int64 `json:"limit"`
+	WindowSeconds   int64 `json:"window_seconds"`
+	Consumed        int64 `json:"consumed"`
+	Remaining       int64 `json:"remaining"`
+	ResetsInSeconds int64 `json:"resets_in_seconds"`
+	Exhausted       bool  `json:"exhausted"`
+}
+
+// TeamLiveUsage is the live counter state of a team, or of one of its keys
+type TeamLiveUsage struct {
+	TeamID    string        `json:"team_id"`
+	Counters  []LiveCounter `json:"counters"`
+	UpdatedAt string        `json:"updated_at,omitempty"`
+	// The last collection failed and the counters are from an earlier one
+	Stale            bool   `json:"stale,omitempty"`
+	UsageUnavailable bool   `json:"usage_unavailable,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
+
+// liveAttribution is the team, and user or key, a counter belongs to
+type liveAttribution struct {
+	teamID  string
+	scope   string
+	userID  string
+	keyName string
+}
+
+// LiveUsage periodically collects Limitador's counters and attributes them
+// to teams, users and keys. Counter descriptors only hold a team ID, user ID
+// or key hash, which are resolved through the key secrets. An attribution is
+// remembered for as long as its counter lives, so a key deleted mid-window
+// keeps its usage, and counters of limits that are not ours are skipped. A
+// nil LiveUsage has no counters.
+type LiveUsage struct {
+	teamMgr   *Manager
+	limitador *limitador.Client
+
+	mu           sync.RWMutex
+	byTeam       map[string][]LiveCounter
+	attributions map[string][]liveAttribution
+	updatedAt    time.Time
+	lastErr      error
+}
+
+// NewLiveUsage creates a live usage collector, returns nil if there is no
+// Limitador client
+func NewLiveUsage(teamMgr *Manager, limitadorClient *limitador.Client) *LiveUsage {
+	if limitadorClient == nil {
+		return nil
+	}
+	return &LiveUsage{
+		teamMgr:      teamMgr,
+		limitador:    limitadorClient,
+		byTeam:       make(map[string][]LiveCounter),
+		attributions: make(map[string][]liveAttribution),
+	}
+}
+
+// Start collects the counters now and then every interval in the background
+func (l *LiveUsage) Start(interval time.Duration) {
+	if l == nil {
+		return
+	}
+	if err := l.Refresh(); err != nil {
+		log.Printf("Warning: Live usage collection failed: %v", err)
+	}
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := l.Refresh(); err != nil {
+				log.Printf("Warning: Live usage collection failed: %v", err)
+			}
+		}
+	}()
+	log.Printf("Live usage collector started, interval %s", interval)
+}
+
+// Refresh collects the counters and replaces the aggregate. The previous
+// aggregate is kept when Limitador cannot be reached.
+func (l *LiveUsage) Refresh() error {
+	counters, err := l.limitador.GetCounters()
+	if err != nil {
+		l.mu.Lock()
+		l.lastErr = err
+		l.mu.Unlock()
+		return err
+	}
+
+	// Without the key secrets, counters keyed on a team ID and those
+	// attributed before can still be placed
+	keySecrets, _, err := l.teamMgr.listKeyAndMemberSecrets()
+	if err != nil {
+		log.Printf("Warning: Live usage cannot resolve keys: %v", err)
+	}
+	byHash := make(map[string]*corev1.Secret)
+	byUser := make(map[string][]*corev1.Secret)
+	for _, secret := range keySecrets {
+		if hash := secret.Labels["maas/key-sha256"]; hash != "" {
+			byHash[hash] = secret
+		}
+		if userID := secret.Labels["maas/user-id"]; userID != "" {
+			byUser[userID] = append(byUser[userID], secret)
+		}
+	}
+
+	l.mu.RLock()
+	previous := l.attributions
+	l.mu.RUnlock()
+
+	byTeam := make(map[string][]LiveCounter)
+	attributions := make(map[string][]liveAttribution)
+	for _, counter := range counters {
+		id := liveCounterID(counter)
+		attrs := attributeCounter(counter, byHash, byUser)
+		keyDeleted := false
+		if len(attrs) == 0 {
+			attrs = previous[id]
+			keyDeleted = len(attrs) > 0
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		attributions[id] = attrs
+
+		for _, attr := range attrs {
+			byTeam[attr.teamID] = append(byTeam[attr.teamID], LiveCounter{
+				LimitName:       counter.Limit.Name,
+				Scope:           attr.scope,
+				UserID:          attr.userID,
+				KeyName:         attr.keyName,
+				KeyDeleted:      keyDeleted && attr.keyName != "",
+				Limit:           counter.Limit.MaxValue,
+				WindowSeconds:   counter.Limit.Seconds,
+				Consumed:        counter.Limit.MaxValue - counter.Remaining,
+				Remaining:       counter.Remaining,
+				ResetsInSeconds: counter.ExpiresInSeconds,
+				Exhausted:       counter.Remaining <= 0,
+			})
+		}
+	}
+	for _, teamCounters := range byTeam {
+		sort.Slice(teamCounters, func(i, j int) bool {
+			a, b := teamCounters[i], teamCounters[j]
+			if a.LimitName != b.LimitName {
+				return a.LimitName < b.LimitName
+			}
+			if a.UserID != b.UserID {
+				return a.UserID < b.UserID
+			}
+			return a.KeyName < b.KeyName
+		})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.byTeam = byTeam
+	l.attributions = attributions
+	l.updatedAt = time.Now()
+	l.lastErr = nil
+	return nil
+}
+
+// attributeCounter places a counter by the descriptor it was keyed on. A
+// per-user counter belongs to each team the user has a key in whose policy,
+// or individual member limit, the counter was made for.
+func attributeCounter(counter limitador.Counter, byHash map[string]*corev1.Secret, byUser map[string][]*corev1.Secret) []liveAttribution {
+	vars := counter.SetVariables
+
+	if hash := vars[keyCounterExpression]; hash != "" {
+		secret, ok := byHash[hash]
+		if !ok {
+			return nil
+		}
+		return []liveAttribution{{
+			teamID:  secret.Labels["maas/team-id"],
+			scope:   LiveScopeKey,
+			userID:  secret.Labels["maas/user-id"],
+			keyName: secret.Name,
+		}}
+	}
+	if teamID := vars[teamCounterExpression]; teamID != "" {
+		return []liveAttribution{{teamID: teamID, scope: LiveScopeTeam}}
+	}
+	if parentID := vars[parentCounterExpression]; parentID != "" {
+		return []liveAttribution{{teamID: parentID, scope: LiveScopeSubteams}}
+	}
+
+	userID := vars[userCounterExpression]
+	if userID == "" {
+		userID = vars[userIDExpression]
+	}
+	if userID == "" {
+		return nil
+	}
+	var attrs []liveAttribution
+	seen := make(map[string]bool)
+	for _, secret := range byUser[userID] {
+		teamID := secret.Labels["maas/team-id"]
+		if teamID == "" || seen[teamID] {
+			continue
+		}
+		if limitador.MatchesLimit(counter.Limit, UserLimitName(teamID, userID)) ||
+			limitador.MatchesPolicy(counter.Limit, secret.Annotations["maas/policy"]) {
+			seen[teamID] = true
+			attrs = append(attrs, liveAttribution{teamID: teamID, scope: LiveScopeUser, userID: userID})
+		}
+	}
+	return attrs
+}
+
+// liveCounterID identifies a counter across collections by its limit and
+// descriptor values
+func liveCounterID(counter limitador.Counter) string {
+	vars := make([]string, 0, len(counter.SetVariables))
+	for name, value := range counter.SetVariables {
+		vars = append(vars, name+"="+value)
+	}
+	sort.Strings(vars)
+	return fmt.Sprintf("%s/%s/%s", counter.Limit.Namespace, counter.Limit.Name, strings.Join(vars, ","))
+}
+
+// Team returns the live counters of a team
+func (l *LiveUsage) Team(teamID string) *TeamLiveUsage {
+	return l.snapshot(teamID, func(LiveCounter) bool { return true })
+}
+
+// Key returns the live counters of one key: those keyed on it and on its
+// owner in its team
+func (l *LiveUsage) Key(teamID, keyName, userID string) *TeamLiveUsage {
+	return l.snapshot(teamID, func(counter LiveCounter) bool {
+		return counter.KeyName == keyName || (counter.Scope == LiveScopeUser && counter.UserID == userID)
+	})
+}
+
+// snapshot copies the counters of a team accepted by keep
+func (l *LiveUsage) snapshot(teamID string, keep func(LiveCounter) bool) *TeamLiveUsage {
+	usage := &TeamLiveUsage{TeamID: teamID, Counters: []LiveCounter{}}
+	if l == nil {
+		usage.UsageUnavailable = true
+		usage.Reason = "LIMITADOR_URL is not configured"
+		return usage
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.updatedAt.IsZero() {
+		usage.UsageUnavailable = true
+		usage.Reason = "counters have not been collected yet"
+		if l.lastErr != nil {
+			usage.Reason = "limitador is unreachable"
+		}
+		return usage
+	}
+
+	for _, counter := range l.byTeam[teamID] {
+		if keep(counter) {
+			usage.Counters = append(usage.Counters, counter)
+		}
+	}
+	usage.UpdatedAt = l.updatedAt.UTC().Format(time.RFC3339)
+	if l.lastErr != nil {
+		usage.Stale = true
+		usage.Reason = "limitador is unreachable; counters are from the last successful collection"
+	}
+	return usage
+}
@@ -4,69 +4,232 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
 )
 
 // Manager handles team operations
 type Manager struct {
-	clientset    *kubernetes.Clientset
+	clientset    kubernetes.Interface
 	keyNamespace string
-	policyMgr    *PolicyManager
+	policyMgr    PolicyEngine
+	crdStore     *CRDStore
+	events       *events.Recorder
+	webhooks     *webhook.Dispatcher
+	// Create missing team key namespaces instead of rejecting the team
+	autoCreateNamespaces bool
+	// Accept team webhooks without a signing secret
+	allowUnsignedWebhooks bool
+	// RoleBindings maintained in team key namespaces, may be nil
+	namespaceAccess *KeyNamespaceAccess
+	// Teams created asynchronously waiting for their policies
+	provisioning chan *provisioningJob
+	// Key and member secrets of the shared key namespace, may be nil
+	secretCache *SecretCache
+	// Outcome of the last policy reconciliation
+	reconcileMu   sync.Mutex
+	lastReconcile *ComplianceReport
 }
 
-// NewManager creates a new team manager
-func NewManager(clientset *kubernetes.Clientset, keyNamespace string, policyMgr *PolicyManager) *Manager {
+// NewManager creates a new team manager. policyMgr may be nil to manage
+// teams without policies, crdStore may be nil to keep teams in config
+// secrets only, recorder may be nil to disable events and secretCache may be
+// nil to always list secrets directly. namespaceAccess may be nil to leave
+// access to team key namespaces to the operator.
+// Team-scoped notifications go to webhooks as well as each team's own webhook.
+func NewManager(clientset kubernetes.Interface, keyNamespace string, policyMgr PolicyEngine, crdStore *CRDStore, recorder *events.Recorder, webhooks *webhook.Dispatcher, autoCreateNamespaces, allowUnsignedWebhooks bool, namespaceAccess *KeyNamespaceAccess, secretCache *SecretCache) *Manager {
+	// A nil *PolicyManager is a non-nil PolicyEngine and would pass every
+	// policyMgr != nil check
+	if p, ok := policyMgr.(*PolicyManager); ok && p == nil {
+		policyMgr = nil
+	}
 	return &Manager{
 		clientset:    clientset,
 		keyNamespace: keyNamespace,
 		policyMgr:    policyMgr,
+		crdStore:     crdStore,
+		events:       recorder,
+		webhooks:     webhooks,
+
+		autoCreateNamespaces:  autoCreateNamespaces,
+		allowUnsignedWebhooks: allowUnsignedWebhooks,
+		namespaceAccess:       namespaceAccess,
+		provisioning:          make(chan *provisioningJob, provisioningQueueSize),
+		secretCache:           secretCache,
 	}
 }
 
 // Create creates a new team with policy integration
 func (m *Manager) Create(req *CreateTeamRequest) error {
+	plan, sharedPolicy, err := m.prepareTeam(req, "")
+	if err != nil {
+		return err
+	}
+
+	if step, err := m.applyTeamPolicies(plan, req, sharedPolicy); err != nil {
+		return m.rollback(plan, step, err)
+	}
+
+	m.events.Team(req.TeamID, corev1.EventTypeNormal, events.ReasonTeamCreated,
+		"Team %s created with policy %s", req.TeamID, req.Policy)
+	m.registerOwner(req)
+	m.syncTeamCRByID(req.TeamID)
+
+	log.Printf("Team %s created with policy reference: %s", req.TeamID, req.Policy)
+	return nil
+}
+
+// prepareTeam validates a new team and creates its key namespace and config
+// secret. The returned plan holds what was created, and sharedPolicy reports
+// whether other teams already use the team's policy.
+func (m *Manager) prepareTeam(req *CreateTeamRequest, provisioningStatus string) (*provisioningPlan, bool, error) {
 	// Validate team data
 	if err := m.validateTeamRequest(req); err != nil {
-		return fmt.Errorf("team validation failed: %w", err)
+		return nil, false, fmt.Errorf("team validation failed: %w", err)
 	}
 
 	// Check if team already exists
 	existingSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
 		context.Background(), fmt.Sprintf("team-%s-config", req.TeamID), metav1.GetOptions{})
 	if err == nil && existingSecret != nil {
-		return fmt.Errorf("team %s already exists", req.TeamID)
+		return nil, false, fmt.Errorf("team %s already exists", req.TeamID)
+	}
+	if req.ParentTeamID != "" {
+		if err := m.validateParent(req.ParentTeamID); err != nil {
+			return nil, false, fmt.Errorf("team validation failed: %w", err)
+		}
+	}
+
+	// Every resource created from here on is rolled back if a later step fails
+	plan := &provisioningPlan{teamID: req.TeamID}
+
+	// Teams with their own namespace keep their key secrets there
+	if req.Namespace != "" && req.Namespace != m.keyNamespace {
+		created, err := m.ensureKeyNamespace(req.Namespace)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to prepare key namespace: %w", err)
+		}
+		if created {
+			plan.created("Namespace", req.Namespace, func() error {
+				return m.clientset.CoreV1().Namespaces().Delete(
+					context.Background(), req.Namespace, metav1.DeleteOptions{})
+			})
+		}
+		if err := m.ensureKeyNamespaceAccess(req.Namespace); err != nil {
+			return nil, false, m.rollback(plan, "KeyNamespaceAccess", fmt.Errorf("failed to prepare key namespace: %w", err))
+		}
 	}
 
+	// Policy entries already used by other teams are shared, so a failed
+	// creation must leave them in place
+	sharedPolicy := m.policyMgr != nil && m.policyInUse(req.Policy)
+
 	// Create team configuration secret
-	_, err = m.createTeamConfigSecret(req)
+	teamSecret, err := m.createTeamConfigSecret(req, provisioningStatus)
 	if err != nil {
-		return fmt.Errorf("failed to create team secret: %w", err)
+		return nil, false, m.rollback(plan, "TeamConfig", fmt.Errorf("failed to create team secret: %w", err))
 	}
+	plan.created("TeamConfig", teamSecret.Name, func() error {
+		return m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(
+			context.Background(), teamSecret.Name, metav1.DeleteOptions{})
+	})
 
-	// Update policies via PolicyManager
-	if m.policyMgr != nil {
-		err = m.policyMgr.AddTeamToAuthPolicy(req.Policy)
-		if err != nil {
-			log.Printf("Warning: Failed to update AuthPolicy for team %s: %v", req.TeamID, err)
+	return plan, sharedPolicy, nil
+}
+
+// applyTeamPolicies adds a new team's group and limit to the Kuadrant
+// policies, recording the entries it created on the plan. On failure it
+// returns the step that failed.
+func (m *Manager) applyTeamPolicies(plan *provisioningPlan, req *CreateTeamRequest, sharedPolicy bool) (string, error) {
+	if m.policyMgr == nil {
+		return "", nil
+	}
+
+	err := m.policyMgr.AddTeamToAuthPolicy(req.Policy)
+	if err != nil {
+		m.recordPolicyResult(req.Policy, err)
+		return "AuthPolicy", fmt.Errorf("failed to update AuthPolicy: %w", err)
+	}
+	if !sharedPolicy {
+		plan.created("AuthPolicyGroup", req.Policy, func() error {
+			return m.policyMgr.RemoveTeamFromAuthPolicy(req.Policy)
+		})
+	}
+
+	// Teams on a custom tier get its limits unless they set their own
+	tokenLimit, timeWindow, scope, teamTokenLimit := req.TokenLimit, req.TimeWindow, req.LimitScope, req.TeamTokenLimit
+	if definition, ok := m.customTier(req.Policy); ok {
+		if tokenLimit == 0 {
+			tokenLimit = definition.TokenLimit
 		}
+		if timeWindow == "" {
+			timeWindow = definition.TimeWindow
+		}
+		if scope == "" {
+			scope = definition.LimitScope
+		}
+		if teamTokenLimit == 0 {
+			teamTokenLimit = definition.TeamTokenLimit
+		}
+	}
 
-		err = m.policyMgr.AddTeamToTokenRateLimit(req.Policy, req.TokenLimit, req.TimeWindow)
-		if err != nil {
-			log.Printf("Warning: Failed to update TokenRateLimitPolicy for team %s: %v", req.TeamID, err)
+	err = m.policyMgr.AddTeamToTokenRateLimit(req.Policy, tokenLimit, timeWindow, scope, teamTokenLimit)
+	if err != nil {
+		m.recordPolicyResult(req.Policy, err)
+		return "TokenRateLimitPolicy", fmt.Errorf("failed to update TokenRateLimitPolicy: %w", err)
+	}
+	m.recordPolicyResult(req.Policy, nil)
+
+	if len(req.ModelLimits) > 0 {
+		if err := m.policyMgr.SetModelLimits(req.Policy, req.ModelLimits); err != nil {
+			return "ModelLimits", fmt.Errorf("failed to set model limits: %w", err)
 		}
+	}
 
-		err = m.policyMgr.RestartKuadrantComponents()
+	// Sub-teams share their parent's limit on top of their own
+	if req.ParentTeamID != "" {
+		created, err := m.applySubteamLimit(req.ParentTeamID)
 		if err != nil {
-			log.Printf("Warning: Failed to restart Kuadrant components for team %s: %v", req.TeamID, err)
+			return "SubteamLimit", err
+		}
+		if created {
+			plan.created("TokenRateLimitPolicyLimit", SubteamLimitName(req.ParentTeamID), func() error {
+				return m.policyMgr.RemoveSubteamLimit(req.ParentTeamID)
+			})
 		}
 	}
 
-	log.Printf("Team %s created with policy reference: %s", req.TeamID, req.Policy)
-	return nil
+	err = m.policyMgr.RestartKuadrantComponents()
+	if err != nil {
+		log.Printf("Warning: Failed to restart Kuadrant components for team %s: %v", req.TeamID, err)
+	}
+	return "", nil
+}
+
+// registerOwner adds a new team's owner as a team admin, so ownership can be
+// checked and transferred
+func (m *Manager) registerOwner(req *CreateTeamRequest) {
+	if req.OwnerUserID == "" {
+		return
+	}
+	_, err := m.AddMember(req.TeamID, &AddUserToTeamRequest{
+		UserID:    req.OwnerUserID,
+		UserEmail: req.OwnerEmail,
+		Role:      "admin",
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to register owner %s of team %s: %v", req.OwnerUserID, req.TeamID, err)
+	}
 }
 
 // Get retrieves team details
@@ -75,11 +238,25 @@ func (m *Manager) Get(teamID string) (*GetTeamResponse, error) {
 	teamSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
 		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
 	if err != nil {
+		// Teams defined only as a MaaSTeam have not been provisioned yet
+		if team, crErr := m.crdStore.Get(teamID); crErr == nil {
+			summary := teamFromCR(team)
+			return &GetTeamResponse{
+				TeamID:      teamID,
+				Status:      summary["status"].(string),
+				TeamName:    summary["team_name"].(string),
+				Description: summary["description"].(string),
+				Policy:      summary["policy"].(string),
+				Members:     []TeamMember{},
+				Keys:        []string{},
+				CreatedAt:   summary["created_at"].(string),
+			}, nil
+		}
 		return nil, fmt.Errorf("team not found: %w", err)
 	}
 
-	// Get team members from API keys
-	members, err := m.getTeamMembersFromAPIKeys(teamID)
+	// Get registered team members and those inferred from API keys
+	members, err := m.ListMembers(teamID)
 	if err != nil {
 		log.Printf("Failed to get team members: %v", err)
 		members = []TeamMember{}
@@ -92,19 +269,51 @@ func (m *Manager) Get(teamID string) (*GetTeamResponse, error) {
 		keys = []string{}
 	}
 
+	status := teamSecret.Annotations[annotationTeamStatus]
+	if status == "" {
+		status = "active"
+	}
+
+	subteams, err := m.listSubteams(teamID)
+	if err != nil {
+		log.Printf("Failed to get sub-teams: %v", err)
+	}
+
+	// Show which counters the team's limits are keyed on
+	var limitScope string
+	var teamTokenLimit int
+	if m.policyMgr != nil {
+		limitScope, teamTokenLimit, _ = m.policyMgr.GetPolicyLimitScope(teamSecret.Annotations["maas/policy"])
+	}
+
 	return &GetTeamResponse{
-		TeamID:      teamID,
-		TeamName:    teamSecret.Annotations["maas/team-name"],
-		Description: teamSecret.Annotations["maas/description"],
-		Policy:      teamSecret.Annotations["maas/policy"],
-		Members:     members,
-		Keys:        keys,
-		CreatedAt:   teamSecret.Annotations["maas/created-at"],
+		TeamID:         teamID,
+		Status:         status,
+		TeamName:       teamSecret.Annotations["maas/team-name"],
+		Description:    teamSecret.Annotations["maas/description"],
+		Policy:         teamSecret.Annotations["maas/policy"],
+		Members:        members,
+		Keys:           keys,
+		CreatedAt:      teamSecret.Annotations["maas/created-at"],
+		Budget:         BudgetStatusFromAnnotations(teamSecret.Annotations),
+		LimitScope:     limitScope,
+		TeamTokenLimit: teamTokenLimit,
+		WebhookURL:     teamSecret.Annotations[annotationWebhookURL],
+		KeyNamespace:   m.keyNamespaceOf(teamSecret),
+		PolicyStatus:   m.policyStatus(teamID, teamSecret.Annotations["maas/policy"]),
+		OwnerUserID:    teamSecret.Annotations[annotationOwnerUserID],
+		OwnerEmail:     teamSecret.Annotations[annotationOwnerEmail],
+
+		ProvisioningStatus: teamSecret.Annotations[annotationProvisioningStatus],
+		ParentTeamID:       teamSecret.Labels[LabelParentTeamID],
+		SubTeams:           subteams,
+		DeletionProtected:  deletionProtected(teamSecret),
 	}, nil
 }
 
-// List retrieves all teams
-func (m *Manager) List() ([]map[string]interface{}, error) {
+// List retrieves teams matching the given filters, sorted as requested.
+// Key and member counts are computed from a single list of each resource.
+func (m *Manager) List(opts *ListTeamsOptions) ([]map[string]interface{}, error) {
 	labelSelector := "maas/resource-type=team-config"
 	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
 		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
@@ -112,181 +321,607 @@ func (m *Manager) List() ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to list team secrets: %w", err)
 	}
 
+	keyCounts, memberCounts, err := m.countTeamKeysAndMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	nameFilter := strings.ToLower(opts.NameContains)
+	matches := func(team map[string]interface{}) bool {
+		teamID, teamName := team["team_id"].(string), team["team_name"].(string)
+		if team["status"] == teamStatusArchived && !opts.IncludeArchived {
+			return false
+		}
+		if opts.Tier != "" && team["policy"] != opts.Tier {
+			return false
+		}
+		return nameFilter == "" || strings.Contains(strings.ToLower(teamName), nameFilter) ||
+			strings.Contains(teamID, nameFilter)
+	}
+
 	teams := make([]map[string]interface{}, 0)
+	seen := make(map[string]bool)
 	for _, secret := range secrets.Items {
 		teamID := secret.Labels["maas/team-id"]
-		
-		// Get team key count
-		keyCount := 0
-		userCount := 0
-		if keys, err := m.getTeamAPIKeys(teamID); err == nil {
-			keyCount = len(keys)
-		}
-		if members, err := m.getTeamMembersFromAPIKeys(teamID); err == nil {
-			userCount = len(members)
+		seen[teamID] = true
+		status := secret.Annotations[annotationTeamStatus]
+		if status == "" {
+			status = "active"
 		}
 
 		team := map[string]interface{}{
-			"team_id":    secret.Labels["maas/team-id"],
-			"team_name":  secret.Annotations["maas/team-name"],
-			"description": secret.Annotations["maas/description"],
-			"policy":     secret.Annotations["maas/policy"],
-			"created_at": secret.Annotations["maas/created-at"],
-			"key_count":  keyCount,
-			"user_count": userCount,
+			"team_id":        teamID,
+			"team_name":      secret.Annotations["maas/team-name"],
+			"description":    secret.Annotations["maas/description"],
+			"policy":         secret.Annotations["maas/policy"],
+			"created_at":     secret.Annotations["maas/created-at"],
+			"key_count":      keyCounts[teamID],
+			"member_count":   memberCounts[teamID],
+			"user_count":     memberCounts[teamID], // kept for existing clients
+			"status":         status,
+			"owner_user_id":  secret.Annotations[annotationOwnerUserID],
+			"owner_email":    secret.Annotations[annotationOwnerEmail],
+			"parent_team_id": secret.Labels[LabelParentTeamID],
+		}
+		if matches(team) {
+			teams = append(teams, team)
 		}
-		teams = append(teams, team)
+	}
+
+	// Include teams defined only as a MaaSTeam
+	crTeams, err := m.crdStore.List()
+	if err != nil {
+		log.Printf("Warning: Failed to list MaaSTeams: %v", err)
+	}
+	for i := range crTeams {
+		if team := teamFromCR(&crTeams[i]); !seen[crTeams[i].GetName()] && matches(team) {
+			teams = append(teams, team)
+		}
+	}
+
+	sortField := "team_name"
+	if opts.Sort == TeamSortCreatedAt {
+		sortField = "created_at"
+	}
+	if opts.Sort != "" {
+		sort.SliceStable(teams, func(i, j int) bool {
+			a, b := teams[i][sortField].(string), teams[j][sortField].(string)
+			if opts.Descending {
+				return a > b
+			}
+			return a < b
+		})
 	}
 
 	return teams, nil
 }
 
+// countTeamKeysAndMembers counts keys and distinct members per team, merging
+// membership records with users that only hold keys
+func (m *Manager) countTeamKeysAndMembers() (map[string]int, map[string]int, error) {
+	keySecrets, memberSecrets, err := m.listKeyAndMemberSecrets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyCounts := make(map[string]int)
+	members := make(map[string]map[string]bool)
+	addMember := func(teamID, userID string) {
+		if teamID == "" || userID == "" {
+			return
+		}
+		if members[teamID] == nil {
+			members[teamID] = make(map[string]bool)
+		}
+		members[teamID][userID] = true
+	}
+
+	for _, secret := range keySecrets {
+		teamID := secret.Labels["maas/team-id"]
+		keyCounts[teamID]++
+		addMember(teamID, secret.Labels["maas/user-id"])
+	}
+	for _, secret := range memberSecrets {
+		addMember(secret.Labels["maas/team-id"], secret.Labels["maas/user-id"])
+	}
+
+	memberCounts := make(map[string]int, len(members))
+	for teamID, users := range members {
+		memberCounts[teamID] = len(users)
+	}
+
+	return keyCounts, memberCounts, nil
+}
+
 // Update performs partial updates on team configuration
-func (m *Manager) Update(teamID string, req *UpdateTeamRequest) error {
+func (m *Manager) Update(teamID string, req *UpdateTeamRequest) (*UpdateTeamResponse, error) {
 	// Get current team config secret
 	teamSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
 		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("team not found: %w", err)
+		return nil, fmt.Errorf("team not found: %w", err)
 	}
 
 	// Store original policy for comparison
 	originalPolicy := teamSecret.Annotations["maas/policy"]
+	policyChanged := req.Policy != nil && *req.Policy != originalPolicy
+
+	// Validate new policy exists before anything is written
+	if policyChanged && m.policyMgr != nil && !m.policyMgr.PolicyExists(*req.Policy) {
+		return nil, fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", *req.Policy)
+	}
+	if req.WebhookURL != nil && *req.WebhookURL != "" {
+		if err := webhook.ValidateURL(*req.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateModelLimits(req.ModelLimits); err != nil {
+		return nil, err
+	}
+	if req.TokenLimit != nil {
+		if err := ValidateTokenLimit("token_limit", *req.TokenLimit); err != nil {
+			return nil, err
+		}
+	}
+	if req.TimeWindow != nil {
+		if err := ValidateTimeWindow("time_window", *req.TimeWindow); err != nil {
+			return nil, err
+		}
+	}
+	modelLimitsChanged := false
+
+	response := &UpdateTeamResponse{
+		TeamID:        teamID,
+		ChangedFields: make([]string, 0),
+	}
 
-	// Update annotations with new values (only if provided)
+	// Update annotations with new values (only if provided and different)
+	setAnnotation := func(field, annotation, value string) {
+		if teamSecret.Annotations[annotation] != value {
+			teamSecret.Annotations[annotation] = value
+			response.ChangedFields = append(response.ChangedFields, field)
+		}
+	}
 	if req.TeamName != nil {
-		teamSecret.Annotations["maas/team-name"] = *req.TeamName
+		setAnnotation("team_name", "maas/team-name", *req.TeamName)
 	}
 	if req.Description != nil {
-		teamSecret.Annotations["maas/description"] = *req.Description
+		setAnnotation("description", "maas/description", *req.Description)
 	}
 	if req.Policy != nil {
-		teamSecret.Annotations["maas/policy"] = *req.Policy
+		setAnnotation("policy", "maas/policy", *req.Policy)
+	}
+	if req.MaxKeysPerUser != nil {
+		setAnnotation("max_keys_per_user", "maas/max-keys-per-user", strconv.Itoa(*req.MaxKeysPerUser))
+	}
+	if req.MaxKeysPerTeam != nil {
+		setAnnotation("max_keys_per_team", "maas/max-keys-per-team", strconv.Itoa(*req.MaxKeysPerTeam))
+	}
+	if req.BudgetUSDMonthly != nil {
+		setAnnotation("budget_usd_monthly", annotationBudget, strconv.FormatFloat(*req.BudgetUSDMonthly, 'f', -1, 64))
+		if teamSecret.Annotations[annotationPeriodStart] == "" {
+			teamSecret.Annotations[annotationPeriodStart] = time.Now().Format(time.RFC3339)
+		}
+	}
+	response.ChangedFields = append(response.ChangedFields, setTeamWebhook(teamSecret, req.WebhookURL, req.WebhookSecret)...)
+	if req.WebhookURL != nil || req.WebhookSecret != nil {
+		if err := webhook.ValidateSecret(teamSecret.Annotations[annotationWebhookURL],
+			string(teamSecret.Data[webhookSecretKey]), m.allowUnsignedWebhooks); err != nil {
+			return nil, err
+		}
+	}
+	response.ChangedFields = append(response.ChangedFields, setDeletionProtection(teamSecret, req.DeletionProtected)...)
+	if req.ModelLimits != nil {
+		previous := teamSecret.Annotations[annotationModelLimits]
+		setModelLimitsAnnotation(teamSecret.Annotations, req.ModelLimits)
+		if teamSecret.Annotations[annotationModelLimits] != previous {
+			modelLimitsChanged = true
+			response.ChangedFields = append(response.ChangedFields, "model_limits")
+		}
 	}
 
 	// Update team secret
-	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
-		context.Background(), teamSecret, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to update team: %w", err)
+	if len(response.ChangedFields) > 0 {
+		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), teamSecret, metav1.UpdateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update team: %w", err)
+		}
 	}
 
 	// Handle policy changes via PolicyManager
 	if m.policyMgr != nil {
-		if req.Policy != nil && *req.Policy != originalPolicy {
-			// Validate new policy exists
-			if !m.policyMgr.PolicyExists(*req.Policy) {
-				return fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", *req.Policy)
+		if policyChanged {
+			if err := m.switchPolicy(originalPolicy, *req.Policy); err != nil {
+				return nil, err
+			}
+			m.events.Team(teamID, corev1.EventTypeNormal, events.ReasonTierChanged,
+				"Team %s moved from tier %s to %s", teamID, originalPolicy, *req.Policy)
+			response.PoliciesResynced = true
+		} else if (req.TokenLimit != nil || req.TimeWindow != nil) && originalPolicy != "" {
+			// Update token limits for existing policy
+			currentTokenLimit, currentTimeWindow, err := m.tierLimits(originalPolicy)
+			if err != nil {
+				return nil, fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", originalPolicy)
 			}
 
-			// Remove old policy
-			if originalPolicy != "" {
-				err = m.policyMgr.RemoveTeamFromAuthPolicy(originalPolicy)
+			// Use existing values as defaults, override only what's specified
+			tokenLimit := currentTokenLimit
+			timeWindow := currentTimeWindow
+
+			if req.TokenLimit != nil && *req.TokenLimit != currentTokenLimit {
+				tokenLimit = *req.TokenLimit
+				response.ChangedFields = append(response.ChangedFields, "token_limit")
+			}
+			if req.TimeWindow != nil && *req.TimeWindow != currentTimeWindow {
+				timeWindow = *req.TimeWindow
+				response.ChangedFields = append(response.ChangedFields, "time_window")
+			}
+
+			if tokenLimit != currentTokenLimit || timeWindow != currentTimeWindow {
+				err = m.policyMgr.AddTeamToTokenRateLimit(originalPolicy, tokenLimit, timeWindow, "", 0)
 				if err != nil {
-					log.Printf("Warning: Failed to remove old AuthPolicy group %s: %v", originalPolicy, err)
+					log.Printf("Warning: Failed to update TokenRateLimitPolicy limits: %v", err)
+				} else {
+					response.PoliciesResynced = true
 				}
+				m.recordPolicyResult(originalPolicy, err)
 
-				err = m.policyMgr.RemoveTeamFromTokenRateLimit(originalPolicy)
+				err = m.policyMgr.RestartKuadrantComponents()
 				if err != nil {
-					log.Printf("Warning: Failed to remove old TokenRateLimitPolicy group %s: %v", originalPolicy, err)
+					log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
 				}
 			}
+		}
 
-			// Add new policy
-			existingTokenLimit, existingTimeWindow, err := m.policyMgr.GetPolicyLimits(*req.Policy)
+		// Model limits follow the team to a new policy
+		policy := teamSecret.Annotations["maas/policy"]
+		if modelLimitsChanged && policy != "" {
+			err = m.policyMgr.SetModelLimits(policy, req.ModelLimits)
 			if err != nil {
-				return fmt.Errorf("failed to get policy limits: %w", err)
+				log.Printf("Warning: Failed to update model limits for policy %s: %v", policy, err)
+			} else {
+				response.PoliciesResynced = true
+				if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+					log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+				}
 			}
-
-			err = m.policyMgr.AddTeamToAuthPolicy(*req.Policy)
-			if err != nil {
-				log.Printf("Warning: Failed to update AuthPolicy for new policy %s: %v", *req.Policy, err)
+			m.recordPolicyResult(policy, err)
+		} else if policyChanged {
+			if err := m.applyStoredModelLimits(teamSecret, policy); err != nil {
+				log.Printf("Warning: Failed to move model limits to policy %s: %v", policy, err)
 			}
+		}
+	}
 
-			err = m.policyMgr.AddTeamToTokenRateLimit(*req.Policy, existingTokenLimit, existingTimeWindow)
-			if err != nil {
-				log.Printf("Warning: Failed to update TokenRateLimitPolicy for new policy %s: %v", *req.Policy, err)
-			}
+	if response.PoliciesResynced {
+		m.notifyPolicyResynced(teamID, teamSecret.Annotations["maas/policy"])
+		m.refreshSubteamLimit(teamID)
+	}
 
-			// Restart components and update team keys
-			err = m.policyMgr.RestartKuadrantComponents()
-			if err != nil {
-				log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
-			}
+	// Keys carry the team name and tier, so refresh them after either changes
+	if policyChanged || containsString(response.ChangedFields, "team_name") {
+		response.KeyMetadata, err = m.PropagateMetadata(teamID)
+		if err != nil {
+			log.Printf("Warning: Failed to propagate metadata to team keys: %v", err)
+		}
+	}
 
-			err = m.updateTeamKeysPolicy(teamID, *req.Policy)
-			if err != nil {
-				log.Printf("Warning: Failed to update team keys policy: %v", err)
-			}
-		} else if (req.TokenLimit != nil || req.TimeWindow != nil) && originalPolicy != "" {
-			// Update token limits for existing policy
-			currentTokenLimit, currentTimeWindow, err := m.policyMgr.GetPolicyLimits(originalPolicy)
-			if err != nil {
-				return fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", originalPolicy)
-			}
+	m.syncTeamCRByID(teamID)
 
-			// Use existing values as defaults, override only what's specified
-			tokenLimit := currentTokenLimit
-			timeWindow := currentTimeWindow
-			
-			if req.TokenLimit != nil {
-				tokenLimit = *req.TokenLimit
+	log.Printf("Team %s updated successfully, changed fields: %v", teamID, response.ChangedFields)
+	return response, nil
+}
+
+// ChangeTier moves a team to a different policy tier. When propagate is set
+// every existing team key is re-labelled so Authorino groups stay accurate.
+func (m *Manager) ChangeTier(teamID string, req *ChangeTierRequest) (*ChangeTierResponse, error) {
+	teamSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
+		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("team not found: %w", err)
+	}
+
+	if m.policyMgr != nil && !m.policyMgr.PolicyExists(req.Tier) {
+		return nil, fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", req.Tier)
+	}
+
+	response := &ChangeTierResponse{
+		TeamID:       teamID,
+		PreviousTier: teamSecret.Annotations["maas/policy"],
+		Tier:         req.Tier,
+		KeyFailures:  make([]KeyUpdateFailure, 0),
+		Pruned:       make([]string, 0),
+	}
+
+	if response.PreviousTier != req.Tier {
+		teamSecret.Annotations["maas/policy"] = req.Tier
+		delete(teamSecret.Annotations, annotationUnlimitedMode)
+		if isUnlimitedPolicy(req.Tier) && m.policyMgr != nil {
+			teamSecret.Annotations[annotationUnlimitedMode] = m.policyMgr.UnlimitedMode()
+		}
+		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), teamSecret, metav1.UpdateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update team: %w", err)
+		}
+
+		if m.policyMgr != nil {
+			if err := m.switchPolicy(response.PreviousTier, req.Tier); err != nil {
+				return nil, err
 			}
-			if req.TimeWindow != nil {
-				timeWindow = *req.TimeWindow
+			if err := m.applyStoredModelLimits(teamSecret, req.Tier); err != nil {
+				log.Printf("Warning: Failed to move model limits to policy %s: %v", req.Tier, err)
 			}
-
-			err = m.policyMgr.AddTeamToTokenRateLimit(originalPolicy, tokenLimit, timeWindow)
-			if err != nil {
-				log.Printf("Warning: Failed to update TokenRateLimitPolicy limits: %v", err)
+			// A tier other teams still use keeps only their model limits
+			if response.PreviousTier != "" && m.policyMgr.PolicyExists(response.PreviousTier) {
+				pruned, err := m.refreshPolicyModelLimits(response.PreviousTier)
+				if err != nil {
+					log.Printf("Warning: Failed to prune model limits of policy %s: %v", response.PreviousTier, err)
+				}
+				response.Pruned = append(response.Pruned, pruned...)
 			}
+			response.Pruned = append(response.Pruned, m.syncMemberLimits(teamID, req.Tier)...)
+			response.PoliciesResynced = true
+		}
+		m.events.Team(teamID, corev1.EventTypeNormal, events.ReasonTierChanged,
+			"Team %s moved from tier %s to %s", teamID, response.PreviousTier, req.Tier)
+		if response.PoliciesResynced {
+			m.notifyPolicyResynced(teamID, req.Tier)
+			m.refreshSubteamLimit(teamID)
+		}
+	}
 
-			err = m.policyMgr.RestartKuadrantComponents()
-			if err != nil {
-				log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
-			}
+	// Propagation also repairs keys left behind by an earlier change
+	if req.Propagate {
+		propagated, err := m.PropagateMetadata(teamID)
+		if err != nil {
+			return nil, err
+		}
+		response.KeysUpdated, response.KeyFailures = propagated.Updated, propagated.Failures
+	}
+
+	m.syncTeamCRByID(teamID)
+
+	log.Printf("Team %s moved from tier %s to %s, %d keys updated",
+		teamID, response.PreviousTier, req.Tier, response.KeysUpdated)
+	return response, nil
+}
+
+// switchPolicy moves a team's policy groups from oldPolicy to newPolicy and
+// restarts Kuadrant so the change is enforced
+func (m *Manager) switchPolicy(oldPolicy, newPolicy string) error {
+	// Remove old policy, unless other teams still use it
+	if oldPolicy != "" && !m.policyInUse(oldPolicy) {
+		err := m.policyMgr.RemoveTeamFromAuthPolicy(oldPolicy)
+		if err != nil {
+			log.Printf("Warning: Failed to remove old AuthPolicy group %s: %v", oldPolicy, err)
+		}
+
+		err = m.policyMgr.RemoveTeamFromTokenRateLimit(oldPolicy)
+		if err != nil {
+			log.Printf("Warning: Failed to remove old TokenRateLimitPolicy group %s: %v", oldPolicy, err)
 		}
 	}
 
-	log.Printf("Team %s updated successfully", teamID)
+	// Add new policy
+	existingTokenLimit, existingTimeWindow, err := m.tierLimits(newPolicy)
+	if err != nil && !isUnlimitedPolicy(newPolicy) {
+		return fmt.Errorf("failed to get policy limits: %w", err)
+	}
+
+	var policyErr error
+	err = m.policyMgr.AddTeamToAuthPolicy(newPolicy)
+	if err != nil {
+		log.Printf("Warning: Failed to update AuthPolicy for new policy %s: %v", newPolicy, err)
+		policyErr = err
+	}
+
+	err = m.policyMgr.AddTeamToTokenRateLimit(newPolicy, existingTokenLimit, existingTimeWindow, "", 0)
+	if err != nil {
+		log.Printf("Warning: Failed to update TokenRateLimitPolicy for new policy %s: %v", newPolicy, err)
+		policyErr = err
+	}
+	m.recordPolicyResult(newPolicy, policyErr)
+
+	err = m.policyMgr.RestartKuadrantComponents()
+	if err != nil {
+		log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+	}
+
 	return nil
 }
 
-// Delete removes team and all associated resources
-func (m *Manager) Delete(teamID string) error {
+// recordPolicyResult records whether a policy was applied to the gateway
+func (m *Manager) recordPolicyResult(policy string, err error) {
+	if err != nil {
+		m.events.Gateway(corev1.EventTypeWarning, events.ReasonPolicyFailed,
+			"Failed to apply policy %s: %v", policy, err)
+		return
+	}
+	m.events.Gateway(corev1.EventTypeNormal, events.ReasonPoliciesApplied, "Applied policy %s", policy)
+}
+
+// policyInUse reports whether any team is still configured with a policy.
+// Custom tiers stay in use without teams.
+func (m *Manager) policyInUse(policy string) bool {
+	if _, ok := m.customTier(policy); ok {
+		return true
+	}
+
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		// Assume it is in use rather than risk removing shared limits
+		log.Printf("Warning: Failed to check usage of policy %s: %v", policy, err)
+		return true
+	}
+
+	for _, secret := range secrets.Items {
+		if secret.Annotations["maas/policy"] == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete removes team and all associated resources. With dryRun set every
+// lookup is performed but nothing is changed, and the result is the plan.
+func (m *Manager) Delete(teamID string, dryRun bool) (*DeleteTeamResult, error) {
 	// Check if team exists
 	teamSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
 		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("team not found: %w", err)
+		return nil, fmt.Errorf("team not found: %w", err)
+	}
+
+	if deletionProtected(teamSecret) {
+		return nil, fmt.Errorf("team %s is deletion-protected, set deletion_protected to false first", teamID)
 	}
 
 	// Get team policy before deletion for cleanup
 	teamPolicy := teamSecret.Annotations["maas/policy"]
 
+	// Sub-teams rely on their parent's limit, so they must be deleted first
+	subteams, err := m.listSubteams(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subteams) > 0 {
+		return nil, fmt.Errorf("team %s has %d sub-teams, delete them first", teamID, len(subteams))
+	}
+	parentID := teamSecret.Labels[LabelParentTeamID]
+
+	// Collect everything that belongs to the team
+	keyNamespace := m.keyNamespaceOf(teamSecret)
+	keys, err := m.listTeamSecrets(keyNamespace, fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team keys: %w", err)
+	}
+	members, err := m.listTeamSecrets(m.keyNamespace, fmt.Sprintf("maas/resource-type=team-member,maas/team-id=%s", teamID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team members: %w", err)
+	}
+	tokens, err := m.listTeamSecrets(m.keyNamespace, fmt.Sprintf("maas/resource-type=team-admin-token,maas/team-id=%s", teamID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team admin tokens: %w", err)
+	}
+	invites, err := m.listTeamSecrets(m.keyNamespace, fmt.Sprintf("maas/resource-type=team-invite,maas/team-id=%s", teamID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team invites: %w", err)
+	}
+
+	result := &DeleteTeamResult{
+		TeamID:      teamID,
+		DryRun:      dryRun,
+		KeyCount:    len(keys),
+		MemberCount: len(members),
+		Resources:   make([]DeletedResource, 0),
+	}
+
+	// record runs a deletion step unless this is a dry run
+	record := func(kind, name string, del func() error) {
+		resource := DeletedResource{Kind: kind, Name: name, Status: DeleteStatusPlanned}
+		if !dryRun {
+			if err := del(); err != nil {
+				log.Printf("Warning: Failed to delete %s %s for team %s: %v", kind, name, teamID, err)
+				resource.Status = DeleteStatusFailed
+				resource.Error = err.Error()
+				result.Failed++
+			} else {
+				resource.Status = DeleteStatusDeleted
+			}
+		}
+		result.Resources = append(result.Resources, resource)
+	}
+	deleteSecret := func(namespace, name string) func() error {
+		return func() error {
+			return m.clientset.CoreV1().Secrets(namespace).Delete(
+				context.Background(), name, metav1.DeleteOptions{})
+		}
+	}
+
 	// Update TokenRateLimitPolicy to remove the team's policy
+	if m.policyMgr != nil && teamPolicy != "" {
+		record("TokenRateLimitPolicyLimit", teamPolicy, func() error {
+			return m.policyMgr.RemoveTeamFromTokenRateLimit(teamPolicy)
+		})
+	}
+
+	// Individual member limits go with the team
 	if m.policyMgr != nil {
-		err = m.policyMgr.RemoveTeamFromTokenRateLimit(teamPolicy)
-		if err != nil {
-			log.Printf("Warning: Failed to update TokenRateLimitPolicy for team deletion %s: %v", teamID, err)
+		record("TokenRateLimitPolicyLimit", UserLimitName(teamID, "*"), func() error {
+			return m.policyMgr.RemoveTeamUserLimits(teamID)
+		})
+	}
+
+	// The last sub-team of a parent takes the shared limit with it
+	if m.policyMgr != nil && parentID != "" {
+		siblings, err := m.listSubteams(parentID)
+		if err == nil && len(siblings) <= 1 {
+			record("TokenRateLimitPolicyLimit", SubteamLimitName(parentID), func() error {
+				return m.policyMgr.RemoveSubteamLimit(parentID)
+			})
 		}
 	}
 
-	// Delete all team API keys
-	err = m.deleteAllTeamKeys(teamID)
-	if err != nil {
-		log.Printf("Failed to delete team keys: %v", err)
+	// Delete all team API keys, membership records, admin tokens and invites
+	for _, name := range keys {
+		record("APIKey", name, deleteSecret(keyNamespace, name))
+	}
+	for _, name := range members {
+		record("TeamMember", name, deleteSecret(m.keyNamespace, name))
+	}
+	for _, name := range tokens {
+		record("TeamAdminToken", name, deleteSecret(m.keyNamespace, name))
+	}
+	for _, name := range invites {
+		record("TeamInvite", name, deleteSecret(m.keyNamespace, name))
+	}
+	if m.crdStore != nil {
+		record("MaaSTeam", teamID, func() error {
+			return m.crdStore.Delete(teamID)
+		})
 	}
 
-	// Delete team configuration secret
-	err = m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(
-		context.Background(), teamSecret.Name, metav1.DeleteOptions{})
+	// Delete team configuration secret last, and keep it if anything it owns
+	// survived so the deletion can be retried
+	pending := result.Failed
+	record("TeamConfig", teamSecret.Name, func() error {
+		if pending > 0 {
+			return fmt.Errorf("kept because %d team resources failed to delete", pending)
+		}
+		return deleteSecret(m.keyNamespace, teamSecret.Name)()
+	})
+
+	if dryRun {
+		log.Printf("Dry run: deleting team %s would remove %d resources", teamID, len(result.Resources))
+	} else {
+		// The config secret may be gone, so record against the gateway
+		eventType := corev1.EventTypeNormal
+		if result.Failed > 0 {
+			eventType = corev1.EventTypeWarning
+		}
+		m.events.Gateway(eventType, events.ReasonTeamDeleted,
+			"Team %s deleted, %d of %d resources failed", teamID, result.Failed, len(result.Resources))
+		log.Printf("Team %s deleted, %d of %d resources failed", teamID, result.Failed, len(result.Resources))
+	}
+	return result, nil
+}
+
+// listTeamSecrets returns the names of secrets matching a label selector
+func (m *Manager) listTeamSecrets(namespace, labelSelector string) ([]string, error) {
+	secrets, err := m.clientset.CoreV1().Secrets(namespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
-		return fmt.Errorf("failed to delete team: %w", err)
+		return nil, err
 	}
 
-	log.Printf("Team deleted successfully: %s", teamID)
-	return nil
+	names := make([]string, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		names = append(names, secret.Name)
+	}
+	return names, nil
 }
 
 // Exists checks if a team exists
@@ -311,25 +946,57 @@ func (m *Manager) GetPolicy(teamID string) (string, error) {
 	return policy, nil
 }
 
-// CreateDefaultTeam creates the default team if it doesn't exist
-func (m *Manager) CreateDefaultTeam() error {
-	teamID := "default"
+// GetKeyLimitOverrides returns the per-team key caps, -1 when not overridden
+func (m *Manager) GetKeyLimitOverrides(teamID string) (int, int, error) {
+	teamSecret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
+		context.Background(), fmt.Sprintf("team-%s-config", teamID), metav1.GetOptions{})
+	if err != nil {
+		return -1, -1, fmt.Errorf("team not found: %w", err)
+	}
+
+	return annotationInt(teamSecret.Annotations, "maas/max-keys-per-user"),
+		annotationInt(teamSecret.Annotations, "maas/max-keys-per-team"), nil
+}
+
+// GetLimits returns the token limit and time window enforced for a team's policy
+func (m *Manager) GetLimits(teamID string) (int, string, error) {
+	policy, err := m.GetPolicy(teamID)
+	if err != nil {
+		return 0, "", err
+	}
 
-	// Check if default team already exists
-	if m.Exists(teamID) {
-		log.Printf("Default team already exists, skipping creation")
-		return nil
+	if m.policyMgr == nil {
+		return 0, "", fmt.Errorf("policy management is not configured")
 	}
 
-	// Create default team with unlimited policy
-	req := &CreateTeamRequest{
-		TeamID:      teamID,
-		TeamName:    "Default Team",
-		Description: "Default team for simple MaaS deployments - users without team assignment",
-		Policy:      "unlimited-policy",
+	return m.tierLimits(policy)
+}
+
+// tierLimits returns the main rate of a policy as its token limit and time
+// window
+func (m *Manager) tierLimits(policy string) (int, string, error) {
+	rates, err := m.policyMgr.GetPolicyRates(policy)
+	if err != nil {
+		return 0, "", err
 	}
+	if len(rates) == 0 {
+		return 0, "", fmt.Errorf("policy '%s' has no rates in TokenRateLimitPolicy", policy)
+	}
+	return rates[0].Limit, rates[0].Window, nil
+}
 
-	return m.Create(req)
+// GetRates returns every sustained rate enforced for a team's policy
+func (m *Manager) GetRates(teamID string) ([]RateLimit, error) {
+	policy, err := m.GetPolicy(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	return m.policyMgr.GetPolicyRates(policy)
 }
 
 // validateTeamRequest validates team creation/update data
@@ -347,11 +1014,45 @@ func (m *Manager) validateTeamRequest(req *CreateTeamRequest) error {
 			return fmt.Errorf("policy name must contain only lowercase alphanumeric characters and hyphens")
 		}
 	}
-	return nil
+	if req.BudgetUSDMonthly < 0 {
+		return fmt.Errorf("budget_usd_monthly must not be negative")
+	}
+	if req.LimitScope != "" && !IsValidLimitScope(req.LimitScope) {
+		return fmt.Errorf("limit_scope must be one of %s, %s, %s or %s", LimitScopePerUser, LimitScopePerTeam, LimitScopePerKey, LimitScopeBoth)
+	}
+	if req.TeamTokenLimit < 0 {
+		return fmt.Errorf("team_token_limit must not be negative")
+	}
+	if err := ValidateLimitOverrides(req.TokenLimit, 0, req.TimeWindow); err != nil {
+		return err
+	}
+	if req.Namespace != "" && !isValidTeamID(req.Namespace) {
+		return fmt.Errorf("namespace must be a valid Kubernetes namespace name")
+	}
+	if req.OwnerUserID != "" && !isValidTeamID(req.OwnerUserID) {
+		return fmt.Errorf("owner_user_id must be a valid user ID")
+	}
+	if req.OwnerEmail != "" && req.OwnerUserID == "" {
+		return fmt.Errorf("owner_email requires owner_user_id")
+	}
+	if req.ParentTeamID != "" && (!isValidTeamID(req.ParentTeamID) || req.ParentTeamID == req.TeamID) {
+		return fmt.Errorf("parent_team_id must be the ID of another team")
+	}
+	if req.WebhookURL != "" {
+		if err := webhook.ValidateURL(req.WebhookURL); err != nil {
+			return err
+		}
+		if err := webhook.ValidateSecret(req.WebhookURL, req.WebhookSecret, m.allowUnsignedWebhooks); err != nil {
+			return err
+		}
+	} else if req.WebhookSecret != "" {
+		return fmt.Errorf("webhook_secret requires webhook_url")
+	}
+	return validateModelLimits(req.ModelLimits)
 }
 
 // createTeamConfigSecret creates the team configuration secret
-func (m *Manager) createTeamConfigSecret(req *CreateTeamRequest) (*corev1.Secret, error) {
+func (m *Manager) createTeamConfigSecret(req *CreateTeamRequest, provisioningStatus string) (*corev1.Secret, error) {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("team-%s-config", req.TeamID),
@@ -374,6 +1075,36 @@ func (m *Manager) createTeamConfigSecret(req *CreateTeamRequest) (*corev1.Secret
 		},
 	}
 
+	if req.BudgetUSDMonthly > 0 {
+		secret.Annotations[annotationBudget] = strconv.FormatFloat(req.BudgetUSDMonthly, 'f', -1, 64)
+		secret.Annotations[annotationSpend] = "0"
+		secret.Annotations[annotationPeriodStart] = secret.Annotations["maas/created-at"]
+	}
+
+	if req.WebhookURL != "" {
+		secret.Annotations[annotationWebhookURL] = req.WebhookURL
+	}
+	if isUnlimitedPolicy(req.Policy) && m.policyMgr != nil {
+		secret.Annotations[annotationUnlimitedMode] = m.policyMgr.UnlimitedMode()
+	}
+	if req.Namespace != "" && req.Namespace != m.keyNamespace {
+		secret.Annotations[annotationKeyNamespace] = req.Namespace
+	}
+	if req.OwnerUserID != "" {
+		secret.Annotations[annotationOwnerUserID] = req.OwnerUserID
+		secret.Annotations[annotationOwnerEmail] = req.OwnerEmail
+	}
+	if req.WebhookSecret != "" {
+		secret.StringData[webhookSecretKey] = req.WebhookSecret
+	}
+	if provisioningStatus != "" {
+		secret.Annotations[annotationProvisioningStatus] = provisioningStatus
+	}
+	if req.ParentTeamID != "" {
+		secret.Labels[LabelParentTeamID] = req.ParentTeamID
+	}
+	setModelLimitsAnnotation(secret.Annotations, req.ModelLimits)
+
 	return m.clientset.CoreV1().Secrets(m.keyNamespace).Create(
 		context.Background(), secret, metav1.CreateOptions{})
 }
@@ -381,15 +1112,13 @@ func (m *Manager) createTeamConfigSecret(req *CreateTeamRequest) (*corev1.Secret
 // Helper methods for team API keys and members
 
 func (m *Manager) getTeamAPIKeys(teamID string) ([]string, error) {
-	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
-		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	secrets, err := m.teamKeySecrets(teamID)
 	if err != nil {
 		return nil, err
 	}
 
 	keys := make([]string, 0)
-	for _, secret := range secrets.Items {
+	for _, secret := range secrets {
 		keys = append(keys, secret.Name)
 	}
 
@@ -397,16 +1126,14 @@ func (m *Manager) getTeamAPIKeys(teamID string) ([]string, error) {
 }
 
 func (m *Manager) getTeamMembersFromAPIKeys(teamID string) ([]TeamMember, error) {
-	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
-		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	secrets, err := m.teamKeySecrets(teamID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create a map to deduplicate members (one user might have multiple keys)
 	memberMap := make(map[string]TeamMember)
-	for _, secret := range secrets.Items {
+	for _, secret := range secrets {
 		userID := secret.Labels["maas/user-id"]
 		if userID == "" {
 			continue // Skip invalid secrets
@@ -437,44 +1164,11 @@ func (m *Manager) getTeamMembersFromAPIKeys(teamID string) ([]TeamMember, error)
 	return members, nil
 }
 
-func (m *Manager) deleteAllTeamKeys(teamID string) error {
-	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
-	return m.clientset.CoreV1().Secrets(m.keyNamespace).DeleteCollection(
-		context.Background(), metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: labelSelector})
-}
-
-// updateTeamKeysPolicy updates the kuadrant.io/groups annotation for all team API keys
-func (m *Manager) updateTeamKeysPolicy(teamID, newPolicy string) error {
-	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
-	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
-		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+// annotationInt parses an integer annotation, returning -1 when absent or invalid
+func annotationInt(annotations map[string]string, key string) int {
+	value, err := strconv.Atoi(annotations[key])
 	if err != nil {
-		return fmt.Errorf("failed to list team API keys: %w", err)
-	}
-
-	for _, secret := range secrets.Items {
-		// Update the groups annotation with new policy
-		if secret.Annotations == nil {
-			secret.Annotations = make(map[string]string)
-		}
-		secret.Annotations["kuadrant.io/groups"] = newPolicy
-		secret.Annotations["maas/policy"] = newPolicy
-
-		// Update policy-specific label
-		oldPolicy := secret.Annotations["maas/policy"]
-		if oldPolicy != "" && oldPolicy != newPolicy {
-			delete(secret.Labels, fmt.Sprintf("maas/policy-%s", oldPolicy))
-		}
-		secret.Labels[fmt.Sprintf("maas/policy-%s", newPolicy)] = "true"
-
-		// Update secret
-		_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
-			context.Background(), &secret, metav1.UpdateOptions{})
-		if err != nil {
-			log.Printf("Warning: Failed to update API key %s policy: %v", secret.Name, err)
-		}
+		return -1
 	}
-
-	log.Printf("Updated %d API keys for team %s to policy %s", len(secrets.Items), teamID, newPolicy)
-	return nil
-}
\ No newline at end of file
+	return value
+}
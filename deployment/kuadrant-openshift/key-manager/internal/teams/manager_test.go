@@ -0,0 +1,42 @@
+package teams
+
+import (
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetLimitsReturnsTierLimits(t *testing.T) {
+	p, _ := newFakePolicyManager(3)
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-a", "gold"), testTeamSecret("team-b", "free"))
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	tests := []struct {
+		teamID     string
+		wantLimit  int
+		wantWindow string
+		wantErr    bool
+	}{
+		{teamID: "team-a", wantLimit: 5000, wantWindow: "1h"},
+		{teamID: "team-b", wantLimit: 100, wantWindow: "1m"},
+		{teamID: "team-c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.teamID, func(t *testing.T) {
+			tokenLimit, timeWindow, err := m.GetLimits(tt.teamID)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetLimits(%s) = nil error for a missing team", tt.teamID)
+				}
+				return
+			}
+			if err != nil || tokenLimit != tt.wantLimit || timeWindow != tt.wantWindow {
+				t.Errorf("GetLimits(%s) = %d, %q, %v, want %d per %s", tt.teamID, tokenLimit, timeWindow, err, tt.wantLimit, tt.wantWindow)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Team roles a member can hold
+var validRoles = map[string]bool{
+	"member": true,
+	"admin":  true,
+	"viewer": true,
+}
+
+// IsValidRole reports whether role is one of the supported team roles
+func IsValidRole(role string) bool {
+	return validRoles[role]
+}
+
+// AddMember registers a user with a team before any key exists for them
+func (m *Manager) AddMember(teamID string, req *AddUserToTeamRequest) (*TeamMember, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isValidTeamID(req.UserID) {
+		return nil, fmt.Errorf("invalid user_id")
+	}
+	if !IsValidRole(req.Role) {
+		return nil, fmt.Errorf("invalid role %s, must be one of member, admin, viewer", req.Role)
+	}
+	if err := ValidateLimitOverrides(req.TokenLimit, req.RequestLimit, req.TimeWindow); err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      memberSecretName(teamID, req.UserID),
+			Namespace: m.keyNamespace,
+			Labels: map[string]string{
+				"maas/resource-type": "team-member",
+				"maas/team-id":       teamID,
+				"maas/user-id":       req.UserID,
+			},
+			Annotations: map[string]string{
+				"maas/user-email": req.UserEmail,
+				"maas/team-role":  req.Role,
+				"maas/team-name":  teamSecret.Annotations["maas/team-name"],
+				"maas/created-at": time.Now().Format(time.RFC3339),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	setMemberOverrides(secret.Annotations, req.TokenLimit, req.RequestLimit, req.TimeWindow)
+
+	created, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Create(
+		context.Background(), secret, metav1.CreateOptions{})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("user %s is already a member of team %s", req.UserID, teamID)
+		}
+		return nil, fmt.Errorf("failed to create membership: %w", err)
+	}
+
+	if req.TokenLimit > 0 {
+		m.applyMemberLimit(teamID, req.UserID, teamSecret.Annotations["maas/policy"], req.TokenLimit, req.TimeWindow)
+	}
+
+	log.Printf("User %s added to team %s with role %s", req.UserID, teamID, req.Role)
+	return memberFromSecret(created, teamSecret.Annotations["maas/policy"]), nil
+}
+
+// GetMember returns the membership record of a user in a team
+func (m *Manager) GetMember(teamID, userID string) (*TeamMember, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
+		context.Background(), memberSecretName(teamID, userID), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("membership not found: %w", err)
+	}
+
+	return memberFromSecret(secret, teamSecret.Annotations["maas/policy"]), nil
+}
+
+// ListMembers merges registered members with members inferred from API keys.
+// Registered records take precedence.
+func (m *Manager) ListMembers(teamID string) ([]TeamMember, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	labelSelector := fmt.Sprintf("maas/resource-type=team-member,maas/team-id=%s", teamID)
+	records, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team members: %w", err)
+	}
+
+	members := make([]TeamMember, 0, len(records.Items))
+	seen := make(map[string]bool)
+	for i := range records.Items {
+		member := memberFromSecret(&records.Items[i], teamSecret.Annotations["maas/policy"])
+		members = append(members, *member)
+		seen[member.UserID] = true
+	}
+
+	keyMembers, err := m.getTeamMembersFromAPIKeys(teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range keyMembers {
+		if !seen[member.UserID] {
+			members = append(members, member)
+		}
+	}
+
+	return members, nil
+}
+
+// UpdateMember changes a member's role and individual limits. The membership
+// record and all of the user's team keys are updated; members only known from
+// their keys get a record created.
+func (m *Manager) UpdateMember(teamID, userID string, req *UpdateMemberRequest) (*TeamMember, error) {
+	if req.Role != nil && !IsValidRole(*req.Role) {
+		return nil, fmt.Errorf("invalid role %s, must be one of member, admin, viewer", *req.Role)
+	}
+	if (req.TokenLimit != nil && *req.TokenLimit < 0) || (req.RequestLimit != nil && *req.RequestLimit < 0) {
+		return nil, fmt.Errorf("invalid limits, token_limit and request_limit must not be negative")
+	}
+	if req.TimeWindow != nil {
+		if err := ValidateLimitOverrides(0, 0, *req.TimeWindow); err != nil {
+			return nil, err
+		}
+	}
+
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s,maas/user-id=%s", teamID, userID)
+	keys, err := m.clientset.CoreV1().Secrets(m.keyNamespaceOf(teamSecret)).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user keys: %w", err)
+	}
+
+	record, err := m.clientset.CoreV1().Secrets(m.keyNamespace).Get(
+		context.Background(), memberSecretName(teamID, userID), metav1.GetOptions{})
+	exists := err == nil
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get membership: %w", err)
+		}
+		if len(keys.Items) == 0 {
+			return nil, fmt.Errorf("user %s is not a member of team %s", userID, teamID)
+		}
+
+		// Promote the membership inferred from the user's keys to a record
+		key := keys.Items[0]
+		record = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      memberSecretName(teamID, userID),
+				Namespace: m.keyNamespace,
+				Labels: map[string]string{
+					"maas/resource-type": "team-member",
+					"maas/team-id":       teamID,
+					"maas/user-id":       userID,
+				},
+				Annotations: map[string]string{
+					"maas/user-email": key.Annotations["maas/user-email"],
+					"maas/team-role":  key.Labels["maas/team-role"],
+					"maas/team-name":  teamSecret.Annotations["maas/team-name"],
+					"maas/created-at": key.Annotations["maas/created-at"],
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+	}
+
+	current := memberFromSecret(record, "")
+	if req.Role != nil {
+		record.Annotations["maas/team-role"] = *req.Role
+	}
+	tokenLimit, requestLimit, timeWindow := current.TokenLimit, current.RequestLimit, current.TimeWindow
+	if req.TokenLimit != nil {
+		tokenLimit = *req.TokenLimit
+	}
+	if req.RequestLimit != nil {
+		requestLimit = *req.RequestLimit
+	}
+	if req.TimeWindow != nil {
+		timeWindow = *req.TimeWindow
+	}
+	setMemberOverrides(record.Annotations, tokenLimit, requestLimit, timeWindow)
+
+	if exists {
+		record, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+			context.Background(), record, metav1.UpdateOptions{})
+	} else {
+		record, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Create(
+			context.Background(), record, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save membership: %w", err)
+	}
+
+	// Keep the user's key secrets in line with the membership record
+	for _, key := range keys.Items {
+		key.Labels["maas/team-role"] = record.Annotations["maas/team-role"]
+		setMemberOverrides(key.Annotations, tokenLimit, requestLimit, timeWindow)
+
+		_, err = m.clientset.CoreV1().Secrets(key.Namespace).Update(
+			context.Background(), &key, metav1.UpdateOptions{})
+		if err != nil {
+			log.Printf("Warning: Failed to update API key %s for member %s: %v", key.Name, userID, err)
+		}
+	}
+
+	if tokenLimit != current.TokenLimit || timeWindow != current.TimeWindow {
+		m.applyMemberLimit(teamID, userID, teamSecret.Annotations["maas/policy"], tokenLimit, timeWindow)
+	}
+
+	log.Printf("Member %s of team %s updated, %d keys refreshed", userID, teamID, len(keys.Items))
+	return memberFromSecret(record, teamSecret.Annotations["maas/policy"]), nil
+}
+
+// RemoveMember deletes a user's membership record. Their team keys are
+// deleted or, in deactivate mode, kept as inactive keys that no longer
+// authenticate.
+func (m *Manager) RemoveMember(teamID, userID, mode string) (*RemoveMemberResult, error) {
+	if err := checkRemovalMode(mode); err != nil {
+		return nil, err
+	}
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if teamSecret.Annotations[annotationOwnerUserID] == userID {
+		return nil, fmt.Errorf("user %s is the owner of team %s, transfer ownership before removing them", userID, teamID)
+	}
+
+	keyNamespace := m.KeyNamespace(teamID)
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s,maas/user-id=%s", teamID, userID)
+	keys, err := m.clientset.CoreV1().Secrets(keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user keys: %w", err)
+	}
+
+	err = m.clientset.CoreV1().Secrets(m.keyNamespace).Delete(
+		context.Background(), memberSecretName(teamID, userID), metav1.DeleteOptions{})
+	recordDeleted := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete membership: %w", err)
+	}
+
+	if !recordDeleted && len(keys.Items) == 0 {
+		return nil, fmt.Errorf("user %s is not a member of team %s", userID, teamID)
+	}
+
+	if m.policyMgr != nil {
+		if err := m.policyMgr.RemoveUserLimit(teamID, userID); err != nil {
+			log.Printf("Warning: Failed to remove limit for member %s of team %s: %v", userID, teamID, err)
+		}
+	}
+
+	result := &RemoveMemberResult{TeamID: teamID, UserID: userID, Mode: mode}
+	for i := range keys.Items {
+		key := &keys.Items[i]
+		if mode == RemovalModeDeactivate {
+			if key.Annotations["maas/status"] == keyStatusInactive {
+				continue
+			}
+			if err := m.deactivateKey(key); err != nil {
+				log.Printf("Warning: Failed to deactivate API key %s: %v", key.Name, err)
+				continue
+			}
+			result.DeactivatedKeys++
+			continue
+		}
+
+		err = m.clientset.CoreV1().Secrets(keyNamespace).Delete(
+			context.Background(), key.Name, metav1.DeleteOptions{})
+		if err != nil {
+			log.Printf("Warning: Failed to delete API key %s: %v", key.Name, err)
+			continue
+		}
+		result.DeletedKeys++
+	}
+
+	log.Printf("User %s removed from team %s, %d keys deleted, %d deactivated",
+		userID, teamID, result.DeletedKeys, result.DeactivatedKeys)
+	return result, nil
+}
+
+// memberSecretName returns the name of a user's membership record
+func memberSecretName(teamID, userID string) string {
+	return fmt.Sprintf("member-%s-%s", teamID, userID)
+}
+
+// memberFromSecret builds a TeamMember from a membership record
+func memberFromSecret(secret *corev1.Secret, policy string) *TeamMember {
+	member := &TeamMember{
+		UserID:     secret.Labels["maas/user-id"],
+		UserEmail:  secret.Annotations["maas/user-email"],
+		Role:       secret.Annotations["maas/team-role"],
+		TeamID:     secret.Labels["maas/team-id"],
+		TeamName:   secret.Annotations["maas/team-name"],
+		JoinedAt:   secret.Annotations["maas/created-at"],
+		Policy:     policy,
+		TimeWindow: secret.Annotations["maas/time-window"],
+		Registered: true,
+	}
+	member.TokenLimit, _ = strconv.Atoi(secret.Annotations["maas/token-limit"])
+	member.RequestLimit, _ = strconv.Atoi(secret.Annotations["maas/request-limit"])
+	return member
+}
+
+// setMemberOverrides records individual rate overrides, clearing unset ones
+func setMemberOverrides(annotations map[string]string, tokenLimit, requestLimit int, timeWindow string) {
+	setOrDelete := func(key, value string, set bool) {
+		if set {
+			annotations[key] = value
+		} else {
+			delete(annotations, key)
+		}
+	}
+	setOrDelete("maas/token-limit", strconv.Itoa(tokenLimit), tokenLimit > 0)
+	setOrDelete("maas/request-limit", strconv.Itoa(requestLimit), requestLimit > 0)
+	setOrDelete("maas/time-window", timeWindow, timeWindow != "")
+}
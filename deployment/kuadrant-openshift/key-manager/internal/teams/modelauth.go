@@ -0,0 +1,30 @@
+package teams
+
+import "fmt"
+
+// modelHeader names the model a request targets. Each model's HTTPRoute sets
+// it, overwriting any value sent by the client.
+const modelHeader = "x-maas-model"
+
+// modelAllowlistRule is the AuthPolicy authorization rule enforcing the
+// models-allowed annotation of the API key a request authenticates with
+const modelAllowlistRule = "model-allowlist"
+
+// modelAllowlistRego allows keys without a model allowlist, and otherwise
+// only requests for a model on the key's list. The annotation is read from
+// the key secret on every request, so changing a key's models needs no
+// AuthPolicy update.
+var modelAllowlistRego = fmt.Sprintf(`allowed := [trim_space(m) | m := split(object.get(input.auth.identity.metadata.annotations, "%s", ""), ",")[_]; trim_space(m) != ""]
+model := object.get(input.context.request.http.headers, "%s", "")
+allow { count(allowed) == 0 }
+allow { allowed[_] == model }`, annotationModelsAllowed, modelHeader)
+
+// renderModelAllowlist sets the model allowlist rule among the AuthPolicy's
+// authorization rules
+func renderModelAllowlist(authorization map[string]interface{}) {
+	authorization[modelAllowlistRule] = map[string]interface{}{
+		"opa": map[string]interface{}{
+			"rego": modelAllowlistRego,
+		},
+	}
+}
@@ -0,0 +1,249 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationModelLimits holds a team's per-model limits as JSON
+const annotationModelLimits = "maas/model-limits"
+
+// Predicates matching the model named in the request body. Model limits
+// match one model and the policy's blanket limits exclude every model with
+// its own limit.
+const (
+	modelPredicatePrefix   = `requestBodyJSON("/model") == `
+	modelExclusionPrefix   = `!(requestBodyJSON("/model") in [`
+	maxModelLimitsPerTeam  = 20
+	modelLimitNameInfix    = "-model-"
+	modelLimitNameMaxChars = 63
+)
+
+var modelNameUnsafeChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// modelLimitName names the TokenRateLimitPolicy limit of a model override
+func modelLimitName(policyName, model string) string {
+	name := strings.Trim(modelNameUnsafeChars.ReplaceAllString(strings.ToLower(model), "-"), "-")
+	if len(name) > modelLimitNameMaxChars {
+		name = name[:modelLimitNameMaxChars]
+	}
+	return policyName + modelLimitNameInfix + name
+}
+
+// validateModelLimits checks per-model limit overrides
+func validateModelLimits(modelLimits map[string]ModelLimit) error {
+	if len(modelLimits) > maxModelLimitsPerTeam {
+		return fmt.Errorf("invalid model_limits: at most %d models can have their own limit", maxModelLimitsPerTeam)
+	}
+	for model, limit := range modelLimits {
+		if strings.TrimSpace(model) == "" || strings.ContainsAny(model, "\"\\") {
+			return fmt.Errorf("invalid model_limits: model %q is not a valid model ID", model)
+		}
+		if limit.TokenLimit <= 0 {
+			return fmt.Errorf("invalid model_limits: token_limit for model %s must be positive", model)
+		}
+		if !IsValidTimeWindow(limit.TimeWindow) {
+			return fmt.Errorf("invalid model_limits: time_window %q for model %s must be a duration such as 1m or 1h", limit.TimeWindow, model)
+		}
+	}
+	return nil
+}
+
+// modelLimitsOf returns the model limits stored on a team config secret and
+// whether the team has any recorded
+func modelLimitsOf(teamSecret *corev1.Secret) (map[string]ModelLimit, bool) {
+	data, ok := teamSecret.Annotations[annotationModelLimits]
+	if !ok {
+		return nil, false
+	}
+	modelLimits := make(map[string]ModelLimit)
+	if err := json.Unmarshal([]byte(data), &modelLimits); err != nil {
+		log.Printf("Warning: Ignoring invalid model limits of team %s: %v", teamSecret.Labels["maas/team-id"], err)
+		return nil, false
+	}
+	return modelLimits, true
+}
+
+// setModelLimitsAnnotation records a team's model limits, removing the
+// annotation when there are none
+func setModelLimitsAnnotation(annotations map[string]string, modelLimits map[string]ModelLimit) {
+	if len(modelLimits) == 0 {
+		delete(annotations, annotationModelLimits)
+		return
+	}
+	data, _ := json.Marshal(modelLimits)
+	annotations[annotationModelLimits] = string(data)
+}
+
+// applyStoredModelLimits writes a team's recorded model limits to its policy,
+// pruning overrides that were removed. Teams that never set model limits
+// leave the policy's overrides alone, since they may belong to another team
+// on the same policy.
+func (m *Manager) applyStoredModelLimits(teamSecret *corev1.Secret, policy string) error {
+	modelLimits, ok := modelLimitsOf(teamSecret)
+	if !ok || m.policyMgr == nil {
+		return nil
+	}
+	return m.policyMgr.SetModelLimits(policy, modelLimits)
+}
+
+// SetModelLimits replaces the per-model limits of a policy. Each model gets
+// its own limit, counted like the policy's blanket limit, and the blanket
+// limits no longer apply to those models.
+func (p *PolicyManager) SetModelLimits(policyName string, modelLimits map[string]ModelLimit) error {
+	if !p.PolicyExists(policyName) {
+		return fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", policyName)
+	}
+
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		for name, limitConfig := range limits {
+			if _, ok := limitModel(policyName, name, limitConfig); ok {
+				delete(limits, name)
+			}
+		}
+
+		counterExpression := userCounterExpression
+		if base, ok := limits[policyName].(map[string]interface{}); ok {
+			if expression := limitCounterExpression(base); expression != "" {
+				counterExpression = expression
+			}
+		}
+		for model, limit := range modelLimits {
+			limitConfig := tokenRateLimit(policyName, limit.TokenLimit, limit.TimeWindow, counterExpression)
+			limitConfig["when"] = append(limitPredicates(limitConfig),
+				map[string]interface{}{"predicate": modelPredicatePrefix + strconv.Quote(model)})
+			limits[modelLimitName(policyName, model)] = limitConfig
+		}
+
+		excludeModelOverrides(limits, policyName)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Updated TokenRateLimitPolicy model limits for policy %s: %d models", policyName, len(modelLimits))
+	return nil
+}
+
+// GetModelLimits returns the per-model limits of a policy
+func (p *PolicyManager) GetModelLimits(policyName string) (map[string]ModelLimit, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+
+	modelLimits := make(map[string]ModelLimit)
+	spec, _ := policyObj.Object["spec"].(map[string]interface{})
+	limits, _ := spec["limits"].(map[string]interface{})
+	for name, limitConfig := range limits {
+		model, ok := limitModel(policyName, name, limitConfig)
+		if !ok {
+			continue
+		}
+		limitMap := limitConfig.(map[string]interface{})
+		var limit ModelLimit
+		if rates, ok := limitMap["rates"].([]interface{}); ok && len(rates) > 0 {
+			if rate, ok := rates[0].(map[string]interface{}); ok {
+				limit.TokenLimit = int(numberValue(rate["limit"]))
+				limit.TimeWindow = stringValue(rate["window"])
+			}
+		}
+		modelLimits[model] = limit
+	}
+	return modelLimits, nil
+}
+
+// excludeModelOverrides keeps a policy's blanket limits from counting models
+// that have their own limit
+func excludeModelOverrides(limits map[string]interface{}, policyName string) {
+	models := make([]string, 0)
+	for name, limitConfig := range limits {
+		if model, ok := limitModel(policyName, name, limitConfig); ok {
+			models = append(models, strconv.Quote(model))
+		}
+	}
+	sort.Strings(models)
+
+	for _, name := range []string{policyName, teamLimitName(policyName)} {
+		limitConfig, ok := limits[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		when := make([]interface{}, 0)
+		for _, predicate := range limitPredicates(limitConfig) {
+			if !strings.HasPrefix(stringValue(predicate["predicate"]), modelExclusionPrefix) {
+				when = append(when, predicate)
+			}
+		}
+		if len(models) > 0 {
+			when = append(when, map[string]interface{}{
+				"predicate": modelExclusionPrefix + strings.Join(models, ", ") + "])",
+			})
+		}
+		limitConfig["when"] = when
+	}
+}
+
+// limitModel returns the model a policy's model limit applies to
+func limitModel(policyName, name string, limitConfig interface{}) (string, bool) {
+	if !strings.HasPrefix(name, policyName+modelLimitNameInfix) {
+		return "", false
+	}
+	limitMap, ok := limitConfig.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	for _, predicate := range limitPredicates(limitMap) {
+		value := stringValue(predicate["predicate"])
+		if !strings.HasPrefix(value, modelPredicatePrefix) {
+			continue
+		}
+		if model, err := strconv.Unquote(strings.TrimPrefix(value, modelPredicatePrefix)); err == nil {
+			return model, true
+		}
+	}
+	return "", false
+}
+
+// isModelLimit reports whether a limit is a per-model override
+func isModelLimit(limitConfig interface{}) bool {
+	limitMap, ok := limitConfig.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, predicate := range limitPredicates(limitMap) {
+		if strings.HasPrefix(stringValue(predicate["predicate"]), modelPredicatePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitPredicates returns the when predicates of a limit, whether it was
+// read from the cluster or built locally
+func limitPredicates(limitConfig map[string]interface{}) []map[string]interface{} {
+	predicates := make([]map[string]interface{}, 0)
+	switch when := limitConfig["when"].(type) {
+	case []map[string]interface{}:
+		predicates = append(predicates, when...)
+	case []interface{}:
+		for _, item := range when {
+			if predicate, ok := item.(map[string]interface{}); ok {
+				predicates = append(predicates, predicate)
+			}
+		}
+	}
+	return predicates
+}
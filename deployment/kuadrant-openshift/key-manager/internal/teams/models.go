@@ -0,0 +1,219 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Sources of a model allowlist, from least to most specific
+const (
+	ModelSourceTier = "tier"
+	ModelSourceTeam = "team"
+	ModelSourceKey  = "key"
+)
+
+// Annotations recording model access
+const (
+	annotationModelsAllowed = "maas/models-allowed"
+	annotationModelsSource  = "maas/models-source"
+)
+
+// SplitModels parses a comma separated models-allowed annotation
+func SplitModels(modelsAllowed string) []string {
+	models := make([]string, 0)
+	for _, model := range strings.Split(modelsAllowed, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// GetTeamModels returns the team's model allowlist, nil when the team uses
+// its tier default
+func (m *Manager) GetTeamModels(teamID string) ([]string, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	models := SplitModels(teamSecret.Annotations[annotationModelsAllowed])
+	if len(models) == 0 {
+		return nil, nil
+	}
+	return models, nil
+}
+
+// GetEffectiveModels reports the models a team's keys may use and where each
+// list comes from
+func (m *Manager) GetEffectiveModels(teamID string) (*TeamModelsResponse, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &TeamModelsResponse{
+		TeamID:       teamID,
+		Tier:         teamSecret.Annotations["maas/policy"],
+		Models:       SplitModels(teamSecret.Annotations[annotationModelsAllowed]),
+		Source:       ModelSourceTeam,
+		KeyOverrides: make([]KeyModels, 0),
+	}
+	if len(response.Models) == 0 {
+		// Tiers carry no model restriction, so the default is every model
+		response.Source = ModelSourceTier
+		response.AllModels = true
+	}
+
+	keySecrets, err := m.listTeamKeySecrets(teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range keySecrets {
+		if KeyModelsSource(&secret) == ModelSourceKey {
+			response.KeyOverrides = append(response.KeyOverrides, KeyModels{
+				KeyName: secret.Name,
+				Models:  SplitModels(secret.Annotations[annotationModelsAllowed]),
+				Source:  ModelSourceKey,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+// SetTeamModels replaces the team's model allowlist, an empty list reverts to
+// the tier default. Keys without their own override follow the new list.
+func (m *Manager) SetTeamModels(teamID string, models []string) (*TeamModelsResponse, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	cleaned := make([]string, 0, len(models))
+	seen := make(map[string]bool)
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if model == "" || strings.Contains(model, ",") {
+			return nil, fmt.Errorf("invalid model name %q", model)
+		}
+		if !seen[model] {
+			seen[model] = true
+			cleaned = append(cleaned, model)
+		}
+	}
+
+	modelsAllowed := strings.Join(cleaned, ",")
+	if modelsAllowed == "" {
+		delete(teamSecret.Annotations, annotationModelsAllowed)
+	} else {
+		teamSecret.Annotations[annotationModelsAllowed] = modelsAllowed
+	}
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+		context.Background(), teamSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update team: %w", err)
+	}
+
+	source := ModelSourceTeam
+	if modelsAllowed == "" {
+		source = ModelSourceTier
+	}
+
+	keySecrets, err := m.listTeamKeySecrets(teamID)
+	if err != nil {
+		return nil, err
+	}
+	updated := 0
+	for i := range keySecrets {
+		secret := &keySecrets[i]
+		if KeyModelsSource(secret) == ModelSourceKey {
+			continue
+		}
+		secret.Annotations[annotationModelsAllowed] = modelsAllowed
+		secret.Annotations[annotationModelsSource] = source
+		_, err := m.clientset.CoreV1().Secrets(secret.Namespace).Update(
+			context.Background(), secret, metav1.UpdateOptions{})
+		if err != nil {
+			log.Printf("Warning: Failed to update models for API key %s: %v", secret.Name, err)
+			continue
+		}
+		updated++
+	}
+
+	if updated > 0 && m.policyMgr != nil {
+		if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+			log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+		}
+	}
+
+	log.Printf("Team %s model allowlist set to [%s], %d keys updated", teamID, modelsAllowed, updated)
+	return m.GetEffectiveModels(teamID)
+}
+
+// ResolveKeyModels returns the models a new key should be allowed along with
+// their source. Requested models must fall within the team allowlist.
+func (m *Manager) ResolveKeyModels(teamID string, requested []string) ([]string, string, error) {
+	teamModels, err := m.GetTeamModels(teamID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(requested) == 0 {
+		if teamModels == nil {
+			return []string{}, ModelSourceTier, nil
+		}
+		return teamModels, ModelSourceTeam, nil
+	}
+
+	if teamModels != nil {
+		for _, model := range requested {
+			if !containsString(teamModels, model) {
+				return nil, "", fmt.Errorf("model %s is not allowed for team %s", model, teamID)
+			}
+		}
+	}
+	return requested, ModelSourceKey, nil
+}
+
+// listTeamKeySecrets returns the API key secrets belonging to a team
+func (m *Manager) listTeamKeySecrets(teamID string) ([]corev1.Secret, error) {
+	labelSelector := fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.KeyNamespace(teamID)).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team API keys: %w", err)
+	}
+	for i := range secrets.Items {
+		if secrets.Items[i].Annotations == nil {
+			secrets.Items[i].Annotations = make(map[string]string)
+		}
+	}
+	return secrets.Items, nil
+}
+
+// KeyModelsSource reports where a key's model list came from. Keys created
+// before sources were recorded count as overrides when they list models.
+func KeyModelsSource(secret *corev1.Secret) string {
+	if source := secret.Annotations[annotationModelsSource]; source != "" {
+		return source
+	}
+	if secret.Annotations[annotationModelsAllowed] != "" {
+		return ModelSourceKey
+	}
+	return ModelSourceTier
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// keyNamespaceRoleBinding is the RoleBinding granting the key manager
+// access to the secrets of a team key namespace
+const keyNamespaceRoleBinding = "key-manager-maas-secrets"
+
+// KeyNamespaceAccess is how the key manager is granted access to the secrets
+// of team key namespaces: a RoleBinding it maintains in each binds a
+// ClusterRole, installed with the deployment, to its service account. The
+// key manager never holds access to secrets in other namespaces.
+type KeyNamespaceAccess struct {
+	ClusterRole             string
+	ServiceAccountNamespace string
+	ServiceAccountName      string
+}
+
+// ensureKeyNamespaceAccess creates or corrects the RoleBinding of a team
+// key namespace. The shared key namespace is bound by the deployment
+// manifests instead.
+func (m *Manager) ensureKeyNamespaceAccess(namespace string) error {
+	if m.namespaceAccess == nil || namespace == m.keyNamespace {
+		return nil
+	}
+
+	desired := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      keyNamespaceRoleBinding,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"maas/resource-type": "key-namespace-access",
+			},
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      m.namespaceAccess.ServiceAccountName,
+			Namespace: m.namespaceAccess.ServiceAccountNamespace,
+		}},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     m.namespaceAccess.ClusterRole,
+		},
+	}
+
+	bindings := m.clientset.RbacV1().RoleBindings(namespace)
+	existing, err := bindings.Get(context.Background(), keyNamespaceRoleBinding, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = bindings.Create(context.Background(), desired, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create role binding in namespace %s: %w", namespace, err)
+		}
+		log.Printf("Granted key secret access in namespace %s", namespace)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check role binding in namespace %s: %w", namespace, err)
+	}
+
+	if existing.RoleRef != desired.RoleRef {
+		// The role of a binding cannot be changed, only replaced
+		if err := bindings.Delete(context.Background(), keyNamespaceRoleBinding, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("failed to replace role binding in namespace %s: %w", namespace, err)
+		}
+		if _, err := bindings.Create(context.Background(), desired, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to replace role binding in namespace %s: %w", namespace, err)
+		}
+		log.Printf("Replaced key secret role binding in namespace %s", namespace)
+		return nil
+	}
+	if !reflect.DeepEqual(existing.Subjects, desired.Subjects) {
+		existing.Subjects = desired.Subjects
+		if _, err := bindings.Update(context.Background(), existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update role binding in namespace %s: %w", namespace, err)
+		}
+		log.Printf("Corrected key secret role binding in namespace %s", namespace)
+	}
+	return nil
+}
+
+// EnsureKeyNamespaceAccess restores the RoleBinding of every team key
+// namespace, such as one deleted by hand or bound to a renamed service
+// account
+func (m *Manager) EnsureKeyNamespaceAccess() error {
+	if m.namespaceAccess == nil {
+		return nil
+	}
+	namespaces, err := m.KeyNamespaces()
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		if err := m.ensureKeyNamespaceAccess(namespace); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationKeyNamespace records the namespace holding a team's key secrets
+// when it differs from the shared key namespace
+const annotationKeyNamespace = "maas/key-namespace"
+
+// KeyNamespace returns the namespace holding a team's key secrets. Teams
+// without their own namespace use the shared key namespace.
+func (m *Manager) KeyNamespace(teamID string) string {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return m.keyNamespace
+	}
+	return m.keyNamespaceOf(teamSecret)
+}
+
+// keyNamespaceOf returns the key namespace recorded on a team config secret
+func (m *Manager) keyNamespaceOf(teamSecret *corev1.Secret) string {
+	if namespace := teamSecret.Annotations[annotationKeyNamespace]; namespace != "" {
+		return namespace
+	}
+	return m.keyNamespace
+}
+
+// KeyNamespaces returns every namespace that may hold key secrets, starting
+// with the shared key namespace
+func (m *Manager) KeyNamespaces() ([]string, error) {
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+
+	namespaces := []string{m.keyNamespace}
+	seen := map[string]bool{m.keyNamespace: true}
+	for i := range secrets.Items {
+		namespace := m.keyNamespaceOf(&secrets.Items[i])
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, nil
+}
+
+// ListKeySecrets lists key secrets matching a label selector across all key
+// namespaces
+func (m *Manager) ListKeySecrets(labelSelector string) ([]corev1.Secret, error) {
+	namespaces, err := m.KeyNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]corev1.Secret, 0)
+	for _, namespace := range namespaces {
+		secrets, err := m.clientset.CoreV1().Secrets(namespace).List(
+			context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API keys in namespace %s: %w", namespace, err)
+		}
+		result = append(result, secrets.Items...)
+	}
+	return result, nil
+}
+
+// FindKeySecret looks up a key secret by name across all key namespaces
+func (m *Manager) FindKeySecret(name string) (*corev1.Secret, error) {
+	namespaces, err := m.KeyNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespace := range namespaces {
+		secret, err := m.clientset.CoreV1().Secrets(namespace).Get(
+			context.Background(), name, metav1.GetOptions{})
+		if err == nil {
+			return secret, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("secret %s not found", name)
+}
+
+// ensureKeyNamespace checks that a team key namespace exists, creating it
+// when automatic creation is enabled. It reports whether it was created.
+func (m *Manager) ensureKeyNamespace(namespace string) (bool, error) {
+	_, err := m.clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to check namespace %s: %w", namespace, err)
+	}
+	if !m.autoCreateNamespaces {
+		return false, fmt.Errorf("namespace %s does not exist", namespace)
+	}
+
+	_, err = m.clientset.CoreV1().Namespaces().Create(context.Background(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namespace,
+			Labels: map[string]string{
+				"maas/resource-type": "team-namespace",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+	}
+
+	log.Printf("Created key namespace %s", namespace)
+	return true, nil
+}
@@ -0,0 +1,124 @@
+package teams
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/webhook"
+)
+
+// Team config fields for the team's own webhook. The signing secret is kept
+// in the secret data rather than an annotation.
+const (
+	annotationWebhookURL = "maas/webhook-url"
+	webhookSecretKey     = "webhook_secret"
+)
+
+// annotationBudgetAlerted records the highest budget threshold already
+// notified in the current billing period
+const annotationBudgetAlerted = "maas/budget-alerted"
+
+// budgetWarningPercent is the share of the budget that triggers a warning
+const budgetWarningPercent = 80
+
+// teamWebhook returns the dispatcher for a team's own webhook, nil when the
+// team has none configured
+func teamWebhook(teamSecret *corev1.Secret) *webhook.Dispatcher {
+	return webhook.NewDispatcher(teamSecret.Annotations[annotationWebhookURL],
+		string(teamSecret.Data[webhookSecretKey]))
+}
+
+// setTeamWebhook stores the team's webhook URL and signing secret on its
+// config secret and reports which fields changed
+func setTeamWebhook(teamSecret *corev1.Secret, url, secret *string) []string {
+	changed := make([]string, 0)
+	if url != nil && teamSecret.Annotations[annotationWebhookURL] != *url {
+		if *url == "" {
+			delete(teamSecret.Annotations, annotationWebhookURL)
+		} else {
+			teamSecret.Annotations[annotationWebhookURL] = *url
+		}
+		changed = append(changed, "webhook_url")
+	}
+	if secret != nil && string(teamSecret.Data[webhookSecretKey]) != *secret {
+		if teamSecret.Data == nil {
+			teamSecret.Data = make(map[string][]byte)
+		}
+		if *secret == "" {
+			delete(teamSecret.Data, webhookSecretKey)
+		} else {
+			teamSecret.Data[webhookSecretKey] = []byte(*secret)
+		}
+		changed = append(changed, "webhook_secret")
+	}
+	return changed
+}
+
+// notifyTeam delivers team-scoped events to the global webhook and to the
+// team's own webhook when one is configured
+func (m *Manager) notifyTeam(teamID string, events ...webhook.Event) {
+	var teamDispatcher *webhook.Dispatcher
+	if teamSecret, err := m.getTeamSecret(teamID); err == nil {
+		teamDispatcher = teamWebhook(teamSecret)
+	}
+
+	for _, event := range events {
+		m.webhooks.DispatchEvent(event)
+		teamDispatcher.DispatchEvent(event)
+	}
+}
+
+// notifyPolicyResynced reports that a team's policies were reapplied
+func (m *Manager) notifyPolicyResynced(teamID, policy string) {
+	event := webhook.NewEvent(webhook.EventPolicyResynced, teamID, "", "")
+	event.Details = map[string]interface{}{"policy": policy}
+	m.notifyTeam(teamID, event)
+}
+
+// budgetAlert returns the event for a budget threshold newly crossed in the
+// current billing period along with the threshold, or nil if there is none
+func budgetAlert(teamID, alerted string, status *types.BudgetStatus) (*webhook.Event, string) {
+	if status == nil {
+		return nil, alerted
+	}
+
+	var event webhook.Event
+	var threshold string
+	switch {
+	case status.Exceeded && alerted != "100":
+		event, threshold = webhook.NewEvent(webhook.EventBudgetExceeded, teamID, "", ""), "100"
+	case status.PercentConsumed >= budgetWarningPercent && alerted == "":
+		event, threshold = webhook.NewEvent(webhook.EventBudgetWarning, teamID, "", ""), fmt.Sprint(budgetWarningPercent)
+	default:
+		return nil, alerted
+	}
+
+	event.Details = map[string]interface{}{
+		"budget_usd_monthly": status.BudgetUSDMonthly,
+		"spend_usd":          status.SpendUSD,
+		"percent_consumed":   status.PercentConsumed,
+		"period_start":       status.PeriodStart,
+	}
+	return &event, threshold
+}
+
+// TestWebhook sends a test event to the team's own webhook and waits for the
+// receiver to accept it
+func (m *Manager) TestWebhook(teamID string) error {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return err
+	}
+
+	dispatcher := teamWebhook(teamSecret)
+	if dispatcher == nil {
+		return fmt.Errorf("team %s has no webhook configured", teamID)
+	}
+
+	if err := dispatcher.Send(webhook.NewEvent(webhook.EventWebhookTest, teamID, "", "")); err != nil {
+		return fmt.Errorf("webhook delivery failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Team config annotations recording ownership and its last transfer
+const (
+	annotationOwnerUserID            = "maas/owner-user-id"
+	annotationOwnerEmail             = "maas/owner-email"
+	annotationPreviousOwnerUserID    = "maas/previous-owner-user-id"
+	annotationOwnershipTransferredAt = "maas/ownership-transferred-at"
+)
+
+// TransferOwnership makes another team member the team owner. The new owner
+// must hold a membership record or a key in the team.
+func (m *Manager) TransferOwnership(teamID string, req *TransferOwnershipRequest) (*TransferOwnershipResponse, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	previousOwner := teamSecret.Annotations[annotationOwnerUserID]
+	if req.NewOwnerUserID == previousOwner {
+		return nil, fmt.Errorf("user %s already owns team %s", req.NewOwnerUserID, teamID)
+	}
+
+	member, err := m.findMember(teamID, req.NewOwnerUserID)
+	if err != nil {
+		return nil, err
+	}
+	ownerEmail := req.NewOwnerEmail
+	if ownerEmail == "" {
+		ownerEmail = member.UserEmail
+	}
+
+	transferredAt := time.Now().Format(time.RFC3339)
+	teamSecret.Annotations[annotationOwnerUserID] = req.NewOwnerUserID
+	teamSecret.Annotations[annotationOwnerEmail] = ownerEmail
+	teamSecret.Annotations[annotationPreviousOwnerUserID] = previousOwner
+	teamSecret.Annotations[annotationOwnershipTransferredAt] = transferredAt
+
+	_, err = m.clientset.CoreV1().Secrets(m.keyNamespace).Update(
+		context.Background(), teamSecret, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update team: %w", err)
+	}
+
+	log.Printf("Ownership of team %s transferred from %q to %s", teamID, previousOwner, req.NewOwnerUserID)
+	return &TransferOwnershipResponse{
+		TeamID:              teamID,
+		OwnerUserID:         req.NewOwnerUserID,
+		OwnerEmail:          ownerEmail,
+		PreviousOwnerUserID: previousOwner,
+		TransferredAt:       transferredAt,
+	}, nil
+}
+
+// findMember returns a user's membership in a team from the membership record
+// or, failing that, from a key the user holds in the team
+func (m *Manager) findMember(teamID, userID string) (*TeamMember, error) {
+	if member, err := m.GetMember(teamID, userID); err == nil {
+		return member, nil
+	}
+
+	keyMembers, err := m.getTeamMembersFromAPIKeys(teamID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keyMembers {
+		if keyMembers[i].UserID == userID {
+			return &keyMembers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("user %s is not a member of team %s", userID, teamID)
+}
@@ -5,32 +5,88 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
+// Limit scopes decide what a policy's token counters are keyed on
+const (
+	LimitScopePerUser = "per_user"
+	LimitScopePerTeam = "per_team"
+	LimitScopePerKey  = "per_key"
+	LimitScopeBoth    = "both"
+)
+
+// Counter expressions for each limit scope
+const (
+	userCounterExpression = "auth.identity.userid"
+	teamCounterExpression = `auth.identity.metadata.labels["maas/team-id"]`
+	keyCounterExpression  = `auth.identity.metadata.labels["maas/key-sha256"]`
+)
+
+// IsValidLimitScope checks if a limit scope is supported
+func IsValidLimitScope(scope string) bool {
+	return scope == LimitScopePerUser || scope == LimitScopePerTeam || scope == LimitScopePerKey || scope == LimitScopeBoth
+}
+
+// limitScopeCounter returns the counter expression a limit scope keys on
+func limitScopeCounter(scope string) string {
+	switch scope {
+	case LimitScopePerTeam:
+		return teamCounterExpression
+	case LimitScopePerKey:
+		return keyCounterExpression
+	}
+	return userCounterExpression
+}
+
 // PolicyManager handles Kuadrant policy operations
 type PolicyManager struct {
 	kuadrantClient           dynamic.Interface
-	clientset                *kubernetes.Clientset
+	clientset                kubernetes.Interface
 	keyNamespace             string
 	tokenRateLimitPolicyName string
 	authPolicyName           string
+	defaultLimitScope        string
+	unlimitedMode            string
+	retryAttempts            int
+	sleep                    func(time.Duration)
+	gvrs                     *PolicyGVRs
+	applyMode                string
+
+	// Desired policies recorded in export-only mode, by kind
+	desiredMu     sync.Mutex
+	desired       map[string]*unstructured.Unstructured
+	desiredWrites int
 }
 
-// NewPolicyManager creates a new policy manager
-func NewPolicyManager(kuadrantClient dynamic.Interface, clientset *kubernetes.Clientset, keyNamespace, tokenRateLimitPolicyName, authPolicyName string) *PolicyManager {
+// NewPolicyManager creates a new policy manager. defaultLimitScope applies to
+// policies created without an explicit scope, unlimitedMode decides how
+// unlimited-policy is written, retryAttempts caps the tries at each policy
+// write and gvrs may be nil to use the default policy versions. applyMode
+// decides whether policy changes are written or only recorded for export.
+func NewPolicyManager(kuadrantClient dynamic.Interface, clientset kubernetes.Interface, keyNamespace, tokenRateLimitPolicyName, authPolicyName, defaultLimitScope, unlimitedMode string, retryAttempts int, gvrs *PolicyGVRs, applyMode string) *PolicyManager {
+	if gvrs == nil {
+		gvrs = DefaultPolicyGVRs()
+	}
 	return &PolicyManager{
 		kuadrantClient:           kuadrantClient,
 		clientset:                clientset,
 		keyNamespace:             keyNamespace,
 		tokenRateLimitPolicyName: tokenRateLimitPolicyName,
 		authPolicyName:           authPolicyName,
+		defaultLimitScope:        defaultLimitScope,
+		unlimitedMode:            unlimitedMode,
+		retryAttempts:            retryAttempts,
+		sleep:                    time.Sleep,
+		gvrs:                     gvrs,
+		applyMode:                applyMode,
 	}
 }
 
@@ -44,30 +100,117 @@ func (p *PolicyManager) RemoveTeamFromAuthPolicy(policyName string) error {
 	return p.updateAuthPolicyForTeam(policyName, false)
 }
 
-// AddTeamToTokenRateLimit adds a team policy to the TokenRateLimitPolicy
-func (p *PolicyManager) AddTeamToTokenRateLimit(policyName string, tokenLimit int, timeWindow string) error {
-	return p.updateTokenRateLimitPolicyForTeam(policyName, true, tokenLimit, timeWindow)
+// AddTeamToTokenRateLimit adds a team policy to the TokenRateLimitPolicy. An
+// empty scope or zero team limit keeps what the policy already has in force.
+// teamTokenLimit is the shared team-wide limit used by the "both" scope.
+func (p *PolicyManager) AddTeamToTokenRateLimit(policyName string, tokenLimit int, timeWindow, scope string, teamTokenLimit int) error {
+	if isUnlimitedPolicy(policyName) {
+		return p.applyUnlimitedPolicy()
+	}
+
+	tokenLimit, timeWindow, scope, teamTokenLimit, err := p.tokenLimitDefaults(policyName, tokenLimit, timeWindow, scope, teamTokenLimit)
+	if err != nil {
+		return err
+	}
+
+	return p.updateTokenRateLimitPolicyForTeam(policyName, true, tokenLimit, timeWindow, scope, teamTokenLimit)
+}
+
+// tokenLimitDefaults fills in the limits not provided for a policy, keeping
+// the scope it is currently keyed on
+func (p *PolicyManager) tokenLimitDefaults(policyName string, tokenLimit int, timeWindow, scope string, teamTokenLimit int) (int, string, string, int, error) {
+	// Set default values if not provided
+	if tokenLimit <= 0 {
+		tokenLimit = 100000
+	}
+	if timeWindow == "" {
+		timeWindow = "1h"
+	}
+
+	if scope == "" || teamTokenLimit <= 0 {
+		currentScope, currentTeamLimit, err := p.GetPolicyLimitScope(policyName)
+		if err != nil {
+			currentScope = p.defaultLimitScope
+		}
+		if scope == "" {
+			scope = currentScope
+		}
+		if teamTokenLimit <= 0 {
+			teamTokenLimit = currentTeamLimit
+		}
+	}
+	if scope == "" {
+		scope = LimitScopePerUser
+	}
+	if !IsValidLimitScope(scope) {
+		return 0, "", "", 0, fmt.Errorf("invalid limit scope: %s", scope)
+	}
+	if teamTokenLimit <= 0 {
+		teamTokenLimit = tokenLimit
+	}
+
+	return tokenLimit, timeWindow, scope, teamTokenLimit, nil
+}
+
+// AddBlockingLimitToTokenRateLimit adds a policy whose limit is zero, so no
+// tokens are allowed for its group
+func (p *PolicyManager) AddBlockingLimitToTokenRateLimit(policyName string) error {
+	return p.updateTokenRateLimitPolicyForTeam(policyName, true, 0, "1m", LimitScopePerUser, 0)
 }
 
 // RemoveTeamFromTokenRateLimit removes a team policy from the TokenRateLimitPolicy
 func (p *PolicyManager) RemoveTeamFromTokenRateLimit(policyName string) error {
-	return p.updateTokenRateLimitPolicyForTeam(policyName, false, 0, "")
+	return p.updateTokenRateLimitPolicyForTeam(policyName, false, 0, "", "", 0)
+}
+
+// GetPolicyLimitScope reports which counters a policy's limits are keyed on,
+// along with the shared team-wide limit when the scope is "both"
+func (p *PolicyManager) GetPolicyLimitScope(policyName string) (string, int, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+
+	limits, _, _ := unstructured.NestedMap(policyObj.Object, "spec", "limits")
+	limitConfig, ok := limits[policyName].(map[string]interface{})
+	if !ok {
+		return "", 0, fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", policyName)
+	}
+
+	switch limitCounterExpression(limitConfig) {
+	case teamCounterExpression:
+		return LimitScopePerTeam, 0, nil
+	case keyCounterExpression:
+		return LimitScopePerKey, 0, nil
+	}
+	if teamLimit, ok := limits[teamLimitName(policyName)].(map[string]interface{}); ok {
+		teamTokenLimit := 0
+		if rates, ok := teamLimit["rates"].([]interface{}); ok && len(rates) > 0 {
+			if rate, ok := rates[0].(map[string]interface{}); ok {
+				teamTokenLimit = int(numberValue(rate["limit"]))
+			}
+		}
+		return LimitScopeBoth, teamTokenLimit, nil
+	}
+	return LimitScopePerUser, 0, nil
 }
 
 // PolicyExists checks if a policy exists in the TokenRateLimitPolicy
 func (p *PolicyManager) PolicyExists(policyName string) bool {
+	// An exempted unlimited-policy exists without a limit entry
+	if isUnlimitedPolicy(policyName) && p.unlimitedMode == UnlimitedModeExempt {
+		return true
+	}
 	_, _, err := p.GetPolicyLimits(policyName)
 	return err == nil
 }
 
 // GetPolicyLimits retrieves the current token limits for a policy
 func (p *PolicyManager) GetPolicyLimits(policyName string) (int, string, error) {
-	// Define TokenRateLimitPolicy GVR
-	tokenRateLimitGVR := schema.GroupVersionResource{
-		Group:    "kuadrant.io",
-		Version:  "v1alpha1",
-		Resource: "tokenratelimitpolicies",
-	}
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
 
 	// Get the current TokenRateLimitPolicy
 	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
@@ -87,8 +230,8 @@ func (p *PolicyManager) GetPolicyLimits(policyName string) (int, string, error)
 							tokenLimit := 100000 // default
 							timeWindow := "1h"   // default
 							
-							if limit, ok := rate["limit"].(float64); ok {
-								tokenLimit = int(limit)
+							if limit, ok := rate["limit"]; ok {
+								tokenLimit = int(numberValue(limit))
 							}
 							if window, ok := rate["window"].(string); ok {
 								timeWindow = window
@@ -109,6 +252,10 @@ func (p *PolicyManager) GetPolicyLimits(policyName string) (int, string, error)
 
 // RestartKuadrantComponents restarts Authorino and Kuadrant operator
 func (p *PolicyManager) RestartKuadrantComponents() error {
+	if p.skipRestart() {
+		return nil
+	}
+
 	// Restart Authorino deployment
 	err := p.restartDeployment("kuadrant-system", "authorino")
 	if err != nil {
@@ -139,22 +286,62 @@ func (p *PolicyManager) RestartKuadrantComponents() error {
 
 // updateAuthPolicyForTeam updates the AuthPolicy rego rules to include/exclude a team's policy
 func (p *PolicyManager) updateAuthPolicyForTeam(policyName string, add bool) error {
-	// Define AuthPolicy GVR
-	authPolicyGVR := schema.GroupVersionResource{
-		Group:    "kuadrant.io",
-		Version:  "v1",
-		Resource: "authpolicies",
+	authPolicyGVR := p.gvrs.AuthPolicy()
+
+	// Concurrent changes to the shared policy conflict on its resource
+	// version, so re-read and reapply rather than overwrite them
+	err := p.retryPolicyWrite("AuthPolicy", func() error {
+		// Get the current AuthPolicy
+		authPolicyObj, err := p.readPolicyForWrite(authPolicyGVR, "AuthPolicy", p.authPolicyName)
+		if err != nil {
+			return fmt.Errorf("failed to get AuthPolicy: %w", err)
+		}
+		liveHash := specHash(authPolicyObj)
+
+		renderAuthPolicyGroups(authPolicyObj, policyName, add)
+
+		// Apply the updated AuthPolicy
+		return p.writePolicy(authPolicyGVR, "AuthPolicy", authPolicyObj, liveHash)
+	})
+	if err != nil {
+		return err
 	}
 
-	// Get the current AuthPolicy
-	authPolicyObj, err := p.kuadrantClient.Resource(authPolicyGVR).Namespace(p.keyNamespace).Get(
-		context.Background(), p.authPolicyName, metav1.GetOptions{})
+	log.Printf("Updated AuthPolicy to %s group: %s", map[bool]string{true: "include", false: "exclude"}[add], policyName)
+	return nil
+}
+
+// updateTokenRateLimitPolicyForTeam updates the TokenRateLimitPolicy limits to include/exclude a team's policy
+func (p *PolicyManager) updateTokenRateLimitPolicyForTeam(policyName string, add bool, tokenLimit int, timeWindow, scope string, teamTokenLimit int) error {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	// Retried like the AuthPolicy, other teams change the same policy
+	err := p.retryPolicyWrite("TokenRateLimitPolicy", func() error {
+		// Get the current TokenRateLimitPolicy
+		policyObj, err := p.readPolicyForWrite(tokenRateLimitGVR, "TokenRateLimitPolicy", p.tokenRateLimitPolicyName)
+		if err != nil {
+			return fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+		}
+		liveHash := specHash(policyObj)
+
+		renderTokenRateLimit(policyObj, policyName, add, tokenLimit, timeWindow, scope, teamTokenLimit)
+
+		// Apply the updated TokenRateLimitPolicy
+		return p.writePolicy(tokenRateLimitGVR, "TokenRateLimitPolicy", policyObj, liveHash)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get AuthPolicy: %w", err)
+		return err
 	}
 
+	log.Printf("Updated TokenRateLimitPolicy to %s group: %s", map[bool]string{true: "include", false: "exclude"}[add], policyName)
+	return nil
+}
+
+// renderAuthPolicyGroups adds or removes a policy's group in the AuthPolicy
+// rego rules without applying the change
+func renderAuthPolicyGroups(authPolicyObj *unstructured.Unstructured, policyName string, add bool) {
 	// Parse existing allowed groups from rego
-	allowedGroups := []string{"free", "premium", "enterprise"} // default groups
+	allowedGroups := append([]string{}, builtinAuthGroups...)
 
 	// Extract current rego to see if there are additional groups
 	if spec, ok := authPolicyObj.Object["spec"].(map[string]interface{}); ok {
@@ -235,84 +422,107 @@ func (p *PolicyManager) updateAuthPolicyForTeam(policyName string, add bool) err
 					"rego": newRego,
 				},
 			}
+			// Kept in place on every write, so older deployments gain it
+			renderModelAllowlist(auth)
 		}
 	}
-
-	// Apply the updated AuthPolicy
-	_, err = p.kuadrantClient.Resource(authPolicyGVR).Namespace(p.keyNamespace).Update(
-		context.Background(), authPolicyObj, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to update AuthPolicy: %w", err)
-	}
-
-	log.Printf("Updated AuthPolicy to %s group: %s", map[bool]string{true: "include", false: "exclude"}[add], policyName)
-	return nil
 }
 
-// updateTokenRateLimitPolicyForTeam updates the TokenRateLimitPolicy limits to include/exclude a team's policy
-func (p *PolicyManager) updateTokenRateLimitPolicyForTeam(policyName string, add bool, tokenLimit int, timeWindow string) error {
-	// Define TokenRateLimitPolicy GVR
-	tokenRateLimitGVR := schema.GroupVersionResource{
-		Group:    "kuadrant.io",
-		Version:  "v1alpha1",
-		Resource: "tokenratelimitpolicies",
-	}
-
-	// Get the current TokenRateLimitPolicy
-	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
-		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
-	}
-
+// renderTokenRateLimit adds or removes a policy's limits in the
+// TokenRateLimitPolicy without applying the change
+func renderTokenRateLimit(policyObj *unstructured.Unstructured, policyName string, add bool, tokenLimit int, timeWindow, scope string, teamTokenLimit int) {
 	if spec, ok := policyObj.Object["spec"].(map[string]interface{}); ok {
 		if limits, ok := spec["limits"].(map[string]interface{}); ok {
 			limitName := fmt.Sprintf("%s", policyName)
 
+			// The shared team-wide limit only exists for the "both" scope
+			delete(limits, teamLimitName(policyName))
+
 			if add {
-				// Set default values if not provided
-				if tokenLimit <= 0 {
-					tokenLimit = 100000
-				}
-				if timeWindow == "" {
-					timeWindow = "1h"
+				// Add new limit for the team, counted per user, team or key
+				limitConfig := tokenRateLimit(policyName, tokenLimit, timeWindow, limitScopeCounter(scope))
+
+				// Secondary rates, such as a burst cap, outlive changes to the main rate
+				if previous, ok := limits[limitName].(map[string]interface{}); ok {
+					if rates := limitRates(previous); len(rates) > 1 {
+						for _, rate := range rates[1:] {
+							limitConfig["rates"] = append(limitConfig["rates"].([]map[string]interface{}), rate)
+						}
+					}
 				}
+				limits[limitName] = limitConfig
 
-				// Add new limit for the team
-				limits[limitName] = map[string]interface{}{
-					"rates": []map[string]interface{}{
-						{
-							"limit":  tokenLimit,
-							"window": timeWindow,
-						},
-					},
-					"when": []map[string]interface{}{
-						{
-							"predicate": fmt.Sprintf("auth.identity.groups.split(\",\").exists(g, g == \"%s\")", policyName),
-						},
-					},
-					"counters": []map[string]interface{}{
-						{
-							"expression": "auth.identity.userid",
-						},
-					},
+				if scope == LimitScopeBoth {
+					limits[teamLimitName(policyName)] = tokenRateLimit(policyName, teamTokenLimit, timeWindow, teamCounterExpression)
 				}
+
+				// Models with their own limit stay out of the blanket limits
+				excludeModelOverrides(limits, policyName)
 			} else {
-				// Remove limit for the team
+				// Remove limit for the team, along with its model limits
 				delete(limits, limitName)
+				for name, limitConfig := range limits {
+					if _, ok := limitModel(policyName, name, limitConfig); ok {
+						delete(limits, name)
+					}
+				}
 			}
 		}
 	}
+}
+
+// tokenRateLimit builds a TokenRateLimitPolicy limit for a policy's group
+func tokenRateLimit(policyName string, tokenLimit int, timeWindow, counterExpression string) map[string]interface{} {
+	return map[string]interface{}{
+		"rates": []map[string]interface{}{
+			{
+				"limit":  tokenLimit,
+				"window": timeWindow,
+			},
+		},
+		"when": []map[string]interface{}{
+			{
+				"predicate": fmt.Sprintf("auth.identity.groups.split(\",\").exists(g, g == \"%s\")", policyName),
+			},
+		},
+		"counters": []map[string]interface{}{
+			{
+				"expression": counterExpression,
+			},
+		},
+	}
+}
 
-	// Apply the updated TokenRateLimitPolicy
-	_, err = p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Update(
-		context.Background(), policyObj, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to update TokenRateLimitPolicy: %w", err)
+// teamLimitName names the shared team-wide limit emitted next to a policy's
+// per-user limit for the "both" scope
+func teamLimitName(policyName string) string {
+	return policyName + "-per-team"
+}
+
+// limitCounterExpression returns the first counter expression of a limit
+func limitCounterExpression(limitConfig map[string]interface{}) string {
+	counters, ok := limitConfig["counters"].([]interface{})
+	if !ok || len(counters) == 0 {
+		return ""
+	}
+	if counter, ok := counters[0].(map[string]interface{}); ok {
+		expression, _ := counter["expression"].(string)
+		return expression
 	}
+	return ""
+}
 
-	log.Printf("Updated TokenRateLimitPolicy to %s group: %s", map[bool]string{true: "include", false: "exclude"}[add], policyName)
-	return nil
+// numberValue converts a numeric value decoded from JSON to float64
+func numberValue(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	}
+	return 0
 }
 
 // restartDeployment restarts a deployment by patching it with a restart annotation
@@ -333,17 +543,8 @@ func (p *PolicyManager) restartDeployment(namespace, deploymentName string) erro
 
 // verifyPolicyReload checks if AuthPolicy and TokenRateLimitPolicy are in Enforced state
 func (p *PolicyManager) verifyPolicyReload() error {
-	// Define policy GVRs
-	authPolicyGVR := schema.GroupVersionResource{
-		Group:    "kuadrant.io",
-		Version:  "v1",
-		Resource: "authpolicies",
-	}
-	tokenRateLimitGVR := schema.GroupVersionResource{
-		Group:    "kuadrant.io",
-		Version:  "v1alpha1",
-		Resource: "tokenratelimitpolicies",
-	}
+	authPolicyGVR := p.gvrs.AuthPolicy()
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
 
 	// Check AuthPolicy status with timeout
 	timeout := time.Now().Add(30 * time.Second)
@@ -0,0 +1,394 @@
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNamespace                = "llm"
+	testTokenRateLimitPolicyName = "gateway-token-rate-limits"
+	testAuthPolicyName           = "gateway-auth-policy"
+)
+
+// enforcedStatus is the status Kuadrant gives a policy it enforces
+func enforcedStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Enforced", "status": "True"},
+		},
+	}
+}
+
+// testTokenRateLimitPolicy returns an enforced TokenRateLimitPolicy holding
+// a free tier limit and a target set by whoever installed it
+func testTokenRateLimitPolicy() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kuadrant.io/v1alpha1",
+		"kind":       "TokenRateLimitPolicy",
+		"metadata": map[string]interface{}{
+			"name":            testTokenRateLimitPolicyName,
+			"namespace":       testNamespace,
+			"resourceVersion": "1",
+			"annotations":     map[string]interface{}{"example.com/owner": "platform"},
+		},
+		"spec": map[string]interface{}{
+			"targetRef": map[string]interface{}{
+				"group": "gateway.networking.k8s.io",
+				"kind":  "Gateway",
+				"name":  "inference-gateway",
+			},
+			"limits": map[string]interface{}{
+				"free": map[string]interface{}{
+					"rates": []interface{}{
+						map[string]interface{}{"limit": int64(100), "window": "1m"},
+					},
+				},
+			},
+		},
+		"status": enforcedStatus(),
+	}}
+}
+
+// testAuthPolicy returns an enforced AuthPolicy allowing the built-in groups
+func testAuthPolicy() *unstructured.Unstructured {
+	rego := `groups := split(object.get(input.auth.identity.metadata.annotations, "kuadrant.io/groups", ""), ",")`
+	for _, group := range builtinAuthGroups {
+		rego += fmt.Sprintf("\nallow { groups[_] == \"%s\" }", group)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kuadrant.io/v1",
+		"kind":       "AuthPolicy",
+		"metadata": map[string]interface{}{
+			"name":            testAuthPolicyName,
+			"namespace":       testNamespace,
+			"resourceVersion": "1",
+		},
+		"spec": map[string]interface{}{
+			"targetRef": map[string]interface{}{
+				"group": "gateway.networking.k8s.io",
+				"kind":  "Gateway",
+				"name":  "inference-gateway",
+			},
+			"rules": map[string]interface{}{
+				"authentication": map[string]interface{}{
+					"api-key-users": map[string]interface{}{"apiKey": map[string]interface{}{}},
+				},
+				"authorization": map[string]interface{}{
+					"allow-groups": map[string]interface{}{
+						"opa": map[string]interface{}{"rego": rego},
+					},
+				},
+			},
+		},
+		"status": enforcedStatus(),
+	}}
+}
+
+// newFakePolicyManager returns a policy manager writing to a fake dynamic
+// client seeded with both shared policies. Retries do not wait.
+func newFakePolicyManager(retryAttempts int) (*PolicyManager, *dynamicfake.FakeDynamicClient) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testTokenRateLimitPolicy(), testAuthPolicy())
+	p := &PolicyManager{
+		kuadrantClient:           client,
+		clientset:                k8sfake.NewSimpleClientset(),
+		keyNamespace:             testNamespace,
+		tokenRateLimitPolicyName: testTokenRateLimitPolicyName,
+		authPolicyName:           testAuthPolicyName,
+		defaultLimitScope:        LimitScopePerUser,
+		unlimitedMode:            UnlimitedModeHighLimit,
+		retryAttempts:            retryAttempts,
+		sleep:                    func(time.Duration) {},
+		gvrs:                     DefaultPolicyGVRs(),
+		applyMode:                ApplyModeApply,
+	}
+	return p, client
+}
+
+// enforceResourceVersions makes the fake client reject updates read at an
+// older resource version with a conflict, as the API server does
+func enforceResourceVersions(client *dynamicfake.FakeDynamicClient) {
+	client.PrependReactor("update", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(k8stesting.UpdateAction).GetObject().(*unstructured.Unstructured)
+		current, err := client.Tracker().Get(action.GetResource(), action.GetNamespace(), obj.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+
+		currentVersion := current.(*unstructured.Unstructured).GetResourceVersion()
+		if obj.GetResourceVersion() != currentVersion {
+			return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), obj.GetName(),
+				errors.New("the object has been modified"))
+		}
+		version, _ := strconv.Atoi(currentVersion)
+		obj.SetResourceVersion(strconv.Itoa(version + 1))
+		return false, nil, nil
+	})
+}
+
+// countUpdates counts the updates sent for a resource
+func countUpdates(client *dynamicfake.FakeDynamicClient, resource string) int {
+	updates := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" && action.GetResource().Resource == resource {
+			updates++
+		}
+	}
+	return updates
+}
+
+// getPolicy reads a policy back from the fake client
+func getPolicy(t *testing.T, client *dynamicfake.FakeDynamicClient, resource string) *unstructured.Unstructured {
+	t.Helper()
+	gvrs := DefaultPolicyGVRs()
+	gvr, name := gvrs.TokenRateLimitPolicy(), testTokenRateLimitPolicyName
+	if resource == gvrs.AuthPolicy().Resource {
+		gvr, name = gvrs.AuthPolicy(), testAuthPolicyName
+	}
+	obj, err := client.Tracker().Get(gvr, testNamespace, name)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", resource, err)
+	}
+	return obj.(*unstructured.Unstructured)
+}
+
+// policyLimits returns the limit entries of the TokenRateLimitPolicy
+func policyLimits(t *testing.T, client *dynamicfake.FakeDynamicClient) map[string]interface{} {
+	t.Helper()
+	limits, _, _ := unstructured.NestedMap(getPolicy(t, client, "tokenratelimitpolicies").Object, "spec", "limits")
+	return limits
+}
+
+// authRego returns the group rules of the AuthPolicy
+func authRego(t *testing.T, client *dynamicfake.FakeDynamicClient) string {
+	t.Helper()
+	rego, _, _ := unstructured.NestedString(getPolicy(t, client, "authpolicies").Object,
+		"spec", "rules", "authorization", "allow-groups", "opa", "rego")
+	return rego
+}
+
+func TestAddTeamToTokenRateLimitIsIdempotent(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+
+	for i := 0; i < 3; i++ {
+		if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+			t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+		}
+	}
+
+	if updates := countUpdates(client, "tokenratelimitpolicies"); updates != 1 {
+		t.Errorf("reapplying the same limit sent %d updates, want 1", updates)
+	}
+
+	policyObj := getPolicy(t, client, "tokenratelimitpolicies")
+	if policyObj.GetAnnotations()[annotationSpecHash] != specHash(policyObj) {
+		t.Errorf("spec hash annotation does not match the written spec")
+	}
+	if policyObj.GetAnnotations()["example.com/owner"] != "platform" {
+		t.Errorf("annotation set by another writer was dropped")
+	}
+	if name, _, _ := unstructured.NestedString(policyObj.Object, "spec", "targetRef", "name"); name != "inference-gateway" {
+		t.Errorf("targetRef set by another writer was dropped")
+	}
+
+	limits := policyLimits(t, client)
+	if _, ok := limits["free"]; !ok {
+		t.Errorf("limit of another policy was dropped")
+	}
+	rates := limitRates(limits["gold"].(map[string]interface{}))
+	if len(rates) != 1 || numberValue(rates[0]["limit"]) != 5000 || rates[0]["window"] != "1h" {
+		t.Errorf("gold rates = %v, want 5000 per 1h", rates)
+	}
+
+	// A changed limit is written again
+	if err := p.AddTeamToTokenRateLimit("gold", 6000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	if updates := countUpdates(client, "tokenratelimitpolicies"); updates != 2 {
+		t.Errorf("changing the limit sent %d updates in total, want 2", updates)
+	}
+}
+
+func TestAddTeamToAuthPolicyIsIdempotent(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+
+	for i := 0; i < 3; i++ {
+		if err := p.AddTeamToAuthPolicy("gold"); err != nil {
+			t.Fatalf("AddTeamToAuthPolicy() = %v", err)
+		}
+	}
+
+	if updates := countUpdates(client, "authpolicies"); updates != 1 {
+		t.Errorf("reapplying the same group sent %d updates, want 1", updates)
+	}
+	rego := authRego(t, client)
+	if strings.Count(rego, `groups[_] == "gold"`) != 1 {
+		t.Errorf("rego = %q, want the gold group once", rego)
+	}
+	for _, group := range builtinAuthGroups {
+		if !strings.Contains(rego, fmt.Sprintf("groups[_] == %q", group)) {
+			t.Errorf("rego = %q, lost built-in group %s", rego, group)
+		}
+	}
+
+	// Removing the group twice writes once
+	for i := 0; i < 2; i++ {
+		if err := p.RemoveTeamFromAuthPolicy("gold"); err != nil {
+			t.Fatalf("RemoveTeamFromAuthPolicy() = %v", err)
+		}
+	}
+	if updates := countUpdates(client, "authpolicies"); updates != 2 {
+		t.Errorf("removing the group sent %d updates in total, want 2", updates)
+	}
+	if strings.Contains(authRego(t, client), `"gold"`) {
+		t.Errorf("gold group is still allowed")
+	}
+}
+
+func TestPolicyWriteRereadsAfterConflict(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	enforceResourceVersions(client)
+
+	// Another writer changes the policy between the read and the first update
+	gvr := DefaultPolicyGVRs().TokenRateLimitPolicy()
+	interfered := false
+	client.PrependReactor("update", "tokenratelimitpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if interfered {
+			return false, nil, nil
+		}
+		interfered = true
+
+		current, err := client.Tracker().Get(gvr, testNamespace, testTokenRateLimitPolicyName)
+		if err != nil {
+			return true, nil, err
+		}
+		other := current.(*unstructured.Unstructured)
+		limits, _, _ := unstructured.NestedMap(other.Object, "spec", "limits")
+		limits["silver"] = map[string]interface{}{
+			"rates": []interface{}{map[string]interface{}{"limit": int64(1000), "window": "1m"}},
+		}
+		if err := unstructured.SetNestedMap(other.Object, limits, "spec", "limits"); err != nil {
+			return true, nil, err
+		}
+		other.SetResourceVersion("2")
+		return false, nil, client.Tracker().Update(gvr, other, testNamespace)
+	})
+
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+
+	if updates := countUpdates(client, "tokenratelimitpolicies"); updates != 2 {
+		t.Errorf("sent %d updates, want a conflict and a retry", updates)
+	}
+	limits := policyLimits(t, client)
+	for _, name := range []string{"free", "silver", "gold"} {
+		if _, ok := limits[name]; !ok {
+			t.Errorf("limit %s is missing after the conflicting writes", name)
+		}
+	}
+}
+
+func TestPolicyWriteConflictGivesUp(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	client.PrependReactor("update", "tokenratelimitpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(action.GetResource().GroupResource(), testTokenRateLimitPolicyName,
+			errors.New("the object has been modified"))
+	})
+
+	err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0)
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v, want a conflict", err)
+	}
+	if !strings.Contains(err.Error(), "gave up after 3 attempts") {
+		t.Errorf("AddTeamToTokenRateLimit() = %q, want the attempt count", err)
+	}
+	if _, ok := policyLimits(t, client)["gold"]; ok {
+		t.Errorf("gold limit was written despite the conflict")
+	}
+}
+
+func TestConcurrentPolicyWrites(t *testing.T) {
+	const writers = 5
+	p, client := newFakePolicyManager(writers + 1)
+	enforceResourceVersions(client)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		policyName := fmt.Sprintf("team-%d-policy", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- p.AddTeamToTokenRateLimit(policyName, 1000, "1m", LimitScopePerUser, 0)
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- p.AddTeamToAuthPolicy(policyName)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent write failed: %v", err)
+		}
+	}
+
+	limits := policyLimits(t, client)
+	rego := authRego(t, client)
+	for i := 0; i < writers; i++ {
+		policyName := fmt.Sprintf("team-%d-policy", i)
+		if _, ok := limits[policyName]; !ok {
+			t.Errorf("limit %s was lost to a concurrent write", policyName)
+		}
+		if !strings.Contains(rego, fmt.Sprintf("groups[_] == %q", policyName)) {
+			t.Errorf("group %s was lost to a concurrent write", policyName)
+		}
+	}
+	if _, ok := limits["free"]; !ok {
+		t.Errorf("limit of another policy was dropped")
+	}
+}
+
+func TestGetPolicyLimitsReadsDecodedIntegers(t *testing.T) {
+	p, _ := newFakePolicyManager(3)
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1m", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+
+	tests := []struct {
+		policy     string
+		wantLimit  int
+		wantWindow string
+	}{
+		{policy: "free", wantLimit: 100, wantWindow: "1m"},
+		{policy: "gold", wantLimit: 5000, wantWindow: "1m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			limit, window, err := p.GetPolicyLimits(tt.policy)
+			if err != nil || limit != tt.wantLimit || window != tt.wantWindow {
+				t.Errorf("GetPolicyLimits(%s) = %d, %q, %v, want %d per %s", tt.policy, limit, window, err, tt.wantLimit, tt.wantWindow)
+			}
+		})
+	}
+
+	if _, _, err := p.GetPolicyLimits("silver"); err == nil {
+		t.Errorf("GetPolicyLimits(silver) = nil error for a missing policy")
+	}
+}
@@ -0,0 +1,230 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// keySelectorLabels are the labels Authorino and the key manager select an
+// active API key secret by
+var keySelectorLabels = map[string]string{
+	"kuadrant.io/auth-secret": "true",
+	"kuadrant.io/apikeys-by":  "rhcl-keys",
+	"app":                     "llm-gateway",
+}
+
+// ValidatePolicies runs checks on everything a team's keys depend on to be
+// authorized and rate limited, from its config to the labels on its keys.
+// Each failed test says what was expected and what was found.
+func (m *Manager) ValidatePolicies(teamID string) (*PolicyValidation, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	validation := &PolicyValidation{
+		TeamID:    teamID,
+		CheckedAt: time.Now().Format(time.RFC3339),
+		Tests:     make([]ValidationTest, 0),
+	}
+
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		validation.Tests = append(validation.Tests, ValidationTest{
+			Name:     "team_config",
+			Message:  "team config secret not found",
+			Expected: fmt.Sprintf("secret team-%s-config in %s", teamID, m.keyNamespace),
+			Actual:   "not found",
+		})
+		return validation, nil
+	}
+	validation.Policy = teamSecret.Annotations["maas/policy"]
+	configTest := ValidationTest{
+		Name:     "team_config",
+		Passed:   validation.Policy != "",
+		Expected: "team config with a maas/policy annotation",
+		Actual:   fmt.Sprintf("policy %q", validation.Policy),
+	}
+	if configTest.Passed {
+		configTest.Message = fmt.Sprintf("team config found, policy %s", validation.Policy)
+	} else {
+		configTest.Message = "team config has no policy"
+	}
+	validation.Tests = append(validation.Tests, configTest)
+
+	if validation.Policy != "" {
+		for _, status := range m.policyMgr.GetPolicyStatus(validation.Policy) {
+			validation.Tests = append(validation.Tests, policyAcceptedTest(status))
+		}
+		validation.Tests = append(validation.Tests, m.policySpecTest(teamID))
+	}
+
+	keys, err := m.clientset.CoreV1().Secrets(m.keyNamespaceOf(teamSecret)).List(context.Background(),
+		metav1.ListOptions{LabelSelector: fmt.Sprintf("kuadrant.io/apikeys-by=rhcl-keys,maas/team-id=%s", teamID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team API keys: %w", err)
+	}
+	active := make([]corev1.Secret, 0, len(keys.Items))
+	for _, key := range keys.Items {
+		if isActiveKey(&key) {
+			active = append(active, key)
+		}
+	}
+	validation.Tests = append(validation.Tests,
+		ValidationTest{
+			Name:     "active_keys",
+			Passed:   len(active) > 0,
+			Message:  fmt.Sprintf("%d of %d keys are active", len(active), len(keys.Items)),
+			Expected: "at least 1 active key",
+			Actual:   fmt.Sprintf("%d active", len(active)),
+		},
+		keyLabelsTest(active, teamID, validation.Policy),
+	)
+
+	validation.Valid = true
+	for _, test := range validation.Tests {
+		if !test.Passed {
+			validation.Valid = false
+		}
+	}
+	return validation, nil
+}
+
+// policyAcceptedTest checks a policy exists and Kuadrant has accepted it
+func policyAcceptedTest(status PolicyStatus) ValidationTest {
+	test := ValidationTest{
+		Name:     strings.ToLower(status.Kind) + "_accepted",
+		Expected: fmt.Sprintf("%s %s/%s exists with Accepted=True", status.Kind, status.Namespace, status.Name),
+	}
+	switch {
+	case status.Error != "":
+		test.Actual = "error: " + status.Error
+		test.Message = fmt.Sprintf("failed to read %s", status.Kind)
+	case !status.Exists:
+		test.Actual = "not found"
+		test.Message = fmt.Sprintf("%s not found, re-apply the deployment manifests", status.Kind)
+	case status.Accepted == nil:
+		test.Actual = "no Accepted condition"
+		test.Message = fmt.Sprintf("%s has not been processed by Kuadrant", status.Kind)
+	default:
+		test.Actual = fmt.Sprintf("Accepted=%s", status.Accepted.Status)
+		if status.Accepted.Reason != "" {
+			test.Actual += " (" + status.Accepted.Reason + ")"
+		}
+		test.Passed = status.Accepted.Status == "True"
+		if test.Passed {
+			test.Message = fmt.Sprintf("%s is accepted", status.Kind)
+		} else {
+			test.Message = status.Accepted.Message
+		}
+	}
+	return test
+}
+
+// policySpecTest checks the team's policy entries match its tier and stored
+// overrides
+func (m *Manager) policySpecTest(teamID string) ValidationTest {
+	test := ValidationTest{Name: "policy_spec", Expected: ComplianceCompliant}
+	report, err := m.CheckCompliance(&ComplianceOptions{TeamID: teamID})
+	if err != nil {
+		test.Actual = "error: " + err.Error()
+		test.Message = "failed to compare the policies with the cluster"
+		return test
+	}
+	if len(report.TeamResults) == 0 {
+		test.Actual = "no result"
+		test.Message = "team was not found by the compliance check"
+		return test
+	}
+
+	result := report.TeamResults[0]
+	test.Actual = result.Status
+	switch result.Status {
+	case ComplianceCompliant:
+		test.Passed = true
+		test.Message = "policy entries match the team's tier and overrides"
+	case ComplianceNotChecked:
+		test.Passed = true
+		test.Message = fmt.Sprintf("policy %s is not checked against a tier", result.Policy)
+	default:
+		fields := make([]string, 0, len(result.Differences))
+		for _, difference := range result.Differences {
+			fields = append(fields, fmt.Sprintf("%s %s: %s (%s)", difference.Kind, difference.Field, difference.Detail, difference.Reason))
+		}
+		test.Message = strings.Join(fields, "; ")
+	}
+	return test
+}
+
+// keyLabelsTest checks active keys carry the labels and annotations the
+// AuthPolicy selects and reads them by
+func keyLabelsTest(keys []corev1.Secret, teamID, policy string) ValidationTest {
+	test := ValidationTest{
+		Name: "key_labels",
+		Expected: fmt.Sprintf("labels %s, maas/team-id=%s and annotations secret.kuadrant.io/user-id, kuadrant.io/groups=%s",
+			formatLabels(keySelectorLabels), teamID, policy),
+	}
+
+	problems := make([]string, 0)
+	for _, key := range keys {
+		missing := make([]string, 0)
+		for label, value := range keySelectorLabels {
+			if key.Labels[label] != value {
+				missing = append(missing, fmt.Sprintf("%s=%s", label, value))
+			}
+		}
+		if key.Labels["maas/team-id"] != teamID && key.Annotations["maas/team-id"] != teamID {
+			missing = append(missing, "maas/team-id="+teamID)
+		}
+		if key.Annotations["secret.kuadrant.io/user-id"] == "" {
+			missing = append(missing, "secret.kuadrant.io/user-id")
+		}
+		if policy != "" && key.Annotations["kuadrant.io/groups"] != policy {
+			missing = append(missing, "kuadrant.io/groups="+policy)
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			problems = append(problems, fmt.Sprintf("%s: %s", key.Name, strings.Join(missing, ", ")))
+		}
+	}
+
+	test.Passed = len(problems) == 0
+	if test.Passed {
+		test.Actual = fmt.Sprintf("%d active keys well-formed", len(keys))
+		test.Message = "active keys match the AuthPolicy selector"
+	} else {
+		test.Actual = strings.Join(problems, "; ")
+		test.Message = fmt.Sprintf("%d of %d active keys are missing labels or annotations", len(problems), len(keys))
+	}
+	return test
+}
+
+// isActiveKey reports whether a key secret is neither suspended, deactivated
+// nor expired
+func isActiveKey(key *corev1.Secret) bool {
+	if status := key.Annotations["maas/status"]; status != "" && status != "active" {
+		return false
+	}
+	if expiresAt := key.Annotations["maas/expires-at"]; expiresAt != "" {
+		expiry, err := time.Parse(time.RFC3339, expiresAt)
+		if err == nil && time.Now().After(expiry) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLabels renders labels as a sorted selector
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for label, value := range labels {
+		pairs = append(pairs, label+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,140 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// How policy changes reach the cluster
+const (
+	// ApplyModeApply writes policy changes to the cluster
+	ApplyModeApply = "apply"
+	// ApplyModeExportOnly records policy changes for export and never writes
+	// them, for clusters where a GitOps tool applies the exported manifests
+	ApplyModeExportOnly = "export-only"
+)
+
+// IsValidApplyMode checks if a policy apply mode is supported
+func IsValidApplyMode(mode string) bool {
+	return mode == ApplyModeApply || mode == ApplyModeExportOnly
+}
+
+// exportOnly reports whether policy changes are only recorded
+func (p *PolicyManager) exportOnly() bool {
+	return p.applyMode == ApplyModeExportOnly
+}
+
+// readPolicyForWrite reads a policy to change it. In export-only mode the
+// change is made to the recorded state, seeded from the cluster on the first
+// write, so successive changes build on each other.
+func (p *PolicyManager) readPolicyForWrite(gvr schema.GroupVersionResource, kind, name string) (*unstructured.Unstructured, error) {
+	if p.exportOnly() {
+		p.desiredMu.Lock()
+		recorded := p.desired[kind]
+		p.desiredMu.Unlock()
+		if recorded != nil {
+			return recorded.DeepCopy(), nil
+		}
+	}
+	return p.kuadrantClient.Resource(gvr).Namespace(p.keyNamespace).Get(
+		context.Background(), name, metav1.GetOptions{})
+}
+
+// recordPolicy keeps a policy's desired state in export-only mode
+func (p *PolicyManager) recordPolicy(kind string, policyObj *unstructured.Unstructured) {
+	p.desiredMu.Lock()
+	defer p.desiredMu.Unlock()
+	if p.desired == nil {
+		p.desired = make(map[string]*unstructured.Unstructured)
+	}
+	p.desired[kind] = policyObj.DeepCopy()
+	p.desiredWrites++
+}
+
+// recordedVersion counts the changes recorded in export-only mode, standing
+// in for the resource versions that stay unchanged there
+func (p *PolicyManager) recordedVersion() string {
+	p.desiredMu.Lock()
+	defer p.desiredMu.Unlock()
+	return strconv.Itoa(p.desiredWrites)
+}
+
+// ExportPolicies renders the TokenRateLimitPolicy and AuthPolicy as a
+// multi-document YAML stream, ordered by kind and without the metadata the
+// API server fills in. In export-only mode it holds the recorded desired
+// state, and otherwise the policies as applied.
+func (m *Manager) ExportPolicies() ([]byte, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+	return m.policyMgr.ExportPolicies()
+}
+
+// ExportPolicies renders both policies for export, see Manager.ExportPolicies
+func (p *PolicyManager) ExportPolicies() ([]byte, error) {
+	policies := []struct {
+		kind string
+		gvr  schema.GroupVersionResource
+		name string
+	}{
+		{"AuthPolicy", p.gvrs.AuthPolicy(), p.authPolicyName},
+		{"TokenRateLimitPolicy", p.gvrs.TokenRateLimitPolicy(), p.tokenRateLimitPolicyName},
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].kind < policies[j].kind })
+
+	var out bytes.Buffer
+	for _, policy := range policies {
+		policyObj, err := p.readPolicyForWrite(policy.gvr, policy.kind, policy.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", policy.kind, err)
+		}
+		data, err := yaml.Marshal(exportManifest(policyObj).Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", policy.kind, err)
+		}
+		out.WriteString("---\n")
+		out.Write(data)
+	}
+	return out.Bytes(), nil
+}
+
+// exportManifest keeps what a GitOps tool should apply of a policy: its
+// identity, labels, annotations and spec
+func exportManifest(policyObj *unstructured.Unstructured) *unstructured.Unstructured {
+	manifest := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	manifest.SetAPIVersion(policyObj.GetAPIVersion())
+	manifest.SetKind(policyObj.GetKind())
+	manifest.SetName(policyObj.GetName())
+	manifest.SetNamespace(policyObj.GetNamespace())
+	if labels := policyObj.GetLabels(); len(labels) > 0 {
+		manifest.SetLabels(labels)
+	}
+	annotations := policyObj.GetAnnotations()
+	delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	if len(annotations) > 0 {
+		manifest.SetAnnotations(annotations)
+	}
+	if spec, ok := policyObj.Object["spec"]; ok {
+		manifest.Object["spec"] = spec
+	}
+	return manifest
+}
+
+// skipRestart reports whether Kuadrant restarts are skipped, since nothing
+// was written for its components to reload
+func (p *PolicyManager) skipRestart() bool {
+	if p.exportOnly() {
+		log.Printf("Skipping Kuadrant restart in %s mode", ApplyModeExportOnly)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GetPolicyStatus reads the live TokenRateLimitPolicy and AuthPolicy from the
+// cluster and reports whether each exists, carries the policy's limit or
+// group, and has been accepted and enforced by Kuadrant
+func (p *PolicyManager) GetPolicyStatus(policyName string) []PolicyStatus {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+	authPolicyGVR := p.gvrs.AuthPolicy()
+
+	tokenRateLimit := p.readPolicyStatus(tokenRateLimitGVR, "TokenRateLimitPolicy", p.tokenRateLimitPolicyName,
+		func(obj *unstructured.Unstructured) bool {
+			limits, _, _ := unstructured.NestedMap(obj.Object, "spec", "limits")
+			_, found := limits[policyName]
+			return found
+		})
+	auth := p.readPolicyStatus(authPolicyGVR, "AuthPolicy", p.authPolicyName,
+		func(obj *unstructured.Unstructured) bool {
+			rego, _, _ := unstructured.NestedString(obj.Object, "spec", "rules", "authorization", "allow-groups", "opa", "rego")
+			return strings.Contains(rego, fmt.Sprintf("groups[_] == \"%s\"", policyName))
+		})
+
+	return []PolicyStatus{tokenRateLimit, auth}
+}
+
+// readPolicyStatus fetches one policy and summarizes its status conditions
+func (p *PolicyManager) readPolicyStatus(gvr schema.GroupVersionResource, kind, name string, hasEntry func(*unstructured.Unstructured) bool) PolicyStatus {
+	status := PolicyStatus{Kind: kind, Name: name, Namespace: p.keyNamespace}
+
+	obj, err := p.kuadrantClient.Resource(gvr).Namespace(p.keyNamespace).Get(
+		context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			status.Error = err.Error()
+		}
+		return status
+	}
+
+	status.Exists = true
+	status.TeamEntry = hasEntry(obj)
+	status.Generation = obj.GetGeneration()
+	status.ObservedGeneration, _, _ = unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		summary := &PolicyCondition{
+			Status:             fmt.Sprint(condition["status"]),
+			Reason:             stringValue(condition["reason"]),
+			Message:            stringValue(condition["message"]),
+			ObservedGeneration: int64(numberValue(condition["observedGeneration"])),
+			LastTransitionTime: stringValue(condition["lastTransitionTime"]),
+		}
+		switch condition["type"] {
+		case "Accepted":
+			status.Accepted = summary
+		case "Enforced":
+			status.Enforced = summary
+		}
+	}
+
+	return status
+}
+
+// policyStatus reports the live state of a team's policies. Drift is only
+// flagged for teams whose policy must have its own limit and group.
+func (m *Manager) policyStatus(teamID, policy string) *TeamPolicyStatus {
+	if m.policyMgr == nil || policy == "" {
+		return nil
+	}
+
+	status := &TeamPolicyStatus{Policy: policy, Policies: m.policyMgr.GetPolicyStatus(policy)}
+	if scope, _, err := m.policyMgr.GetPolicyLimitScope(policy); err == nil {
+		status.LimitScope = scope
+	}
+	if modelLimits, err := m.policyMgr.GetModelLimits(policy); err == nil && len(modelLimits) > 0 {
+		status.ModelLimits = modelLimits
+	}
+	if memberLimits, err := m.policyMgr.GetTeamUserLimits(teamID); err == nil && len(memberLimits) > 0 {
+		status.MemberLimits = memberLimits
+	}
+	if rates, err := m.policyMgr.GetPolicyRates(policy); err == nil {
+		status.Rates = rates
+	}
+	if burstLimit, burstWindow, err := m.policyMgr.GetPolicyBurst(policy); err == nil {
+		status.BurstLimit, status.BurstWindow = burstLimit, burstWindow
+	}
+	if isUnlimitedPolicy(policy) {
+		mode := m.policyMgr.UnlimitedMode()
+		if teamSecret, err := m.getTeamSecret(teamID); err == nil && teamSecret.Annotations[annotationUnlimitedMode] != "" {
+			mode = teamSecret.Annotations[annotationUnlimitedMode]
+		}
+		status.Unlimited = unlimitedDescription(mode)
+	}
+	if teamID != DefaultTeamID && policy != "unlimited-policy" {
+		for _, policyStatus := range status.Policies {
+			if !policyStatus.Exists || !policyStatus.TeamEntry {
+				status.Drift = true
+			}
+		}
+	}
+	return status
+}
+
+// GetPolicyStatus reports the live state of a team's Kuadrant policies
+func (m *Manager) GetPolicyStatus(teamID string) (*TeamPolicyStatus, error) {
+	policy, err := m.GetPolicy(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	return m.policyStatus(teamID, policy), nil
+}
+
+// SyncPolicies re-applies a team's limit and group to the Kuadrant policies,
+// keeping the limits currently in force where they can still be read. A
+// limitScope rewrites the counters of the policy, which every team on it
+// shares; empty keeps the current scope.
+func (m *Manager) SyncPolicies(teamID, limitScope string) (*TeamPolicyStatus, error) {
+	if limitScope != "" && !IsValidLimitScope(limitScope) {
+		return nil, fmt.Errorf("invalid limit scope: %s", limitScope)
+	}
+	policy, err := m.GetPolicy(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	pruned, changed, err := m.syncTeamPolicy(teamID, policy, limitScope)
+	if err != nil {
+		return nil, err
+	}
+
+	result := PolicyResultUnchanged
+	if changed {
+		result = PolicyResultUpdated
+		if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+			log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+		}
+		m.notifyPolicyResynced(teamID, policy)
+	}
+
+	log.Printf("Policies synced for team %s (%s): %s", teamID, policy, result)
+	status := m.policyStatus(teamID, policy)
+	status.Result = result
+	status.Pruned = pruned
+	return status, nil
+}
+
+// syncTeamPolicy re-applies a team's limit, group, model limits and member
+// limits without restarting Kuadrant. It returns the member limits pruned and
+// whether either policy was written. Writes that would not change a policy
+// are skipped, so a team already in sync reports no change. A concurrent
+// write by another team also counts as a change.
+func (m *Manager) syncTeamPolicy(teamID, policy, limitScope string) ([]string, bool, error) {
+	versions := m.policyMgr.PolicyVersions()
+
+	// Missing limits fall back to the TokenRateLimitPolicy defaults
+	tokenLimit, timeWindow, _ := m.tierLimits(policy)
+
+	var policyErr error
+	if err := m.policyMgr.AddTeamToAuthPolicy(policy); err != nil {
+		log.Printf("Warning: Failed to sync AuthPolicy for team %s: %v", teamID, err)
+		policyErr = err
+	}
+	if err := m.policyMgr.AddTeamToTokenRateLimit(policy, tokenLimit, timeWindow, limitScope, 0); err != nil {
+		log.Printf("Warning: Failed to sync TokenRateLimitPolicy for team %s: %v", teamID, err)
+		policyErr = err
+	}
+	// Re-applying the team's model limits prunes overrides it has removed
+	if policyErr == nil {
+		teamSecret, err := m.getTeamSecret(teamID)
+		if err == nil {
+			err = m.applyStoredModelLimits(teamSecret, policy)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to sync model limits for team %s: %v", teamID, err)
+			policyErr = err
+		}
+	}
+	m.recordPolicyResult(policy, policyErr)
+	if policyErr != nil {
+		return nil, false, fmt.Errorf("failed to sync policies: %w", policyErr)
+	}
+
+	// Teams whose asynchronous provisioning failed are ready once synced
+	if status, err := m.GetProvisioningStatus(teamID); err == nil && status.Status == ProvisioningFailed {
+		m.setProvisioningStatus(teamID, ProvisioningReady, "")
+	}
+
+	// Member limits follow the tier, so stale ones go with the sync
+	pruned := m.syncMemberLimits(teamID, policy)
+	return pruned, m.policyMgr.PolicyVersions() != versions, nil
+}
+
+func stringValue(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,243 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Changes reported by a policy preview
+const (
+	PolicyChangeAdded   = "added"
+	PolicyChangeRemoved = "removed"
+	PolicyChangeChanged = "changed"
+)
+
+// PreviewPolicies renders the Kuadrant policies as they would be after moving
+// a team to a tier, without applying them. The request's limits replace the
+// tier's current limits when set.
+func (m *Manager) PreviewPolicies(teamID string, req *PolicyPreviewRequest) (*PolicyPreview, error) {
+	currentPolicy, err := m.GetPolicy(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	policy := req.Tier
+	if policy == "" {
+		policy = currentPolicy
+	}
+
+	// The old policy is only removed when no other team still uses it
+	removeOld := false
+	if currentPolicy != policy {
+		teamIDs, err := m.teamsOnTier(currentPolicy)
+		if err != nil {
+			return nil, err
+		}
+		_, custom := m.customTier(currentPolicy)
+		removeOld = !custom && len(teamIDs) <= 1
+	}
+
+	tokenLimit, timeWindow := req.TokenLimit, req.TimeWindow
+	if isUnlimitedPolicy(policy) {
+		tokenLimit, timeWindow = unlimitedTokenLimit, unlimitedTimeWindow
+	}
+	if tokenLimit <= 0 || timeWindow == "" {
+		existingTokenLimit, existingTimeWindow, err := m.tierLimits(policy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get policy limits: %w", err)
+		}
+		if tokenLimit <= 0 {
+			tokenLimit = existingTokenLimit
+		}
+		if timeWindow == "" {
+			timeWindow = existingTimeWindow
+		}
+	}
+	if !IsValidTimeWindow(timeWindow) {
+		return nil, fmt.Errorf("invalid time_window %q: must be a duration such as 1m, 1h or 24h", timeWindow)
+	}
+
+	objects, changes, err := m.policyMgr.RenderPolicyChange(currentPolicy, removeOld, policy,
+		tokenLimit, timeWindow, req.LimitScope, req.TeamTokenLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		manifest, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", obj.GetKind(), err)
+		}
+		manifests = append(manifests, string(manifest))
+	}
+
+	return &PolicyPreview{
+		TeamID:        teamID,
+		CurrentPolicy: currentPolicy,
+		Policy:        policy,
+		Manifests:     strings.Join(manifests, "---\n"),
+		Changes:       changes,
+	}, nil
+}
+
+// RenderPolicyChange renders the TokenRateLimitPolicy and AuthPolicy with
+// oldPolicy removed, when removeOld is set, and newPolicy added with the given
+// limits. Nothing is applied; the rendered objects are returned along with
+// their differences from the live ones.
+func (p *PolicyManager) RenderPolicyChange(oldPolicy string, removeOld bool, newPolicy string, tokenLimit int, timeWindow, scope string, teamTokenLimit int) ([]*unstructured.Unstructured, []PolicyChange, error) {
+	tokenLimit, timeWindow, scope, teamTokenLimit, err := p.tokenLimitDefaults(newPolicy, tokenLimit, timeWindow, scope, teamTokenLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	currentTokenRateLimit, err := p.kuadrantClient.Resource(p.gvrs.TokenRateLimitPolicy()).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+	currentAuth, err := p.kuadrantClient.Resource(p.gvrs.AuthPolicy()).Namespace(p.keyNamespace).Get(
+		context.Background(), p.authPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get AuthPolicy: %w", err)
+	}
+
+	tokenRateLimit := previewObject(currentTokenRateLimit)
+	auth := previewObject(currentAuth)
+	if removeOld && oldPolicy != "" {
+		renderAuthPolicyGroups(auth, oldPolicy, false)
+		renderTokenRateLimit(tokenRateLimit, oldPolicy, false, 0, "", "", 0)
+	}
+	renderAuthPolicyGroups(auth, newPolicy, true)
+	if isUnlimitedPolicy(newPolicy) && p.unlimitedMode == UnlimitedModeExempt {
+		renderTokenRateLimit(tokenRateLimit, newPolicy, false, 0, "", "", 0)
+	} else {
+		renderTokenRateLimit(tokenRateLimit, newPolicy, true, tokenLimit, timeWindow, scope, teamTokenLimit)
+	}
+
+	changes := diffLimits(currentTokenRateLimit, tokenRateLimit)
+	changes = append(changes, diffAuthGroups(currentAuth, auth)...)
+	return []*unstructured.Unstructured{tokenRateLimit, auth}, changes, nil
+}
+
+// previewObject copies a live policy without the server-managed fields
+func previewObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	preview := obj.DeepCopy()
+	preview.SetManagedFields(nil)
+	preview.SetResourceVersion("")
+	preview.SetUID("")
+	preview.SetGeneration(0)
+	preview.SetCreationTimestamp(metav1.Time{})
+	delete(preview.Object, "status")
+	return preview
+}
+
+// diffLimits lists the TokenRateLimitPolicy limits added, removed or changed
+func diffLimits(before, after *unstructured.Unstructured) []PolicyChange {
+	beforeLimits := normalizedLimits(before)
+	afterLimits := normalizedLimits(after)
+
+	names := make([]string, 0, len(beforeLimits)+len(afterLimits))
+	for name := range beforeLimits {
+		names = append(names, name)
+	}
+	for name := range afterLimits {
+		if _, ok := beforeLimits[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]PolicyChange, 0)
+	for _, name := range names {
+		old, hadOld := beforeLimits[name]
+		updated, hasUpdated := afterLimits[name]
+		change := PolicyChange{Kind: "TokenRateLimitPolicy", Name: name, Before: old, After: updated}
+		switch {
+		case !hadOld:
+			change.Change = PolicyChangeAdded
+		case !hasUpdated:
+			change.Change = PolicyChangeRemoved
+		case !reflect.DeepEqual(old, updated):
+			change.Change = PolicyChangeChanged
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// normalizedLimits returns a policy's limits in their JSON form, so limits
+// read from the cluster and rendered locally compare equal
+func normalizedLimits(obj *unstructured.Unstructured) map[string]interface{} {
+	limits := make(map[string]interface{})
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return limits
+	}
+	data, err := json.Marshal(spec["limits"])
+	if err != nil {
+		return limits
+	}
+	if err := json.Unmarshal(data, &limits); err != nil || limits == nil {
+		return make(map[string]interface{})
+	}
+	return limits
+}
+
+// diffAuthGroups lists the groups added to or removed from the AuthPolicy
+func diffAuthGroups(before, after *unstructured.Unstructured) []PolicyChange {
+	beforeGroups := authGroups(before)
+	afterGroups := authGroups(after)
+
+	changes := make([]PolicyChange, 0)
+	for _, group := range sortedKeys(beforeGroups) {
+		if !afterGroups[group] {
+			changes = append(changes, PolicyChange{Kind: "AuthPolicy", Name: group, Change: PolicyChangeRemoved})
+		}
+	}
+	for _, group := range sortedKeys(afterGroups) {
+		if !beforeGroups[group] {
+			changes = append(changes, PolicyChange{Kind: "AuthPolicy", Name: group, Change: PolicyChangeAdded})
+		}
+	}
+	return changes
+}
+
+// authGroups returns the groups allowed by the AuthPolicy rego
+func authGroups(obj *unstructured.Unstructured) map[string]bool {
+	groups := make(map[string]bool)
+	rego, _, _ := unstructured.NestedString(obj.Object, "spec", "rules", "authorization", "allow-groups", "opa", "rego")
+	for _, line := range strings.Split(rego, "\n") {
+		if !strings.Contains(line, "allow { groups[_] ==") {
+			continue
+		}
+		start := strings.Index(line, "\"")
+		end := strings.LastIndex(line, "\"")
+		if start != -1 && end > start+1 {
+			groups[line[start+1:end]] = true
+		}
+	}
+	return groups
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
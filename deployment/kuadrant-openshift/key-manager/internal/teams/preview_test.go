@@ -0,0 +1,63 @@
+package teams
+
+import (
+	"reflect"
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPreviewPoliciesKeepsExistingLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         PolicyPreviewRequest
+		wantChanged bool
+		wantRates   []interface{}
+	}{
+		{name: "no limits given", req: PolicyPreviewRequest{}, wantChanged: false},
+		{name: "same limit given", req: PolicyPreviewRequest{TokenLimit: 5000}, wantChanged: false},
+		{
+			name:        "window only",
+			req:         PolicyPreviewRequest{TimeWindow: "1m"},
+			wantChanged: true,
+			wantRates:   []interface{}{map[string]interface{}{"limit": float64(5000), "window": "1m"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newFakePolicyManager(3)
+			if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+				t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+			}
+			if err := p.AddTeamToAuthPolicy("gold"); err != nil {
+				t.Fatalf("AddTeamToAuthPolicy() = %v", err)
+			}
+			clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-a", "gold"))
+			m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+			preview, err := m.PreviewPolicies("team-a", &tt.req)
+			if err != nil {
+				t.Fatalf("PreviewPolicies() = %v", err)
+			}
+
+			var changed *PolicyChange
+			for i, change := range preview.Changes {
+				if change.Kind == "TokenRateLimitPolicy" && change.Name == "gold" {
+					changed = &preview.Changes[i]
+				} else {
+					t.Errorf("unexpected change %+v", change)
+				}
+			}
+			if (changed != nil) != tt.wantChanged {
+				t.Fatalf("PreviewPolicies() changes = %+v, want gold changed: %v", preview.Changes, tt.wantChanged)
+			}
+			if changed != nil {
+				after, _ := changed.After.(map[string]interface{})
+				if rates, _ := after["rates"].([]interface{}); len(rates) != 1 || !reflect.DeepEqual(rates, tt.wantRates) {
+					t.Errorf("gold rates after = %v, want %v", after["rates"], tt.wantRates)
+				}
+			}
+		})
+	}
+}
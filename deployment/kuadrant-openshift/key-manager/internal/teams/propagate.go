@@ -0,0 +1,105 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// PropagateMetadata refreshes the team name, tier and groups carried by every
+// team key from the team config. Keys already in sync are left untouched, so
+// it is safe to run repeatedly.
+func (m *Manager) PropagateMetadata(teamID string) (*PropagateMetadataResult, error) {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return nil, err
+	}
+	teamName := teamSecret.Annotations["maas/team-name"]
+	policy := teamSecret.Annotations["maas/policy"]
+	keyNamespace := m.keyNamespaceOf(teamSecret)
+
+	keyNames, err := m.getTeamAPIKeys(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PropagateMetadataResult{
+		TeamID:   teamID,
+		TeamName: teamName,
+		Policy:   policy,
+		Failures: make([]KeyUpdateFailure, 0),
+	}
+
+	for _, keyName := range keyNames {
+		changed := false
+		// Re-read the key on every attempt so concurrent edits are not lost
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			secret, err := m.clientset.CoreV1().Secrets(keyNamespace).Get(
+				context.Background(), keyName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			changed = applyTeamMetadata(secret, teamName, policy)
+			if !changed {
+				return nil
+			}
+
+			_, err = m.clientset.CoreV1().Secrets(keyNamespace).Update(
+				context.Background(), secret, metav1.UpdateOptions{})
+			return err
+		})
+
+		switch {
+		case err != nil:
+			log.Printf("Warning: Failed to propagate team metadata to API key %s: %v", keyName, err)
+			result.Failures = append(result.Failures, KeyUpdateFailure{SecretName: keyName, Error: err.Error()})
+		case changed:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+
+	log.Printf("Propagated metadata for team %s: %d keys updated, %d unchanged, %d failed",
+		teamID, result.Updated, result.Unchanged, len(result.Failures))
+	return result, nil
+}
+
+// applyTeamMetadata sets the team name, tier and groups on a key secret and
+// reports whether anything changed
+func applyTeamMetadata(secret *corev1.Secret, teamName, policy string) bool {
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	if secret.Labels == nil {
+		secret.Labels = make(map[string]string)
+	}
+
+	changed := false
+	set := func(values map[string]string, key, value string) {
+		if values[key] != value {
+			values[key] = value
+			changed = true
+		}
+	}
+
+	set(secret.Annotations, "maas/team-name", teamName)
+	if policy != "" {
+		// Drop the label of the previous policy so selectors stop matching
+		oldPolicy := secret.Annotations["maas/policy"]
+		if oldPolicy != "" && oldPolicy != policy {
+			delete(secret.Labels, fmt.Sprintf("maas/policy-%s", oldPolicy))
+			changed = true
+		}
+		set(secret.Labels, fmt.Sprintf("maas/policy-%s", policy), "true")
+		set(secret.Annotations, "maas/policy", policy)
+		set(secret.Annotations, "kuadrant.io/groups", policy)
+	}
+
+	return changed
+}
@@ -0,0 +1,28 @@
+package teams
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// annotationDeletionProtected blocks deleting a team until it is removed
+const annotationDeletionProtected = "maas/deletion-protected"
+
+// deletionProtected reports whether a team config secret is protected from
+// deletion
+func deletionProtected(teamSecret *corev1.Secret) bool {
+	return teamSecret.Annotations[annotationDeletionProtected] == "true"
+}
+
+// setDeletionProtection protects a team or lifts its protection, returning
+// the changed field
+func setDeletionProtection(teamSecret *corev1.Secret, protected *bool) []string {
+	if protected == nil || *protected == deletionProtected(teamSecret) {
+		return nil
+	}
+	if *protected {
+		teamSecret.Annotations[annotationDeletionProtected] = "true"
+	} else {
+		delete(teamSecret.Annotations, annotationDeletionProtected)
+	}
+	return []string{"deletion_protected"}
+}
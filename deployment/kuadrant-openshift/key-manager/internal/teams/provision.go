@@ -0,0 +1,240 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+)
+
+// builtinAuthGroups are always allowed by the AuthPolicy, whether or not a
+// team uses them
+var builtinAuthGroups = []string{"free", "premium", "enterprise"}
+
+// ProvisioningError is returned when team creation fails part way. Everything
+// created before the failure is rolled back; resources that could not be
+// removed are listed as leaked.
+type ProvisioningError struct {
+	TeamID     string                `json:"team_id"`
+	Step       string                `json:"step"`
+	Err        error                 `json:"-"`
+	RolledBack []ProvisionedResource `json:"rolled_back"`
+	Leaked     []ProvisionedResource `json:"leaked"`
+}
+
+func (e *ProvisioningError) Error() string {
+	return fmt.Sprintf("failed to provision team %s at %s: %v (%d resources rolled back, %d leaked)",
+		e.TeamID, e.Step, e.Err, len(e.RolledBack), len(e.Leaked))
+}
+
+func (e *ProvisioningError) Unwrap() error {
+	return e.Err
+}
+
+// provisioningPlan records the resources created while provisioning a team
+// so they can be removed again, newest first, if a later step fails
+type provisioningPlan struct {
+	teamID    string
+	resources []ProvisionedResource
+	undo      []func() error
+}
+
+// created records a resource along with the call that removes it
+func (p *provisioningPlan) created(kind, name string, undo func() error) {
+	p.resources = append(p.resources, ProvisionedResource{Kind: kind, Name: name})
+	p.undo = append(p.undo, undo)
+}
+
+// rollback removes everything created so far and returns the provisioning
+// error for the failed step. With nothing to undo the cause is returned as is.
+func (m *Manager) rollback(plan *provisioningPlan, step string, cause error) error {
+	if len(plan.resources) == 0 {
+		return cause
+	}
+
+	provErr := &ProvisioningError{
+		TeamID:     plan.teamID,
+		Step:       step,
+		Err:        cause,
+		RolledBack: make([]ProvisionedResource, 0),
+		Leaked:     make([]ProvisionedResource, 0),
+	}
+	for i := len(plan.resources) - 1; i >= 0; i-- {
+		resource := plan.resources[i]
+		if err := plan.undo[i](); err != nil {
+			log.Printf("Warning: Failed to roll back %s %s for team %s: %v", resource.Kind, resource.Name, plan.teamID, err)
+			resource.Error = err.Error()
+			provErr.Leaked = append(provErr.Leaked, resource)
+			continue
+		}
+		provErr.RolledBack = append(provErr.RolledBack, resource)
+	}
+
+	eventType := corev1.EventTypeNormal
+	if len(provErr.Leaked) > 0 {
+		eventType = corev1.EventTypeWarning
+	}
+	m.events.Gateway(eventType, events.ReasonProvisioningRolledBack,
+		"Provisioning team %s failed at %s, %d resources rolled back, %d leaked",
+		plan.teamID, step, len(provErr.RolledBack), len(provErr.Leaked))
+	log.Printf("Provisioning team %s failed at %s: %v", plan.teamID, step, provErr)
+	return provErr
+}
+
+// FindOrphans reports policy entries that no team config uses and team
+// configs whose policy is missing from the Kuadrant policies. The default
+// team and unlimited-policy are exempt, as in the team policy status, and
+// custom tiers are never orphaned.
+func (m *Manager) FindOrphans() (*OrphanReport, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+
+	limits, groups, err := m.policyMgr.ListPolicyEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OrphanReport{
+		OrphanedPolicies:      make([]OrphanedPolicy, 0),
+		TeamsMissingPolicies:  make([]TeamMissingPolicy, 0),
+		TokenRateLimitEntries: len(limits),
+		AuthPolicyGroups:      len(groups),
+	}
+
+	definitions, err := m.tierDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]bool)
+	for tier := range definitions {
+		policies[tier] = true
+	}
+	for _, secret := range secrets.Items {
+		teamID := secret.Labels["maas/team-id"]
+		policy := secret.Annotations["maas/policy"]
+		if policy == "" {
+			continue
+		}
+		policies[policy] = true
+
+		if teamID == DefaultTeamID || policy == "unlimited-policy" {
+			continue
+		}
+		missing := make([]string, 0)
+		if !limits[policy] {
+			missing = append(missing, "TokenRateLimitPolicy")
+		}
+		if !groups[policy] {
+			missing = append(missing, "AuthPolicy")
+		}
+		if len(missing) > 0 {
+			report.TeamsMissingPolicies = append(report.TeamsMissingPolicies, TeamMissingPolicy{
+				TeamID:  teamID,
+				Policy:  policy,
+				Missing: missing,
+			})
+		}
+	}
+
+	for policy := range limits {
+		if !policies[policy] {
+			report.OrphanedPolicies = append(report.OrphanedPolicies, OrphanedPolicy{
+				Kind:   "TokenRateLimitPolicy",
+				Name:   m.policyMgr.TokenRateLimitPolicyName(),
+				Policy: policy,
+			})
+		}
+	}
+	for group := range groups {
+		if !policies[group] && !containsString(builtinAuthGroups, group) {
+			report.OrphanedPolicies = append(report.OrphanedPolicies, OrphanedPolicy{
+				Kind:   "AuthPolicy",
+				Name:   m.policyMgr.AuthPolicyName(),
+				Policy: group,
+			})
+		}
+	}
+	sort.Slice(report.OrphanedPolicies, func(i, j int) bool {
+		a, b := report.OrphanedPolicies[i], report.OrphanedPolicies[j]
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		return a.Kind < b.Kind
+	})
+
+	return report, nil
+}
+
+// ListPolicyEntries returns the policies with a limit in the
+// TokenRateLimitPolicy and the groups allowed by the AuthPolicy. Shared
+// team-wide limits and per-model limits are folded into their policy.
+func (p *PolicyManager) ListPolicyEntries() (map[string]bool, map[string]bool, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+	authPolicyGVR := p.gvrs.AuthPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+	limits := make(map[string]bool)
+	if spec, ok := policyObj.Object["spec"].(map[string]interface{}); ok {
+		if limitMap, ok := spec["limits"].(map[string]interface{}); ok {
+			for name, limitConfig := range limitMap {
+				// Sub-team limits belong to a parent team rather than a policy
+				if strings.HasPrefix(name, "team-") && strings.HasSuffix(name, "-subteams") {
+					continue
+				}
+				if isModelLimit(limitConfig) || isUserLimit(limitConfig) {
+					continue
+				}
+				limits[strings.TrimSuffix(name, teamLimitName(""))] = true
+			}
+		}
+	}
+
+	authPolicyObj, err := p.kuadrantClient.Resource(authPolicyGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.authPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get AuthPolicy: %w", err)
+	}
+	groups := make(map[string]bool)
+	if spec, ok := authPolicyObj.Object["spec"].(map[string]interface{}); ok {
+		if rules, ok := spec["rules"].(map[string]interface{}); ok {
+			if auth, ok := rules["authorization"].(map[string]interface{}); ok {
+				if allowGroups, ok := auth["allow-groups"].(map[string]interface{}); ok {
+					if opa, ok := allowGroups["opa"].(map[string]interface{}); ok {
+						if rego, ok := opa["rego"].(string); ok {
+							for _, line := range strings.Split(rego, "\n") {
+								if !strings.Contains(line, "allow { groups[_] ==") {
+									continue
+								}
+								start := strings.Index(line, "\"")
+								end := strings.LastIndex(line, "\"")
+								if start != -1 && end > start+1 {
+									groups[line[start+1:end]] = true
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return limits, groups, nil
+}
@@ -0,0 +1,138 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// failPolicyUpdates makes every update of a policy resource fail with a
+// permanent error, after the first skip updates succeed
+func failPolicyUpdates(client *dynamicfake.FakeDynamicClient, resource string, skip int) {
+	client.PrependReactor("update", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if skip > 0 {
+			skip--
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewForbidden(action.GetResource().GroupResource(), "", errors.New("denied by admission webhook"))
+	})
+}
+
+// testTeamSecret returns the config secret of a team on the given policy
+func testTeamSecret(teamID, policy string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:        fmt.Sprintf("team-%s-config", teamID),
+		Namespace:   testNamespace,
+		Labels:      map[string]string{"maas/resource-type": "team-config", "maas/team-id": teamID},
+		Annotations: map[string]string{"maas/team-name": teamID, "maas/policy": policy},
+	}}
+}
+
+// provisionedKinds lists provisioned resources as kind/name, in order
+func provisionedKinds(resources []ProvisionedResource) []string {
+	kinds := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		kinds = append(kinds, resource.Kind+"/"+resource.Name)
+	}
+	return kinds
+}
+
+func TestCreateRollsBackWhenSecondPolicyFails(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	failPolicyUpdates(client, "tokenratelimitpolicies", 0)
+	clientset := k8sfake.NewSimpleClientset()
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	err := m.Create(&CreateTeamRequest{TeamID: "team-a", TeamName: "Team A", Policy: "gold", TokenLimit: 5000, TimeWindow: "1h"})
+
+	var provErr *ProvisioningError
+	if !errors.As(err, &provErr) {
+		t.Fatalf("Create() = %v, want a ProvisioningError", err)
+	}
+	if provErr.Step != "TokenRateLimitPolicy" || !apierrors.IsForbidden(err) {
+		t.Errorf("Create() failed at %s with %v, want the TokenRateLimitPolicy error", provErr.Step, err)
+	}
+	want := []string{"AuthPolicyGroup/gold", "TeamConfig/team-team-a-config"}
+	if got := provisionedKinds(provErr.RolledBack); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("rolled back %v, want %v", got, want)
+	}
+	if len(provErr.Leaked) != 0 {
+		t.Errorf("leaked %v, want nothing", provErr.Leaked)
+	}
+
+	// The AuthPolicy group was added, then removed again
+	if updates := countUpdates(client, "authpolicies"); updates != 2 {
+		t.Errorf("sent %d AuthPolicy updates, want the add and its removal", updates)
+	}
+	if strings.Contains(authRego(t, client), `"gold"`) {
+		t.Errorf("gold group is still allowed after the rollback")
+	}
+	if _, ok := policyLimits(t, client)["gold"]; ok {
+		t.Errorf("gold limit exists after the failed write")
+	}
+	_, err = clientset.CoreV1().Secrets(testNamespace).Get(context.Background(), "team-team-a-config", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("team config secret was not removed: %v", err)
+	}
+}
+
+func TestCreateKeepsSharedPolicyOnFailure(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	if err := p.AddTeamToAuthPolicy("gold"); err != nil {
+		t.Fatalf("AddTeamToAuthPolicy() = %v", err)
+	}
+	failPolicyUpdates(client, "tokenratelimitpolicies", 0)
+
+	// Another team is already on gold
+	clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-b", "gold"))
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	err := m.Create(&CreateTeamRequest{TeamID: "team-a", TeamName: "Team A", Policy: "gold"})
+
+	var provErr *ProvisioningError
+	if !errors.As(err, &provErr) {
+		t.Fatalf("Create() = %v, want a ProvisioningError", err)
+	}
+	want := []string{"TeamConfig/team-team-a-config"}
+	if got := provisionedKinds(provErr.RolledBack); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("rolled back %v, want %v", got, want)
+	}
+	if !strings.Contains(authRego(t, client), `groups[_] == "gold"`) {
+		t.Errorf("gold group shared with team-b was removed")
+	}
+}
+
+func TestCreateReportsLeakedPolicy(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	failPolicyUpdates(client, "tokenratelimitpolicies", 0)
+	// The group is added, but removing it again fails
+	failPolicyUpdates(client, "authpolicies", 1)
+	clientset := k8sfake.NewSimpleClientset()
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	err := m.Create(&CreateTeamRequest{TeamID: "team-a", TeamName: "Team A", Policy: "gold"})
+
+	var provErr *ProvisioningError
+	if !errors.As(err, &provErr) {
+		t.Fatalf("Create() = %v, want a ProvisioningError", err)
+	}
+	if got := provisionedKinds(provErr.Leaked); len(got) != 1 || got[0] != "AuthPolicyGroup/gold" {
+		t.Fatalf("leaked %v, want the AuthPolicy group", got)
+	}
+	if provErr.Leaked[0].Error == "" {
+		t.Errorf("leaked resource has no error")
+	}
+	if got := provisionedKinds(provErr.RolledBack); len(got) != 1 || got[0] != "TeamConfig/team-team-a-config" {
+		t.Errorf("rolled back %v, want the team config", got)
+	}
+}
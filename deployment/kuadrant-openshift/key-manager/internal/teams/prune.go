@@ -0,0 +1,103 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncMemberLimits re-applies each member's individual limit against the
+// team's tier. Limits of members who left, and overrides the tier no longer
+// makes worth enforcing, are removed. It returns the limits removed.
+func (m *Manager) syncMemberLimits(teamID, policy string) []string {
+	before, err := m.policyMgr.GetTeamUserLimits(teamID)
+	if err != nil {
+		log.Printf("Warning: Failed to read member limits of team %s: %v", teamID, err)
+		return nil
+	}
+	members, err := m.ListMembers(teamID)
+	if err != nil {
+		log.Printf("Warning: Failed to list members of team %s: %v", teamID, err)
+		return nil
+	}
+
+	overridden := make(map[string]bool)
+	for _, member := range members {
+		if member.TokenLimit > 0 {
+			overridden[member.UserID] = true
+			m.applyMemberLimit(teamID, member.UserID, policy, member.TokenLimit, member.TimeWindow)
+		}
+	}
+	for _, limit := range before {
+		if !overridden[limit.UserID] {
+			if err := m.policyMgr.RemoveUserLimit(teamID, limit.UserID); err != nil {
+				log.Printf("Warning: Failed to remove limit of former member %s of team %s: %v", limit.UserID, teamID, err)
+			}
+		}
+	}
+
+	after, err := m.policyMgr.GetTeamUserLimits(teamID)
+	if err != nil {
+		return nil
+	}
+	remaining := make(map[string]bool)
+	for _, limit := range after {
+		remaining[limit.UserID] = true
+	}
+	pruned := make([]string, 0)
+	for _, limit := range before {
+		if !remaining[limit.UserID] {
+			pruned = append(pruned, UserLimitName(teamID, limit.UserID))
+		}
+	}
+	return pruned
+}
+
+// refreshPolicyModelLimits re-applies a tier's model limits from the first
+// team on it, by ID, with recorded model limits, or clears them when none
+// has any. A team leaving the tier no longer leaves its model limits behind.
+// It returns the model limits removed.
+func (m *Manager) refreshPolicyModelLimits(policy string) ([]string, error) {
+	before, err := m.policyMgr.GetModelLimits(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool {
+		return secrets.Items[i].Labels["maas/team-id"] < secrets.Items[j].Labels["maas/team-id"]
+	})
+
+	desired := make(map[string]ModelLimit)
+	for i := range secrets.Items {
+		if secrets.Items[i].Annotations["maas/policy"] != policy {
+			continue
+		}
+		if modelLimits, recorded := modelLimitsOf(&secrets.Items[i]); recorded {
+			desired = modelLimits
+			break
+		}
+	}
+
+	pruned := make([]string, 0)
+	for model := range before {
+		if _, ok := desired[model]; !ok {
+			pruned = append(pruned, modelLimitName(policy, model))
+		}
+	}
+	if len(pruned) == 0 {
+		return pruned, nil
+	}
+	sort.Strings(pruned)
+	if err := m.policyMgr.SetModelLimits(policy, desired); err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
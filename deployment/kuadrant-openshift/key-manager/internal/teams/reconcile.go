@@ -0,0 +1,522 @@
+package teams
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/events"
+)
+
+// Reasons a policy has drifted from what its teams need
+const (
+	DriftPolicyMissing      = "policy_missing"
+	DriftLimitMissing       = "limit_missing"
+	DriftGroupMissing       = "group_missing"
+	DriftLimitsChanged      = "limits_changed"
+	DriftModelLimitsChanged = "model_limits_changed"
+)
+
+// How a team's policy entries compare with what it needs
+const (
+	ComplianceCompliant = "compliant"
+	// An entry differs from the tier or the team's stored overrides
+	ComplianceDrifted = "drifted"
+	// A policy resource, limit or group the team needs is gone
+	ComplianceMissing = "missing"
+	// The team is not checked, such as the default team or one still being
+	// provisioned
+	ComplianceNotChecked = "not_checked"
+)
+
+// authGroupsField is where the AuthPolicy lists the allowed groups
+const authGroupsField = "spec.rules.authorization.allow-groups.opa.rego"
+
+// policyTeams groups the teams that rely on a policy
+type policyTeams struct {
+	policy  string
+	teamIDs []string
+	// First team, by ID, with recorded model limits
+	modelLimitsTeam *corev1.Secret
+	// Set when a team was moved to the policy as a zero-limit over-budget policy
+	blocking bool
+}
+
+// StartPolicyReconciler periodically compares the Kuadrant policies with
+// what every team needs and corrects drift, such as a limit deleted with
+// kubectl. A non-positive interval disables it.
+func (m *Manager) StartPolicyReconciler(interval time.Duration) {
+	if interval <= 0 || m.policyMgr == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := m.ReconcilePolicies(); err != nil {
+				log.Printf("Warning: Policy reconciliation failed: %v", err)
+			}
+		}
+	}()
+	log.Printf("Policy reconciler started, interval %s", interval)
+}
+
+// CheckCompliance reports where the Kuadrant policies have drifted from what
+// the teams need, without changing anything. Options may scope the report to
+// one team and page its team results.
+func (m *Manager) CheckCompliance(opts *ComplianceOptions) (*ComplianceReport, error) {
+	report, err := m.checkPolicies(false)
+	if err != nil || opts == nil {
+		return report, err
+	}
+
+	if opts.TeamID != "" {
+		if err := m.scopeCompliance(report, opts.TeamID); err != nil {
+			return nil, err
+		}
+	}
+
+	report.Offset, report.Limit = opts.Offset, opts.Limit
+	results := report.TeamResults
+	if opts.Offset >= len(results) {
+		results = results[:0]
+	} else {
+		results = results[opts.Offset:]
+	}
+	if opts.Limit > 0 && opts.Limit < len(results) {
+		results = results[:opts.Limit]
+	}
+	report.TeamResults = results
+	return report, nil
+}
+
+// scopeCompliance narrows a report to one team and the drift affecting it
+func (m *Manager) scopeCompliance(report *ComplianceReport, teamID string) error {
+	results := make([]TeamCompliance, 0, 1)
+	for _, result := range report.TeamResults {
+		if result.TeamID == teamID {
+			results = append(results, result)
+		}
+	}
+	if len(results) == 0 {
+		teamSecret, err := m.getTeamSecret(teamID)
+		if err != nil {
+			return err
+		}
+		results = append(results, TeamCompliance{
+			TeamID: teamID,
+			Policy: teamSecret.Annotations["maas/policy"],
+			Status: ComplianceNotChecked,
+		})
+	}
+
+	drift := make([]PolicyDrift, 0)
+	for _, entry := range report.Drift {
+		for _, affected := range entry.Teams {
+			if affected == teamID {
+				drift = append(drift, entry)
+				break
+			}
+		}
+	}
+
+	report.TeamResults, report.Drift = results, drift
+	report.summarize()
+	return nil
+}
+
+// ReconcilePolicies corrects every drifted policy it can and records an
+// Event on each affected team
+func (m *Manager) ReconcilePolicies() (*ComplianceReport, error) {
+	report, err := m.checkPolicies(true)
+	if err != nil {
+		return nil, err
+	}
+
+	m.reconcileMu.Lock()
+	m.lastReconcile = report
+	m.reconcileMu.Unlock()
+
+	if len(report.Drift) > 0 {
+		log.Printf("Policy reconciliation found %d drifted entries, %d corrected", len(report.Drift), report.Corrected)
+	}
+	return report, nil
+}
+
+// checkPolicies compares each policy used by a team with the cluster and,
+// with correct set, re-applies what is missing or changed
+func (m *Manager) checkPolicies(correct bool) (*ComplianceReport, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	byPolicy, teamCount, err := m.teamsByPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ComplianceReport{
+		CheckedAt: time.Now().Format(time.RFC3339),
+		Teams:     teamCount,
+		Drift:     make([]PolicyDrift, 0),
+	}
+	m.reconcileMu.Lock()
+	if m.lastReconcile != nil {
+		report.LastReconciledAt = m.lastReconcile.CheckedAt
+	}
+	m.reconcileMu.Unlock()
+
+	limits, groups, err := m.policyMgr.ListPolicyEntries()
+	if apierrors.IsNotFound(err) {
+		// The policy resources come from the deployment manifests, so they
+		// cannot be recreated from team configs alone
+		drift := PolicyDrift{
+			Kind:   "TokenRateLimitPolicy",
+			Name:   m.policyMgr.TokenRateLimitPolicyName(),
+			Field:  "metadata.name",
+			Reason: DriftPolicyMissing,
+			Detail: "policy resource not found, re-apply the deployment manifests",
+		}
+		if strings.Contains(err.Error(), "AuthPolicy") {
+			drift.Kind, drift.Name = "AuthPolicy", m.policyMgr.AuthPolicyName()
+		}
+		for _, entry := range byPolicy {
+			drift.Teams = append(drift.Teams, entry.teamIDs...)
+		}
+		sort.Strings(drift.Teams)
+		report.Drift = append(report.Drift, drift)
+		report.TeamResults = teamCompliance(byPolicy, report.Drift)
+		report.summarize()
+		if correct {
+			m.events.Gateway(corev1.EventTypeWarning, events.ReasonPolicyDrift,
+				"%s %s not found, %d teams are affected", drift.Kind, drift.Name, len(drift.Teams))
+		}
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]string, 0, len(byPolicy))
+	for policy := range byPolicy {
+		policies = append(policies, policy)
+	}
+	sort.Strings(policies)
+
+	for _, policy := range policies {
+		entry := byPolicy[policy]
+		for _, drift := range m.policyDrift(entry, limits[policy], groups[policy]) {
+			if correct {
+				m.correctDrift(entry, &drift)
+			}
+			if drift.Corrected {
+				report.Corrected++
+			}
+			report.Drift = append(report.Drift, drift)
+		}
+	}
+	report.TeamResults = teamCompliance(byPolicy, report.Drift)
+	report.summarize()
+
+	if report.Corrected > 0 {
+		if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+			log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// teamsByPolicy groups team configs by policy. Teams still being
+// provisioned, the default team and unlimited-policy are left out, as in the
+// team policy status.
+func (m *Manager) teamsByPolicy() (map[string]*policyTeams, int, error) {
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool {
+		return secrets.Items[i].Labels["maas/team-id"] < secrets.Items[j].Labels["maas/team-id"]
+	})
+
+	byPolicy := make(map[string]*policyTeams)
+	teamCount := 0
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		teamID := secret.Labels["maas/team-id"]
+		policy := secret.Annotations["maas/policy"]
+		if policy == "" || teamID == DefaultTeamID || policy == "unlimited-policy" ||
+			secret.Annotations[annotationProvisioningStatus] == ProvisioningPending {
+			continue
+		}
+
+		entry, ok := byPolicy[policy]
+		if !ok {
+			entry = &policyTeams{policy: policy}
+			byPolicy[policy] = entry
+		}
+		entry.teamIDs = append(entry.teamIDs, teamID)
+		if secret.Annotations[annotationBudgetEnforced] == BudgetModeDowngrade {
+			entry.blocking = true
+		}
+		if _, recorded := modelLimitsOf(secret); recorded && entry.modelLimitsTeam == nil {
+			entry.modelLimitsTeam = secret
+		}
+		teamCount++
+	}
+	return byPolicy, teamCount, nil
+}
+
+// policyDrift lists how a policy differs from what its teams need
+func (m *Manager) policyDrift(entry *policyTeams, hasLimit, hasGroup bool) []PolicyDrift {
+	drift := make([]PolicyDrift, 0)
+	newDrift := func(kind, name, field, reason, detail string) PolicyDrift {
+		return PolicyDrift{
+			Policy: entry.policy,
+			Kind:   kind,
+			Name:   name,
+			Field:  field,
+			Reason: reason,
+			Detail: detail,
+			Teams:  entry.teamIDs,
+		}
+	}
+	limitField := "spec.limits." + entry.policy
+
+	if !hasGroup {
+		drift = append(drift, newDrift("AuthPolicy", m.policyMgr.AuthPolicyName(), authGroupsField, DriftGroupMissing,
+			"group is not allowed by the AuthPolicy"))
+	}
+	if !hasLimit {
+		drift = append(drift, newDrift("TokenRateLimitPolicy", m.policyMgr.TokenRateLimitPolicyName(), limitField, DriftLimitMissing,
+			"limit is missing from the TokenRateLimitPolicy"))
+		return drift
+	}
+
+	// Only custom tiers record the limits they should have
+	if definition, ok := m.customTier(entry.policy); ok {
+		desiredSpec, actualSpec := m.desiredTierSpec(definition), m.actualTierSpec(entry.policy)
+		desired, actual := tierSpecHash(desiredSpec), tierSpecHash(actualSpec)
+		if desired != actual {
+			field := limitField
+			if fields := tierSpecFields(desiredSpec, actualSpec); len(fields) > 0 {
+				field = limitField + "." + strings.Join(fields, ", "+limitField+".")
+			}
+			drift = append(drift, newDrift("TokenRateLimitPolicy", m.policyMgr.TokenRateLimitPolicyName(), field, DriftLimitsChanged,
+				fmt.Sprintf("limits differ from the tier definition (spec hash %s, expected %s)", actual, desired)))
+		}
+	}
+
+	if entry.modelLimitsTeam != nil {
+		desired, _ := modelLimitsOf(entry.modelLimitsTeam)
+		actual, err := m.policyMgr.GetModelLimits(entry.policy)
+		if err == nil && !reflect.DeepEqual(desired, actual) && !(len(desired) == 0 && len(actual) == 0) {
+			fields := make([]string, 0)
+			for _, model := range modelLimitDifferences(desired, actual) {
+				fields = append(fields, "spec.limits."+modelLimitName(entry.policy, model))
+			}
+			drift = append(drift, newDrift("TokenRateLimitPolicy", m.policyMgr.TokenRateLimitPolicyName(), strings.Join(fields, ", "),
+				DriftModelLimitsChanged, fmt.Sprintf("model limits differ from those of team %s", entry.modelLimitsTeam.Labels["maas/team-id"])))
+		}
+	}
+	return drift
+}
+
+// tierSpecFields lists the fields of a tier spec that differ
+func tierSpecFields(desired, actual tierSpec) []string {
+	fields := make([]string, 0)
+	if !reflect.DeepEqual(desired.Rates, actual.Rates) && len(desired.Rates)+len(actual.Rates) > 0 {
+		fields = append(fields, "rates")
+	}
+	if desired.BurstLimit != actual.BurstLimit {
+		fields = append(fields, "burst_limit")
+	}
+	if desired.BurstWindow != actual.BurstWindow {
+		fields = append(fields, "burst_window")
+	}
+	if desired.LimitScope != actual.LimitScope {
+		fields = append(fields, "limit_scope")
+	}
+	if desired.TeamTokenLimit != actual.TeamTokenLimit {
+		fields = append(fields, "team_token_limit")
+	}
+	return fields
+}
+
+// modelLimitDifferences lists, sorted, the models whose limits differ
+func modelLimitDifferences(desired, actual map[string]ModelLimit) []string {
+	models := make([]string, 0)
+	for model, limit := range desired {
+		if other, ok := actual[model]; !ok || !reflect.DeepEqual(limit, other) {
+			models = append(models, model)
+		}
+	}
+	for model := range actual {
+		if _, ok := desired[model]; !ok {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// teamCompliance classifies each checked team by the drift affecting its
+// policy. A missing resource, limit or group outranks a changed one.
+func teamCompliance(byPolicy map[string]*policyTeams, drift []PolicyDrift) []TeamCompliance {
+	byTeam := make(map[string][]PolicyDrift)
+	for _, entry := range drift {
+		for _, teamID := range entry.Teams {
+			byTeam[teamID] = append(byTeam[teamID], entry)
+		}
+	}
+
+	results := make([]TeamCompliance, 0)
+	for policy, entry := range byPolicy {
+		for _, teamID := range entry.teamIDs {
+			result := TeamCompliance{TeamID: teamID, Policy: policy, Status: ComplianceCompliant}
+			for _, d := range byTeam[teamID] {
+				switch d.Reason {
+				case DriftPolicyMissing, DriftLimitMissing, DriftGroupMissing:
+					result.Status = ComplianceMissing
+				default:
+					if result.Status == ComplianceCompliant {
+						result.Status = ComplianceDrifted
+					}
+				}
+				result.Differences = append(result.Differences, PolicyDifference{
+					Kind:   d.Kind,
+					Name:   d.Name,
+					Field:  d.Field,
+					Reason: d.Reason,
+					Detail: d.Detail,
+				})
+			}
+			results = append(results, result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].TeamID < results[j].TeamID
+	})
+	return results
+}
+
+// summarize counts the compliant teams among the team results
+func (r *ComplianceReport) summarize() {
+	r.Teams, r.CompliantTeams = 0, 0
+	for _, result := range r.TeamResults {
+		if result.Status == ComplianceNotChecked {
+			continue
+		}
+		r.Teams++
+		if result.Status == ComplianceCompliant {
+			r.CompliantTeams++
+		}
+	}
+	// With no teams to check, nothing has drifted
+	r.CompliancePercent = 100
+	if r.Teams > 0 {
+		r.CompliancePercent = math.Round(float64(r.CompliantTeams)*1000/float64(r.Teams)) / 10
+	}
+}
+
+// correctDrift re-applies what a drifted policy is missing and records the
+// outcome on each affected team
+func (m *Manager) correctDrift(entry *policyTeams, drift *PolicyDrift) {
+	var err error
+	switch drift.Reason {
+	case DriftGroupMissing:
+		err = m.policyMgr.AddTeamToAuthPolicy(entry.policy)
+	case DriftLimitMissing:
+		if definition, ok := m.customTier(entry.policy); ok {
+			err = m.applyTierDefinition(entry.policy, definition)
+		} else if entry.policy == ArchivedPolicy || entry.blocking {
+			err = m.policyMgr.AddBlockingLimitToTokenRateLimit(entry.policy)
+		} else {
+			// The limits themselves are gone, so the defaults apply
+			err = m.policyMgr.AddTeamToTokenRateLimit(entry.policy, 0, "", "", 0)
+		}
+		if err == nil && entry.modelLimitsTeam != nil {
+			err = m.applyStoredModelLimits(entry.modelLimitsTeam, entry.policy)
+		}
+	case DriftLimitsChanged:
+		definition, _ := m.customTier(entry.policy)
+		err = m.applyTierDefinition(entry.policy, definition)
+	case DriftModelLimitsChanged:
+		err = m.applyStoredModelLimits(entry.modelLimitsTeam, entry.policy)
+	default:
+		return
+	}
+	m.recordPolicyResult(entry.policy, err)
+
+	if err != nil {
+		drift.Error = err.Error()
+		log.Printf("Warning: Failed to correct %s drift of policy %s: %v", drift.Reason, entry.policy, err)
+		for _, teamID := range drift.Teams {
+			m.events.Team(teamID, corev1.EventTypeWarning, events.ReasonPolicyDrift,
+				"Policy %s drifted (%s) and could not be corrected: %v", entry.policy, drift.Reason, err)
+		}
+		return
+	}
+
+	drift.Corrected = true
+	log.Printf("Corrected %s drift of policy %s", drift.Reason, entry.policy)
+	for _, teamID := range drift.Teams {
+		m.events.Team(teamID, corev1.EventTypeNormal, events.ReasonPolicyDriftCorrected,
+			"Policy %s drifted (%s) and was corrected", entry.policy, drift.Reason)
+	}
+}
+
+// tierSpec is the part of a tier's policy entry its definition decides
+type tierSpec struct {
+	Rates          []RateLimit `json:"rates"`
+	BurstLimit     int         `json:"burst_limit"`
+	BurstWindow    string      `json:"burst_window"`
+	LimitScope     string      `json:"limit_scope"`
+	TeamTokenLimit int         `json:"team_token_limit"`
+}
+
+// desiredTierSpec is what a custom tier's policy entry should hold
+func (m *Manager) desiredTierSpec(definition *TierDefinition) tierSpec {
+	spec := tierSpec{
+		Rates:       definition.rates(),
+		BurstLimit:  definition.BurstLimit,
+		BurstWindow: definition.BurstWindow,
+		LimitScope:  definition.LimitScope,
+	}
+	if spec.LimitScope == LimitScopeBoth {
+		spec.TeamTokenLimit = definition.TeamTokenLimit
+		if spec.TeamTokenLimit <= 0 {
+			spec.TeamTokenLimit = definition.TokenLimit
+		}
+	}
+	return spec
+}
+
+// actualTierSpec is what a tier's policy entry holds in the cluster
+func (m *Manager) actualTierSpec(tier string) tierSpec {
+	var spec tierSpec
+	spec.Rates, _ = m.policyMgr.GetPolicyRates(tier)
+	spec.BurstLimit, spec.BurstWindow, _ = m.policyMgr.GetPolicyBurst(tier)
+	spec.LimitScope, spec.TeamTokenLimit, _ = m.policyMgr.GetPolicyLimitScope(tier)
+	return spec
+}
+
+// tierSpecHash fingerprints a tier spec for comparison
+func tierSpecHash(spec tierSpec) string {
+	data, _ := json.Marshal(spec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
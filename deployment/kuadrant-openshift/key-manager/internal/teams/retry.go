@@ -0,0 +1,74 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// policyRetryBackoff returns the exponential backoff between attempts at a
+// policy write. Jitter keeps concurrent writers to the shared policies from
+// retrying in lockstep.
+func policyRetryBackoff(attempts int) wait.Backoff {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return wait.Backoff{
+		Steps:    attempts,
+		Duration: 100 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.5,
+		Cap:      5 * time.Second,
+	}
+}
+
+// retryPolicyWrite runs a read-modify-write of a Kuadrant policy, re-running
+// it from the read while it fails with a transient error. Permanent errors,
+// such as a rejected or forbidden update, are returned at once.
+func (p *PolicyManager) retryPolicyWrite(kind string, write func() error) error {
+	sleep := p.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+
+	backoff := policyRetryBackoff(p.retryAttempts)
+	for attempts := 1; ; attempts++ {
+		err := write()
+		if err == nil {
+			return nil
+		}
+
+		retryable := isRetryablePolicyError(err)
+		if retryable {
+			log.Printf("Warning: Attempt %d to update %s failed: %v", attempts, kind, err)
+		}
+		if !retryable || backoff.Steps <= 1 {
+			if attempts > 1 {
+				return fmt.Errorf("%w (gave up after %d attempts)", err, attempts)
+			}
+			return err
+		}
+		sleep(backoff.Step())
+	}
+}
+
+// isRetryablePolicyError reports whether a failed policy write may succeed
+// when tried again
+func isRetryablePolicyError(err error) bool {
+	switch {
+	case apierrors.IsConflict(err),
+		apierrors.IsServerTimeout(err),
+		apierrors.IsTimeout(err),
+		apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err),
+		apierrors.IsInternalError(err),
+		errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var tokenRateLimitResource = schema.GroupResource{Group: "kuadrant.io", Resource: "tokenratelimitpolicies"}
+
+// newRetryingPolicyManager returns a policy manager recording its sleeps
+// instead of waiting
+func newRetryingPolicyManager(attempts int) (*PolicyManager, *[]time.Duration) {
+	var sleeps []time.Duration
+	return &PolicyManager{
+		retryAttempts: attempts,
+		sleep:         func(d time.Duration) { sleeps = append(sleeps, d) },
+	}, &sleeps
+}
+
+// failingWrite fails with each of errs in turn, then succeeds
+func failingWrite(errs ...error) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetryPolicyWriteRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "too many requests", err: apierrors.NewTooManyRequests("slow down", 1)},
+		{name: "server timeout", err: apierrors.NewServerTimeout(tokenRateLimitResource, "update", 1)},
+		{name: "timeout", err: apierrors.NewTimeoutError("request timed out", 1)},
+		{name: "conflict", err: apierrors.NewConflict(tokenRateLimitResource, "gateway-token-rate-limits", errors.New("modified"))},
+		{name: "service unavailable", err: apierrors.NewServiceUnavailable("unavailable")},
+		{name: "internal error", err: apierrors.NewInternalError(errors.New("etcd"))},
+		{name: "deadline exceeded", err: fmt.Errorf("update: %w", context.DeadlineExceeded)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, sleeps := newRetryingPolicyManager(5)
+			write, calls := failingWrite(tt.err, tt.err)
+
+			if err := p.retryPolicyWrite("TokenRateLimitPolicy", write); err != nil {
+				t.Fatalf("retryPolicyWrite() = %v, want success", err)
+			}
+			if *calls != 3 {
+				t.Errorf("write ran %d times, want 3", *calls)
+			}
+			if len(*sleeps) != 2 {
+				t.Errorf("slept %d times, want 2", len(*sleeps))
+			}
+		})
+	}
+}
+
+func TestRetryPolicyWriteStopsOnPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "bad request", err: apierrors.NewBadRequest("invalid limit")},
+		{name: "forbidden", err: apierrors.NewForbidden(tokenRateLimitResource, "gateway-token-rate-limits", errors.New("denied"))},
+		{name: "not found", err: apierrors.NewNotFound(tokenRateLimitResource, "gateway-token-rate-limits")},
+		{name: "invalid", err: apierrors.NewInvalid(schema.GroupKind{Group: "kuadrant.io", Kind: "TokenRateLimitPolicy"}, "gateway-token-rate-limits", nil)},
+		{name: "plain error", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, sleeps := newRetryingPolicyManager(5)
+			write, calls := failingWrite(tt.err)
+
+			err := p.retryPolicyWrite("TokenRateLimitPolicy", write)
+			if err != tt.err {
+				t.Errorf("retryPolicyWrite() = %v, want %v unwrapped", err, tt.err)
+			}
+			if *calls != 1 {
+				t.Errorf("write ran %d times, want 1", *calls)
+			}
+			if len(*sleeps) != 0 {
+				t.Errorf("slept %d times, want none", len(*sleeps))
+			}
+		})
+	}
+}
+
+func TestRetryPolicyWritePermanentErrorAfterRetries(t *testing.T) {
+	p, sleeps := newRetryingPolicyManager(5)
+	forbidden := apierrors.NewForbidden(tokenRateLimitResource, "gateway-token-rate-limits", errors.New("denied"))
+	write, calls := failingWrite(apierrors.NewTooManyRequests("slow down", 1), forbidden)
+
+	err := p.retryPolicyWrite("TokenRateLimitPolicy", write)
+	if !apierrors.IsForbidden(err) {
+		t.Fatalf("retryPolicyWrite() = %v, want the forbidden error", err)
+	}
+	if !strings.Contains(err.Error(), "gave up after 2 attempts") {
+		t.Errorf("retryPolicyWrite() = %q, want the attempt count", err)
+	}
+	if *calls != 2 || len(*sleeps) != 1 {
+		t.Errorf("write ran %d times with %d sleeps, want 2 and 1", *calls, len(*sleeps))
+	}
+}
+
+func TestRetryPolicyWriteAttemptCap(t *testing.T) {
+	tests := []struct {
+		name         string
+		attempts     int
+		wantAttempts int
+	}{
+		{name: "single attempt", attempts: 1, wantAttempts: 1},
+		{name: "zero means one attempt", attempts: 0, wantAttempts: 1},
+		{name: "negative means one attempt", attempts: -3, wantAttempts: 1},
+		{name: "three attempts", attempts: 3, wantAttempts: 3},
+		// The backoff stops once the next delay would pass its cap
+		{name: "capped by the backoff", attempts: 20, wantAttempts: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, sleeps := newRetryingPolicyManager(tt.attempts)
+			calls := 0
+			tooMany := apierrors.NewTooManyRequests("slow down", 1)
+
+			err := p.retryPolicyWrite("AuthPolicy", func() error {
+				calls++
+				return tooMany
+			})
+			if !apierrors.IsTooManyRequests(err) {
+				t.Fatalf("retryPolicyWrite() = %v, want the last error", err)
+			}
+			if calls != tt.wantAttempts {
+				t.Errorf("write ran %d times, want %d", calls, tt.wantAttempts)
+			}
+			if len(*sleeps) != tt.wantAttempts-1 {
+				t.Errorf("slept %d times, want %d", len(*sleeps), tt.wantAttempts-1)
+			}
+
+			wantSuffix := fmt.Sprintf("(gave up after %d attempts)", tt.wantAttempts)
+			if tt.wantAttempts == 1 {
+				if err != tooMany {
+					t.Errorf("retryPolicyWrite() = %q, want the error unwrapped", err)
+				}
+			} else if !strings.HasSuffix(err.Error(), wantSuffix) {
+				t.Errorf("retryPolicyWrite() = %q, want suffix %q", err, wantSuffix)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyWriteBackoff(t *testing.T) {
+	// Jitter is random, so check the bounds over several runs
+	for run := 0; run < 20; run++ {
+		p, sleeps := newRetryingPolicyManager(20)
+		_ = p.retryPolicyWrite("AuthPolicy", func() error {
+			return apierrors.NewTooManyRequests("slow down", 1)
+		})
+
+		base := 100 * time.Millisecond
+		for i, slept := range *sleeps {
+			maxDelay := base + base/2
+			if slept < base || slept > maxDelay {
+				t.Fatalf("sleep %d = %v, want between %v and %v", i+1, slept, base, maxDelay)
+			}
+			base *= 2
+			if base > 5*time.Second {
+				base = 5 * time.Second
+			}
+		}
+	}
+}
+
+func TestRetryPolicyWriteDefaultSleep(t *testing.T) {
+	// A manager built without NewPolicyManager still retries
+	p := &PolicyManager{retryAttempts: 2}
+	write, calls := failingWrite(apierrors.NewConflict(tokenRateLimitResource, "gateway-token-rate-limits", errors.New("modified")))
+
+	if err := p.retryPolicyWrite("TokenRateLimitPolicy", write); err != nil {
+		t.Fatalf("retryPolicyWrite() = %v, want success", err)
+	}
+	if *calls != 2 {
+		t.Errorf("write ran %d times, want 2", *calls)
+	}
+}
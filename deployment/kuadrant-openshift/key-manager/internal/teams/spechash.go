@@ -0,0 +1,99 @@
+package teams
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// annotationSpecHash records the hash of the spec the key manager last wrote
+// to a policy
+const annotationSpecHash = "maas/spec-hash"
+
+// Outcomes of re-applying a team's policies
+const (
+	PolicyResultUpdated   = "updated"
+	PolicyResultUnchanged = "unchanged"
+)
+
+// specHash fingerprints a policy's spec. Metadata and status, which the API
+// server fills in, are left out, and JSON encoding sorts map keys, so the
+// same limits always hash the same however they were built.
+func specHash(policyObj *unstructured.Unstructured) string {
+	data, _ := json.Marshal(policyObj.Object["spec"])
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writePolicy updates a policy after it was rendered, unless its spec is
+// the same as when it was read. Rewriting an identical spec would still bump
+// the resource version and wake every watcher and Kuadrant's reconcilers.
+// The live spec is compared rather than the annotation alone, which a manual
+// edit leaves stale, and a policy without the annotation is written once to
+// record it.
+func (p *PolicyManager) writePolicy(gvr schema.GroupVersionResource, kind string, policyObj *unstructured.Unstructured, liveHash string) error {
+	hash := specHash(policyObj)
+	if hash == liveHash && policyObj.GetAnnotations()[annotationSpecHash] == hash {
+		return nil
+	}
+
+	annotations := policyObj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[annotationSpecHash] = hash
+	policyObj.SetAnnotations(annotations)
+	if err := normalizePolicy(policyObj); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", kind, err)
+	}
+
+	if p.exportOnly() {
+		p.recordPolicy(kind, policyObj)
+		return nil
+	}
+
+	_, err := p.kuadrantClient.Resource(gvr).Namespace(p.keyNamespace).Update(
+		context.Background(), policyObj, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", kind, err)
+	}
+	return nil
+}
+
+// normalizePolicy re-decodes a rendered policy the way the API server would
+// return it. Rendered limits hold typed slices and ints, which unstructured
+// deep copies reject, and export-only mode copies what it records.
+func normalizePolicy(policyObj *unstructured.Unstructured) error {
+	data, err := policyObj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return policyObj.UnmarshalJSON(data)
+}
+
+// PolicyVersions returns the resource versions of both policies, which a
+// write changes and a skipped write leaves as they were
+func (p *PolicyManager) PolicyVersions() string {
+	versions := p.recordedVersion() + "/"
+	for _, policy := range []struct {
+		gvr  schema.GroupVersionResource
+		name string
+	}{
+		{p.gvrs.TokenRateLimitPolicy(), p.tokenRateLimitPolicyName},
+		{p.gvrs.AuthPolicy(), p.authPolicyName},
+	} {
+		policyObj, err := p.kuadrantClient.Resource(policy.gvr).Namespace(p.keyNamespace).Get(
+			context.Background(), policy.name, metav1.GetOptions{})
+		if err == nil {
+			versions += policyObj.GetResourceVersion()
+		}
+		versions += "/"
+	}
+	return versions
+}
@@ -0,0 +1,36 @@
+package teams
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWritePolicyExportOnly(t *testing.T) {
+	p := &PolicyManager{applyMode: ApplyModeExportOnly}
+	policyObj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kuadrant.io/v1alpha1",
+		"kind":       "TokenRateLimitPolicy",
+		"metadata":   map[string]interface{}{"name": "gateway-token-rate-limits", "namespace": "llm"},
+		"spec":       map[string]interface{}{"limits": map[string]interface{}{}},
+	}}
+	liveHash := specHash(policyObj)
+	renderTokenRateLimit(policyObj, "gold", true, 1000, "1m", LimitScopePerUser, 0)
+
+	if err := p.writePolicy(DefaultPolicyGVRs().TokenRateLimitPolicy(), "TokenRateLimitPolicy", policyObj, liveHash); err != nil {
+		t.Fatalf("writePolicy() = %v", err)
+	}
+
+	recorded, err := p.readPolicyForWrite(DefaultPolicyGVRs().TokenRateLimitPolicy(), "TokenRateLimitPolicy", "gateway-token-rate-limits")
+	if err != nil {
+		t.Fatalf("readPolicyForWrite() = %v", err)
+	}
+	if recorded.GetAnnotations()[annotationSpecHash] != specHash(recorded) {
+		t.Errorf("recorded policy has spec hash %q, want %q", recorded.GetAnnotations()[annotationSpecHash], specHash(recorded))
+	}
+	limits, _, _ := unstructured.NestedMap(recorded.Object, "spec", "limits")
+	rates := limitRates(limits["gold"].(map[string]interface{}))
+	if len(rates) != 1 || numberValue(rates[0]["limit"]) != 1000 || rates[0]["window"] != "1m" {
+		t.Errorf("recorded rates = %v, want 1000 per 1m", rates)
+	}
+}
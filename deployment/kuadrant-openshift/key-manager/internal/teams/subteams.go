@@ -0,0 +1,166 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LabelParentTeamID links a sub-team's config and key secrets to its parent
+const LabelParentTeamID = "maas/parent-team-id"
+
+// parentCounterExpression keys the aggregate limit shared by a parent's
+// sub-teams
+const parentCounterExpression = `auth.identity.metadata.labels["maas/parent-team-id"]`
+
+// SubteamLimitName returns the TokenRateLimitPolicy limit capping the
+// combined usage of a parent team's sub-teams
+func SubteamLimitName(parentID string) string {
+	return fmt.Sprintf("team-%s-subteams", parentID)
+}
+
+// ParentTeam returns the parent of a sub-team, empty for top-level teams
+func (m *Manager) ParentTeam(teamID string) string {
+	teamSecret, err := m.getTeamSecret(teamID)
+	if err != nil {
+		return ""
+	}
+	return teamSecret.Labels[LabelParentTeamID]
+}
+
+// listSubteams returns the sub-teams of a parent team
+func (m *Manager) listSubteams(parentID string) ([]SubTeam, error) {
+	labelSelector := fmt.Sprintf("maas/resource-type=team-config,%s=%s", LabelParentTeamID, parentID)
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sub-teams: %w", err)
+	}
+
+	subteams := make([]SubTeam, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		subteams = append(subteams, SubTeam{
+			TeamID:   secret.Labels["maas/team-id"],
+			TeamName: secret.Annotations["maas/team-name"],
+			Policy:   secret.Annotations["maas/policy"],
+		})
+	}
+	return subteams, nil
+}
+
+// validateParent checks that a new sub-team's parent exists and is itself a
+// top-level team. Only one level of nesting is supported.
+func (m *Manager) validateParent(parentID string) error {
+	parentSecret, err := m.getTeamSecret(parentID)
+	if err != nil {
+		return fmt.Errorf("parent team %s not found", parentID)
+	}
+	if parentSecret.Annotations[annotationTeamStatus] == teamStatusArchived {
+		return fmt.Errorf("parent team %s is archived", parentID)
+	}
+	if grandparent := parentSecret.Labels[LabelParentTeamID]; grandparent != "" {
+		return fmt.Errorf("parent team %s is itself a sub-team of %s", parentID, grandparent)
+	}
+	return nil
+}
+
+// applySubteamLimit caps the combined usage of a parent's sub-teams at the
+// token limit of the parent's tier and reports whether the limit is new
+func (m *Manager) applySubteamLimit(parentID string) (bool, error) {
+	policy, err := m.GetPolicy(parentID)
+	if err != nil {
+		return false, err
+	}
+	tokenLimit, timeWindow, err := m.tierLimits(policy)
+	if err != nil && isUnlimitedPolicy(policy) {
+		// An exempted parent has no limit for its sub-teams to share
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read limits of parent team %s: %w", parentID, err)
+	}
+	return m.policyMgr.SetSubteamLimit(parentID, tokenLimit, timeWindow)
+}
+
+// refreshSubteamLimit follows a parent team's tier or limit changes in the
+// aggregate limit of its sub-teams
+func (m *Manager) refreshSubteamLimit(teamID string) {
+	if m.policyMgr == nil {
+		return
+	}
+	subteams, err := m.listSubteams(teamID)
+	if err != nil || len(subteams) == 0 {
+		return
+	}
+	if _, err := m.applySubteamLimit(teamID); err != nil {
+		log.Printf("Warning: Failed to update sub-team limit of team %s: %v", teamID, err)
+	}
+}
+
+// SetSubteamLimit adds or updates the limit shared by all keys labelled with
+// the parent team, and reports whether it was newly added
+func (p *PolicyManager) SetSubteamLimit(parentID string, tokenLimit int, timeWindow string) (bool, error) {
+	created := false
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		_, exists := limits[SubteamLimitName(parentID)]
+		created = !exists
+
+		limit := tokenRateLimit(parentID, tokenLimit, timeWindow, parentCounterExpression)
+		limit["when"] = []map[string]interface{}{
+			{
+				"predicate": fmt.Sprintf("\"%s\" in auth.identity.metadata.labels && %s == \"%s\"",
+					LabelParentTeamID, parentCounterExpression, parentID),
+			},
+		}
+		limits[SubteamLimitName(parentID)] = limit
+	})
+	if err != nil {
+		return false, err
+	}
+
+	log.Printf("Updated TokenRateLimitPolicy sub-team limit for team %s: %d tokens per %s", parentID, tokenLimit, timeWindow)
+	return created, nil
+}
+
+// RemoveSubteamLimit removes a parent team's sub-team limit
+func (p *PolicyManager) RemoveSubteamLimit(parentID string) error {
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		delete(limits, SubteamLimitName(parentID))
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Removed TokenRateLimitPolicy sub-team limit for team %s", parentID)
+	return nil
+}
+
+// updateTokenRateLimits applies a change to the TokenRateLimitPolicy limits
+func (p *PolicyManager) updateTokenRateLimits(mutate func(limits map[string]interface{})) error {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	// The mutation is re-run on a fresh copy if another writer got there
+	// first or the write failed transiently
+	return p.retryPolicyWrite("TokenRateLimitPolicy", func() error {
+		policyObj, err := p.readPolicyForWrite(tokenRateLimitGVR, "TokenRateLimitPolicy", p.tokenRateLimitPolicyName)
+		if err != nil {
+			return fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+		}
+		liveHash := specHash(policyObj)
+
+		spec, ok := policyObj.Object["spec"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("failed to parse TokenRateLimitPolicy structure")
+		}
+		limits, ok := spec["limits"].(map[string]interface{})
+		if !ok {
+			limits = make(map[string]interface{})
+			spec["limits"] = limits
+		}
+		mutate(limits)
+
+		return p.writePolicy(tokenRateLimitGVR, "TokenRateLimitPolicy", policyObj, liveHash)
+	})
+}
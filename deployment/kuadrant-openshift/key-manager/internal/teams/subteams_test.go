@@ -0,0 +1,42 @@
+package teams
+
+import (
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSubteamLimitFollowsParentTier(t *testing.T) {
+	p, client := newFakePolicyManager(3)
+	if err := p.AddTeamToTokenRateLimit("gold", 5000, "1h", LimitScopePerUser, 0); err != nil {
+		t.Fatalf("AddTeamToTokenRateLimit() = %v", err)
+	}
+	clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-free", "free"), testTeamSecret("team-gold", "gold"))
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	tests := []struct {
+		parentID   string
+		wantLimit  float64
+		wantWindow string
+	}{
+		{parentID: "team-free", wantLimit: 100, wantWindow: "1m"},
+		{parentID: "team-gold", wantLimit: 5000, wantWindow: "1h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.parentID, func(t *testing.T) {
+			created, err := m.applySubteamLimit(tt.parentID)
+			if err != nil || !created {
+				t.Fatalf("applySubteamLimit() = %v, %v, want a new limit", created, err)
+			}
+			limitConfig, ok := policyLimits(t, client)[SubteamLimitName(tt.parentID)].(map[string]interface{})
+			if !ok {
+				t.Fatalf("no sub-team limit for %s", tt.parentID)
+			}
+			rates := limitRates(limitConfig)
+			if len(rates) != 1 || numberValue(rates[0]["limit"]) != tt.wantLimit || rates[0]["window"] != tt.wantWindow {
+				t.Errorf("sub-team rates = %v, want the parent's %v per %s", rates, tt.wantLimit, tt.wantWindow)
+			}
+		})
+	}
+}
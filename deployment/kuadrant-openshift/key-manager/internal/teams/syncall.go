@@ -0,0 +1,178 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Outcomes of syncing one team in a sync of every team
+const (
+	SyncStatusSynced           = "synced"
+	SyncStatusUnchanged        = "unchanged"
+	SyncStatusFailed           = "failed"
+	SyncStatusSkipped          = "skipped"
+	SyncStatusSkippedUnlimited = "skipped_unlimited"
+	// Dry run outcomes, from the compliance check
+	SyncStatusWouldSync = "would_sync"
+	SyncStatusInSync    = "in_sync"
+)
+
+// syncAllConcurrency bounds the teams synced at once. Every team writes the
+// same two policies, so more workers mostly add conflicts to retry.
+const syncAllConcurrency = 4
+
+// SyncAllPolicies re-applies the policies of every team, such as after a
+// Kuadrant upgrade. Each team is synced to the state its config describes,
+// so a run that partly failed is completed by running it again. With dryRun
+// set nothing is written and each team reports whether its entries match.
+func (m *Manager) SyncAllPolicies(dryRun bool) (*SyncAllResult, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool {
+		return secrets.Items[i].Labels["maas/team-id"] < secrets.Items[j].Labels["maas/team-id"]
+	})
+
+	result := &SyncAllResult{DryRun: dryRun, Results: make([]TeamSyncResult, len(secrets.Items))}
+	pending := make([]int, 0, len(secrets.Items))
+	unlimited := false
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		entry := TeamSyncResult{TeamID: secret.Labels["maas/team-id"], Policy: secret.Annotations["maas/policy"]}
+		switch {
+		case entry.Policy == "":
+			entry.Status, entry.Reason = SyncStatusSkipped, "team has no policy"
+		case secret.Annotations[annotationProvisioningStatus] == ProvisioningPending:
+			entry.Status, entry.Reason = SyncStatusSkipped, "team is still being provisioned"
+		case isUnlimitedPolicy(entry.Policy):
+			entry.Status, entry.Reason = SyncStatusSkippedUnlimited, "unlimited-policy has no per-team limits"
+			unlimited = true
+		default:
+			pending = append(pending, i)
+		}
+		result.Results[i] = entry
+	}
+
+	if dryRun {
+		err = m.previewSyncAll(result, pending)
+	} else {
+		err = m.runSyncAll(result, pending, unlimited)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range result.Results {
+		switch entry.Status {
+		case SyncStatusSynced, SyncStatusWouldSync, SyncStatusInSync:
+			result.Synced++
+		case SyncStatusUnchanged:
+			result.Synced++
+			result.Unchanged++
+		case SyncStatusFailed:
+			result.Failed++
+		default:
+			result.Skipped++
+		}
+	}
+	result.Teams = len(result.Results)
+	return result, nil
+}
+
+// previewSyncAll reports, for each pending team, whether a sync would
+// change its policy entries
+func (m *Manager) previewSyncAll(result *SyncAllResult, pending []int) error {
+	report, err := m.CheckCompliance(nil)
+	if err != nil {
+		return err
+	}
+	byTeam := make(map[string]TeamCompliance, len(report.TeamResults))
+	for _, teamResult := range report.TeamResults {
+		byTeam[teamResult.TeamID] = teamResult
+	}
+
+	for _, i := range pending {
+		entry := &result.Results[i]
+		compliance, ok := byTeam[entry.TeamID]
+		if !ok || compliance.Status == ComplianceCompliant {
+			entry.Status = SyncStatusInSync
+			continue
+		}
+		entry.Status = SyncStatusWouldSync
+		entry.Reason = compliance.Status
+		for _, difference := range compliance.Differences {
+			entry.Reason += fmt.Sprintf("; %s %s", difference.Kind, difference.Field)
+		}
+	}
+	return nil
+}
+
+// runSyncAll syncs the pending teams with bounded concurrency and restarts
+// Kuadrant once at the end
+func (m *Manager) runSyncAll(result *SyncAllResult, pending []int, unlimited bool) error {
+	// unlimited-policy is shared by its teams and applied once for all of them
+	if unlimited {
+		if err := m.policyMgr.AddTeamToAuthPolicy("unlimited-policy"); err != nil {
+			log.Printf("Warning: Failed to sync AuthPolicy for unlimited-policy: %v", err)
+		}
+		if err := m.policyMgr.AddTeamToTokenRateLimit("unlimited-policy", 0, "", "", 0); err != nil {
+			log.Printf("Warning: Failed to sync TokenRateLimitPolicy for unlimited-policy: %v", err)
+		}
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < syncAllConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				entry := &result.Results[i]
+				pruned, changed, err := m.syncTeamPolicy(entry.TeamID, entry.Policy, "")
+				if err != nil {
+					entry.Status, entry.Reason = SyncStatusFailed, err.Error()
+					continue
+				}
+				entry.Status, entry.Pruned = SyncStatusSynced, pruned
+				if !changed {
+					entry.Status = SyncStatusUnchanged
+				}
+			}
+		}()
+	}
+	for _, i := range pending {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	synced, unchanged := 0, 0
+	for _, i := range pending {
+		switch entry := result.Results[i]; entry.Status {
+		case SyncStatusSynced:
+			m.notifyPolicyResynced(entry.TeamID, entry.Policy)
+			synced++
+		case SyncStatusUnchanged:
+			unchanged++
+		}
+	}
+	if synced > 0 || unlimited {
+		if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+			log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+		}
+	}
+
+	log.Printf("Policies synced for %d of %d teams, %d unchanged", synced, len(pending), unchanged)
+	return nil
+}
@@ -0,0 +1,486 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// tierConfigMapName holds the definitions of custom tiers, one JSON entry
+// per tier
+const tierConfigMapName = "maas-custom-tiers"
+
+// timeWindowPattern matches the rate windows Kuadrant accepts, such as 1h,
+// 30m or 1h30m
+var timeWindowPattern = regexp.MustCompile(`^([0-9]{1,5}(h|m|s|ms)){1,4}$`)
+
+// IsValidTimeWindow reports whether a rate limit window is accepted by Kuadrant
+func IsValidTimeWindow(window string) bool {
+	return timeWindowPattern.MatchString(window) && isPositiveDuration(window)
+}
+
+// GetTierPolicy returns the limits a tier enforces and the teams on it
+func (m *Manager) GetTierPolicy(tier string) (*TierPolicy, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	tokenLimit, timeWindow, err := m.tierLimits(tier)
+	if err != nil {
+		return nil, err
+	}
+	scope, teamTokenLimit, err := m.policyMgr.GetPolicyLimitScope(tier)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := m.policyMgr.GetPolicyRates(tier)
+	if err != nil {
+		return nil, err
+	}
+	burstLimit, burstWindow, err := m.policyMgr.GetPolicyBurst(tier)
+	if err != nil {
+		return nil, err
+	}
+	teamIDs, err := m.teamsOnTier(tier)
+	if err != nil {
+		return nil, err
+	}
+	_, custom := m.customTier(tier)
+
+	return &TierPolicy{
+		Tier:           tier,
+		Custom:         custom,
+		TokenLimit:     tokenLimit,
+		TimeWindow:     timeWindow,
+		LimitScope:     scope,
+		TeamTokenLimit: teamTokenLimit,
+		Rates:          rates,
+		BurstLimit:     burstLimit,
+		BurstWindow:    burstWindow,
+		Teams:          teamIDs,
+	}, nil
+}
+
+// CreateTierPolicy defines a custom tier with its own limits, so teams can be
+// created on or moved to it. The definition is kept in a ConfigMap, which
+// also keeps the tier's policy entries when no team uses it. Built-in tiers
+// and existing tiers are only redefined with AllowOverride set.
+func (m *Manager) CreateTierPolicy(req *CreateTierPolicyRequest) (*TierPolicy, error) {
+	if m.policyMgr == nil {
+		return nil, fmt.Errorf("policy management is not configured")
+	}
+
+	if !isValidTeamID(req.Tier) {
+		return nil, fmt.Errorf("invalid tier name: must contain only lowercase alphanumeric characters and hyphens")
+	}
+	if !req.AllowOverride && (req.Tier == "unlimited-policy" || containsString(builtinAuthGroups, req.Tier)) {
+		return nil, fmt.Errorf("tier %s is built in, set allow_override to redefine it", req.Tier)
+	}
+	// With several windows the shortest is the tier's main rate
+	var rates []RateLimit
+	if len(req.Rates) > 0 {
+		if req.TokenLimit != 0 || req.TimeWindow != "" {
+			return nil, fmt.Errorf("invalid rates: set either rates or token_limit and time_window")
+		}
+		normalized, err := normalizeRates(req.Rates)
+		if err != nil {
+			return nil, err
+		}
+		rates = normalized
+		req.TokenLimit, req.TimeWindow = rates[0].Limit, rates[0].Window
+	}
+	if req.TimeWindow == "" {
+		req.TimeWindow = "1h"
+	}
+	if req.LimitScope == "" {
+		req.LimitScope = m.policyMgr.DefaultLimitScope()
+	}
+	err := validateTierPolicyRequest(&UpdateTierPolicyRequest{
+		TokenLimit:     &req.TokenLimit,
+		TimeWindow:     &req.TimeWindow,
+		LimitScope:     &req.LimitScope,
+		TeamTokenLimit: &req.TeamTokenLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := validateBurst(req.TokenLimit, req.TimeWindow, req.BurstLimit, req.BurstWindow); err != nil {
+		return nil, err
+	}
+
+	previous, defined := m.customTier(req.Tier)
+	exists := m.policyMgr.PolicyExists(req.Tier)
+	if (exists || defined) && !req.AllowOverride {
+		return nil, fmt.Errorf("tier %s already exists, set allow_override to redefine it", req.Tier)
+	}
+
+	definition := TierDefinition{
+		TokenLimit:     req.TokenLimit,
+		TimeWindow:     req.TimeWindow,
+		LimitScope:     req.LimitScope,
+		TeamTokenLimit: req.TeamTokenLimit,
+		BurstLimit:     req.BurstLimit,
+		BurstWindow:    req.BurstWindow,
+		CreatedAt:      time.Now().Format(time.RFC3339),
+	}
+	if len(rates) > 1 {
+		definition.Rates = rates
+	}
+	if defined {
+		definition.CreatedAt = previous.CreatedAt
+	}
+	if err := m.saveTierDefinition(req.Tier, &definition); err != nil {
+		return nil, err
+	}
+
+	if err := m.applyTierDefinition(req.Tier, &definition); err != nil {
+		// Leave an earlier definition in place, it still describes the tier
+		if !defined {
+			if removeErr := m.deleteTierDefinition(req.Tier); removeErr != nil {
+				log.Printf("Warning: Failed to remove definition of tier %s: %v", req.Tier, removeErr)
+			}
+		}
+		return nil, err
+	}
+
+	log.Printf("Custom tier %s defined: %d tokens per %s", req.Tier, req.TokenLimit, req.TimeWindow)
+	return m.GetTierPolicy(req.Tier)
+}
+
+// applyTierDefinition writes a tier's group and limits to the Kuadrant
+// policies and restarts Kuadrant so they are enforced
+func (m *Manager) applyTierDefinition(tier string, definition *TierDefinition) error {
+	if err := m.policyMgr.AddTeamToAuthPolicy(tier); err != nil {
+		m.recordPolicyResult(tier, err)
+		return fmt.Errorf("failed to update AuthPolicy: %w", err)
+	}
+	err := m.policyMgr.AddTeamToTokenRateLimit(tier, definition.TokenLimit, definition.TimeWindow,
+		definition.LimitScope, definition.TeamTokenLimit)
+	if err == nil {
+		err = m.policyMgr.SetPolicyRates(tier, definition.rates())
+	}
+	if err == nil {
+		err = m.policyMgr.SetPolicyBurst(tier, definition.BurstLimit, definition.BurstWindow)
+	}
+	m.recordPolicyResult(tier, err)
+	if err != nil {
+		return fmt.Errorf("failed to update TokenRateLimitPolicy: %w", err)
+	}
+
+	if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+		log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+	}
+	return nil
+}
+
+// UpdateTierPolicy changes the limits of an existing tier in the
+// TokenRateLimitPolicy, which every team on the tier shares. Fields left out
+// keep their current value. With ResyncTeams set, each team on the tier gets
+// its sub-team limit, MaaSTeam and policy webhook refreshed.
+func (m *Manager) UpdateTierPolicy(tier string, req *UpdateTierPolicyRequest) (*UpdateTierPolicyResponse, error) {
+	if err := validateTierPolicyRequest(req); err != nil {
+		return nil, err
+	}
+
+	current, err := m.GetTierPolicy(tier)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &UpdateTierPolicyResponse{
+		TierPolicy:    *current,
+		ChangedFields: make([]string, 0),
+		TeamsResynced: make([]string, 0),
+	}
+	response.Rates = append([]RateLimit(nil), current.Rates...)
+	if len(req.Rates) > 0 {
+		if req.TokenLimit != nil || req.TimeWindow != nil {
+			return nil, fmt.Errorf("invalid rates: set either rates or token_limit and time_window")
+		}
+		rates, err := normalizeRates(req.Rates)
+		if err != nil {
+			return nil, err
+		}
+		if !equalRates(rates, current.Rates) {
+			response.Rates = rates
+			response.ChangedFields = append(response.ChangedFields, "rates")
+		}
+		req.TokenLimit, req.TimeWindow = &rates[0].Limit, &rates[0].Window
+	}
+	if req.TokenLimit != nil && *req.TokenLimit != current.TokenLimit {
+		response.TokenLimit = *req.TokenLimit
+		response.ChangedFields = append(response.ChangedFields, "token_limit")
+	}
+	if req.TimeWindow != nil && *req.TimeWindow != current.TimeWindow {
+		response.TimeWindow = *req.TimeWindow
+		response.ChangedFields = append(response.ChangedFields, "time_window")
+	}
+	if req.LimitScope != nil && *req.LimitScope != current.LimitScope {
+		response.LimitScope = *req.LimitScope
+		response.ChangedFields = append(response.ChangedFields, "limit_scope")
+	}
+	if req.TeamTokenLimit != nil && *req.TeamTokenLimit != current.TeamTokenLimit {
+		response.TeamTokenLimit = *req.TeamTokenLimit
+		response.ChangedFields = append(response.ChangedFields, "team_token_limit")
+	}
+	burstChanged := false
+	if req.BurstLimit != nil && *req.BurstLimit != current.BurstLimit {
+		response.BurstLimit = *req.BurstLimit
+		burstChanged = true
+		response.ChangedFields = append(response.ChangedFields, "burst_limit")
+	}
+	if req.BurstWindow != nil && *req.BurstWindow != current.BurstWindow && response.BurstLimit > 0 {
+		response.BurstWindow = *req.BurstWindow
+		burstChanged = true
+		response.ChangedFields = append(response.ChangedFields, "burst_window")
+	}
+	if response.BurstLimit == 0 {
+		response.BurstWindow = ""
+	}
+
+	// The main rate must keep the shortest window of the tier
+	if len(response.Rates) > 0 {
+		response.Rates[0] = RateLimit{Limit: response.TokenLimit, Window: response.TimeWindow}
+	}
+	if len(response.Rates) > 1 {
+		rates, err := normalizeRates(response.Rates)
+		if err != nil {
+			return nil, err
+		}
+		if rates[0].Window != response.TimeWindow {
+			return nil, fmt.Errorf("invalid time_window: %s must be shorter than the tier's other windows", response.TimeWindow)
+		}
+	}
+
+	// The burst is checked against the sustained rate it will sit on
+	if err := validateBurst(response.TokenLimit, response.TimeWindow, response.BurstLimit, response.BurstWindow); err != nil {
+		return nil, err
+	}
+
+	if len(response.ChangedFields) > 0 {
+		err = m.policyMgr.AddTeamToTokenRateLimit(tier, response.TokenLimit, response.TimeWindow,
+			response.LimitScope, response.TeamTokenLimit)
+		if err == nil && len(response.Rates) > 0 {
+			// Drops windows the tier no longer has
+			err = m.policyMgr.SetPolicyRates(tier, response.Rates)
+		}
+		if err == nil && burstChanged {
+			err = m.policyMgr.SetPolicyBurst(tier, response.BurstLimit, response.BurstWindow)
+		}
+		m.recordPolicyResult(tier, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update tier %s: %w", tier, err)
+		}
+
+		if err := m.policyMgr.RestartKuadrantComponents(); err != nil {
+			log.Printf("Warning: Failed to restart Kuadrant components: %v", err)
+		}
+
+		// Only the "both" scope keeps a team-wide limit
+		if scope, teamTokenLimit, err := m.policyMgr.GetPolicyLimitScope(tier); err == nil {
+			response.LimitScope, response.TeamTokenLimit = scope, teamTokenLimit
+		}
+
+		// Keep a custom tier's definition in line with what is enforced
+		if definition, ok := m.customTier(tier); ok {
+			definition.TokenLimit, definition.TimeWindow = response.TokenLimit, response.TimeWindow
+			definition.LimitScope, definition.TeamTokenLimit = response.LimitScope, response.TeamTokenLimit
+			definition.BurstLimit, definition.BurstWindow = response.BurstLimit, response.BurstWindow
+			definition.Rates = nil
+			if len(response.Rates) > 1 {
+				definition.Rates = response.Rates
+			}
+			if err := m.saveTierDefinition(tier, definition); err != nil {
+				log.Printf("Warning: Failed to update definition of tier %s: %v", tier, err)
+			}
+		}
+	}
+
+	if req.ResyncTeams {
+		for _, teamID := range response.Teams {
+			m.notifyPolicyResynced(teamID, tier)
+			m.refreshSubteamLimit(teamID)
+			m.syncTeamCRByID(teamID)
+			response.TeamsResynced = append(response.TeamsResynced, teamID)
+		}
+	}
+
+	log.Printf("Tier %s updated, changed fields: %v, %d teams resynced",
+		tier, response.ChangedFields, len(response.TeamsResynced))
+	return response, nil
+}
+
+// validateTierPolicyRequest checks the limits of a tier update
+func validateTierPolicyRequest(req *UpdateTierPolicyRequest) error {
+	if req.TokenLimit != nil {
+		if err := ValidateTokenLimit("token_limit", *req.TokenLimit); err != nil {
+			return err
+		}
+	}
+	if req.TimeWindow != nil {
+		if err := ValidateTimeWindow("time_window", *req.TimeWindow); err != nil {
+			return err
+		}
+	}
+	if req.LimitScope != nil && !IsValidLimitScope(*req.LimitScope) {
+		return fmt.Errorf("invalid limit_scope: must be one of %s, %s, %s or %s", LimitScopePerUser, LimitScopePerTeam, LimitScopePerKey, LimitScopeBoth)
+	}
+	if req.TeamTokenLimit != nil && *req.TeamTokenLimit < 0 {
+		return fmt.Errorf("invalid team_token_limit: must not be negative")
+	}
+	if req.BurstLimit != nil && *req.BurstLimit < 0 {
+		return fmt.Errorf("invalid burst_limit: must not be negative")
+	}
+	return nil
+}
+
+// rates returns the sustained rates of a custom tier
+func (d *TierDefinition) rates() []RateLimit {
+	if len(d.Rates) > 0 {
+		return d.Rates
+	}
+	return []RateLimit{{Limit: d.TokenLimit, Window: d.TimeWindow}}
+}
+
+// equalRates reports whether two sets of rates are the same
+func equalRates(a, b []RateLimit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// teamsOnTier returns the IDs of the teams configured with a tier
+func (m *Manager) teamsOnTier(tier string) ([]string, error) {
+	secrets, err := m.clientset.CoreV1().Secrets(m.keyNamespace).List(
+		context.Background(), metav1.ListOptions{LabelSelector: "maas/resource-type=team-config"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team secrets: %w", err)
+	}
+
+	teamIDs := make([]string, 0)
+	for _, secret := range secrets.Items {
+		if secret.Annotations["maas/policy"] == tier {
+			teamIDs = append(teamIDs, secret.Labels["maas/team-id"])
+		}
+	}
+	sort.Strings(teamIDs)
+	return teamIDs, nil
+}
+
+// customTier returns the definition of a custom tier, if there is one
+func (m *Manager) customTier(tier string) (*TierDefinition, bool) {
+	definitions, err := m.tierDefinitions()
+	if err != nil {
+		log.Printf("Warning: Failed to read custom tiers: %v", err)
+		return nil, false
+	}
+	definition, ok := definitions[tier]
+	return definition, ok
+}
+
+// tierDefinitions returns every custom tier definition. A missing ConfigMap
+// means there are none.
+func (m *Manager) tierDefinitions() (map[string]*TierDefinition, error) {
+	configMap, err := m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Get(
+		context.Background(), tierConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]*TierDefinition{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom tiers: %w", err)
+	}
+
+	definitions := make(map[string]*TierDefinition, len(configMap.Data))
+	for tier, data := range configMap.Data {
+		var definition TierDefinition
+		if err := json.Unmarshal([]byte(data), &definition); err != nil {
+			log.Printf("Warning: Ignoring invalid definition of tier %s: %v", tier, err)
+			continue
+		}
+		definitions[tier] = &definition
+	}
+	return definitions, nil
+}
+
+// saveTierDefinition stores a custom tier definition, creating the ConfigMap
+// on first use
+func (m *Manager) saveTierDefinition(tier string, definition *TierDefinition) error {
+	data, err := json.Marshal(definition)
+	if err != nil {
+		return fmt.Errorf("failed to encode tier %s: %w", tier, err)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Get(
+			context.Background(), tierConfigMapName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      tierConfigMapName,
+					Namespace: m.keyNamespace,
+					Labels: map[string]string{
+						"maas/managed-by":    "key-manager",
+						"maas/resource-type": "custom-tiers",
+					},
+				},
+				Data: map[string]string{tier: string(data)},
+			}
+			_, err = m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Create(
+				context.Background(), configMap, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently, retry as an update
+				return apierrors.NewConflict(corev1.Resource("configmaps"), tierConfigMapName, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[tier] = string(data)
+		_, err = m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Update(
+			context.Background(), configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save tier %s: %w", tier, err)
+	}
+	return nil
+}
+
+// deleteTierDefinition removes a custom tier definition
+func (m *Manager) deleteTierDefinition(tier string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Get(
+			context.Background(), tierConfigMapName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		delete(configMap.Data, tier)
+		_, err = m.clientset.CoreV1().ConfigMaps(m.keyNamespace).Update(
+			context.Background(), configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,55 @@
+package teams
+
+import (
+	"testing"
+
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUpdateTierPolicyRoundTrip(t *testing.T) {
+	p, _ := newFakePolicyManager(3)
+	clientset := k8sfake.NewSimpleClientset(testTeamSecret("team-a", "free"))
+	m := NewManager(clientset, testNamespace, p, nil, nil, nil, false, false, nil, nil)
+
+	// The free tier starts at 100 tokens per minute
+	current, err := m.GetTierPolicy("free")
+	if err != nil {
+		t.Fatalf("GetTierPolicy() = %v", err)
+	}
+	if current.TokenLimit != 100 || current.TimeWindow != "1m" {
+		t.Errorf("GetTierPolicy() = %d per %s, want 100 per 1m", current.TokenLimit, current.TimeWindow)
+	}
+	if len(current.Teams) != 1 || current.Teams[0] != "team-a" {
+		t.Errorf("GetTierPolicy() teams = %v, want team-a", current.Teams)
+	}
+
+	// Resending the current limit changes nothing
+	unchanged := 100
+	response, err := m.UpdateTierPolicy("free", &UpdateTierPolicyRequest{TokenLimit: &unchanged})
+	if err != nil {
+		t.Fatalf("UpdateTierPolicy() = %v", err)
+	}
+	if len(response.ChangedFields) != 0 {
+		t.Errorf("UpdateTierPolicy() changed %v, want nothing", response.ChangedFields)
+	}
+
+	tokenLimit, timeWindow := 500, "1h"
+	response, err = m.UpdateTierPolicy("free", &UpdateTierPolicyRequest{TokenLimit: &tokenLimit, TimeWindow: &timeWindow})
+	if err != nil {
+		t.Fatalf("UpdateTierPolicy() = %v", err)
+	}
+	if response.TokenLimit != 500 || response.TimeWindow != "1h" {
+		t.Errorf("UpdateTierPolicy() = %d per %s, want 500 per 1h", response.TokenLimit, response.TimeWindow)
+	}
+
+	updated, err := m.GetTierPolicy("free")
+	if err != nil {
+		t.Fatalf("GetTierPolicy() = %v", err)
+	}
+	if updated.TokenLimit != 500 || updated.TimeWindow != "1h" {
+		t.Errorf("GetTierPolicy() after the update = %d per %s, want 500 per 1h", updated.TokenLimit, updated.TimeWindow)
+	}
+	if len(updated.Rates) != 1 || updated.Rates[0] != (RateLimit{Limit: 500, Window: "1h"}) {
+		t.Errorf("GetTierPolicy() rates = %v, want 500 per 1h", updated.Rates)
+	}
+}
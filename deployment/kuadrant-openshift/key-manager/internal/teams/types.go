@@ -1,6 +1,11 @@
 package teams
 
-import "regexp"
+import (
+	"regexp"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/limitador"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
+)
 
 // Team management structures
 type CreateTeamRequest struct {
@@ -10,32 +15,216 @@ type CreateTeamRequest struct {
 	Policy      string `json:"policy,omitempty"`
 	TokenLimit  int    `json:"token_limit,omitempty"` // Token limit per window (default: 100000)
 	TimeWindow  string `json:"time_window,omitempty"` // Time window (default: "1h")
+	// Monthly spend budget in USD, 0 disables budget enforcement
+	BudgetUSDMonthly float64 `json:"budget_usd_monthly,omitempty"`
+	// What the policy's token counters are keyed on: per_user, per_team or
+	// both. Scopes apply to the whole policy, so teams sharing it share the scope.
+	LimitScope string `json:"limit_scope,omitempty"`
+	// Shared team-wide token limit for the "both" scope (default: token_limit)
+	TeamTokenLimit int `json:"team_token_limit,omitempty"`
+	// Team webhook for policy, budget and suspension events, signed with
+	// HMAC-SHA256 when a secret is set
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	// Namespace for the team's key secrets (default: the shared key namespace)
+	Namespace string `json:"namespace,omitempty"`
+	// Accountable owner, registered as a team admin
+	OwnerUserID string `json:"owner_user_id,omitempty"`
+	OwnerEmail  string `json:"owner_email,omitempty"`
+	// Parent team whose tier limit caps the combined usage of its sub-teams
+	ParentTeamID string `json:"parent_team_id,omitempty"`
+	// Token limits for individual models, replacing the blanket limit for them
+	ModelLimits map[string]ModelLimit `json:"model_limits,omitempty"`
+}
+
+// ModelLimit is the token limit of a single model
+type ModelLimit struct {
+	TokenLimit int    `json:"token_limit"`
+	TimeWindow string `json:"time_window"`
 }
 
 type UpdateTeamRequest struct {
+	// TeamID is accepted only so that attempts to change it can be rejected
+	TeamID      *string `json:"team_id,omitempty"`
 	TeamName    *string `json:"team_name,omitempty"`
 	Description *string `json:"description,omitempty"`
 	Policy      *string `json:"policy,omitempty"`
 	TokenLimit  *int    `json:"token_limit,omitempty"`
 	TimeWindow  *string `json:"time_window,omitempty"`
+	// Key caps overriding MAX_KEYS_PER_USER/MAX_KEYS_PER_TEAM, 0 means unlimited
+	MaxKeysPerUser *int `json:"max_keys_per_user,omitempty"`
+	MaxKeysPerTeam *int `json:"max_keys_per_team,omitempty"`
+	// Monthly spend budget in USD, 0 disables budget enforcement
+	BudgetUSDMonthly *float64 `json:"budget_usd_monthly,omitempty"`
+	// Team webhook, an empty string removes it
+	WebhookURL    *string `json:"webhook_url,omitempty"`
+	WebhookSecret *string `json:"webhook_secret,omitempty"`
+	// Per-model token limits, an empty map removes them
+	ModelLimits map[string]ModelLimit `json:"model_limits,omitempty"`
+	// Blocks deleting the team until set back to false
+	DeletionProtected *bool `json:"deletion_protected,omitempty"`
+}
+
+type UpdateTeamResponse struct {
+	TeamID           string   `json:"team_id"`
+	ChangedFields    []string `json:"changed_fields"`
+	PoliciesResynced bool     `json:"policies_resynced"`
+	// Set when a name or tier change was propagated to the team's keys
+	KeyMetadata *PropagateMetadataResult `json:"key_metadata,omitempty"`
+}
+
+type ChangeTierRequest struct {
+	Tier      string `json:"tier" binding:"required"`
+	Propagate bool   `json:"propagate"`
+}
+
+type ChangeTierResponse struct {
+	TeamID           string             `json:"team_id"`
+	PreviousTier     string             `json:"previous_tier"`
+	Tier             string             `json:"tier"`
+	PoliciesResynced bool               `json:"policies_resynced"`
+	KeysUpdated      int                `json:"keys_updated"`
+	KeyFailures      []KeyUpdateFailure `json:"key_failures"`
+	// Limit entries the previous tier no longer justifies
+	Pruned []string `json:"pruned"`
+}
+
+// TierPolicy is a tier's entry in the TokenRateLimitPolicy along with the
+// teams that share it
+type TierPolicy struct {
+	Tier string `json:"tier"`
+	// Custom is set for tiers defined through the API
+	Custom         bool   `json:"custom"`
+	TokenLimit     int    `json:"token_limit"`
+	TimeWindow     string `json:"time_window"`
+	LimitScope     string `json:"limit_scope"`
+	TeamTokenLimit int    `json:"team_token_limit,omitempty"`
+	// Every sustained rate of the tier, the token_limit and time_window first
+	Rates []RateLimit `json:"rates"`
+	// Cap on short spikes, enforced next to the sustained rate
+	BurstLimit  int      `json:"burst_limit,omitempty"`
+	BurstWindow string   `json:"burst_window,omitempty"`
+	Teams       []string `json:"teams"`
+}
+
+// RateLimit is a token limit over one window. A limit of -1 leaves the
+// window unlimited.
+type RateLimit struct {
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+type UpdateTierPolicyRequest struct {
+	TokenLimit     *int    `json:"token_limit,omitempty"`
+	TimeWindow     *string `json:"time_window,omitempty"`
+	LimitScope     *string `json:"limit_scope,omitempty"`
+	TeamTokenLimit *int    `json:"team_token_limit,omitempty"`
+	// Limits over several windows, replacing token_limit and time_window
+	Rates []RateLimit `json:"rates,omitempty"`
+	// Burst cap, a burst_limit of 0 removes it
+	BurstLimit  *int    `json:"burst_limit,omitempty"`
+	BurstWindow *string `json:"burst_window,omitempty"`
+	// Refresh sub-team limits, MaaSTeams and webhooks of teams on the tier
+	ResyncTeams bool `json:"resync_teams"`
+}
+
+type CreateTierPolicyRequest struct {
+	Tier           string `json:"tier" binding:"required"`
+	TokenLimit     int    `json:"token_limit"`
+	TimeWindow     string `json:"time_window"`
+	LimitScope     string `json:"limit_scope"`
+	TeamTokenLimit int    `json:"team_token_limit"`
+	BurstLimit     int    `json:"burst_limit"`
+	BurstWindow    string `json:"burst_window"`
+	// Limits over several windows, used instead of token_limit and time_window
+	Rates []RateLimit `json:"rates"`
+	// Redefine a built-in or existing tier instead of rejecting it
+	AllowOverride bool `json:"allow_override"`
+}
+
+// TierDefinition is a custom tier as stored in the custom tiers ConfigMap
+type TierDefinition struct {
+	TokenLimit     int    `json:"token_limit"`
+	TimeWindow     string `json:"time_window"`
+	LimitScope     string `json:"limit_scope"`
+	TeamTokenLimit int    `json:"team_token_limit,omitempty"`
+	BurstLimit     int    `json:"burst_limit,omitempty"`
+	BurstWindow    string `json:"burst_window,omitempty"`
+	// Sustained rates over several windows, set when the tier has more than one
+	Rates     []RateLimit `json:"rates,omitempty"`
+	CreatedAt string      `json:"created_at"`
+}
+
+type UpdateTierPolicyResponse struct {
+	TierPolicy
+	ChangedFields []string `json:"changed_fields"`
+	TeamsResynced []string `json:"teams_resynced"`
+}
+
+// PropagateMetadataResult summarizes a refresh of team metadata on its keys
+type PropagateMetadataResult struct {
+	TeamID    string             `json:"team_id"`
+	TeamName  string             `json:"team_name"`
+	Policy    string             `json:"policy"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Failures  []KeyUpdateFailure `json:"failures"`
+}
+
+// KeyUpdateFailure records a team key that could not be updated
+type KeyUpdateFailure struct {
+	SecretName string `json:"secret_name"`
+	Error      string `json:"error"`
 }
 
 type CreateTeamResponse struct {
-	TeamID      string `json:"team_id"`
-	TeamName    string `json:"team_name"`
-	Description string `json:"description"`
-	Policy      string `json:"policy"`
-	CreatedAt   string `json:"created_at"`
+	TeamID           string  `json:"team_id"`
+	TeamName         string  `json:"team_name"`
+	Description      string  `json:"description"`
+	Policy           string  `json:"policy"`
+	CreatedAt        string  `json:"created_at"`
+	BudgetUSDMonthly float64 `json:"budget_usd_monthly,omitempty"`
+	LimitScope       string  `json:"limit_scope,omitempty"`
+	Namespace        string  `json:"namespace,omitempty"`
+	OwnerUserID      string  `json:"owner_user_id,omitempty"`
+	OwnerEmail       string  `json:"owner_email,omitempty"`
+	ParentTeamID     string  `json:"parent_team_id,omitempty"`
+	// Rates of the policy the team was created on
+	InheritedLimits []RateLimit `json:"inherited_limits,omitempty"`
 }
 
 type GetTeamResponse struct {
-	TeamID      string       `json:"team_id"`
-	TeamName    string       `json:"team_name"`
-	Description string       `json:"description"`
-	Policy      string       `json:"policy"`
-	Members     []TeamMember `json:"users"`
-	Keys        []string     `json:"keys"`
-	CreatedAt   string       `json:"created_at"`
+	TeamID      string              `json:"team_id"`
+	TeamName    string              `json:"team_name"`
+	Description string              `json:"description"`
+	Policy      string              `json:"policy"`
+	Members     []TeamMember        `json:"users"`
+	Keys        []string            `json:"keys"`
+	CreatedAt   string              `json:"created_at"`
+	Status      string              `json:"status"`
+	Budget      *types.BudgetStatus `json:"budget,omitempty"`
+	// Counters the team's policy limits are keyed on
+	LimitScope     string `json:"limit_scope,omitempty"`
+	TeamTokenLimit int    `json:"team_token_limit,omitempty"`
+	WebhookURL     string `json:"webhook_url,omitempty"`
+	// Namespace holding the team's key secrets
+	KeyNamespace string `json:"key_namespace"`
+	// Live state of the team's Kuadrant policies
+	PolicyStatus *TeamPolicyStatus `json:"policy_status,omitempty"`
+	OwnerUserID  string            `json:"owner_user_id,omitempty"`
+	OwnerEmail   string            `json:"owner_email,omitempty"`
+	// Set for teams created asynchronously: pending, ready or failed
+	ProvisioningStatus string `json:"provisioning_status,omitempty"`
+	ParentTeamID       string `json:"parent_team_id,omitempty"`
+	// Teams whose combined usage is capped by this team's limit
+	SubTeams          []SubTeam `json:"sub_teams,omitempty"`
+	DeletionProtected bool      `json:"deletion_protected"`
+}
+
+type SubTeam struct {
+	TeamID   string `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Policy   string `json:"policy"`
 }
 
 type TeamMember struct {
@@ -46,10 +235,17 @@ type TeamMember struct {
 	TeamName  string `json:"team_name"`
 	JoinedAt  string `json:"joined_at"`
 	Policy    string `json:"policy"` // Inherited from team
+	// Individual rate overrides from the membership record
+	TokenLimit   int    `json:"token_limit,omitempty"`
+	RequestLimit int    `json:"request_limit,omitempty"`
+	TimeWindow   string `json:"time_window,omitempty"`
+	// Registered is set when the member has an explicit membership record
+	Registered bool `json:"registered"`
 }
 
 // User management structures
 type AddUserToTeamRequest struct {
+	UserID    string `json:"user_id" binding:"required"`
 	UserEmail string `json:"user_email" binding:"required"`
 	Role      string `json:"role" binding:"required"`
 	// Individual rate overrides
@@ -58,6 +254,138 @@ type AddUserToTeamRequest struct {
 	TimeWindow   string `json:"time_window,omitempty"`
 }
 
+type UpdateMemberRequest struct {
+	Role         *string `json:"role,omitempty"`
+	TokenLimit   *int    `json:"token_limit,omitempty"`
+	RequestLimit *int    `json:"request_limit,omitempty"`
+	TimeWindow   *string `json:"time_window,omitempty"`
+}
+
+// Team admin token structures
+type CreateAdminTokenRequest struct {
+	Description string `json:"description"`
+}
+
+type AdminToken struct {
+	TokenID     string `json:"token_id"`
+	TeamID      string `json:"team_id"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type CreateAdminTokenResponse struct {
+	Token string `json:"token"`
+	AdminToken
+}
+
+// Invite states, derived from the invite's expiry and use
+const (
+	InviteStatusPending = "pending"
+	InviteStatusUsed    = "used"
+	InviteStatusExpired = "expired"
+)
+
+type CreateInviteRequest struct {
+	Role        string `json:"role"`
+	Description string `json:"description"`
+	// Go duration, defaults to the configured invite TTL
+	ExpiresIn string `json:"expires_in"`
+	// Mint a first API key for the user when the invite is accepted
+	CreateKey bool `json:"create_key"`
+}
+
+type Invite struct {
+	InviteID    string `json:"invite_id"`
+	TeamID      string `json:"team_id"`
+	Role        string `json:"role"`
+	Description string `json:"description"`
+	CreateKey   bool   `json:"create_key"`
+	Status      string `json:"status"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at"`
+	UsedAt      string `json:"used_at,omitempty"`
+	UsedBy      string `json:"used_by,omitempty"`
+}
+
+type CreateInviteResponse struct {
+	Token string `json:"token"`
+	Invite
+}
+
+type AcceptInviteRequest struct {
+	UserID    string `json:"user_id" binding:"required"`
+	UserEmail string `json:"user_email"`
+}
+
+// Outcomes of each resource in a team deletion
+const (
+	DeleteStatusPlanned = "planned"
+	DeleteStatusDeleted = "deleted"
+	DeleteStatusFailed  = "failed"
+)
+
+// RemoveMemberResult reports what happened to a removed member's keys
+type RemoveMemberResult struct {
+	TeamID          string `json:"team_id"`
+	UserID          string `json:"user_id"`
+	Mode            string `json:"mode"`
+	DeletedKeys     int    `json:"deleted_keys"`
+	DeactivatedKeys int    `json:"deactivated_keys"`
+}
+
+type DeleteTeamResult struct {
+	TeamID      string            `json:"team_id"`
+	DryRun      bool              `json:"dry_run"`
+	KeyCount    int               `json:"key_count"`
+	MemberCount int               `json:"member_count"`
+	Failed      int               `json:"failed"`
+	Resources   []DeletedResource `json:"resources"`
+}
+
+type DeletedResource struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Sort fields accepted by the teams list
+const (
+	TeamSortName      = "name"
+	TeamSortCreatedAt = "created_at"
+)
+
+// ListTeamsOptions filters and sorts the teams list
+type ListTeamsOptions struct {
+	Tier            string
+	NameContains    string
+	Sort            string
+	Descending      bool
+	IncludeArchived bool
+}
+
+// SetTeamModelsRequest replaces a team's model allowlist
+type SetTeamModelsRequest struct {
+	Models []string `json:"models"`
+}
+
+// TeamModelsResponse shows the models a team may use and where they come from
+type TeamModelsResponse struct {
+	TeamID       string      `json:"team_id"`
+	Tier         string      `json:"tier"`
+	Models       []string    `json:"models"`
+	Source       string      `json:"source"`
+	AllModels    bool        `json:"all_models"`
+	KeyOverrides []KeyModels `json:"key_overrides"`
+}
+
+// KeyModels is a key whose model list overrides the team's
+type KeyModels struct {
+	KeyName string   `json:"key_name"`
+	Models  []string `json:"models"`
+	Source  string   `json:"source"`
+}
+
 // Validation helpers
 
 // isValidTeamID validates team ID according to Kubernetes RFC 1123 subdomain rules
@@ -71,4 +399,364 @@ func isValidTeamID(teamID string) bool {
 	// Must start and end with an alphanumeric character
 	validPattern := regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
 	return validPattern.MatchString(teamID)
-}
\ No newline at end of file
+}
+
+// TeamExport is a team's configuration as carried between clusters. Webhook
+// signing secrets are not exported.
+type TeamExport struct {
+	TeamID           string        `json:"team_id"`
+	TeamName         string        `json:"team_name"`
+	Description      string        `json:"description"`
+	Policy           string        `json:"policy"`
+	Status           string        `json:"status"`
+	CreatedAt        string        `json:"created_at"`
+	BudgetUSDMonthly float64       `json:"budget_usd_monthly,omitempty"`
+	MaxKeysPerUser   *int          `json:"max_keys_per_user,omitempty"`
+	MaxKeysPerTeam   *int          `json:"max_keys_per_team,omitempty"`
+	ModelsAllowed    []string      `json:"models_allowed,omitempty"`
+	WebhookURL       string        `json:"webhook_url,omitempty"`
+	Namespace        string        `json:"namespace,omitempty"`
+	OwnerUserID      string        `json:"owner_user_id,omitempty"`
+	OwnerEmail       string        `json:"owner_email,omitempty"`
+	ParentTeamID     string        `json:"parent_team_id,omitempty"`
+	Limits           *PolicyExport `json:"limits,omitempty"`
+	Members          []TeamMember  `json:"members"`
+	// What the team's usage has cost so far, reported only; an import does
+	// not restore it
+	Cost *types.CostSummary `json:"cost,omitempty"`
+}
+
+// PolicyExport is the rate limit rendered for a team's policy
+type PolicyExport struct {
+	TokenLimit     int    `json:"token_limit"`
+	TimeWindow     string `json:"time_window"`
+	LimitScope     string `json:"limit_scope,omitempty"`
+	TeamTokenLimit int    `json:"team_token_limit,omitempty"`
+}
+
+// UpdateDefaultTeamRequest changes the default team's tier and limits
+type UpdateDefaultTeamRequest struct {
+	Tier       *string `json:"tier,omitempty"`
+	TokenLimit *int    `json:"token_limit,omitempty"`
+	TimeWindow *string `json:"time_window,omitempty"`
+}
+
+// RecreateDefaultTeamRequest optionally overrides the configured default tier
+type RecreateDefaultTeamRequest struct {
+	Tier string `json:"tier,omitempty"`
+}
+
+// TeamPolicyStatus is the live state of the Kuadrant policies a team relies
+// on. Drift means the team's limit or group is missing from the cluster.
+type TeamPolicyStatus struct {
+	Policy   string         `json:"policy"`
+	Drift    bool           `json:"drift"`
+	Policies []PolicyStatus `json:"policies"`
+	// Counters the policy's limits are keyed on
+	LimitScope string `json:"limit_scope,omitempty"`
+	// How a team on unlimited-policy is kept clear of token limits
+	Unlimited string `json:"unlimited,omitempty"`
+	// Individual limits of members with overrides tighter than the tier
+	MemberLimits []MemberLimit `json:"member_limits,omitempty"`
+	// Per-model limits of the team's policy
+	ModelLimits map[string]ModelLimit `json:"model_limits,omitempty"`
+	// Sustained rates and burst cap of the team's policy
+	Rates       []RateLimit `json:"rates,omitempty"`
+	BurstLimit  int         `json:"burst_limit,omitempty"`
+	BurstWindow string      `json:"burst_window,omitempty"`
+	// Whether a sync wrote the policies or found them already in sync
+	Result string `json:"result,omitempty"`
+	// Limit entries removed by a sync because the tier no longer justifies them
+	Pruned []string `json:"pruned,omitempty"`
+}
+
+// PolicyStatus is the live state of a single Kuadrant policy
+type PolicyStatus struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Exists    bool   `json:"exists"`
+	// TeamEntry is set when the policy carries the team's limit or group
+	TeamEntry          bool             `json:"team_entry"`
+	Accepted           *PolicyCondition `json:"accepted,omitempty"`
+	Enforced           *PolicyCondition `json:"enforced,omitempty"`
+	Generation         int64            `json:"generation,omitempty"`
+	ObservedGeneration int64            `json:"observed_generation,omitempty"`
+	Error              string           `json:"error,omitempty"`
+}
+
+// PolicyCondition is a Kuadrant policy status condition
+type PolicyCondition struct {
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	ObservedGeneration int64  `json:"observed_generation,omitempty"`
+	LastTransitionTime string `json:"last_transition_time,omitempty"`
+}
+
+type TransferOwnershipRequest struct {
+	NewOwnerUserID string `json:"new_owner_user_id" binding:"required"`
+	// Defaults to the email on the new owner's membership
+	NewOwnerEmail string `json:"new_owner_email,omitempty"`
+}
+
+type TransferOwnershipResponse struct {
+	TeamID              string `json:"team_id"`
+	OwnerUserID         string `json:"owner_user_id"`
+	OwnerEmail          string `json:"owner_email"`
+	PreviousOwnerUserID string `json:"previous_owner_user_id"`
+	TransferredAt       string `json:"transferred_at"`
+}
+
+// ProvisionedResource is a resource created while provisioning a team
+type ProvisionedResource struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// OrphanReport lists policy entries and team configs that have lost their
+// counterpart
+type OrphanReport struct {
+	OrphanedPolicies      []OrphanedPolicy    `json:"orphaned_policies"`
+	TeamsMissingPolicies  []TeamMissingPolicy `json:"teams_missing_policies"`
+	TokenRateLimitEntries int                 `json:"token_rate_limit_entries"`
+	AuthPolicyGroups      int                 `json:"auth_policy_groups"`
+}
+
+// OrphanedPolicy is a policy entry no team config refers to
+type OrphanedPolicy struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Policy string `json:"policy"`
+}
+
+// TeamMissingPolicy is a team config whose policy lacks a limit or group
+type TeamMissingPolicy struct {
+	TeamID  string   `json:"team_id"`
+	Policy  string   `json:"policy"`
+	Missing []string `json:"missing"`
+}
+
+type ProvisioningStatusResponse struct {
+	TeamID    string `json:"team_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// ApplyTeamsRequest is a declarative manifest of teams
+type ApplyTeamsRequest struct {
+	Teams []TeamSpec `json:"teams"`
+	// Archive teams that exist in the cluster but are not in the manifest
+	Prune bool `json:"prune,omitempty"`
+}
+
+// TeamSpec is the desired state of one team. Fields left empty keep the
+// team's current value; an empty models_allowed list reverts to the tier
+// default.
+type TeamSpec struct {
+	TeamID           string   `json:"team_id"`
+	TeamName         string   `json:"team_name"`
+	Description      string   `json:"description,omitempty"`
+	Tier             string   `json:"tier,omitempty"`
+	TokenLimit       int      `json:"token_limit,omitempty"`
+	TimeWindow       string   `json:"time_window,omitempty"`
+	BudgetUSDMonthly *float64 `json:"budget_usd_monthly,omitempty"`
+	ModelsAllowed    []string `json:"models_allowed,omitempty"`
+}
+
+type ApplyTeamsResponse struct {
+	Prune     bool              `json:"prune"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Pruned    int               `json:"pruned"`
+	Failed    int               `json:"failed"`
+	Teams     []ApplyTeamResult `json:"teams"`
+}
+
+type ApplyTeamResult struct {
+	TeamID        string   `json:"team_id"`
+	Action        string   `json:"action"`
+	ChangedFields []string `json:"changed_fields"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// ComplianceReport compares the Kuadrant policies with what the teams using
+// them need
+type ComplianceReport struct {
+	CheckedAt         string        `json:"checked_at"`
+	Teams             int           `json:"teams"`
+	CompliantTeams    int           `json:"compliant_teams"`
+	CompliancePercent float64       `json:"compliance_percent"`
+	Drift             []PolicyDrift `json:"drift"`
+	// One entry per team, sorted by team ID and paged by ComplianceOptions
+	TeamResults []TeamCompliance `json:"team_results"`
+	Offset      int              `json:"offset"`
+	Limit       int              `json:"limit,omitempty"`
+	// Corrections made, only set by the reconciler
+	Corrected        int    `json:"corrected"`
+	LastReconciledAt string `json:"last_reconciled_at,omitempty"`
+}
+
+// SyncAllResult summarizes a sync of every team's policies
+type SyncAllResult struct {
+	DryRun    bool             `json:"dry_run"`
+	Teams     int              `json:"teams"`
+	Synced    int              `json:"synced"`
+	Unchanged int              `json:"unchanged"`
+	Skipped   int              `json:"skipped"`
+	Failed    int              `json:"failed"`
+	Results   []TeamSyncResult `json:"results"`
+}
+
+// TeamSyncResult is the outcome of syncing one team's policies
+type TeamSyncResult struct {
+	TeamID string   `json:"team_id"`
+	Policy string   `json:"policy"`
+	Status string   `json:"status"`
+	Reason string   `json:"reason,omitempty"`
+	Pruned []string `json:"pruned,omitempty"`
+}
+
+// PolicyValidation is the outcome of validating a team's policies end to end
+type PolicyValidation struct {
+	TeamID    string           `json:"team_id"`
+	Policy    string           `json:"policy"`
+	Valid     bool             `json:"valid"`
+	CheckedAt string           `json:"checked_at"`
+	Tests     []ValidationTest `json:"tests"`
+}
+
+// ValidationTest is one check of a policy validation
+type ValidationTest struct {
+	Name     string `json:"name"`
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// ComplianceOptions scopes and pages a compliance report
+type ComplianceOptions struct {
+	// Only report on this team
+	TeamID string
+	// Page of team results, a zero Limit returns them all
+	Offset int
+	Limit  int
+}
+
+// TeamCompliance is how a team's policy entries compare with what its tier
+// and stored overrides need
+type TeamCompliance struct {
+	TeamID      string             `json:"team_id"`
+	Policy      string             `json:"policy"`
+	Status      string             `json:"status"`
+	Differences []PolicyDifference `json:"differences,omitempty"`
+}
+
+// PolicyDifference is one field of a policy that differs for a team
+type PolicyDifference struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+// PolicyDrift is a policy entry that differs from what its teams need
+type PolicyDrift struct {
+	Policy    string   `json:"policy,omitempty"`
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Field     string   `json:"field,omitempty"`
+	Reason    string   `json:"reason"`
+	Detail    string   `json:"detail"`
+	Teams     []string `json:"teams"`
+	Corrected bool     `json:"corrected"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// MemberLimit is a team member's individual token limit
+type MemberLimit struct {
+	UserID     string `json:"user_id"`
+	TokenLimit int    `json:"token_limit"`
+	TimeWindow string `json:"time_window"`
+}
+
+// SyncPoliciesRequest optionally switches the counters of a team's policy
+// while its limits are re-applied
+type SyncPoliciesRequest struct {
+	LimitScope string `json:"limit_scope"`
+}
+
+// PolicyPreviewRequest selects the tier, and optionally ad-hoc limits, to
+// render a team's policies with
+type PolicyPreviewRequest struct {
+	Tier           string `json:"tier"`
+	TokenLimit     int    `json:"token_limit"`
+	TimeWindow     string `json:"time_window"`
+	LimitScope     string `json:"limit_scope"`
+	TeamTokenLimit int    `json:"team_token_limit"`
+}
+
+// PolicyPreview is a team's Kuadrant policies as they would be rendered,
+// along with how they differ from the applied ones
+type PolicyPreview struct {
+	TeamID        string         `json:"team_id"`
+	CurrentPolicy string         `json:"current_policy"`
+	Policy        string         `json:"policy"`
+	Manifests     string         `json:"manifests"`
+	Changes       []PolicyChange `json:"changes"`
+}
+
+// PolicyChange is a limit or group a preview adds, removes or changes
+type PolicyChange struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Change string      `json:"change"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// PolicyHealthCheck is the outcome of one check of the policy setup
+type PolicyHealthCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// PolicyHealthReport summarizes whether team policies can be enforced
+type PolicyHealthReport struct {
+	Status    string              `json:"status"`
+	CheckedAt string              `json:"checked_at"`
+	Checks    []PolicyHealthCheck `json:"checks"`
+}
+
+// TeamLimitStatus compares each TokenRateLimitPolicy limit a team is held to
+// with what its config renders, next to the live Limitador counters
+type TeamLimitStatus struct {
+	TeamID     string        `json:"team_id"`
+	Policy     string        `json:"policy"`
+	LimitScope string        `json:"limit_scope,omitempty"`
+	Limits     []LimitStatus `json:"limits"`
+	// Set when the TokenRateLimitPolicy could not be read
+	PolicyError string `json:"policy_error,omitempty"`
+	// Set when the live counters could not be read
+	CountersUnavailable bool   `json:"counters_unavailable,omitempty"`
+	CountersReason      string `json:"counters_reason,omitempty"`
+}
+
+// LimitStatus is one limit of a team. Desired is left empty for limits the
+// key manager does not render, such as those of built-in tiers.
+type LimitStatus struct {
+	Name      string                 `json:"name"`
+	Kind      string                 `json:"kind"`
+	Status    string                 `json:"status"`
+	Desired   []RateLimit            `json:"desired,omitempty"`
+	Applied   []RateLimit            `json:"applied,omitempty"`
+	Counters  []limitador.LimitUsage `json:"counters"`
+	Exhausted bool                   `json:"exhausted"`
+}
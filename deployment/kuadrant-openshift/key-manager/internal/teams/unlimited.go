@@ -0,0 +1,51 @@
+package teams
+
+import "fmt"
+
+// Ways teams on unlimited-policy are kept clear of token limits
+const (
+	// UnlimitedModeHighLimit gives unlimited-policy a limit too high to reach
+	UnlimitedModeHighLimit = "high-limit"
+	// UnlimitedModeExempt leaves unlimited-policy without any limit entry,
+	// so its group only matches the AuthPolicy
+	UnlimitedModeExempt = "exempt"
+)
+
+// annotationUnlimitedMode records on a team config how the team's
+// unlimited policy was applied
+const annotationUnlimitedMode = "maas/unlimited-mode"
+
+// Limit written for unlimited-policy in high-limit mode, the same as the
+// gateway's default unlimited policy
+const (
+	unlimitedTokenLimit = 999999999
+	unlimitedTimeWindow = "1h"
+)
+
+// IsValidUnlimitedMode checks if an unlimited tier mode is supported
+func IsValidUnlimitedMode(mode string) bool {
+	return mode == UnlimitedModeHighLimit || mode == UnlimitedModeExempt
+}
+
+// isUnlimitedPolicy reports whether a policy is the unlimited tier
+func isUnlimitedPolicy(policyName string) bool {
+	return policyName == "unlimited-policy"
+}
+
+// applyUnlimitedPolicy writes unlimited-policy's entry in the configured
+// mode, ignoring any limits requested for it
+func (p *PolicyManager) applyUnlimitedPolicy() error {
+	if p.unlimitedMode == UnlimitedModeExempt {
+		return p.updateTokenRateLimitPolicyForTeam("unlimited-policy", false, 0, "", "", 0)
+	}
+	return p.updateTokenRateLimitPolicyForTeam("unlimited-policy", true, unlimitedTokenLimit, unlimitedTimeWindow, LimitScopePerUser, unlimitedTokenLimit)
+}
+
+// unlimitedDescription explains how a team on unlimited-policy is kept clear
+// of token limits
+func unlimitedDescription(mode string) string {
+	if mode == UnlimitedModeExempt {
+		return "explicitly exempted: unlimited-policy has no TokenRateLimitPolicy limit"
+	}
+	return fmt.Sprintf("explicit high limit: %d tokens per %s", unlimitedTokenLimit, unlimitedTimeWindow)
+}
@@ -0,0 +1,185 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// userIDExpression keys a member's individual limit on the user its API
+// keys belong to
+const userIDExpression = `auth.identity.metadata.labels["maas/user-id"]`
+
+// UserLimitName returns the TokenRateLimitPolicy limit holding a team
+// member's individual token limit
+func UserLimitName(teamID, userID string) string {
+	return fmt.Sprintf("team-%s-user-%s", teamID, userID)
+}
+
+// userLimitTeamPredicate matches the keys of a team
+func userLimitTeamPredicate(teamID string) string {
+	return fmt.Sprintf("%s == \"%s\"", teamCounterExpression, teamID)
+}
+
+// SetUserLimit adds or updates a member's individual limit, counted across
+// all of the member's keys in the team
+func (p *PolicyManager) SetUserLimit(teamID, userID string, tokenLimit int, timeWindow string) error {
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		limit := tokenRateLimit(userID, tokenLimit, timeWindow, userIDExpression)
+		limit["when"] = []map[string]interface{}{
+			{
+				"predicate": fmt.Sprintf("\"maas/user-id\" in auth.identity.metadata.labels && %s && %s == \"%s\"",
+					userLimitTeamPredicate(teamID), userIDExpression, userID),
+			},
+		}
+		limits[UserLimitName(teamID, userID)] = limit
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Updated TokenRateLimitPolicy limit for member %s of team %s: %d tokens per %s", userID, teamID, tokenLimit, timeWindow)
+	return nil
+}
+
+// RemoveUserLimit removes a member's individual limit
+func (p *PolicyManager) RemoveUserLimit(teamID, userID string) error {
+	removed := false
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		_, removed = limits[UserLimitName(teamID, userID)]
+		delete(limits, UserLimitName(teamID, userID))
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed {
+		log.Printf("Removed TokenRateLimitPolicy limit for member %s of team %s", userID, teamID)
+	}
+	return nil
+}
+
+// RemoveTeamUserLimits removes the individual limits of every member of a team
+func (p *PolicyManager) RemoveTeamUserLimits(teamID string) error {
+	return p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		for name, limitConfig := range limits {
+			if _, ok := userLimitOf(teamID, name, limitConfig); ok {
+				delete(limits, name)
+			}
+		}
+	})
+}
+
+// GetTeamUserLimits returns the individual limits of a team's members
+func (p *PolicyManager) GetTeamUserLimits(teamID string) ([]MemberLimit, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+
+	limits, _, _ := unstructured.NestedMap(policyObj.Object, "spec", "limits")
+	memberLimits := make([]MemberLimit, 0)
+	for name, limitConfig := range limits {
+		if limit, ok := userLimitOf(teamID, name, limitConfig); ok {
+			memberLimits = append(memberLimits, limit)
+		}
+	}
+	sort.Slice(memberLimits, func(i, j int) bool {
+		return memberLimits[i].UserID < memberLimits[j].UserID
+	})
+	return memberLimits, nil
+}
+
+// userLimitOf returns the member limit a TokenRateLimitPolicy limit holds
+// for a team, if it is one
+func userLimitOf(teamID, name string, limitConfig interface{}) (MemberLimit, bool) {
+	prefix := UserLimitName(teamID, "")
+	limitMap, ok := limitConfig.(map[string]interface{})
+	if !ok || !strings.HasPrefix(name, prefix) || !isUserLimit(limitMap) {
+		return MemberLimit{}, false
+	}
+	// The team's own predicate tells it apart from a team whose ID merely
+	// shares the prefix
+	for _, predicate := range limitPredicates(limitMap) {
+		if !strings.Contains(stringValue(predicate["predicate"]), userLimitTeamPredicate(teamID)) {
+			return MemberLimit{}, false
+		}
+	}
+
+	limit := MemberLimit{UserID: strings.TrimPrefix(name, prefix)}
+	if rates := limitRates(limitMap); len(rates) > 0 {
+		limit.TokenLimit = int(numberValue(rates[0]["limit"]))
+		limit.TimeWindow = stringValue(rates[0]["window"])
+	}
+	return limit, true
+}
+
+// isUserLimit reports whether a limit is a member's individual limit
+func isUserLimit(limitConfig interface{}) bool {
+	limitMap, ok := limitConfig.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, predicate := range limitPredicates(limitMap) {
+		if strings.Contains(stringValue(predicate["predicate"]), userIDExpression) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMemberLimit enforces a member's token limit override when it is
+// tighter than the team's tier, and removes it otherwise. A looser override
+// would never be reached, since the tier still applies.
+func (m *Manager) applyMemberLimit(teamID, userID, policy string, tokenLimit int, timeWindow string) {
+	if m.policyMgr == nil {
+		return
+	}
+
+	tierLimit, tierWindow, err := m.tierLimits(policy)
+	if err != nil {
+		// Without tier limits, such as on the unlimited tier, any override is tighter
+		tierLimit, tierWindow = 0, ""
+	}
+	if timeWindow == "" {
+		timeWindow = tierWindow
+	}
+	if timeWindow == "" {
+		timeWindow = "1h"
+	}
+
+	if tokenLimit > 0 && tighterRate(tokenLimit, timeWindow, tierLimit, tierWindow) {
+		err = m.policyMgr.SetUserLimit(teamID, userID, tokenLimit, timeWindow)
+	} else {
+		err = m.policyMgr.RemoveUserLimit(teamID, userID)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to update limit for member %s of team %s: %v", userID, teamID, err)
+	}
+}
+
+// tighterRate reports whether limit per window allows fewer tokens over
+// time than tierLimit per tierWindow. A tier without a limit is never tighter.
+func tighterRate(limit int, window string, tierLimit int, tierWindow string) bool {
+	if tierLimit <= 0 {
+		return true
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil {
+		return false
+	}
+	tierDuration, err := time.ParseDuration(tierWindow)
+	if err != nil {
+		return true
+	}
+	return float64(limit)/duration.Seconds() < float64(tierLimit)/tierDuration.Seconds()
+}
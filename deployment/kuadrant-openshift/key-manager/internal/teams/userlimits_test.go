@@ -0,0 +1,56 @@
+package teams
+
+import "testing"
+
+func TestApplyMemberLimitOnlyKeepsTighterOverrides(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokenLimit int
+		timeWindow string
+		wantLimit  bool
+	}{
+		{name: "looser than the tier", tokenLimit: 500, timeWindow: "1m", wantLimit: false},
+		{name: "looser over a shorter window", tokenLimit: 10, timeWindow: "1s", wantLimit: false},
+		{name: "same as the tier", tokenLimit: 100, timeWindow: "1m", wantLimit: false},
+		{name: "tighter than the tier", tokenLimit: 50, timeWindow: "1m", wantLimit: true},
+		{name: "tighter over a longer window", tokenLimit: 1000, timeWindow: "1h", wantLimit: true},
+		{name: "tighter in the tier window", tokenLimit: 50, timeWindow: "", wantLimit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := newFakePolicyManager(3)
+			m := &Manager{policyMgr: p}
+
+			// The free tier allows 100 tokens per minute
+			m.applyMemberLimit("team-a", "alice", "free", tt.tokenLimit, tt.timeWindow)
+
+			limits, err := p.GetTeamUserLimits("team-a")
+			if err != nil {
+				t.Fatalf("GetTeamUserLimits() = %v", err)
+			}
+			if (len(limits) == 1) != tt.wantLimit {
+				t.Fatalf("member limits = %v, want a limit: %v", limits, tt.wantLimit)
+			}
+			wantWindow := tt.timeWindow
+			if wantWindow == "" {
+				wantWindow = "1m"
+			}
+			if tt.wantLimit && (limits[0].UserID != "alice" || limits[0].TokenLimit != tt.tokenLimit || limits[0].TimeWindow != wantWindow) {
+				t.Errorf("member limit = %+v, want alice at %d per %s", limits[0], tt.tokenLimit, wantWindow)
+			}
+		})
+	}
+}
+
+func TestApplyMemberLimitRemovesLoosenedOverride(t *testing.T) {
+	p, _ := newFakePolicyManager(3)
+	m := &Manager{policyMgr: p}
+
+	m.applyMemberLimit("team-a", "alice", "free", 50, "1m")
+	m.applyMemberLimit("team-a", "alice", "free", 500, "1m")
+
+	if limits, err := p.GetTeamUserLimits("team-a"); err != nil || len(limits) != 0 {
+		t.Errorf("GetTeamUserLimits() = %v, %v, want the loosened limit removed", limits, err)
+	}
+}
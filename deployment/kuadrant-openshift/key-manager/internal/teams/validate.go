@@ -0,0 +1,51 @@
+package teams
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxRatesPerLimit caps the windows a single limit may enforce. Each rate
+// is a separate Limitador counter checked on every request.
+const maxRatesPerLimit = 10
+
+// ValidateTokenLimit checks a token limit before it is written to the
+// TokenRateLimitPolicy, which rejects limits that are not positive
+func ValidateTokenLimit(field string, limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("invalid %s: must be positive", field)
+	}
+	return nil
+}
+
+// ValidateTimeWindow checks a window against the duration grammar the
+// Kuadrant CRDs accept, such as 30s, 1h or 1h30m. Days and weeks are not
+// part of it.
+func ValidateTimeWindow(field, window string) error {
+	if !IsValidTimeWindow(window) {
+		return fmt.Errorf("invalid %s %q: must be a duration such as 1m, 1h or 1h30m", field, window)
+	}
+	return nil
+}
+
+// ValidateLimitOverrides checks the individual limits a team, member or key
+// may carry. Zero leaves a limit unset.
+func ValidateLimitOverrides(tokenLimit, requestLimit int, timeWindow string) error {
+	if tokenLimit < 0 {
+		return fmt.Errorf("invalid token_limit: must not be negative")
+	}
+	if requestLimit < 0 {
+		return fmt.Errorf("invalid request_limit: must not be negative")
+	}
+	if timeWindow != "" {
+		return ValidateTimeWindow("time_window", timeWindow)
+	}
+	return nil
+}
+
+// isPositiveDuration reports whether a window the grammar accepts can count
+// anything, unlike 0s
+func isPositiveDuration(window string) bool {
+	duration, err := time.ParseDuration(window)
+	return err == nil && duration > 0
+}
@@ -0,0 +1,194 @@
+package teams
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTimeWindow(t *testing.T) {
+	tests := []struct {
+		window string
+		valid  bool
+	}{
+		{window: "1s", valid: true},
+		{window: "30s", valid: true},
+		{window: "1m", valid: true},
+		{window: "1h", valid: true},
+		{window: "24h", valid: true},
+		{window: "1h30m", valid: true},
+		{window: "500ms", valid: true},
+		{window: "168h", valid: true},
+		{window: "1w", valid: false},
+		{window: "1d", valid: false},
+		{window: "7d", valid: false},
+		{window: "0s", valid: false},
+		{window: "0h0m", valid: false},
+		{window: "", valid: false},
+		{window: "h", valid: false},
+		{window: "1", valid: false},
+		{window: "-1h", valid: false},
+		{window: "1.5h", valid: false},
+		{window: "1H", valid: false},
+		{window: " 1h", valid: false},
+		{window: "123456h", valid: false},
+		{window: "1h1m1s1ms1h", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			if got := IsValidTimeWindow(tt.window); got != tt.valid {
+				t.Errorf("IsValidTimeWindow(%q) = %v, want %v", tt.window, got, tt.valid)
+			}
+
+			err := ValidateTimeWindow("time_window", tt.window)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateTimeWindow(%q) = %v, want nil", tt.window, err)
+			}
+			if !tt.valid && (err == nil || !strings.Contains(err.Error(), "invalid time_window")) {
+				t.Errorf("ValidateTimeWindow(%q) = %v, want an invalid time_window error", tt.window, err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		wantErr bool
+	}{
+		{name: "positive", limit: 1000},
+		{name: "one", limit: 1},
+		{name: "zero", limit: 0, wantErr: true},
+		{name: "negative", limit: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTokenLimit("token_limit", tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTokenLimit(%d) = %v, want error %v", tt.limit, err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "invalid token_limit") {
+				t.Errorf("ValidateTokenLimit(%d) = %q, want it to name the field", tt.limit, err)
+			}
+		})
+	}
+}
+
+func TestValidateLimitOverrides(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokenLimit   int
+		requestLimit int
+		timeWindow   string
+		wantErr      string
+	}{
+		{name: "all unset", tokenLimit: 0, requestLimit: 0, timeWindow: ""},
+		{name: "all set", tokenLimit: 1000, requestLimit: 10, timeWindow: "1h"},
+		{name: "zero limits leave them unset", tokenLimit: 0, requestLimit: 0, timeWindow: "1m"},
+		{name: "negative token limit", tokenLimit: -1, wantErr: "invalid token_limit"},
+		{name: "negative request limit", requestLimit: -1, wantErr: "invalid request_limit"},
+		{name: "week window", tokenLimit: 1000, timeWindow: "1w", wantErr: "invalid time_window"},
+		{name: "day window", tokenLimit: 1000, timeWindow: "1d", wantErr: "invalid time_window"},
+		{name: "zero window", tokenLimit: 1000, timeWindow: "0s", wantErr: "invalid time_window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLimitOverrides(tt.tokenLimit, tt.requestLimit, tt.timeWindow)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateLimitOverrides() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateLimitOverrides() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rates   []RateLimit
+		want    []RateLimit
+		wantErr string
+	}{
+		{
+			name:  "ordered by window",
+			rates: []RateLimit{{Limit: 100000, Window: "24h"}, {Limit: 1000, Window: "1m"}, {Limit: 10000, Window: "1h"}},
+			want:  []RateLimit{{Limit: 1000, Window: "1m"}, {Limit: 10000, Window: "1h"}, {Limit: 100000, Window: "24h"}},
+		},
+		{
+			name:  "unlimited windows left out",
+			rates: []RateLimit{{Limit: unlimitedRate, Window: "1m"}, {Limit: 10000, Window: "1h"}},
+			want:  []RateLimit{{Limit: 10000, Window: "1h"}},
+		},
+		{
+			name:    "zero limit",
+			rates:   []RateLimit{{Limit: 0, Window: "1m"}},
+			wantErr: "limit for window 1m must be positive",
+		},
+		{
+			name:    "negative limit other than unlimited",
+			rates:   []RateLimit{{Limit: -2, Window: "1m"}},
+			wantErr: "limit for window 1m must be positive",
+		},
+		{
+			name:    "week window",
+			rates:   []RateLimit{{Limit: 1000, Window: "1w"}},
+			wantErr: `window "1w" must be a duration`,
+		},
+		{
+			name:    "zero window",
+			rates:   []RateLimit{{Limit: 1000, Window: "0m"}},
+			wantErr: `window "0m" must be a duration`,
+		},
+		{
+			name:    "same duration written twice",
+			rates:   []RateLimit{{Limit: 1000, Window: "60m"}, {Limit: 2000, Window: "1h"}},
+			wantErr: "listed more than once",
+		},
+		{
+			name:    "only unlimited windows",
+			rates:   []RateLimit{{Limit: unlimitedRate, Window: "1h"}},
+			wantErr: "at least one window must be limited",
+		},
+		{
+			name:    "empty",
+			rates:   nil,
+			wantErr: "at least one window must be limited",
+		},
+		{
+			name: "too many windows",
+			rates: []RateLimit{
+				{Limit: 1, Window: "1s"}, {Limit: 1, Window: "2s"}, {Limit: 1, Window: "3s"}, {Limit: 1, Window: "4s"},
+				{Limit: 1, Window: "5s"}, {Limit: 1, Window: "6s"}, {Limit: 1, Window: "7s"}, {Limit: 1, Window: "8s"},
+				{Limit: 1, Window: "9s"}, {Limit: 1, Window: "10s"}, {Limit: 1, Window: "11s"},
+			},
+			wantErr: "at most 10 windows",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeRates(tt.rates)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("normalizeRates() = %v, want error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeRates() = %v, want nil", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeRates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// unlimitedRate marks a window a tier does not limit
+const unlimitedRate = -1
+
+// normalizeRates validates the sustained rates of a tier and orders them
+// from the shortest window to the longest. The shortest becomes the policy's
+// main rate. Windows set to -1 are unlimited and left out.
+func normalizeRates(rates []RateLimit) ([]RateLimit, error) {
+	if len(rates) > maxRatesPerLimit {
+		return nil, fmt.Errorf("invalid rates: at most %d windows can be limited", maxRatesPerLimit)
+	}
+	normalized := make([]RateLimit, 0, len(rates))
+	durations := make(map[string]time.Duration, len(rates))
+	seen := make(map[time.Duration]bool, len(rates))
+	for _, rate := range rates {
+		if !IsValidTimeWindow(rate.Window) {
+			return nil, fmt.Errorf("invalid rates: window %q must be a duration such as 1m, 1h or 24h", rate.Window)
+		}
+		duration, err := time.ParseDuration(rate.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rates: window %q: %v", rate.Window, err)
+		}
+		if seen[duration] {
+			return nil, fmt.Errorf("invalid rates: window %s is listed more than once", rate.Window)
+		}
+		seen[duration] = true
+
+		if rate.Limit == unlimitedRate {
+			continue
+		}
+		if rate.Limit <= 0 {
+			return nil, fmt.Errorf("invalid rates: limit for window %s must be positive, or -1 for unlimited", rate.Window)
+		}
+		durations[rate.Window] = duration
+		normalized = append(normalized, rate)
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("invalid rates: at least one window must be limited")
+	}
+
+	sort.Slice(normalized, func(i, j int) bool {
+		return durations[normalized[i].Window] < durations[normalized[j].Window]
+	})
+	return normalized, nil
+}
+
+// SetPolicyRates replaces the sustained rates of a policy's limit, keeping
+// its burst cap. The first rate is the policy's main rate.
+func (p *PolicyManager) SetPolicyRates(policyName string, rates []RateLimit) error {
+	if len(rates) == 0 {
+		return fmt.Errorf("invalid rates: at least one window must be limited")
+	}
+
+	var missing bool
+	err := p.updateTokenRateLimits(func(limits map[string]interface{}) {
+		limitConfig, ok := limits[policyName].(map[string]interface{})
+		missing = !ok
+		if missing {
+			return
+		}
+
+		updated := make([]interface{}, 0, len(rates)+1)
+		for _, rate := range rates {
+			updated = append(updated, map[string]interface{}{
+				"limit":  rate.Limit,
+				"window": rate.Window,
+			})
+		}
+		for _, rate := range limitRates(limitConfig) {
+			if isBurstRate(rate, rates[0].Window) {
+				updated = append(updated, rate)
+			}
+		}
+		limitConfig["rates"] = updated
+	})
+	if err != nil {
+		return err
+	}
+	if missing {
+		return fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", policyName)
+	}
+
+	log.Printf("Updated TokenRateLimitPolicy rates for policy %s: %d windows", policyName, len(rates))
+	return nil
+}
+
+// GetPolicyRates returns the sustained rates of a policy, its main rate
+// first. Burst caps are left out.
+func (p *PolicyManager) GetPolicyRates(policyName string) ([]RateLimit, error) {
+	tokenRateLimitGVR := p.gvrs.TokenRateLimitPolicy()
+
+	policyObj, err := p.kuadrantClient.Resource(tokenRateLimitGVR).Namespace(p.keyNamespace).Get(
+		context.Background(), p.tokenRateLimitPolicyName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TokenRateLimitPolicy: %w", err)
+	}
+
+	limits, _, _ := unstructured.NestedMap(policyObj.Object, "spec", "limits")
+	limitConfig, ok := limits[policyName].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("policy '%s' does not exist in TokenRateLimitPolicy", policyName)
+	}
+
+	rates := make([]RateLimit, 0)
+	items := limitRates(limitConfig)
+	for i, rate := range items {
+		if i > 0 && isBurstRate(rate, stringValue(items[0]["window"])) {
+			continue
+		}
+		rates = append(rates, RateLimit{
+			Limit:  int(numberValue(rate["limit"])),
+			Window: stringValue(rate["window"]),
+		})
+	}
+	return rates, nil
+}
@@ -0,0 +1,24 @@
+package types
+
+// BudgetStatus reports a team's monthly spend against its budget
+type BudgetStatus struct {
+	BudgetUSDMonthly float64 `json:"budget_usd_monthly"`
+	SpendUSD         float64 `json:"spend_usd"`
+	PercentConsumed  float64 `json:"percent_consumed"`
+	PeriodStart      string  `json:"period_start"`
+	Exceeded         bool    `json:"exceeded"`
+	Enforcement      string  `json:"enforcement,omitempty"`
+}
+
+// TokenAllowance reports a team's monthly token allowance against the tokens
+// it used this billing period
+type TokenAllowance struct {
+	TokenLimit  int64  `json:"token_limit"`
+	TimeWindow  string `json:"time_window"`
+	Consumed    int64  `json:"consumed"`
+	Remaining   int64  `json:"remaining"`
+	PeriodStart string `json:"period_start,omitempty"`
+	Exhausted   bool   `json:"exhausted"`
+	// When the allowance runs out at the current pace, empty if not this period
+	ProjectedExhaustion string `json:"projected_exhaustion,omitempty"`
+}
@@ -0,0 +1,38 @@
+package types
+
+// CostSummary reports what a team's usage has cost, by model and by key.
+// Costs are per currency, as models may be priced in different ones.
+type CostSummary struct {
+	Cost             map[string]float64 `json:"cost"`
+	PromptTokens     int64              `json:"prompt_tokens"`
+	CompletionTokens int64              `json:"completion_tokens"`
+	Requests         int64              `json:"requests"`
+	Models           []ModelCost        `json:"models"`
+	Keys             []KeyCost          `json:"keys"`
+	// Models charged the default price because they had no price of their own
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+	// When the costs started accruing, unset before the first charge
+	Since string `json:"since,omitempty"`
+}
+
+// ModelCost reports the usage and cost of one model
+type ModelCost struct {
+	Model            string             `json:"model"`
+	Cost             map[string]float64 `json:"cost"`
+	PromptTokens     int64              `json:"prompt_tokens"`
+	CompletionTokens int64              `json:"completion_tokens"`
+	Requests         int64              `json:"requests"`
+	// Pricing versions the usage was charged at, 0 for the default price
+	PriceVersions []int64 `json:"price_versions"`
+	// Some of the usage was charged the default price
+	DefaultPrice bool `json:"default_price,omitempty"`
+}
+
+// KeyCost reports the usage and cost of one API key
+type KeyCost struct {
+	KeyName          string             `json:"key_name"`
+	Cost             map[string]float64 `json:"cost"`
+	PromptTokens     int64              `json:"prompt_tokens"`
+	CompletionTokens int64              `json:"completion_tokens"`
+	Requests         int64              `json:"requests"`
+}
@@ -21,9 +21,33 @@ type TeamUsage struct {
 	TotalAuthorizedCalls int64                 `json:"total_authorized_calls"`
 	TotalLimitedCalls   int64                  `json:"total_limited_calls"`
 	UserBreakdown       []UserTeamUsage        `json:"user_breakdown"`
+	Budget              *BudgetStatus          `json:"budget,omitempty"`
+	Allowance           *TokenAllowance        `json:"allowance,omitempty"`
+	History             *UsageHistory          `json:"history,omitempty"`
+	Cost                *CostSummary           `json:"cost,omitempty"`
 	LastUpdated         time.Time              `json:"last_updated"`
 }
 
+// UsageHistory represents a team's consumption over a window, read from Prometheus
+type UsageHistory struct {
+	Range               string                 `json:"range"`
+	Step                string                 `json:"step"`
+	Tokens              int64                  `json:"tokens"`
+	Requests            int64                  `json:"requests"`
+	RateLimited         int64                  `json:"rate_limited"`
+	Series              []UsagePoint           `json:"series"`
+	DataQuality         string                 `json:"data_quality"`
+	Note                string                 `json:"note,omitempty"`
+}
+
+// UsagePoint represents consumption during one step of a usage history
+type UsagePoint struct {
+	Timestamp           time.Time `json:"timestamp"`
+	Tokens              int64     `json:"tokens"`
+	Requests            int64     `json:"requests"`
+	RateLimited         int64     `json:"rate_limited"`
+}
+
 // TeamUserUsage represents a user's usage within a specific team context
 type TeamUserUsage struct {
 	TeamID              string `json:"team_id"`
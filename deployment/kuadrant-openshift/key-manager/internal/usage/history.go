@@ -0,0 +1,197 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/prometheus"
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/internal/types"
+)
+
+// Data quality of a usage history
+const (
+	DataComplete = "complete"
+	// Some points of the series are missing, such as across scrape gaps
+	DataPartial = "partial"
+	// No series matched, such as for a team that has not been used yet
+	DataNoData = "no_data"
+	// Prometheus could not be queried
+	DataUnavailable = "unavailable"
+)
+
+// DefaultRange is the usage window when none is requested
+const DefaultRange = "24h"
+
+// usageRange is a window and the step of its time series
+type usageRange struct {
+	window time.Duration
+	step   time.Duration
+}
+
+// usageRanges are the windows team usage can be requested over
+var usageRanges = map[string]usageRange{
+	"24h": {window: 24 * time.Hour, step: time.Hour},
+	"7d":  {window: 7 * 24 * time.Hour, step: 6 * time.Hour},
+	"30d": {window: 30 * 24 * time.Hour, step: 24 * time.Hour},
+}
+
+// IsValidRange reports whether a usage window is supported
+func IsValidRange(name string) bool {
+	_, ok := usageRanges[name]
+	return ok
+}
+
+// Gateway counters the usage history is read from. As on the Envoy stats
+// endpoint, the user and group (policy) are part of the metric name.
+const (
+	tokensMetric      = "token_usage_with_user_and_group"
+	requestsMetric    = "authorized_calls_with_user_and_group"
+	rateLimitedMetric = "limited_calls_with_user_and_group"
+)
+
+// History reads a team's consumption over a window from Prometheus. A nil
+// History has none.
+type History struct {
+	prom *prometheus.Client
+}
+
+// NewHistory creates a usage history over a Prometheus client, returns nil
+// if there is no client
+func NewHistory(prom *prometheus.Client) *History {
+	if prom == nil {
+		return nil
+	}
+	return &History{prom: prom}
+}
+
+// TeamUsage returns the tokens, requests and rate-limited requests of a
+// team's users on its policy over a window, with a series of one point per
+// step. Other teams on the same policy are not counted. It never fails:
+// missing metrics read as zero and Prometheus errors as an unavailable
+// history, each explained in the note.
+func (h *History) TeamUsage(ctx context.Context, policyName string, userIDs []string, rangeName string) *types.UsageHistory {
+	r := usageRanges[rangeName]
+	history := &types.UsageHistory{
+		Range:       rangeName,
+		Step:        promDuration(r.step),
+		Series:      make([]types.UsagePoint, 0),
+		DataQuality: DataComplete,
+	}
+	if len(userIDs) == 0 {
+		history.DataQuality = DataNoData
+		history.Note = "The team has no members or keys yet, so there is no usage to report"
+		return history
+	}
+
+	points := make(map[int64]*types.UsagePoint)
+	found := false
+	partial := false
+	for _, metric := range []string{tokensMetric, requestsMetric, rateLimitedMetric} {
+		selector := teamSelector(metric, policyName, userIDs)
+
+		total, err := h.prom.Query(ctx, fmt.Sprintf("sum(increase(%s[%s]))", selector, promDuration(r.window)))
+		if err != nil {
+			return unavailableHistory(history, policyName, err)
+		}
+		series, err := h.prom.QueryRange(ctx, fmt.Sprintf("sum(increase(%s[%s]))", selector, promDuration(r.step)), r.window, r.step)
+		if err != nil {
+			return unavailableHistory(history, policyName, err)
+		}
+
+		if len(total) > 0 {
+			found = true
+			addUsage(&history.Tokens, &history.Requests, &history.RateLimited, metric, total[0].Value)
+		}
+		for _, s := range series {
+			if len(s.Points) < int(r.window/r.step) {
+				partial = true
+			}
+			for _, point := range s.Points {
+				entry, ok := points[point.Time.Unix()]
+				if !ok {
+					entry = &types.UsagePoint{Timestamp: point.Time}
+					points[point.Time.Unix()] = entry
+				}
+				addUsage(&entry.Tokens, &entry.Requests, &entry.RateLimited, metric, point.Value)
+			}
+		}
+	}
+
+	for _, point := range points {
+		history.Series = append(history.Series, *point)
+	}
+	sort.Slice(history.Series, func(i, j int) bool {
+		return history.Series[i].Timestamp.Before(history.Series[j].Timestamp)
+	})
+
+	switch {
+	case !found:
+		history.DataQuality = DataNoData
+		history.Note = "No usage metrics were found for the team's policy in this window; it may not have been used yet"
+	case partial:
+		history.DataQuality = DataPartial
+		history.Note = "Some intervals have no samples, such as during scrape gaps; they are left out of the series"
+	}
+	return history
+}
+
+// unavailableHistory reports a history Prometheus could not provide as zero
+func unavailableHistory(history *types.UsageHistory, policyName string, err error) *types.UsageHistory {
+	log.Printf("Warning: Failed to query usage history for policy %s: %v", policyName, err)
+	return &types.UsageHistory{
+		Range:       history.Range,
+		Step:        history.Step,
+		Series:      make([]types.UsagePoint, 0),
+		DataQuality: DataUnavailable,
+		Note:        "Usage metrics could not be queried; totals are reported as zero",
+	}
+}
+
+// addUsage adds a metric value to the matching counter
+func addUsage(tokens, requests, rateLimited *int64, metric string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+		return
+	}
+	rounded := int64(math.Round(value))
+	switch metric {
+	case tokensMetric:
+		*tokens += rounded
+	case requestsMetric:
+		*requests += rounded
+	case rateLimitedMetric:
+		*rateLimited += rounded
+	}
+}
+
+// teamSelector matches a gateway counter of a team's users on its policy.
+// The counters are keyed by user and group only, and teams on the same
+// policy share the group, so the team is told apart by its users. Envoy
+// turns the hyphens of the policy name into underscores.
+func teamSelector(metric, policyName string, userIDs []string) string {
+	policy := regexp.QuoteMeta(strings.ReplaceAll(policyName, "-", "_"))
+	users := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		// Match user IDs whether or not their hyphens were converted too
+		users = append(users, strings.ReplaceAll(regexp.QuoteMeta(userID), "-", "[-_]"))
+	}
+	sort.Strings(users)
+	pattern := fmt.Sprintf("%s__user___(%s)___group___%s___namespace__.+", metric, strings.Join(users, "|"), policy)
+	return fmt.Sprintf(`{__name__=~"%s"}`, strings.ReplaceAll(pattern, `\`, `\\`))
+}
+
+// promDuration renders a duration in PromQL syntax
+func promDuration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
@@ -0,0 +1,40 @@
+package usage
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTeamSelector(t *testing.T) {
+	selector := teamSelector(tokensMetric, "gold-tier", []string{"alice", "bob-smith"})
+	pattern := strings.TrimSuffix(strings.TrimPrefix(selector, `{__name__=~"`), `"}`)
+	re := regexp.MustCompile("^(?:" + strings.ReplaceAll(pattern, `\\`, `\`) + ")$")
+
+	tests := []struct {
+		metric string
+		want   bool
+	}{
+		{metric: "token_usage_with_user_and_group__user___alice___group___gold_tier___namespace__llm", want: true},
+		{metric: "token_usage_with_user_and_group__user___bob_smith___group___gold_tier___namespace__llm", want: true},
+		{metric: "token_usage_with_user_and_group__user___bob-smith___group___gold_tier___namespace__llm", want: true},
+		// Another team's user on the same tier
+		{metric: "token_usage_with_user_and_group__user___carol___group___gold_tier___namespace__llm", want: false},
+		{metric: "token_usage_with_user_and_group__user___alice___group___free___namespace__llm", want: false},
+		{metric: "authorized_calls_with_user_and_group__user___alice___group___gold_tier___namespace__llm", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := re.MatchString(tt.metric); got != tt.want {
+			t.Errorf("%s matches %s: %v, want %v", selector, tt.metric, got, tt.want)
+		}
+	}
+}
+
+func TestTeamUsageWithoutUsers(t *testing.T) {
+	history := (&History{}).TeamUsage(context.Background(), "gold", nil, "24h")
+	if history.DataQuality != DataNoData || history.Tokens != 0 {
+		t.Errorf("TeamUsage() = %+v, want no data", history)
+	}
+}
@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redhat-et/maas-billing/deployment/kuadrant-openshift/key-manager-v2/pkg/webhookclient"
+)
+
+// Key lifecycle event types
+const (
+	EventKeyCreated   = "key.created"
+	EventKeyUpdated   = "key.updated"
+	EventKeyRotated   = "key.rotated"
+	EventKeySuspended = "key.suspended"
+	EventKeyDeleted   = "key.deleted"
+)
+
+// Team lifecycle event types, also delivered to the team's own webhook
+const (
+	EventPolicyResynced = "team.policy_resynced"
+	EventBudgetWarning  = "budget.warning"
+	EventBudgetExceeded = "budget.exceeded"
+	EventWebhookTest    = "webhook.test"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the timestamp and
+// request body
+const SignatureHeader = webhookclient.SignatureHeader
+
+// lastDelivery is the last delivery ID handed out. It starts from the
+// current time in nanoseconds, so IDs keep increasing across restarts.
+var lastDelivery atomic.Int64
+
+func init() {
+	lastDelivery.Store(time.Now().UnixNano())
+}
+
+// Event is the payload delivered to webhook receivers. It must never carry key material.
+type Event struct {
+	Type       string `json:"event"`
+	TeamID     string `json:"team_id"`
+	UserID     string `json:"user_id"`
+	SecretName string `json:"secret_name"`
+	Timestamp  string `json:"timestamp"`
+	// Event-specific fields, such as budget figures
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// NewEvent creates an event stamped with the current time
+func NewEvent(eventType, teamID, userID, secretName string) Event {
+	return Event{
+		Type:       eventType,
+		TeamID:     teamID,
+		UserID:     userID,
+		SecretName: secretName,
+		Timestamp:  time.Now().Format(time.RFC3339),
+	}
+}
+
+// ValidateSecret rejects a webhook URL without a signing secret, as its
+// receiver could not tell deliveries from forgeries, unless unsigned
+// webhooks are explicitly allowed
+func ValidateSecret(rawURL, secret string, allowUnsigned bool) error {
+	if rawURL != "" && secret == "" && !allowUnsigned {
+		return fmt.Errorf("webhook URL requires a signing secret unless ALLOW_UNSIGNED_WEBHOOKS=true")
+	}
+	return nil
+}
+
+// ValidateURL checks that a webhook URL is an absolute http or https URL
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL: scheme must be http or https")
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid webhook URL: host is required")
+	}
+	return nil
+}
+
+// Dispatcher delivers events to an outbound webhook
+type Dispatcher struct {
+	url         string
+	secret      string
+	httpClient  *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewDispatcher creates a new webhook dispatcher, returns nil if no URL is configured
+func NewDispatcher(url, secret string) *Dispatcher {
+	if url == "" {
+		return nil
+	}
+
+	return &Dispatcher{
+		url:    url,
+		secret: secret,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		maxAttempts: 4,
+		backoff:     time.Second,
+	}
+}
+
+// Dispatch sends an event in the background so the caller is never blocked
+func (d *Dispatcher) Dispatch(eventType, teamID, userID, secretName string) {
+	d.DispatchEvent(NewEvent(eventType, teamID, userID, secretName))
+}
+
+// DispatchEvent sends a prepared event in the background
+func (d *Dispatcher) DispatchEvent(event Event) {
+	if d == nil {
+		return
+	}
+
+	go d.deliver(event)
+}
+
+// Send delivers an event once and reports the outcome, for callers that
+// need to know whether the receiver accepted it
+func (d *Dispatcher) Send(event Event) error {
+	if d == nil {
+		return fmt.Errorf("no webhook configured")
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	return d.post(body, lastDelivery.Add(1))
+}
+
+// deliver posts an event, retrying with exponential backoff before dropping it
+func (d *Dispatcher) deliver(event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Warning: Failed to encode webhook event %s: %v", event.Type, err)
+		return
+	}
+
+	// Retries keep the delivery ID so receivers can drop duplicates
+	deliveryID := lastDelivery.Add(1)
+	backoff := d.backoff
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		err = d.post(body, deliveryID)
+		if err == nil {
+			return
+		}
+
+		if attempt < d.maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	log.Printf("Warning: Dropping webhook event %s for %s after %d attempts: %v", event.Type, event.SecretName, d.maxAttempts, err)
+}
+
+// post performs a single delivery attempt, signed as of now
+func (d *Dispatcher) post(body []byte, deliveryID int64) error {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookclient.DeliveryHeader, strconv.FormatInt(deliveryID, 10))
+
+	if d.secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(webhookclient.TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, webhookclient.Sign(d.secret, timestamp, body))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package webhookclient verifies webhook deliveries from the key manager.
+// Each delivery is signed with HMAC-SHA256 over its timestamp and body, so
+// a receiver can check it came from the key manager and reject old
+// deliveries being replayed:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	if err := webhookclient.Verify(secret, r.Header, body, webhookclient.DefaultTolerance); err != nil {
+//		http.Error(w, err.Error(), http.StatusUnauthorized)
+//		return
+//	}
+//
+// Retries of a delivery carry the same delivery ID, which receivers can
+// remember to process each delivery once.
+package webhookclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a webhook delivery
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" keyed with the webhook secret
+	SignatureHeader = "X-MaaS-Signature"
+	// TimestampHeader carries the Unix time the delivery was signed at
+	TimestampHeader = "X-MaaS-Timestamp"
+	// DeliveryHeader carries the delivery ID, which increases with each
+	// delivery and is kept by its retries
+	DeliveryHeader = "X-MaaS-Delivery"
+)
+
+// DefaultTolerance is how far a delivery's timestamp may be from the
+// receiver's clock
+const DefaultTolerance = 5 * time.Minute
+
+// Verification errors
+var (
+	ErrMissingSignature = errors.New("webhook signature or timestamp missing")
+	ErrInvalidTimestamp = errors.New("webhook timestamp is invalid")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is outside the tolerance")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+)
+
+// Sign returns the signature header value of a body signed at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature and that its timestamp is within
+// tolerance of now, in either direction to allow for clock skew
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	return verifyAt(secret, header, body, tolerance, time.Now())
+}
+
+// verifyAt verifies a delivery as of now
+func verifyAt(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	signature := header.Get(SignatureHeader)
+	rawTimestamp := header.Get(TimestampHeader)
+	if signature == "" || rawTimestamp == "" {
+		return ErrMissingSignature
+	}
+	timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > tolerance {
+		return ErrStaleTimestamp
+	}
+	if !strings.HasPrefix(signature, "sha256=") ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
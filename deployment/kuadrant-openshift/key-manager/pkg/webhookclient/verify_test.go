@@ -0,0 +1,116 @@
+package webhookclient
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyAt(t *testing.T) {
+	const secret = "webhook-secret"
+	body := []byte(`{"type":"key.created","team_id":"a"}`)
+	now := time.Unix(1700000000, 0)
+
+	headers := func(timestamp, signature string) http.Header {
+		header := http.Header{}
+		if timestamp != "" {
+			header.Set(TimestampHeader, timestamp)
+		}
+		if signature != "" {
+			header.Set(SignatureHeader, signature)
+		}
+		return header
+	}
+	signed := func(timestamp int64, signature string) http.Header {
+		return headers(strconv.FormatInt(timestamp, 10), signature)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		{
+			name:   "valid signature",
+			header: signed(now.Unix(), Sign(secret, now.Unix(), body)),
+			body:   body,
+		},
+		{
+			name:   "skew within tolerance",
+			header: signed(now.Add(-4*time.Minute).Unix(), Sign(secret, now.Add(-4*time.Minute).Unix(), body)),
+			body:   body,
+		},
+		{
+			name:   "tampered body",
+			header: signed(now.Unix(), Sign(secret, now.Unix(), body)),
+			body:   []byte(`{"type":"key.created","team_id":"b"}`),
+			want:   ErrInvalidSignature,
+		},
+		{
+			name:   "other secret",
+			header: signed(now.Unix(), Sign("other-secret", now.Unix(), body)),
+			body:   body,
+			want:   ErrInvalidSignature,
+		},
+		{
+			name:   "signature for another timestamp",
+			header: signed(now.Unix(), Sign(secret, now.Unix()-1, body)),
+			body:   body,
+			want:   ErrInvalidSignature,
+		},
+		{
+			name:   "expired timestamp",
+			header: signed(now.Add(-6*time.Minute).Unix(), Sign(secret, now.Add(-6*time.Minute).Unix(), body)),
+			body:   body,
+			want:   ErrStaleTimestamp,
+		},
+		{
+			name:   "future skew",
+			header: signed(now.Add(6*time.Minute).Unix(), Sign(secret, now.Add(6*time.Minute).Unix(), body)),
+			body:   body,
+			want:   ErrStaleTimestamp,
+		},
+		{
+			name:   "missing signature",
+			header: signed(now.Unix(), ""),
+			body:   body,
+			want:   ErrMissingSignature,
+		},
+		{
+			name:   "missing timestamp",
+			header: headers("", Sign(secret, now.Unix(), body)),
+			body:   body,
+			want:   ErrMissingSignature,
+		},
+		{
+			name:   "malformed timestamp",
+			header: headers("yesterday", Sign(secret, now.Unix(), body)),
+			body:   body,
+			want:   ErrInvalidTimestamp,
+		},
+		{
+			name:   "signature without scheme",
+			header: signed(now.Unix(), Sign(secret, now.Unix(), body)[len("sha256="):]),
+			body:   body,
+			want:   ErrInvalidSignature,
+		},
+		{
+			name:   "signature with another scheme",
+			header: signed(now.Unix(), "sha1="+Sign(secret, now.Unix(), body)[len("sha256="):]),
+			body:   body,
+			want:   ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyAt(secret, tt.header, tt.body, DefaultTolerance, now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("verifyAt() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}